@@ -38,4 +38,10 @@ type AccessKey struct {
 	//     wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY
 	//
 	Secret string
+
+	// The session token accompanying temporary credentials issued by the AWS
+	// Security Token Service (for example, when assuming a role). It must be
+	// sent with every request signed using the key. Long-term keys have no
+	// session token, and leave this empty.
+	SessionToken string
 }
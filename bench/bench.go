@@ -0,0 +1,161 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench contains the pieces shared by the benchmark tools in this
+// repository: a loop that runs an operation concurrently for a fixed time
+// while recording its latencies, and reports of the results in text, JSON or
+// CSV form.
+//
+// For example:
+//
+//     result, err := bench.Run(4, 10*time.Second, func() (int64, error) {
+//         data, err := bucket.GetObject("some/key")
+//         return int64(len(data)), err
+//     })
+//
+//     result.Name = "GetObject"
+//     result.Params = []bench.Param{{Name: "parallelism", Value: "4"}}
+//
+//     bench.WriteJSON(os.Stdout, []bench.Result{result})
+//
+package bench
+
+import (
+	"sync"
+	"time"
+)
+
+// A parameter of a benchmark run, e.g. the object size used.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// A statistic specific to one benchmark, e.g. the cost of each operation.
+type Stat struct {
+	Name  string
+	Value float64
+}
+
+// The result of running a benchmark with one particular set of parameters.
+type Result struct {
+	// The name of the operation measured, e.g. "GetObject".
+	Name string
+
+	// The parameters of the run, in the order in which they should be
+	// reported.
+	Params []Param
+
+	// The wall time for which the operation was run.
+	Duration time.Duration
+
+	// The number of times the operation was performed, and how many of those
+	// failed.
+	Ops    uint64
+	Errors uint64
+
+	// The total number of bytes transferred by successful operations.
+	Bytes uint64
+
+	// The latencies of all operations, successful or not.
+	Latency Histogram
+
+	// Further statistics, reported after the standard ones in the order given.
+	Stats []Stat
+}
+
+// Return the rate at which operations were performed.
+func (r *Result) OpsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// Return the rate at which data was transferred.
+func (r *Result) BytesPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+// Run calls f repeatedly from the given number of goroutines until the
+// supplied duration has passed, returning a result with the statistics
+// filled in. f returns the number of bytes it transferred.
+//
+// A failing call doesn't stop the run; failures are counted in the result,
+// and the first error encountered is returned.
+func Run(
+	parallelism int,
+	duration time.Duration,
+	f func() (int64, error)) (r Result, err error) {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	deadline := start.Add(duration)
+
+	worker := func() {
+		defer wg.Done()
+
+		// Record into a private histogram, merging at the end, so that workers
+		// don't contend.
+		var h Histogram
+		var ops, errors, bytes uint64
+		var firstErr error
+
+		for time.Now().Before(deadline) {
+			before := time.Now()
+			n, opErr := f()
+			h.Record(time.Since(before))
+
+			ops++
+			if opErr != nil {
+				errors++
+				if firstErr == nil {
+					firstErr = opErr
+				}
+
+				continue
+			}
+
+			bytes += uint64(n)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		r.Latency.Merge(&h)
+		r.Ops += ops
+		r.Errors += errors
+		r.Bytes += bytes
+		if err == nil {
+			err = firstErr
+		}
+	}
+
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	wg.Wait()
+	r.Duration = time.Since(start)
+
+	return
+}
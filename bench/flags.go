@@ -0,0 +1,108 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse a comma-separated list of non-negative integers, e.g. "1,2,8".
+func ParseInts(s string) (ints []int, err error) {
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+
+		var n int
+		n, err = strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Invalid number: %q", field)
+		}
+
+		ints = append(ints, n)
+	}
+
+	return
+}
+
+// Units accepted by ParseSizes, longest suffix first.
+var sizeUnits = []struct {
+	suffix string
+	shift  uint
+}{
+	{"GiB", 30},
+	{"MiB", 20},
+	{"KiB", 10},
+	{"G", 30},
+	{"M", 20},
+	{"K", 10},
+	{"B", 0},
+}
+
+// Parse a comma-separated list of byte counts, each optionally followed by a
+// binary unit, e.g. "512,16KiB,1MiB". The units K, M and G are accepted as
+// synonyms for KiB, MiB and GiB.
+func ParseSizes(s string) (sizes []int64, err error) {
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+
+		digits := field
+		var shift uint
+		for _, u := range sizeUnits {
+			if strings.HasSuffix(field, u.suffix) {
+				digits = strings.TrimSpace(strings.TrimSuffix(field, u.suffix))
+				shift = u.shift
+				break
+			}
+		}
+
+		var n int64
+		n, err = strconv.ParseInt(digits, 10, 64)
+		if err != nil || n < 0 || n > (1<<62)>>shift {
+			return nil, fmt.Errorf("Invalid size: %q", field)
+		}
+
+		sizes = append(sizes, n<<shift)
+	}
+
+	return
+}
+
+// Format a number of bytes for humans, e.g. "1.50 MiB".
+func FormatBytes(n uint64) string {
+	type exponentAndSuffix struct {
+		exponent uint
+		suffix   string
+	}
+
+	suffixes := []exponentAndSuffix{
+		{0, "bytes"},
+		{10, "KiB"},
+		{20, "MiB"},
+		{30, "GiB"},
+	}
+
+	for i, element := range suffixes {
+		exponent := element.exponent
+		nextExponent := exponent + 10
+		if n < (1<<nextExponent) || i == len(suffixes)-1 {
+			scaled := float64(n) / float64(uint(1)<<exponent)
+			return fmt.Sprintf("%.2f %s", scaled, element.suffix)
+		}
+	}
+
+	panic("Shouldn't reach here.")
+}
@@ -0,0 +1,84 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench_test
+
+import (
+	"github.com/jacobsa/aws/bench"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestFlags(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type FlagsTest struct {
+}
+
+func init() { RegisterTestSuite(&FlagsTest{}) }
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *FlagsTest) Ints() {
+	ints, err := bench.ParseInts("1, 2,16")
+	AssertEq(nil, err)
+	ExpectThat(ints, ElementsAre(1, 2, 16))
+}
+
+func (t *FlagsTest) InvalidInts() {
+	_, err := bench.ParseInts("1,taco")
+	ExpectThat(err, Error(HasSubstr("taco")))
+
+	_, err = bench.ParseInts("-1")
+	ExpectThat(err, Error(HasSubstr("-1")))
+
+	_, err = bench.ParseInts("")
+	ExpectThat(err, Error(HasSubstr("Invalid")))
+}
+
+func (t *FlagsTest) Sizes() {
+	sizes, err := bench.ParseSizes("0,512,16KiB,3K,1MiB,2 M,1GiB,7B")
+	AssertEq(nil, err)
+	ExpectThat(
+		sizes,
+		ElementsAre(0, 512, 16<<10, 3<<10, 1<<20, 2<<20, 1<<30, 7))
+}
+
+func (t *FlagsTest) InvalidSizes() {
+	_, err := bench.ParseSizes("1KiB,taco")
+	ExpectThat(err, Error(HasSubstr("taco")))
+
+	_, err = bench.ParseSizes("KiB")
+	ExpectThat(err, Error(HasSubstr("KiB")))
+
+	_, err = bench.ParseSizes("1TiB")
+	ExpectThat(err, Error(HasSubstr("1TiB")))
+
+	_, err = bench.ParseSizes("100000000000GiB")
+	ExpectThat(err, Error(HasSubstr("100000000000GiB")))
+}
+
+func (t *FlagsTest) FormatBytes() {
+	ExpectEq("17.00 bytes", bench.FormatBytes(17))
+	ExpectEq("1.50 KiB", bench.FormatBytes(1536))
+	ExpectEq("256.00 MiB", bench.FormatBytes(1<<28))
+	ExpectEq("2048.00 GiB", bench.FormatBytes(1<<41))
+}
@@ -0,0 +1,154 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"math/bits"
+	"time"
+)
+
+// The number of bits of precision kept for each latency recorded. Latencies
+// are reported with a relative error of at most 1/2^subBucketBits.
+const subBucketBits = 5
+
+const subBuckets = 1 << subBucketBits
+
+// A Histogram records latencies at microsecond resolution, bucketed so that
+// quantiles can be computed in constant space with a small relative error.
+// The zero value is an empty histogram. A Histogram must not be used
+// concurrently.
+type Histogram struct {
+	// The number of latencies in each bucket, indexed by bucketIndex. Grown as
+	// necessary.
+	counts []uint64
+
+	count uint64
+	sum   time.Duration
+	max   time.Duration
+}
+
+// Return the bucket for a latency of v microseconds. Values below
+// 2*subBuckets have their own buckets; above that each power of two is split
+// into subBuckets buckets.
+func bucketIndex(v uint64) int {
+	if v < 2*subBuckets {
+		return int(v)
+	}
+
+	e := bits.Len64(v) - (subBucketBits + 1)
+	return e*subBuckets + int(v>>uint(e))
+}
+
+// Return the largest value, in microseconds, that falls into the given
+// bucket.
+func bucketUpperBound(i int) uint64 {
+	if i < 2*subBuckets {
+		return uint64(i)
+	}
+
+	e := uint(i/subBuckets - 1)
+	m := uint64(i%subBuckets + subBuckets)
+	return (m+1)<<e - 1
+}
+
+// Record a single latency.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	i := bucketIndex(uint64(d / time.Microsecond))
+	for len(h.counts) <= i {
+		h.counts = append(h.counts, 0)
+	}
+
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Add the latencies recorded by another histogram to this one.
+func (h *Histogram) Merge(other *Histogram) {
+	for len(h.counts) < len(other.counts) {
+		h.counts = append(h.counts, 0)
+	}
+
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+
+	h.count += other.count
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Return the number of latencies recorded.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Return the largest latency recorded, exactly.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Return the mean of the latencies recorded, exactly.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	return h.sum / time.Duration(h.count)
+}
+
+// Return the latency below which the given fraction of latencies fall, for q
+// in [0, 1]. For example, Quantile(0.99) returns the 99th percentile. Return
+// zero if no latencies have been recorded.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	// Find the rank of the latency we want, counting from one.
+	rank := uint64(q * float64(h.count))
+	if float64(rank) < q*float64(h.count) {
+		rank++
+	}
+
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			d := time.Duration(bucketUpperBound(i)) * time.Microsecond
+			if d > h.max {
+				d = h.max
+			}
+
+			return d
+		}
+	}
+
+	return h.max
+}
@@ -0,0 +1,114 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench_test
+
+import (
+	"github.com/jacobsa/aws/bench"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type HistogramTest struct {
+	h bench.Histogram
+}
+
+func init() { RegisterTestSuite(&HistogramTest{}) }
+
+// Expect that the actual duration is within the histogram's relative error of
+// the expected one.
+func expectClose(expected time.Duration, actual time.Duration) {
+	ExpectThat(actual, LessOrEqual(expected+expected/32))
+	ExpectThat(actual, GreaterOrEqual(expected-expected/32))
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *HistogramTest) Empty() {
+	ExpectEq(0, t.h.Count())
+	ExpectEq(0, t.h.Max())
+	ExpectEq(0, t.h.Mean())
+	ExpectEq(0, t.h.Quantile(0.5))
+}
+
+func (t *HistogramTest) SmallValuesAreExact() {
+	for i := 1; i <= 10; i++ {
+		t.h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	ExpectEq(10, t.h.Count())
+	ExpectEq(5*time.Microsecond, t.h.Quantile(0.5))
+	ExpectEq(9*time.Microsecond, t.h.Quantile(0.9))
+	ExpectEq(10*time.Microsecond, t.h.Quantile(0.99))
+	ExpectEq(10*time.Microsecond, t.h.Max())
+	ExpectEq(5500*time.Nanosecond, t.h.Mean())
+}
+
+func (t *HistogramTest) LargeValuesAreApproximate() {
+	for i := 1; i <= 1000; i++ {
+		t.h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	expectClose(500*time.Millisecond, t.h.Quantile(0.5))
+	expectClose(900*time.Millisecond, t.h.Quantile(0.9))
+	expectClose(990*time.Millisecond, t.h.Quantile(0.99))
+	ExpectEq(time.Second, t.h.Max())
+}
+
+func (t *HistogramTest) QuantileDoesNotExceedMax() {
+	t.h.Record(1234567 * time.Microsecond)
+
+	ExpectEq(1234567*time.Microsecond, t.h.Quantile(0.5))
+	ExpectEq(1234567*time.Microsecond, t.h.Quantile(1))
+}
+
+func (t *HistogramTest) ExtremeQuantiles() {
+	t.h.Record(time.Millisecond)
+	t.h.Record(time.Second)
+
+	expectClose(time.Millisecond, t.h.Quantile(0))
+	ExpectEq(time.Second, t.h.Quantile(1))
+}
+
+func (t *HistogramTest) NegativeLatency() {
+	t.h.Record(-time.Second)
+
+	ExpectEq(1, t.h.Count())
+	ExpectEq(0, t.h.Quantile(0.5))
+}
+
+func (t *HistogramTest) Merge() {
+	var other bench.Histogram
+
+	t.h.Record(10 * time.Microsecond)
+	other.Record(20 * time.Microsecond)
+	other.Record(time.Hour)
+
+	t.h.Merge(&other)
+
+	ExpectEq(3, t.h.Count())
+	ExpectEq(20*time.Microsecond, t.h.Quantile(0.5))
+	ExpectEq(time.Hour, t.h.Max())
+}
@@ -0,0 +1,256 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// The quantiles included in reports, and the names under which they are
+// reported.
+var reportedQuantiles = []struct {
+	name string
+	q    float64
+}{
+	{"p50", 0.5},
+	{"p90", 0.9},
+	{"p99", 0.99},
+}
+
+// Express a duration in milliseconds, for reports.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Write a line for each result in a form suitable for humans.
+func WriteText(w io.Writer, results []Result) error {
+	for _, r := range results {
+		line := r.Name
+		for _, p := range r.Params {
+			line += fmt.Sprintf(" %s=%s", p.Name, p.Value)
+		}
+
+		line += fmt.Sprintf(
+			": %d ops, %d errors, %.1f ops/s, %s/s;",
+			r.Ops,
+			r.Errors,
+			r.OpsPerSecond(),
+			FormatBytes(uint64(r.BytesPerSecond())))
+
+		for _, rq := range reportedQuantiles {
+			line += fmt.Sprintf(" %s %v", rq.name, r.Latency.Quantile(rq.q))
+		}
+
+		line += fmt.Sprintf(" max %v", r.Latency.Max())
+
+		for _, s := range r.Stats {
+			line += fmt.Sprintf("; %s %g", s.Name, s.Value)
+		}
+
+		line += "\n"
+
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The JSON form of a result.
+type jsonResult struct {
+	Name           string             `json:"name"`
+	Params         map[string]string  `json:"params"`
+	Seconds        float64            `json:"duration_seconds"`
+	Ops            uint64             `json:"ops"`
+	Errors         uint64             `json:"errors"`
+	Bytes          uint64             `json:"bytes"`
+	OpsPerSecond   float64            `json:"ops_per_second"`
+	BytesPerSecond float64            `json:"bytes_per_second"`
+	LatencyMs      map[string]float64 `json:"latency_ms"`
+	Stats          map[string]float64 `json:"stats,omitempty"`
+}
+
+// Write the results as a JSON array of objects, one per result. Latencies are
+// given in milliseconds.
+func WriteJSON(w io.Writer, results []Result) error {
+	out := make([]jsonResult, 0, len(results))
+	for _, r := range results {
+		jr := jsonResult{
+			Name:           r.Name,
+			Params:         make(map[string]string),
+			Seconds:        r.Duration.Seconds(),
+			Ops:            r.Ops,
+			Errors:         r.Errors,
+			Bytes:          r.Bytes,
+			OpsPerSecond:   r.OpsPerSecond(),
+			BytesPerSecond: r.BytesPerSecond(),
+			LatencyMs:      make(map[string]float64),
+		}
+
+		for _, p := range r.Params {
+			jr.Params[p.Name] = p.Value
+		}
+
+		for _, rq := range reportedQuantiles {
+			jr.LatencyMs[rq.name] = milliseconds(r.Latency.Quantile(rq.q))
+		}
+
+		jr.LatencyMs["mean"] = milliseconds(r.Latency.Mean())
+		jr.LatencyMs["max"] = milliseconds(r.Latency.Max())
+
+		if len(r.Stats) > 0 {
+			jr.Stats = make(map[string]float64)
+			for _, s := range r.Stats {
+				jr.Stats[s.Name] = s.Value
+			}
+		}
+
+		out = append(out, jr)
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
+
+// Write the results as CSV with a header row. There is a column for each
+// parameter name that appears in any result, in order of first appearance,
+// followed by the standard statistics and then a column for each further
+// statistic that appears in any result. Latencies are given in milliseconds.
+func WriteCSV(w io.Writer, results []Result) error {
+	// Find the parameter and further statistic columns.
+	var paramNames []string
+	var statNames []string
+	seenParams := make(map[string]bool)
+	seenStats := make(map[string]bool)
+	for _, r := range results {
+		for _, p := range r.Params {
+			if !seenParams[p.Name] {
+				seenParams[p.Name] = true
+				paramNames = append(paramNames, p.Name)
+			}
+		}
+
+		for _, s := range r.Stats {
+			if !seenStats[s.Name] {
+				seenStats[s.Name] = true
+				statNames = append(statNames, s.Name)
+			}
+		}
+	}
+
+	header := []string{"name"}
+	header = append(header, paramNames...)
+	header = append(
+		header,
+		"duration_seconds",
+		"ops",
+		"errors",
+		"bytes",
+		"ops_per_second",
+		"bytes_per_second")
+
+	for _, rq := range reportedQuantiles {
+		header = append(header, rq.name+"_ms")
+	}
+
+	header = append(header, "mean_ms", "max_ms")
+	header = append(header, statNames...)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	for _, r := range results {
+		params := make(map[string]string)
+		for _, p := range r.Params {
+			params[p.Name] = p.Value
+		}
+
+		record := []string{r.Name}
+		for _, name := range paramNames {
+			record = append(record, params[name])
+		}
+
+		record = append(
+			record,
+			formatFloat(r.Duration.Seconds()),
+			strconv.FormatUint(r.Ops, 10),
+			strconv.FormatUint(r.Errors, 10),
+			strconv.FormatUint(r.Bytes, 10),
+			formatFloat(r.OpsPerSecond()),
+			formatFloat(r.BytesPerSecond()))
+
+		for _, rq := range reportedQuantiles {
+			record = append(
+				record,
+				formatFloat(milliseconds(r.Latency.Quantile(rq.q))))
+		}
+
+		record = append(
+			record,
+			formatFloat(milliseconds(r.Latency.Mean())),
+			formatFloat(milliseconds(r.Latency.Max())))
+
+		stats := make(map[string]string)
+		for _, s := range r.Stats {
+			stats[s.Name] = formatFloat(s.Value)
+		}
+
+		for _, name := range statNames {
+			record = append(record, stats[name])
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write the results in the named format: "text", "json" or "csv".
+func WriteResults(w io.Writer, format string, results []Result) error {
+	switch format {
+	case "text":
+		return WriteText(w, results)
+
+	case "json":
+		return WriteJSON(w, results)
+
+	case "csv":
+		return WriteCSV(w, results)
+	}
+
+	return fmt.Errorf("Unknown output format: %s", format)
+}
@@ -0,0 +1,143 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jacobsa/aws/bench"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type ReportTest struct {
+	results []bench.Result
+	buf     bytes.Buffer
+}
+
+func init() { RegisterTestSuite(&ReportTest{}) }
+
+func (t *ReportTest) SetUp(i *TestInfo) {
+	get := bench.Result{
+		Name: "GetObject",
+		Params: []bench.Param{
+			bench.Param{Name: "size", Value: "1024"},
+			bench.Param{Name: "parallelism", Value: "2"},
+		},
+		Duration: 2 * time.Second,
+		Ops:      4,
+		Errors:   1,
+		Bytes:    3072,
+	}
+
+	get.Latency.Record(10 * time.Microsecond)
+	get.Latency.Record(20 * time.Microsecond)
+	get.Latency.Record(30 * time.Microsecond)
+	get.Latency.Record(40 * time.Microsecond)
+
+	head := bench.Result{
+		Name: "HeadObject",
+		Params: []bench.Param{
+			bench.Param{Name: "keys", Value: "7"},
+		},
+		Duration: time.Second,
+		Stats: []bench.Stat{
+			bench.Stat{Name: "cost", Value: 0.25},
+		},
+	}
+
+	t.results = []bench.Result{get, head}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *ReportTest) Text() {
+	AssertEq(nil, bench.WriteText(&t.buf, t.results))
+
+	lines := strings.Split(strings.TrimSpace(t.buf.String()), "\n")
+	AssertEq(2, len(lines))
+
+	ExpectEq(
+		"GetObject size=1024 parallelism=2: 4 ops, 1 errors, 2.0 ops/s, "+
+			"1.50 KiB/s; p50 20µs p90 40µs p99 40µs max 40µs",
+		lines[0])
+
+	ExpectThat(lines[1], HasSubstr("HeadObject keys=7: 0 ops"))
+	ExpectThat(lines[1], HasSubstr("max 0s; cost 0.25"))
+}
+
+func (t *ReportTest) JSON() {
+	AssertEq(nil, bench.WriteJSON(&t.buf, t.results))
+
+	var decoded []map[string]interface{}
+	AssertEq(nil, json.Unmarshal(t.buf.Bytes(), &decoded))
+	AssertEq(2, len(decoded))
+
+	get := decoded[0]
+	ExpectEq("GetObject", get["name"])
+	ExpectEq(2, get["duration_seconds"])
+	ExpectEq(4, get["ops"])
+	ExpectEq(1, get["errors"])
+	ExpectEq(3072, get["bytes"])
+	ExpectEq(2, get["ops_per_second"])
+	ExpectEq(1536, get["bytes_per_second"])
+
+	params := get["params"].(map[string]interface{})
+	ExpectEq("1024", params["size"])
+	ExpectEq("2", params["parallelism"])
+
+	latency := get["latency_ms"].(map[string]interface{})
+	ExpectEq(0.02, latency["p50"])
+	ExpectEq(0.04, latency["p90"])
+	ExpectEq(0.04, latency["p99"])
+	ExpectEq(0.025, latency["mean"])
+	ExpectEq(0.04, latency["max"])
+	ExpectEq(nil, get["stats"])
+
+	stats := decoded[1]["stats"].(map[string]interface{})
+	ExpectEq(0.25, stats["cost"])
+}
+
+func (t *ReportTest) CSV() {
+	AssertEq(nil, bench.WriteCSV(&t.buf, t.results))
+
+	ExpectEq(
+		"name,size,parallelism,keys,duration_seconds,ops,errors,bytes,"+
+			"ops_per_second,bytes_per_second,p50_ms,p90_ms,p99_ms,mean_ms,"+
+			"max_ms,cost\n"+
+			"GetObject,1024,2,,2,4,1,3072,2,1536,0.02,0.04,0.04,0.025,0.04,\n"+
+			"HeadObject,,,7,1,0,0,0,0,0,0,0,0,0,0,0.25\n",
+		t.buf.String())
+}
+
+func (t *ReportTest) NamedFormats() {
+	AssertEq(nil, bench.WriteResults(&t.buf, "csv", t.results))
+	ExpectThat(t.buf.String(), HasSubstr("name,size"))
+
+	err := bench.WriteResults(&t.buf, "xml", t.results)
+	ExpectThat(err, Error(HasSubstr("xml")))
+}
@@ -0,0 +1,336 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"errors"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentials(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type failingCredentials string
+
+func (c failingCredentials) Credentials() (AccessKey, error) {
+	return AccessKey{}, errors.New(string(c))
+}
+
+// A provider that counts the calls made to it, returning key if err is nil.
+type countingCredentials struct {
+	calls int
+	key   AccessKey
+	err   error
+}
+
+func (c *countingCredentials) Credentials() (AccessKey, error) {
+	c.calls++
+	return c.key, c.err
+}
+
+type CredentialsTest struct {
+	env map[string]string
+	dir string
+}
+
+func init() { RegisterTestSuite(&CredentialsTest{}) }
+
+func (t *CredentialsTest) SetUp(i *TestInfo) {
+	var err error
+
+	t.env = make(map[string]string)
+	t.dir, err = ioutil.TempDir("", "credentials_test")
+	AssertEq(nil, err)
+}
+
+func (t *CredentialsTest) TearDown() {
+	os.RemoveAll(t.dir)
+}
+
+func (t *CredentialsTest) getenv(name string) string {
+	return t.env[name]
+}
+
+// Write a credentials file with the given contents, returning its path.
+func (t *CredentialsTest) writeFile(contents string) string {
+	p := path.Join(t.dir, "credentials")
+	AssertEq(nil, ioutil.WriteFile(p, []byte(contents), 0600))
+	return p
+}
+
+const sharedCredentials = `
+# A comment.
+[default]
+aws_access_key_id = default_id
+aws_secret_access_key=default_secret
+
+; Another comment.
+[ other ]
+  aws_access_key_id   =   other_id
+  aws_secret_access_key = other/secret=
+region = us-west-2
+
+[incomplete]
+aws_access_key_id = incomplete_id
+
+[temporary]
+aws_access_key_id = temporary_id
+aws_secret_access_key = temporary_secret
+aws_session_token = temporary_token
+`
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *CredentialsTest) AccessKeyReturnsItself() {
+	var p CredentialsProvider = AccessKey{Id: "taco", Secret: "burrito"}
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("taco", key.Id)
+	ExpectEq("burrito", key.Secret)
+}
+
+func (t *CredentialsTest) EnvNotSet() {
+	_, err := newEnvCredentials(t.getenv).Credentials()
+
+	ExpectThat(err, Error(HasSubstr("AWS_ACCESS_KEY_ID")))
+}
+
+func (t *CredentialsTest) EnvSecretNotSet() {
+	t.env["AWS_ACCESS_KEY_ID"] = "taco"
+	_, err := newEnvCredentials(t.getenv).Credentials()
+
+	ExpectThat(err, Error(HasSubstr("AWS_SECRET_ACCESS_KEY")))
+}
+
+func (t *CredentialsTest) EnvSet() {
+	t.env["AWS_ACCESS_KEY_ID"] = "taco"
+	t.env["AWS_SECRET_ACCESS_KEY"] = "burrito"
+	t.env["AWS_ACCESS_KEY"] = "enchilada"
+
+	key, err := newEnvCredentials(t.getenv).Credentials()
+	AssertEq(nil, err)
+	ExpectEq("taco", key.Id)
+	ExpectEq("burrito", key.Secret)
+}
+
+func (t *CredentialsTest) EnvSessionToken() {
+	t.env["AWS_ACCESS_KEY_ID"] = "taco"
+	t.env["AWS_SECRET_ACCESS_KEY"] = "burrito"
+	t.env["AWS_SESSION_TOKEN"] = "enchilada"
+
+	key, err := newEnvCredentials(t.getenv).Credentials()
+	AssertEq(nil, err)
+	ExpectEq("enchilada", key.SessionToken)
+}
+
+func (t *CredentialsTest) EnvOldNames() {
+	t.env["AWS_ACCESS_KEY"] = "taco"
+	t.env["AWS_SECRET_KEY"] = "burrito"
+	t.env["AWS_SECURITY_TOKEN"] = "enchilada"
+
+	key, err := newEnvCredentials(t.getenv).Credentials()
+	AssertEq(nil, err)
+	ExpectEq("taco", key.Id)
+	ExpectEq("burrito", key.Secret)
+	ExpectEq("enchilada", key.SessionToken)
+}
+
+func (t *CredentialsTest) SharedFileMissing() {
+	p := SharedCredentialsFile(path.Join(t.dir, "foo"), "")
+	_, err := p.Credentials()
+
+	ExpectThat(err, Error(HasSubstr("no such file")))
+}
+
+func (t *CredentialsTest) SharedFileDefaultProfile() {
+	p := SharedCredentialsFile(t.writeFile(sharedCredentials), "")
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("default_id", key.Id)
+	ExpectEq("default_secret", key.Secret)
+}
+
+func (t *CredentialsTest) SharedFileNamedProfile() {
+	p := SharedCredentialsFile(t.writeFile(sharedCredentials), "other")
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("other_id", key.Id)
+	ExpectEq("other/secret=", key.Secret)
+	ExpectEq("", key.SessionToken)
+}
+
+func (t *CredentialsTest) SharedFileSessionToken() {
+	p := SharedCredentialsFile(t.writeFile(sharedCredentials), "temporary")
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("temporary_id", key.Id)
+	ExpectEq("temporary_secret", key.Secret)
+	ExpectEq("temporary_token", key.SessionToken)
+}
+
+func (t *CredentialsTest) SharedFileProfileFromEnv() {
+	defer os.Setenv("AWS_PROFILE", os.Getenv("AWS_PROFILE"))
+	os.Setenv("AWS_PROFILE", "other")
+
+	p := SharedCredentialsFile(t.writeFile(sharedCredentials), "")
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("other_id", key.Id)
+}
+
+func (t *CredentialsTest) SharedFilePathFromEnv() {
+	defer os.Setenv(
+		"AWS_SHARED_CREDENTIALS_FILE",
+		os.Getenv("AWS_SHARED_CREDENTIALS_FILE"))
+
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.writeFile(sharedCredentials))
+
+	p := SharedCredentialsFile("", "default")
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("default_id", key.Id)
+}
+
+func (t *CredentialsTest) SharedFileUnknownProfile() {
+	p := SharedCredentialsFile(t.writeFile(sharedCredentials), "taco")
+	_, err := p.Credentials()
+
+	ExpectThat(err, Error(HasSubstr(`No profile "taco"`)))
+}
+
+func (t *CredentialsTest) SharedFileIncompleteProfile() {
+	p := SharedCredentialsFile(t.writeFile(sharedCredentials), "incomplete")
+	_, err := p.Credentials()
+
+	ExpectThat(err, Error(HasSubstr("Incomplete credentials")))
+}
+
+func (t *CredentialsTest) SharedFileIsJunk() {
+	p := SharedCredentialsFile(t.writeFile("[default]\ntaco\n"), "")
+	_, err := p.Credentials()
+
+	ExpectThat(err, Error(HasSubstr("Line 2")))
+}
+
+func (t *CredentialsTest) SharedFileIsCachedUntilChanged() {
+	filePath := t.writeFile(sharedCredentials)
+	p := SharedCredentialsFile(filePath, "")
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("default_id", key.Id)
+
+	// Rewrite the file without changing its size or modification time. The
+	// cached credentials should be used.
+	fi, err := os.Stat(filePath)
+	AssertEq(nil, err)
+
+	t.writeFile(strings.Replace(sharedCredentials, "default_id", "changed_id", 1))
+	AssertEq(nil, os.Chtimes(filePath, fi.ModTime(), fi.ModTime()))
+
+	key, err = p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("default_id", key.Id)
+
+	// Once the modification time changes, the file should be read again.
+	later := fi.ModTime().Add(time.Second)
+	AssertEq(nil, os.Chtimes(filePath, later, later))
+
+	key, err = p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("changed_id", key.Id)
+}
+
+func (t *CredentialsTest) ChainReturnsFirstSuccess() {
+	p := CredentialsChain(
+		failingCredentials("taco"),
+		AccessKey{Id: "foo", Secret: "bar"},
+		AccessKey{Id: "baz", Secret: "qux"})
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("foo", key.Id)
+	ExpectEq("bar", key.Secret)
+}
+
+func (t *CredentialsTest) ChainFails() {
+	p := CredentialsChain(
+		failingCredentials("taco"),
+		failingCredentials("burrito"))
+
+	_, err := p.Credentials()
+
+	ExpectThat(err, Error(HasSubstr("No credentials found")))
+	ExpectThat(err, Error(HasSubstr("taco; burrito")))
+}
+
+func (t *CredentialsTest) ChainRemembersSuccess() {
+	a := &countingCredentials{err: errors.New("taco")}
+	b := &countingCredentials{key: AccessKey{Id: "foo", Secret: "bar"}}
+	p := CredentialsChain(a, b)
+
+	for i := 0; i < 3; i++ {
+		key, err := p.Credentials()
+		AssertEq(nil, err)
+		ExpectEq("foo", key.Id)
+	}
+
+	ExpectEq(1, a.calls)
+	ExpectEq(3, b.calls)
+}
+
+func (t *CredentialsTest) ChainStartsOverWhenRememberedProviderFails() {
+	a := &countingCredentials{err: errors.New("taco")}
+	b := &countingCredentials{key: AccessKey{Id: "foo", Secret: "bar"}}
+	p := CredentialsChain(a, b)
+
+	_, err := p.Credentials()
+	AssertEq(nil, err)
+
+	// b stops working, and a starts.
+	b.err = errors.New("burrito")
+	a.err = nil
+	a.key = AccessKey{Id: "baz", Secret: "qux"}
+
+	key, err := p.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("baz", key.Id)
+
+	// a should now be asked first.
+	_, err = p.Credentials()
+	AssertEq(nil, err)
+
+	ExpectEq(3, a.calls)
+	ExpectEq(2, b.calls)
+}
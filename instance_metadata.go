@@ -0,0 +1,353 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jacobsa/aws/time"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	sys_time "time"
+)
+
+// The EC2 instance metadata service, as seen from an instance.
+var defaultMetadataEndpoint = &url.URL{Scheme: "http", Host: "169.254.169.254"}
+
+const (
+	// How long before they expire cached credentials are refreshed.
+	metadataRefreshWindow = 5 * sys_time.Minute
+
+	// How long to wait for the metadata service, which is unreachable on
+	// machines other than EC2 instances.
+	metadataTimeout = 2 * sys_time.Second
+
+	// The lifetime requested for metadata session tokens, and how long before
+	// it ends a cached token is replaced.
+	metadataTokenTTL           = 6 * sys_time.Hour
+	metadataTokenRefreshWindow = sys_time.Minute
+
+	// How long to wait before trying again after the first failure to fetch
+	// credentials. The delay doubles with each further failure, up to the
+	// maximum.
+	metadataMinBackoff = sys_time.Second
+	metadataMaxBackoff = sys_time.Minute
+
+	// How long to remember that the metadata service couldn't be reached at
+	// all, as on machines other than EC2 instances.
+	metadataUnreachableBackoff = sys_time.Hour
+
+	metadataTokenPath       = "/latest/api/token"
+	metadataCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+)
+
+// InstanceMetadataCredentials returns a provider that fetches the temporary
+// credentials for the IAM role of the EC2 instance on which it runs from the
+// instance metadata service, using the session-oriented (IMDSv2) flow.
+//
+// The credentials are cached, and refreshed shortly before they expire.
+// Because buckets and SimpleDB connections consult their provider for each
+// request, they pick up the new credentials automatically. Only one refresh
+// is made at a time, and callers continue to use the cached credentials while
+// it is in flight. If a refresh fails the cached credentials continue to be
+// used until they expire, and further attempts are made with exponential
+// backoff. If the service can't be reached at all and never has been, as on
+// machines other than EC2 instances, the failure is remembered for an hour.
+func InstanceMetadataCredentials() CredentialsProvider {
+	return InstanceMetadataCredentialsAtEndpoint(defaultMetadataEndpoint)
+}
+
+// InstanceMetadataCredentialsAtEndpoint is like InstanceMetadataCredentials,
+// but talks to the metadata service at the supplied endpoint rather than the
+// usual link-local address. This is mostly useful for testing against a
+// stand-in server.
+func InstanceMetadataCredentialsAtEndpoint(
+	endpoint *url.URL) CredentialsProvider {
+	return newInstanceMetadataCredentials(endpoint, time.RealClock())
+}
+
+func newInstanceMetadataCredentials(
+	endpoint *url.URL,
+	clock time.Clock) CredentialsProvider {
+	return &instanceMetadataCredentials{
+		endpoint: endpoint,
+		clock:    clock,
+		client:   &http.Client{Timeout: metadataTimeout},
+	}
+}
+
+type instanceMetadataCredentials struct {
+	endpoint *url.URL
+	clock    time.Clock
+	client   *http.Client
+
+	mutex      sync.Mutex
+	key        AccessKey     // Protected by mutex
+	expiration sys_time.Time // Protected by mutex
+
+	// The session token used for requests, and when it should be replaced.
+	token           string        // Protected by mutex
+	tokenExpiration sys_time.Time // Protected by mutex
+
+	// The error from the last fetch if it failed, the number of consecutive
+	// failures, and the time before which no further fetch is attempted.
+	lastErr     error         // Protected by mutex
+	failures    uint          // Protected by mutex
+	nextAttempt sys_time.Time // Protected by mutex
+
+	// Closed when a fetch in progress finishes, or nil if there is none.
+	fetching chan struct{} // Protected by mutex
+}
+
+// The response to a request for a role's credentials.
+type metadataCredentials struct {
+	Code            string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      sys_time.Time
+}
+
+func (p *instanceMetadataCredentials) Credentials() (key AccessKey, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for {
+		// Use the cached credentials unless they're about to expire.
+		now := p.clock.Now()
+		if now.Before(p.expiration.Add(-metadataRefreshWindow)) {
+			return p.key, nil
+		}
+
+		// If another caller is already fetching credentials, use the cached
+		// ones while they're still good, or wait for the result.
+		if p.fetching != nil {
+			if now.Before(p.expiration) {
+				return p.key, nil
+			}
+
+			done := p.fetching
+			p.mutex.Unlock()
+			<-done
+			p.mutex.Lock()
+			continue
+		}
+
+		// Don't try again too soon after the last attempt. Credentials that the
+		// service hands out close to their expiry are used as they are.
+		if now.Before(p.nextAttempt) {
+			if p.lastErr == nil || now.Before(p.expiration) {
+				return p.key, nil
+			}
+
+			err = fmt.Errorf("Instance metadata: %v", p.lastErr)
+			return
+		}
+
+		p.refresh()
+	}
+}
+
+// Fetch fresh credentials without holding the lock, recording the outcome.
+//
+// REQUIRES: p.mutex is held, and no fetch is in progress.
+func (p *instanceMetadataCredentials) refresh() {
+	done := make(chan struct{})
+	p.fetching = done
+
+	token, tokenExpiration := p.token, p.tokenExpiration
+	if !p.clock.Now().Before(tokenExpiration) {
+		token = ""
+	}
+
+	p.mutex.Unlock()
+	r, err := p.fetch(token)
+	p.mutex.Lock()
+
+	p.fetching = nil
+	close(done)
+
+	if err != nil {
+		p.recordFailure(err)
+		return
+	}
+
+	p.key = r.key
+	p.expiration = r.expiration
+	p.token = r.token
+	if r.token != token {
+		p.tokenExpiration = r.tokenExpiration
+	}
+
+	p.lastErr = nil
+	p.failures = 0
+	p.nextAttempt = p.clock.Now().Add(metadataMinBackoff)
+}
+
+// Schedule the next attempt at fetching credentials after a failure.
+//
+// REQUIRES: p.mutex is held.
+func (p *instanceMetadataCredentials) recordFailure(err error) {
+	now := p.clock.Now()
+
+	// A cached token may have been what was wrong, so get a new one next time.
+	p.token = ""
+	p.lastErr = err
+	p.failures++
+
+	// If the service has never answered, we're probably not on EC2.
+	if _, unreachable := err.(*url.Error); unreachable && p.expiration.IsZero() {
+		p.nextAttempt = now.Add(metadataUnreachableBackoff)
+		return
+	}
+
+	backoff := metadataMaxBackoff
+	if p.failures <= 10 {
+		backoff = metadataMinBackoff << (p.failures - 1)
+		if backoff > metadataMaxBackoff {
+			backoff = metadataMaxBackoff
+		}
+	}
+
+	p.nextAttempt = now.Add(backoff)
+}
+
+// The outcome of a successful fetch.
+type metadataFetchResult struct {
+	key        AccessKey
+	expiration sys_time.Time
+
+	// The session token used, and when it should be replaced if it is new.
+	token           string
+	tokenExpiration sys_time.Time
+}
+
+// Fetch fresh credentials from the metadata service, using the supplied
+// session token or obtaining a new one if it is empty.
+func (p *instanceMetadataCredentials) fetch(
+	token string) (r metadataFetchResult, err error) {
+	// Obtain a session token, which must accompany the other requests.
+	if token == "" {
+		issued := p.clock.Now()
+
+		var body []byte
+		body, err = p.request(
+			"PUT",
+			metadataTokenPath,
+			"X-aws-ec2-metadata-token-ttl-seconds",
+			fmt.Sprintf("%d", int64(metadataTokenTTL/sys_time.Second)))
+
+		if err != nil {
+			return
+		}
+
+		token = string(body)
+		r.tokenExpiration = issued.Add(
+			metadataTokenTTL - metadataTokenRefreshWindow)
+	}
+
+	r.token = token
+
+	// Find the instance's role.
+	roles, err := p.request(
+		"GET",
+		metadataCredentialsPath,
+		"X-aws-ec2-metadata-token",
+		token)
+
+	if err != nil {
+		return
+	}
+
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		err = fmt.Errorf("No IAM role is associated with the instance")
+		return
+	}
+
+	// Fetch the role's credentials.
+	body, err := p.request(
+		"GET",
+		metadataCredentialsPath+role,
+		"X-aws-ec2-metadata-token",
+		token)
+
+	if err != nil {
+		return
+	}
+
+	var creds metadataCredentials
+	if err = json.Unmarshal(body, &creds); err != nil {
+		err = fmt.Errorf("Invalid credentials for role %s: %v", role, err)
+		return
+	}
+
+	if creds.Code != "Success" {
+		err = fmt.Errorf("Credentials for role %s: %s", role, creds.Code)
+		return
+	}
+
+	r.key = AccessKey{
+		Id:           creds.AccessKeyId,
+		Secret:       creds.SecretAccessKey,
+		SessionToken: creds.Token,
+	}
+
+	r.expiration = creds.Expiration
+	return
+}
+
+// Make a request to the metadata service with the supplied header, returning
+// the response body.
+func (p *instanceMetadataCredentials) request(
+	verb string,
+	path string,
+	headerName string,
+	headerValue string) (body []byte, err error) {
+	u := *p.endpoint
+	u.Path = path
+
+	req, err := http.NewRequest(verb, u.String(), nil)
+	if err != nil {
+		err = fmt.Errorf("http.NewRequest: %v", err)
+		return
+	}
+
+	req.Header.Set(headerName, headerValue)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("%s %s: %v", verb, path, err)
+		return
+	}
+
+	if resp.StatusCode != 200 {
+		err = fmt.Errorf("%s %s: %d %s", verb, path, resp.StatusCode, body)
+		return
+	}
+
+	return
+}
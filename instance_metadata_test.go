@@ -0,0 +1,432 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInstanceMetadata(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A stand-in for the instance metadata service.
+type metadataServer struct {
+	mutex sync.Mutex
+
+	// The role to report, if any, and its credentials.
+	role  string
+	creds metadataCredentials
+
+	// If set, fail all requests with a 500 error.
+	broken bool
+
+	// If set, close the connection without responding to any request.
+	hangUp bool
+
+	// If non-nil, each request is announced on started and then waits for
+	// release to be closed before being handled.
+	started chan struct{}
+	release chan struct{}
+
+	requests        int
+	tokensIssued    int
+	credentialCalls int
+}
+
+func (s *metadataServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	started, release := s.started, s.release
+	s.mutex.Unlock()
+
+	if started != nil {
+		started <- struct{}{}
+		<-release
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests++
+
+	if s.hangUp {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+
+		return
+	}
+
+	if s.broken {
+		http.Error(w, "taco", 500)
+		return
+	}
+
+	// Issue tokens.
+	if r.URL.Path == metadataTokenPath {
+		if r.Method != "PUT" ||
+			r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+			http.Error(w, "bad token request", 400)
+			return
+		}
+
+		s.tokensIssued++
+		fmt.Fprintf(w, "token-%d", s.tokensIssued)
+		return
+	}
+
+	// Everything else requires the most recent token.
+	expectedToken := fmt.Sprintf("token-%d", s.tokensIssued)
+	if r.Header.Get("X-aws-ec2-metadata-token") != expectedToken {
+		http.Error(w, "unauthorized", 401)
+		return
+	}
+
+	switch {
+	case r.URL.Path == metadataCredentialsPath:
+		if s.role == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, "%s\n", s.role)
+
+	case r.URL.Path == metadataCredentialsPath+s.role:
+		s.credentialCalls++
+		json.NewEncoder(w).Encode(s.creds)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Return the number of requests received so far.
+func (s *metadataServer) numRequests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests
+}
+
+// Set whether the server hangs up on requests.
+func (s *metadataServer) setHangUp(hangUp bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.hangUp = hangUp
+}
+
+type InstanceMetadataTest struct {
+	clock      *aws_time.SimulatedClock
+	server     *metadataServer
+	httpServer *httptest.Server
+	provider   CredentialsProvider
+}
+
+func init() { RegisterTestSuite(&InstanceMetadataTest{}) }
+
+func (t *InstanceMetadataTest) SetUp(i *TestInfo) {
+	t.clock = aws_time.NewSimulatedClock(
+		time.Date(2012, 8, 15, 22, 56, 0, 0, time.UTC))
+	t.server = &metadataServer{role: "some_role"}
+	t.setCredentials("id_0", time.Hour)
+
+	t.httpServer = httptest.NewServer(t.server)
+	endpoint, err := url.Parse(t.httpServer.URL)
+	AssertEq(nil, err)
+
+	t.provider = newInstanceMetadataCredentials(endpoint, t.clock)
+}
+
+func (t *InstanceMetadataTest) TearDown() {
+	t.httpServer.Close()
+}
+
+// Make the server hand out credentials with the given ID that expire at the
+// given time after the current time.
+func (t *InstanceMetadataTest) setCredentials(id string, d time.Duration) {
+	t.server.mutex.Lock()
+	defer t.server.mutex.Unlock()
+
+	t.server.creds = metadataCredentials{
+		Code:            "Success",
+		AccessKeyId:     id,
+		SecretAccessKey: "secret_for_" + id,
+		Token:           "token_for_" + id,
+		Expiration:      t.clock.Now().Add(d),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *InstanceMetadataTest) FetchesCredentials() {
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	ExpectEq("id_0", key.Id)
+	ExpectEq("secret_for_id_0", key.Secret)
+	ExpectEq("token_for_id_0", key.SessionToken)
+	ExpectEq(1, t.server.tokensIssued)
+}
+
+func (t *InstanceMetadataTest) CachesCredentials() {
+	_, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	t.setCredentials("id_1", time.Hour)
+	t.clock.AdvanceTime(50 * time.Minute)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	ExpectEq("id_0", key.Id)
+	ExpectEq(1, t.server.credentialCalls)
+}
+
+func (t *InstanceMetadataTest) RefreshesBeforeExpiry() {
+	_, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	t.setCredentials("id_1", 2*time.Hour)
+	t.clock.AdvanceTime(56 * time.Minute)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	ExpectEq("id_1", key.Id)
+	ExpectEq("token_for_id_1", key.SessionToken)
+	ExpectEq(2, t.server.credentialCalls)
+}
+
+func (t *InstanceMetadataTest) CachesSessionToken() {
+	_, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	// A refresh within the token's lifetime reuses it.
+	t.setCredentials("id_1", 2*time.Hour)
+	t.clock.AdvanceTime(56 * time.Minute)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_1", key.Id)
+	ExpectEq(1, t.server.tokensIssued)
+
+	// Once the token is about to expire, a new one is obtained.
+	t.setCredentials("id_2", 6*time.Hour)
+	t.clock.AdvanceTime(304 * time.Minute)
+
+	key, err = t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_2", key.Id)
+	ExpectEq(2, t.server.tokensIssued)
+}
+
+func (t *InstanceMetadataTest) RejectedTokenIsReplaced() {
+	_, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	// Make the server forget the token it issued.
+	t.server.tokensIssued++
+	t.clock.AdvanceTime(56 * time.Minute)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_0", key.Id)
+
+	// The next attempt obtains a new token.
+	t.setCredentials("id_1", time.Hour)
+	t.clock.AdvanceTime(time.Second)
+
+	key, err = t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_1", key.Id)
+	ExpectEq(3, t.server.tokensIssued)
+}
+
+func (t *InstanceMetadataTest) BacksOffAfterFailures() {
+	t.server.broken = true
+
+	// The first attempt fails.
+	_, err := t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("500")))
+	AssertEq(1, t.server.requests)
+
+	// Further calls fail with the same error without trying again until the
+	// backoff has passed.
+	_, err = t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("500")))
+	ExpectEq(1, t.server.requests)
+
+	t.clock.AdvanceTime(time.Second)
+	_, err = t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("500")))
+	ExpectEq(2, t.server.requests)
+
+	// The backoff doubles.
+	t.clock.AdvanceTime(time.Second)
+	_, err = t.provider.Credentials()
+	ExpectEq(2, t.server.requests)
+
+	t.clock.AdvanceTime(time.Second)
+	_, err = t.provider.Credentials()
+	ExpectEq(3, t.server.requests)
+
+	// Success resets it.
+	t.server.broken = false
+	t.clock.AdvanceTime(4 * time.Second)
+	t.setCredentials("id_0", time.Hour)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_0", key.Id)
+}
+
+func (t *InstanceMetadataTest) RemembersUnreachableService() {
+	t.server.setHangUp(true)
+
+	_, err := t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("Instance metadata")))
+	AssertEq(1, t.server.numRequests())
+
+	// The failure is remembered for a long time.
+	t.clock.AdvanceTime(59 * time.Minute)
+
+	_, err = t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("Instance metadata")))
+	ExpectEq(1, t.server.numRequests())
+
+	t.clock.AdvanceTime(time.Minute)
+	t.server.setHangUp(false)
+	t.setCredentials("id_0", time.Hour)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_0", key.Id)
+}
+
+func (t *InstanceMetadataTest) UsesCachedCredentialsDuringRefresh() {
+	_, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	// Start a refresh that blocks in the server.
+	t.setCredentials("id_1", 2*time.Hour)
+	t.clock.AdvanceTime(56 * time.Minute)
+
+	t.server.mutex.Lock()
+	t.server.started = make(chan struct{})
+	t.server.release = make(chan struct{})
+	t.server.mutex.Unlock()
+
+	refreshed := make(chan string)
+	go func() {
+		key, _ := t.provider.Credentials()
+		refreshed <- key.Id
+	}()
+
+	<-t.server.started
+
+	// Other callers aren't held up by it.
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_0", key.Id)
+
+	// Let the refresh finish.
+	t.server.mutex.Lock()
+	t.server.started = nil
+	t.server.mutex.Unlock()
+
+	close(t.server.release)
+	ExpectEq("id_1", <-refreshed)
+}
+
+func (t *InstanceMetadataTest) UsesCachedCredentialsWhenRefreshFails() {
+	_, err := t.provider.Credentials()
+	AssertEq(nil, err)
+
+	t.server.broken = true
+
+	// Within the refresh window, the old credentials are still good.
+	t.clock.AdvanceTime(56 * time.Minute)
+
+	key, err := t.provider.Credentials()
+	AssertEq(nil, err)
+	ExpectEq("id_0", key.Id)
+
+	// After they expire, they're not.
+	t.clock.AdvanceTime(5 * time.Minute)
+
+	_, err = t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("Instance metadata")))
+	ExpectThat(err, Error(HasSubstr("500")))
+}
+
+func (t *InstanceMetadataTest) ServerUnreachable() {
+	t.httpServer.Close()
+
+	_, err := t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("Instance metadata")))
+}
+
+func (t *InstanceMetadataTest) NoRole() {
+	t.server.role = ""
+
+	_, err := t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *InstanceMetadataTest) CredentialsNotAvailable() {
+	t.server.creds.Code = "Failure"
+
+	_, err := t.provider.Credentials()
+	ExpectThat(err, Error(HasSubstr("some_role")))
+	ExpectThat(err, Error(HasSubstr("Failure")))
+}
+
+func (t *InstanceMetadataTest) ConcurrentCallers() {
+	const numCallers = 10
+
+	var wg sync.WaitGroup
+	ids := make([]string, numCallers)
+	for i := 0; i < numCallers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, _ := t.provider.Credentials()
+			ids[i] = key.Id
+		}(i)
+	}
+
+	wg.Wait()
+
+	ExpectEq(strings.Repeat("id_0", numCallers), strings.Join(ids, ""))
+	ExpectEq(1, t.server.credentialCalls)
+}
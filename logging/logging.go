@@ -0,0 +1,128 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging records the requests sent to AWS for debugging. Buckets and
+// SimpleDB connections report to a Logger via an interceptor; see
+// s3.NewLoggingInterceptor and conn.NewLoggingInterceptor in package
+// sdb/conn. Credentials and signatures are redacted before they reach the
+// logger.
+//
+// For example:
+//
+//     logger := logging.NewTextLogger(log.New(os.Stderr, "", log.LstdFlags))
+//     bucket, err := s3.OpenBucket(
+//         name,
+//         region,
+//         creds,
+//         s3.NewLoggingInterceptor(logger, logging.LevelRequests))
+//
+package logging
+
+import (
+	"strings"
+	"time"
+)
+
+// How much to log.
+type Level int
+
+const (
+	// Log only attempts that fail, either with a connection error or with an
+	// error status code.
+	LevelErrors Level = iota
+
+	// Log every attempt.
+	LevelRequests
+
+	// Log every attempt, along with the first MaxBodyBytes of the request and
+	// response bodies.
+	LevelBodies
+)
+
+// The maximum number of bytes of a body included in an entry.
+const MaxBodyBytes = 4096
+
+// The value given to redacted headers and parameters.
+const Redacted = "REDACTED"
+
+// A single attempt at sending a request to AWS, and its outcome.
+type Entry struct {
+	// The service to which the request was sent, e.g. "s3" or "sdb".
+	Service string
+
+	// The name of the operation, e.g. "GetObject" or "PutAttributes".
+	Operation string
+
+	// The HTTP verb and path of the request.
+	Verb string
+	Path string
+
+	// The request's parameters and headers, with secrets redacted.
+	Parameters map[string]string
+	Headers    map[string]string
+
+	// The HTTP status code of the response, or zero if no response was
+	// received.
+	StatusCode int
+
+	// The ID that AWS assigned to the request, if it said.
+	RequestId string
+
+	// The time between sending the request and receiving the response's
+	// headers.
+	Latency time.Duration
+
+	// The error returned by the connection, if any.
+	Err error
+
+	// The start of the request and response bodies, when logging at
+	// LevelBodies.
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// Did the attempt fail?
+func (e *Entry) Failed() bool {
+	return e.Err != nil || e.StatusCode >= 300
+}
+
+// A Logger receives entries. It must be safe for concurrent use.
+type Logger interface {
+	Log(e *Entry)
+}
+
+// The lower-cased names of the headers and parameters that are redacted.
+var secretNames = map[string]bool{
+	"authorization":        true,
+	"x-amz-security-token": true,
+	"signature":            true,
+	"awsaccesskeyid":       true,
+	"securitytoken":        true,
+}
+
+// Return a copy of the supplied headers or parameters, with the values of
+// those that carry credentials or signatures replaced by Redacted.
+func Redact(values map[string]string) map[string]string {
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		if secretNames[strings.ToLower(name)] {
+			value = Redacted
+		}
+
+		result[name] = value
+	}
+
+	return result
+}
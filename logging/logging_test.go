@@ -0,0 +1,83 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging_test
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/logging"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestLogging(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Redact
+////////////////////////////////////////////////////////////////////////
+
+type RedactTest struct {
+}
+
+func init() { RegisterTestSuite(&RedactTest{}) }
+
+func (t *RedactTest) NilMap() {
+	ExpectEq(0, len(logging.Redact(nil)))
+}
+
+func (t *RedactTest) RedactsSecrets() {
+	values := map[string]string{
+		"Authorization":        "AWS foo:bar",
+		"X-Amz-Security-Token": "taco",
+		"Signature":            "burrito",
+		"AWSAccessKeyId":       "enchilada",
+		"SecurityToken":        "queso",
+		"signature":            "nachos",
+		"Date":                 "today",
+		"Action":               "Select",
+	}
+
+	redacted := logging.Redact(values)
+
+	ExpectEq(len(values), len(redacted))
+	ExpectEq(logging.Redacted, redacted["Authorization"])
+	ExpectEq(logging.Redacted, redacted["X-Amz-Security-Token"])
+	ExpectEq(logging.Redacted, redacted["Signature"])
+	ExpectEq(logging.Redacted, redacted["AWSAccessKeyId"])
+	ExpectEq(logging.Redacted, redacted["SecurityToken"])
+	ExpectEq(logging.Redacted, redacted["signature"])
+	ExpectEq("today", redacted["Date"])
+	ExpectEq("Select", redacted["Action"])
+
+	// The original should be untouched.
+	ExpectEq("AWS foo:bar", values["Authorization"])
+}
+
+////////////////////////////////////////////////////////////////////////
+// Entry
+////////////////////////////////////////////////////////////////////////
+
+type EntryTest struct {
+}
+
+func init() { RegisterTestSuite(&EntryTest{}) }
+
+func (t *EntryTest) Failed() {
+	ExpectFalse((&logging.Entry{StatusCode: 200}).Failed())
+	ExpectFalse((&logging.Entry{StatusCode: 204}).Failed())
+	ExpectTrue((&logging.Entry{StatusCode: 301}).Failed())
+	ExpectTrue((&logging.Entry{StatusCode: 404}).Failed())
+	ExpectTrue((&logging.Entry{Err: errors.New("taco")}).Failed())
+}
@@ -0,0 +1,109 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Return a logger that writes each entry to the supplied log as a single line
+// of key=value pairs, for example (wrapped here):
+//
+//     service=s3 operation=GetObject verb=GET path=/bucket/foo status=404
+//     request_id=4442587FB7D0A2F9 latency=21ms header.Date="Mon, ..."
+//
+// Values are quoted with strconv.Quote where necessary.
+func NewTextLogger(l *log.Logger) Logger {
+	return &textLogger{l}
+}
+
+type textLogger struct {
+	log *log.Logger
+}
+
+func (l *textLogger) Log(e *Entry) {
+	buf := new(bytes.Buffer)
+	write := func(key, value string) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+
+		fmt.Fprintf(buf, "%s=%s", key, quote(value))
+	}
+
+	write("service", e.Service)
+	write("operation", e.Operation)
+	write("verb", e.Verb)
+	write("path", e.Path)
+	write("status", strconv.Itoa(e.StatusCode))
+
+	if e.RequestId != "" {
+		write("request_id", e.RequestId)
+	}
+
+	write("latency", e.Latency.String())
+
+	if e.Err != nil {
+		write("err", e.Err.Error())
+	}
+
+	for _, name := range sortedKeys(e.Parameters) {
+		write("param."+name, e.Parameters[name])
+	}
+
+	for _, name := range sortedKeys(e.Headers) {
+		write("header."+name, e.Headers[name])
+	}
+
+	if e.RequestBody != nil {
+		write("request_body", string(e.RequestBody))
+	}
+
+	if e.ResponseBody != nil {
+		write("response_body", string(e.ResponseBody))
+	}
+
+	l.log.Print(buf.String())
+}
+
+// Quote the value if it would otherwise be ambiguous.
+func quote(s string) string {
+	needsQuoting := func(r rune) bool {
+		return r == '"' || r == '=' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}
+
+	if s == "" || strings.IndexFunc(s, needsQuoting) >= 0 {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
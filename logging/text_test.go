@@ -0,0 +1,81 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jacobsa/aws/logging"
+	. "github.com/jacobsa/ogletest"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestText(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type TextLoggerTest struct {
+	buf    bytes.Buffer
+	logger logging.Logger
+}
+
+func init() { RegisterTestSuite(&TextLoggerTest{}) }
+
+func (t *TextLoggerTest) SetUp(i *TestInfo) {
+	t.logger = logging.NewTextLogger(log.New(&t.buf, "", 0))
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *TextLoggerTest) MinimalEntry() {
+	t.logger.Log(&logging.Entry{Service: "s3", Verb: "GET"})
+
+	ExpectEq(
+		`service=s3 operation="" verb=GET path="" status=0 latency=0s`+"\n",
+		t.buf.String())
+}
+
+func (t *TextLoggerTest) FullEntry() {
+	t.logger.Log(
+		&logging.Entry{
+			Service:    "s3",
+			Operation:  "GetObject",
+			Verb:       "GET",
+			Path:       "/bucket/foo bar",
+			Parameters: map[string]string{"b": "1", "a": "x=y"},
+			Headers:    map[string]string{"Date": "Mon, 2 Jan"},
+			StatusCode: 404,
+			RequestId:  "ABC123",
+			Latency:    21 * time.Millisecond,
+			Err:        errors.New("taco"),
+
+			RequestBody:  []byte("burrito"),
+			ResponseBody: []byte("<Error>\n</Error>"),
+		})
+
+	ExpectEq(
+		`service=s3 operation=GetObject verb=GET path="/bucket/foo bar" `+
+			`status=404 request_id=ABC123 latency=21ms err=taco `+
+			`param.a="x=y" param.b=1 header.Date="Mon, 2 Jan" `+
+			`request_body=burrito response_body="<Error>\n</Error>"`+"\n",
+		t.buf.String())
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics collects measurements of the requests sent to AWS. Buckets
+// and SimpleDB connections report to a Sink via an interceptor; see
+// s3.NewMetricsInterceptor and conn.NewMetricsInterceptor in package sdb/conn.
+// Registry is an in-memory Sink whose contents can be served in the
+// Prometheus text exposition format.
+//
+// For example:
+//
+//     registry := metrics.NewRegistry()
+//     http.Handle("/metrics", metrics.Handler(registry))
+//
+//     bucket, err := s3.OpenBucket(
+//         name,
+//         region,
+//         creds,
+//         s3.NewMetricsInterceptor(registry))
+//
+package metrics
+
+import (
+	"time"
+)
+
+// A single attempt at sending a request to AWS, and its outcome.
+type Attempt struct {
+	// The service to which the request was sent, e.g. "s3" or "sdb".
+	Service string
+
+	// The name of the operation, e.g. "GetObject" or "PutAttributes".
+	Operation string
+
+	// The HTTP status code of the response, or zero if no response was
+	// received.
+	StatusCode int
+
+	// The error code given in the body of an error response, e.g.
+	// "NoSuchKey". Empty if the response was not an error or had no code.
+	ErrorCode string
+
+	// The number of bytes in the request and response bodies.
+	BytesSent     int64
+	BytesReceived int64
+
+	// The time between sending the request and receiving the response's
+	// headers.
+	Latency time.Duration
+}
+
+// A Sink receives measurements. It must be safe for concurrent use.
+type Sink interface {
+	// Record the outcome of an attempt at sending a request.
+	RecordAttempt(a Attempt)
+
+	// Record that a request for the given operation is being sent again, for
+	// example to follow a redirect or after correcting for clock skew.
+	RecordRetry(service string, operation string)
+}
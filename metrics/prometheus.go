@@ -0,0 +1,181 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Write the contents of the registry to w in the Prometheus text exposition
+// format. The following metrics are written, each labelled with service and
+// operation:
+//
+//     aws_requests_total               counter, also labelled with status
+//                                      code and error code
+//     aws_request_retries_total        counter
+//     aws_request_sent_bytes_total     counter
+//     aws_request_received_bytes_total counter
+//     aws_request_duration_seconds     histogram
+//
+// A status code of zero means that no response was received.
+//
+// Reference:
+//     https://prometheus.io/docs/instrumenting/exposition_formats/
+func (r *Registry) WriteText(w io.Writer) error {
+	stats := r.Snapshot()
+	b := bufio.NewWriter(w)
+
+	// Requests
+	writeHeader(b, "aws_requests_total", "counter", "Requests sent to AWS.")
+	for _, s := range stats {
+		for _, o := range sortedOutcomes(s.Attempts) {
+			fmt.Fprintf(
+				b,
+				"aws_requests_total{%s,code=\"%d\",error_code=\"%s\"} %d\n",
+				labels(s),
+				o.StatusCode,
+				escape(o.ErrorCode),
+				s.Attempts[o])
+		}
+	}
+
+	// Simple counters
+	counters := []struct {
+		name  string
+		help  string
+		value func(s OperationStats) uint64
+	}{
+		{
+			"aws_request_retries_total",
+			"Requests sent to AWS again.",
+			func(s OperationStats) uint64 { return s.Retries },
+		},
+		{
+			"aws_request_sent_bytes_total",
+			"Bytes sent in request bodies.",
+			func(s OperationStats) uint64 { return s.BytesSent },
+		},
+		{
+			"aws_request_received_bytes_total",
+			"Bytes received in response bodies.",
+			func(s OperationStats) uint64 { return s.BytesReceived },
+		},
+	}
+
+	for _, c := range counters {
+		writeHeader(b, c.name, "counter", c.help)
+		for _, s := range stats {
+			fmt.Fprintf(b, "%s{%s} %d\n", c.name, labels(s), c.value(s))
+		}
+	}
+
+	// Latency
+	const histogram = "aws_request_duration_seconds"
+	writeHeader(b, histogram, "histogram", "Latency of requests to AWS.")
+	for _, s := range stats {
+		var cumulative uint64
+		for i, bound := range LatencyBounds {
+			cumulative += s.Latency.Counts[i]
+			fmt.Fprintf(
+				b,
+				"%s_bucket{%s,le=\"%s\"} %d\n",
+				histogram,
+				labels(s),
+				strconv.FormatFloat(bound.Seconds(), 'g', -1, 64),
+				cumulative)
+		}
+
+		fmt.Fprintf(
+			b,
+			"%s_bucket{%s,le=\"+Inf\"} %d\n",
+			histogram,
+			labels(s),
+			s.Latency.Count)
+
+		fmt.Fprintf(
+			b,
+			"%s_sum{%s} %s\n",
+			histogram,
+			labels(s),
+			strconv.FormatFloat(s.Latency.Sum.Seconds(), 'g', -1, 64))
+
+		fmt.Fprintf(
+			b,
+			"%s_count{%s} %d\n",
+			histogram,
+			labels(s),
+			s.Latency.Count)
+	}
+
+	return b.Flush()
+}
+
+// Return an HTTP handler that serves the contents of the registry in the
+// Prometheus text exposition format, for scraping by a Prometheus server.
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			r.WriteText(w)
+		})
+}
+
+func writeHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+func labels(s OperationStats) string {
+	return fmt.Sprintf(
+		"service=\"%s\",operation=\"%s\"",
+		escape(s.Service),
+		escape(s.Operation))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func sortedOutcomes(m map[Outcome]uint64) []Outcome {
+	result := make([]Outcome, 0, len(m))
+	for o := range m {
+		result = append(result, o)
+	}
+
+	sort.Sort(byOutcome(result))
+	return result
+}
+
+type byOutcome []Outcome
+
+func (l byOutcome) Len() int      { return len(l) }
+func (l byOutcome) Swap(i, j int) { l[j], l[i] = l[i], l[j] }
+
+func (l byOutcome) Less(i, j int) bool {
+	if l[i].StatusCode != l[j].StatusCode {
+		return l[i].StatusCode < l[j].StatusCode
+	}
+
+	return l[i].ErrorCode < l[j].ErrorCode
+}
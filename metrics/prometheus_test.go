@@ -0,0 +1,147 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"bytes"
+	"github.com/jacobsa/aws/metrics"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type PrometheusTest struct {
+	registry *metrics.Registry
+}
+
+func init() { RegisterTestSuite(&PrometheusTest{}) }
+
+func (t *PrometheusTest) SetUp(i *TestInfo) {
+	t.registry = metrics.NewRegistry()
+}
+
+func (t *PrometheusTest) text() string {
+	buf := new(bytes.Buffer)
+	AssertEq(nil, t.registry.WriteText(buf))
+	return buf.String()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *PrometheusTest) Empty() {
+	text := t.text()
+
+	ExpectThat(text, HasSubstr("# TYPE aws_requests_total counter\n"))
+	ExpectThat(
+		text,
+		HasSubstr("# TYPE aws_request_duration_seconds histogram\n"))
+	ExpectFalse(strings.Contains(text, "{"), "%s", text)
+}
+
+func (t *PrometheusTest) Counters() {
+	t.registry.RecordAttempt(
+		metrics.Attempt{
+			Service:       "s3",
+			Operation:     "GetObject",
+			StatusCode:    404,
+			ErrorCode:     "NoSuchKey",
+			BytesSent:     3,
+			BytesReceived: 17,
+		})
+
+	t.registry.RecordRetry("s3", "GetObject")
+
+	text := t.text()
+	labels := `service="s3",operation="GetObject"`
+
+	ExpectThat(
+		text,
+		HasSubstr(
+			"aws_requests_total{"+labels+
+				`,code="404",error_code="NoSuchKey"} 1`+"\n"))
+
+	ExpectThat(text, HasSubstr("aws_request_retries_total{"+labels+"} 1\n"))
+	ExpectThat(
+		text,
+		HasSubstr("aws_request_sent_bytes_total{"+labels+"} 3\n"))
+	ExpectThat(
+		text,
+		HasSubstr("aws_request_received_bytes_total{"+labels+"} 17\n"))
+}
+
+func (t *PrometheusTest) Histogram() {
+	for _, l := range []time.Duration{time.Millisecond, 2 * time.Second} {
+		t.registry.RecordAttempt(
+			metrics.Attempt{Service: "sdb", Operation: "Select", Latency: l})
+	}
+
+	text := t.text()
+	prefix := `aws_request_duration_seconds_bucket{` +
+		`service="sdb",operation="Select",le=`
+
+	ExpectThat(text, HasSubstr(prefix+`"0.005"} 1`+"\n"))
+	ExpectThat(text, HasSubstr(prefix+`"1"} 1`+"\n"))
+	ExpectThat(text, HasSubstr(prefix+`"2.5"} 2`+"\n"))
+	ExpectThat(text, HasSubstr(prefix+`"+Inf"} 2`+"\n"))
+
+	ExpectThat(
+		text,
+		HasSubstr(
+			`aws_request_duration_seconds_sum{`+
+				`service="sdb",operation="Select"} 2.001`+"\n"))
+
+	ExpectThat(
+		text,
+		HasSubstr(
+			`aws_request_duration_seconds_count{`+
+				`service="sdb",operation="Select"} 2`+"\n"))
+}
+
+func (t *PrometheusTest) EscapesLabelValues() {
+	t.registry.RecordRetry("s3", "taco\"burrito\\\n")
+
+	ExpectThat(
+		t.text(),
+		HasSubstr(`operation="taco\"burrito\\\n"`))
+}
+
+func (t *PrometheusTest) Handler() {
+	t.registry.RecordRetry("s3", "GetObject")
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	AssertEq(nil, err)
+
+	metrics.Handler(t.registry).ServeHTTP(recorder, req)
+
+	ExpectEq(200, recorder.Code)
+	ExpectThat(
+		recorder.HeaderMap.Get("Content-Type"),
+		HasSubstr("version=0.0.4"))
+	ExpectEq(t.text(), recorder.Body.String())
+}
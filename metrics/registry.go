@@ -0,0 +1,181 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// The upper bounds of the latency histogram buckets kept by a Registry.
+var LatencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// The outcome of an attempt, as counted by a Registry.
+type Outcome struct {
+	StatusCode int
+	ErrorCode  string
+}
+
+// A histogram of latencies.
+type Histogram struct {
+	// The number of observations falling in each bucket. Counts[i] is the
+	// number no greater than LatencyBounds[i] but greater than the previous
+	// bound, and the final element counts those greater than every bound.
+	Counts []uint64
+
+	// The total number and sum of the observations.
+	Count uint64
+	Sum   time.Duration
+}
+
+// The measurements for a single operation.
+type OperationStats struct {
+	Service   string
+	Operation string
+
+	// The number of attempts with each outcome.
+	Attempts map[Outcome]uint64
+
+	// The number of times a request was sent again.
+	Retries uint64
+
+	// The total number of bytes sent and received in request and response
+	// bodies.
+	BytesSent     uint64
+	BytesReceived uint64
+
+	// The latencies of all attempts.
+	Latency Histogram
+}
+
+// Registry is an in-memory Sink that accumulates counters and latency
+// histograms for each operation.
+type Registry struct {
+	mutex sync.Mutex
+
+	// Protected by mutex
+	ops map[operationKey]*OperationStats
+}
+
+type operationKey struct {
+	service   string
+	operation string
+}
+
+// Create an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		ops: make(map[operationKey]*OperationStats),
+	}
+}
+
+// Return the stats for the given operation, creating them if necessary.
+//
+// REQUIRES: r.mutex is held
+func (r *Registry) stats(service, operation string) *OperationStats {
+	key := operationKey{service, operation}
+	s, ok := r.ops[key]
+	if !ok {
+		s = &OperationStats{
+			Service:   service,
+			Operation: operation,
+			Attempts:  make(map[Outcome]uint64),
+			Latency: Histogram{
+				Counts: make([]uint64, len(LatencyBounds)+1),
+			},
+		}
+
+		r.ops[key] = s
+	}
+
+	return s
+}
+
+func (r *Registry) RecordAttempt(a Attempt) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := r.stats(a.Service, a.Operation)
+	s.Attempts[Outcome{a.StatusCode, a.ErrorCode}]++
+	s.BytesSent += uint64(a.BytesSent)
+	s.BytesReceived += uint64(a.BytesReceived)
+
+	// Find the first bucket whose bound is at least the latency, falling back
+	// to the overflow bucket.
+	i := sort.Search(
+		len(LatencyBounds),
+		func(i int) bool { return a.Latency <= LatencyBounds[i] })
+
+	s.Latency.Counts[i]++
+	s.Latency.Count++
+	s.Latency.Sum += a.Latency
+}
+
+func (r *Registry) RecordRetry(service string, operation string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stats(service, operation).Retries++
+}
+
+// Return a copy of the stats for every operation recorded so far, sorted by
+// service and then by operation.
+func (r *Registry) Snapshot() []OperationStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make([]OperationStats, 0, len(r.ops))
+	for _, s := range r.ops {
+		c := *s
+
+		c.Attempts = make(map[Outcome]uint64)
+		for o, n := range s.Attempts {
+			c.Attempts[o] = n
+		}
+
+		c.Latency.Counts = append([]uint64(nil), s.Latency.Counts...)
+		result = append(result, c)
+	}
+
+	sort.Sort(byOperation(result))
+	return result
+}
+
+type byOperation []OperationStats
+
+func (l byOperation) Len() int      { return len(l) }
+func (l byOperation) Swap(i, j int) { l[j], l[i] = l[i], l[j] }
+
+func (l byOperation) Less(i, j int) bool {
+	if l[i].Service != l[j].Service {
+		return l[i].Service < l[j].Service
+	}
+
+	return l[i].Operation < l[j].Operation
+}
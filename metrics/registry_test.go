@@ -0,0 +1,143 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"github.com/jacobsa/aws/metrics"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type RegistryTest struct {
+	registry *metrics.Registry
+}
+
+func init() { RegisterTestSuite(&RegistryTest{}) }
+
+func (t *RegistryTest) SetUp(i *TestInfo) {
+	t.registry = metrics.NewRegistry()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *RegistryTest) Empty() {
+	ExpectThat(t.registry.Snapshot(), ElementsAre())
+}
+
+func (t *RegistryTest) CountsAttempts() {
+	t.registry.RecordAttempt(
+		metrics.Attempt{
+			Service:       "s3",
+			Operation:     "GetObject",
+			StatusCode:    200,
+			BytesSent:     3,
+			BytesReceived: 17,
+		})
+
+	t.registry.RecordAttempt(
+		metrics.Attempt{
+			Service:       "s3",
+			Operation:     "GetObject",
+			StatusCode:    404,
+			ErrorCode:     "NoSuchKey",
+			BytesSent:     4,
+			BytesReceived: 19,
+		})
+
+	t.registry.RecordAttempt(
+		metrics.Attempt{
+			Service:    "s3",
+			Operation:  "GetObject",
+			StatusCode: 200,
+		})
+
+	t.registry.RecordRetry("s3", "GetObject")
+
+	stats := t.registry.Snapshot()
+	AssertEq(1, len(stats))
+	s := stats[0]
+
+	ExpectEq("s3", s.Service)
+	ExpectEq("GetObject", s.Operation)
+	ExpectEq(2, len(s.Attempts))
+	ok := metrics.Outcome{StatusCode: 200}
+	notFound := metrics.Outcome{StatusCode: 404, ErrorCode: "NoSuchKey"}
+
+	ExpectEq(2, s.Attempts[ok])
+	ExpectEq(1, s.Attempts[notFound])
+	ExpectEq(1, s.Retries)
+	ExpectEq(7, s.BytesSent)
+	ExpectEq(36, s.BytesReceived)
+}
+
+func (t *RegistryTest) LatencyHistogram() {
+	latencies := []time.Duration{
+		0,
+		5 * time.Millisecond,
+		6 * time.Millisecond,
+		time.Second,
+		time.Minute,
+	}
+
+	for _, l := range latencies {
+		t.registry.RecordAttempt(
+			metrics.Attempt{Service: "sdb", Operation: "Select", Latency: l})
+	}
+
+	stats := t.registry.Snapshot()
+	AssertEq(1, len(stats))
+	h := stats[0].Latency
+
+	AssertEq(len(metrics.LatencyBounds)+1, len(h.Counts))
+	ExpectEq(2, h.Counts[0])
+	ExpectEq(1, h.Counts[1])
+	ExpectEq(1, h.Counts[7])
+	ExpectEq(1, h.Counts[len(h.Counts)-1])
+
+	ExpectEq(5, h.Count)
+	ExpectEq(time.Minute+time.Second+11*time.Millisecond, h.Sum)
+}
+
+func (t *RegistryTest) SnapshotIsSortedAndCopied() {
+	t.registry.RecordRetry("sdb", "Select")
+	t.registry.RecordRetry("s3", "PutObject")
+	t.registry.RecordRetry("s3", "GetObject")
+
+	stats := t.registry.Snapshot()
+	AssertEq(3, len(stats))
+
+	ExpectEq("s3/GetObject", stats[0].Service+"/"+stats[0].Operation)
+	ExpectEq("s3/PutObject", stats[1].Service+"/"+stats[1].Operation)
+	ExpectEq("sdb/Select", stats[2].Service+"/"+stats[2].Operation)
+
+	// Modifying the snapshot shouldn't affect the registry.
+	stats[0].Attempts[metrics.Outcome{StatusCode: 200}] = 17
+	stats[0].Latency.Counts[0] = 17
+
+	stats = t.registry.Snapshot()
+	ExpectEq(0, len(stats[0].Attempts))
+	ExpectEq(0, stats[0].Latency.Counts[0])
+}
@@ -0,0 +1,223 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits the rate at which requests are sent to AWS, to
+// stay clear of the throttling that SimpleDB applies per domain and S3 per key
+// prefix. Buckets and SimpleDB connections consult a Limiter before sending
+// each request, via an interceptor; see s3.NewRateLimitInterceptor and
+// conn.NewRateLimitInterceptor in package sdb/conn.
+//
+// For example:
+//
+//     limiter := ratelimit.NewLimiter(
+//         ratelimit.Config{
+//             Default: ratelimit.Limit{RequestsPerSecond: 100},
+//             Operations: map[string]ratelimit.Limit{
+//                 "PutObject": {BytesPerSecond: 1 << 20},
+//             },
+//         })
+//
+//     bucket, err := s3.OpenBucket(
+//         name,
+//         region,
+//         creds,
+//         s3.NewRateLimitInterceptor(limiter))
+//
+package ratelimit
+
+import (
+	"github.com/jacobsa/aws/time"
+	"sync"
+	sys_time "time"
+)
+
+// The limit on the rate of an operation. A zero field means no limit.
+type Limit struct {
+	RequestsPerSecond float64
+
+	// The limit on the number of bytes sent in request bodies.
+	BytesPerSecond float64
+}
+
+// The limits applied by a Limiter.
+type Config struct {
+	// The limit for each operation not listed in Operations.
+	Default Limit
+
+	// Limits for particular operations, keyed by the name of the operation
+	// (e.g. "PutObject" or "Select").
+	Operations map[string]Limit
+}
+
+// The smallest fraction of its configured rate that an operation is slowed to
+// by throttling.
+const minFactor = 1.0 / 64
+
+// The fraction of its configured rate that an operation regains with each
+// request that isn't throttled.
+const recoveryStep = 1.0 / 32
+
+// A Limiter decides when requests may be sent. It must be safe for concurrent
+// use.
+type Limiter interface {
+	// Block until a request for the given operation, whose body contains the
+	// given number of bytes, may be sent.
+	Wait(operation string, bytes int64)
+
+	// Report whether the server throttled a request for the given operation.
+	Observe(operation string, throttled bool)
+}
+
+// Create a limiter that enforces the supplied limits with token buckets,
+// allowing bursts of up to one second's worth of requests and bytes.
+//
+// Each time the server throttles a request for an operation, the operation's
+// rates are halved, down to 1/64 of those configured. Each request that isn't
+// throttled then restores 1/32 of the configured rates. Operations without a
+// limit are never slowed.
+func NewLimiter(c Config) Limiter {
+	return newLimiter(c, time.RealClock())
+}
+
+// The underlying constructor, which accepts a clock for testability.
+func newLimiter(c Config, clock time.Clock) Limiter {
+	return &limiter{
+		config: c,
+		clock:  clock,
+		ops:    make(map[string]*operationState),
+	}
+}
+
+type limiter struct {
+	config Config
+	clock  time.Clock
+
+	mutex sync.Mutex
+
+	// Protected by mutex
+	ops map[string]*operationState
+}
+
+type operationState struct {
+	limit Limit
+
+	// The fraction of the configured rates currently allowed, in
+	// [minFactor, 1].
+	factor float64
+
+	requests tokenBucket
+	bytes    tokenBucket
+}
+
+// Return the state for the given operation, creating it if necessary.
+//
+// REQUIRES: l.mutex is held
+func (l *limiter) state(operation string) *operationState {
+	s, ok := l.ops[operation]
+	if !ok {
+		limit, ok := l.config.Operations[operation]
+		if !ok {
+			limit = l.config.Default
+		}
+
+		s = &operationState{limit: limit, factor: 1}
+		l.ops[operation] = s
+	}
+
+	return s
+}
+
+func (l *limiter) Wait(operation string, bytes int64) {
+	l.mutex.Lock()
+
+	s := l.state(operation)
+	now := l.clock.Now()
+
+	d := s.requests.take(now, s.limit.RequestsPerSecond*s.factor, 1)
+	if bytesDelay := s.bytes.take(
+		now,
+		s.limit.BytesPerSecond*s.factor,
+		float64(bytes)); bytesDelay > d {
+		d = bytesDelay
+	}
+
+	l.mutex.Unlock()
+
+	if d > 0 {
+		l.clock.Sleep(d)
+	}
+}
+
+func (l *limiter) Observe(operation string, throttled bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	s := l.state(operation)
+	if throttled {
+		s.factor /= 2
+		if s.factor < minFactor {
+			s.factor = minFactor
+		}
+	} else {
+		s.factor += recoveryStep
+		if s.factor > 1 {
+			s.factor = 1
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Token buckets
+////////////////////////////////////////////////////////////////////////
+
+// A token bucket that refills continuously, holding at most one second's
+// worth of tokens. Callers may take more tokens than are available, leaving
+// the bucket in debt, in which case they must wait for the debt to be repaid.
+type tokenBucket struct {
+	tokens float64
+	last   sys_time.Time
+}
+
+// Take cost tokens at the given time from a bucket refilling at the given
+// rate per second, returning how long the caller must wait before
+// proceeding. A non-positive rate means no limit.
+func (b *tokenBucket) take(
+	now sys_time.Time,
+	rate float64,
+	cost float64) sys_time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+
+	// Refill the bucket, starting full.
+	if b.last.IsZero() {
+		b.tokens = rate
+	} else {
+		b.tokens += rate * now.Sub(b.last).Seconds()
+		if b.tokens > rate {
+			b.tokens = rate
+		}
+	}
+
+	b.last = now
+
+	// Take the tokens.
+	b.tokens -= cost
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return sys_time.Duration(-b.tokens / rate * float64(sys_time.Second))
+}
@@ -0,0 +1,190 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type LimiterTest struct {
+	clock *aws_time.SimulatedClock
+}
+
+func init() { RegisterTestSuite(&LimiterTest{}) }
+
+func (t *LimiterTest) SetUp(i *TestInfo) {
+	t.clock = aws_time.NewSimulatedClock(time.Unix(1e9, 0))
+}
+
+func (t *LimiterTest) newLimiter(c Config) Limiter {
+	return newLimiter(c, t.clock)
+}
+
+// Call f, advancing the clock in steps of 10ms whenever someone is sleeping,
+// and return how far the clock moved.
+func (t *LimiterTest) elapsed(f func()) time.Duration {
+	stopped := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			t.clock.WaitForTimers(1)
+			select {
+			case <-stopped:
+				return
+			default:
+			}
+
+			t.clock.AdvanceTime(10 * time.Millisecond)
+		}
+	}()
+
+	start := t.clock.Now()
+	f()
+	d := t.clock.Now().Sub(start)
+
+	// Stop the goroutine above, waking it if it's waiting for a timer.
+	close(stopped)
+	wake := t.clock.NewTimer(time.Hour)
+	<-exited
+	wake.Stop()
+
+	return d
+}
+
+// Call Wait on the limiter n times, returning how far the clock moved.
+func (t *LimiterTest) wait(
+	l Limiter,
+	operation string,
+	bytes int64,
+	n int) time.Duration {
+	return t.elapsed(func() {
+		for i := 0; i < n; i++ {
+			l.Wait(operation, bytes)
+		}
+	})
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *LimiterTest) NoLimits() {
+	l := t.newLimiter(Config{})
+
+	ExpectEq(0, t.wait(l, "GetObject", 1<<20, 100))
+}
+
+func (t *LimiterTest) RequestRate() {
+	l := t.newLimiter(Config{Default: Limit{RequestsPerSecond: 2}})
+
+	// A second's worth of requests may be sent at once, after which they are
+	// spaced out.
+	ExpectEq(0, t.wait(l, "GetObject", 0, 2))
+	ExpectEq(500*time.Millisecond, t.wait(l, "GetObject", 0, 1))
+	ExpectEq(500*time.Millisecond, t.wait(l, "GetObject", 0, 1))
+}
+
+func (t *LimiterTest) BurstAfterIdling() {
+	l := t.newLimiter(Config{Default: Limit{RequestsPerSecond: 2}})
+
+	ExpectEq(0, t.wait(l, "GetObject", 0, 2))
+
+	// After a long idle period, the bucket holds no more than a second's
+	// worth.
+	t.clock.AdvanceTime(time.Hour)
+	ExpectEq(500*time.Millisecond, t.wait(l, "GetObject", 0, 3))
+}
+
+func (t *LimiterTest) ByteRate() {
+	l := t.newLimiter(Config{Default: Limit{BytesPerSecond: 1000}})
+
+	ExpectEq(0, t.wait(l, "PutObject", 500, 1))
+	ExpectEq(time.Second, t.wait(l, "PutObject", 1500, 1))
+	ExpectEq(0, t.wait(l, "PutObject", 0, 1))
+}
+
+func (t *LimiterTest) BothRates() {
+	l := t.newLimiter(
+		Config{
+			Default: Limit{RequestsPerSecond: 1, BytesPerSecond: 1000},
+		})
+
+	ExpectEq(0, t.wait(l, "PutObject", 0, 1))
+
+	// The longer of the two waits applies.
+	ExpectEq(2*time.Second, t.wait(l, "PutObject", 3000, 1))
+}
+
+func (t *LimiterTest) PerOperationLimits() {
+	l := t.newLimiter(
+		Config{
+			Default: Limit{RequestsPerSecond: 1},
+			Operations: map[string]Limit{
+				"GetObject": {},
+				"PutObject": {RequestsPerSecond: 4},
+			},
+		})
+
+	ExpectEq(0, t.wait(l, "GetObject", 0, 5))
+	ExpectEq(250*time.Millisecond, t.wait(l, "PutObject", 0, 5))
+	ExpectEq(time.Second, t.wait(l, "DeleteObject", 0, 2))
+}
+
+func (t *LimiterTest) ThrottlingHalvesRate() {
+	l := t.newLimiter(Config{Default: Limit{RequestsPerSecond: 4}})
+	l.Observe("PutObject", true)
+
+	ExpectEq(500*time.Millisecond, t.wait(l, "PutObject", 0, 3))
+
+	// Other operations are unaffected.
+	ExpectEq(0, t.wait(l, "GetObject", 0, 4))
+}
+
+func (t *LimiterTest) ThrottlingHasAFloor() {
+	l := t.newLimiter(Config{Default: Limit{RequestsPerSecond: 64}})
+	for i := 0; i < 20; i++ {
+		l.Observe("PutObject", true)
+	}
+
+	ExpectEq(time.Second, t.wait(l, "PutObject", 0, 2))
+}
+
+func (t *LimiterTest) SuccessesRestoreRate() {
+	l := t.newLimiter(Config{Default: Limit{RequestsPerSecond: 4}})
+	l.Observe("PutObject", true)
+	for i := 0; i < 100; i++ {
+		l.Observe("PutObject", false)
+	}
+
+	ExpectEq(250*time.Millisecond, t.wait(l, "PutObject", 0, 5))
+}
+
+func (t *LimiterTest) RealClockDoesNotBlockWhenUnlimited() {
+	l := NewLimiter(Config{})
+	l.Wait("GetObject", 1<<30)
+	l.Observe("GetObject", true)
+	l.Wait("GetObject", 1<<30)
+}
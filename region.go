@@ -0,0 +1,311 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Service identifies an AWS service for the purposes of endpoint lookup.
+type Service string
+
+const (
+	ServiceS3       Service = "s3"
+	ServiceSimpleDB Service = "sdb"
+)
+
+// EndpointVariant selects among the kinds of endpoint a service may offer in
+// a region.
+type EndpointVariant int
+
+const (
+	// The ordinary IPv4-only endpoint.
+	EndpointStandard EndpointVariant = iota
+
+	// An endpoint reachable over both IPv4 and IPv6.
+	EndpointDualStack
+
+	// An endpoint using FIPS 140-2 validated cryptography.
+	EndpointFIPS
+)
+
+// Region describes an AWS region. Use LookupRegion or Regions to obtain one.
+//
+// See here for more info:
+//
+//     http://docs.aws.amazon.com/general/latest/gr/rande.html
+//
+type Region struct {
+	// The region's name, e.g. "eu-west-1". This is also the name used to
+	// scope Signature Version 4 credentials.
+	Name string
+
+	// A human-readable description of the region's location, e.g.
+	// "Europe (Ireland)".
+	Description string
+
+	// The domain under which the region's endpoints live, e.g.
+	// "amazonaws.com".
+	DNSSuffix string
+
+	// Hostnames that differ from the usual pattern, for regions old enough to
+	// predate it.
+	s3Host  string
+	sdbHost string
+
+	hasSimpleDB bool
+	hasFIPS     bool
+}
+
+// All known regions, sorted by name.
+var regions = []Region{
+	Region{Name: "af-south-1", Description: "Africa (Cape Town)"},
+	Region{Name: "ap-east-1", Description: "Asia Pacific (Hong Kong)"},
+	Region{
+		Name:        "ap-northeast-1",
+		Description: "Asia Pacific (Tokyo)",
+		s3Host:      "s3-ap-northeast-1.amazonaws.com",
+		hasSimpleDB: true,
+	},
+	Region{Name: "ap-northeast-2", Description: "Asia Pacific (Seoul)"},
+	Region{Name: "ap-northeast-3", Description: "Asia Pacific (Osaka)"},
+	Region{Name: "ap-south-1", Description: "Asia Pacific (Mumbai)"},
+	Region{Name: "ap-south-2", Description: "Asia Pacific (Hyderabad)"},
+	Region{
+		Name:        "ap-southeast-1",
+		Description: "Asia Pacific (Singapore)",
+		s3Host:      "s3-ap-southeast-1.amazonaws.com",
+		hasSimpleDB: true,
+	},
+	Region{
+		Name:        "ap-southeast-2",
+		Description: "Asia Pacific (Sydney)",
+		s3Host:      "s3-ap-southeast-2.amazonaws.com",
+		hasSimpleDB: true,
+	},
+	Region{Name: "ap-southeast-3", Description: "Asia Pacific (Jakarta)"},
+	Region{Name: "ap-southeast-4", Description: "Asia Pacific (Melbourne)"},
+	Region{Name: "ca-central-1", Description: "Canada (Central)", hasFIPS: true},
+	Region{
+		Name:        "cn-north-1",
+		Description: "China (Beijing)",
+		DNSSuffix:   "amazonaws.com.cn",
+	},
+	Region{
+		Name:        "cn-northwest-1",
+		Description: "China (Ningxia)",
+		DNSSuffix:   "amazonaws.com.cn",
+	},
+	Region{Name: "eu-central-1", Description: "Europe (Frankfurt)"},
+	Region{Name: "eu-central-2", Description: "Europe (Zurich)"},
+	Region{Name: "eu-north-1", Description: "Europe (Stockholm)"},
+	Region{Name: "eu-south-1", Description: "Europe (Milan)"},
+	Region{Name: "eu-south-2", Description: "Europe (Spain)"},
+	Region{
+		Name:        "eu-west-1",
+		Description: "Europe (Ireland)",
+		s3Host:      "s3-eu-west-1.amazonaws.com",
+		hasSimpleDB: true,
+	},
+	Region{Name: "eu-west-2", Description: "Europe (London)"},
+	Region{Name: "eu-west-3", Description: "Europe (Paris)"},
+	Region{Name: "il-central-1", Description: "Israel (Tel Aviv)"},
+	Region{Name: "me-central-1", Description: "Middle East (UAE)"},
+	Region{Name: "me-south-1", Description: "Middle East (Bahrain)"},
+	Region{
+		Name:        "sa-east-1",
+		Description: "South America (Sao Paulo)",
+		s3Host:      "s3-sa-east-1.amazonaws.com",
+		hasSimpleDB: true,
+	},
+	Region{
+		Name:        "us-east-1",
+		Description: "US East (N. Virginia)",
+		s3Host:      "s3.amazonaws.com",
+		sdbHost:     "sdb.amazonaws.com",
+		hasSimpleDB: true,
+		hasFIPS:     true,
+	},
+	Region{Name: "us-east-2", Description: "US East (Ohio)", hasFIPS: true},
+	Region{
+		Name:        "us-gov-east-1",
+		Description: "AWS GovCloud (US-East)",
+		hasFIPS:     true,
+	},
+	Region{
+		Name:        "us-gov-west-1",
+		Description: "AWS GovCloud (US-West)",
+		hasFIPS:     true,
+	},
+	Region{
+		Name:        "us-west-1",
+		Description: "US West (N. California)",
+		s3Host:      "s3-us-west-1.amazonaws.com",
+		hasSimpleDB: true,
+		hasFIPS:     true,
+	},
+	Region{
+		Name:        "us-west-2",
+		Description: "US West (Oregon)",
+		s3Host:      "s3-us-west-2.amazonaws.com",
+		hasSimpleDB: true,
+		hasFIPS:     true,
+	},
+}
+
+func init() {
+	for i := range regions {
+		if regions[i].DNSSuffix == "" {
+			regions[i].DNSSuffix = "amazonaws.com"
+		}
+	}
+}
+
+// Regions returns all known regions, sorted by name.
+func Regions() []Region {
+	result := make([]Region, len(regions))
+	copy(result, regions)
+	return result
+}
+
+// LookupRegion returns the region with the supplied name, e.g. "eu-west-1".
+// Case and surrounding whitespace are ignored.
+func LookupRegion(name string) (r Region, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	i := sort.Search(len(regions), func(i int) bool {
+		return regions[i].Name >= name
+	})
+
+	if i == len(regions) || regions[i].Name != name {
+		err = fmt.Errorf("Unknown region: %q", name)
+		return
+	}
+
+	return regions[i], nil
+}
+
+// Endpoint returns the hostname of the supplied variant of the service's
+// endpoint in the region, or an error if the region doesn't offer it.
+func (r Region) Endpoint(
+	service Service,
+	variant EndpointVariant) (host string, err error) {
+	switch service {
+	case ServiceS3:
+		switch variant {
+		case EndpointStandard:
+			host = r.s3Host
+			if host == "" {
+				host = fmt.Sprintf("s3.%s.%s", r.Name, r.DNSSuffix)
+			}
+
+		case EndpointDualStack:
+			host = fmt.Sprintf("s3.dualstack.%s.%s", r.Name, r.DNSSuffix)
+
+		case EndpointFIPS:
+			if r.hasFIPS {
+				host = fmt.Sprintf("s3-fips.%s.%s", r.Name, r.DNSSuffix)
+			}
+		}
+
+	case ServiceSimpleDB:
+		if r.hasSimpleDB && variant == EndpointStandard {
+			host = r.sdbHost
+			if host == "" {
+				host = fmt.Sprintf("sdb.%s.%s", r.Name, r.DNSSuffix)
+			}
+		}
+
+	default:
+		err = fmt.Errorf("Unknown service: %q", service)
+		return
+	}
+
+	if host == "" {
+		err = fmt.Errorf(
+			"No %s endpoint for %s in region %s",
+			variant,
+			service,
+			r.Name)
+
+		return
+	}
+
+	return
+}
+
+func (v EndpointVariant) String() string {
+	switch v {
+	case EndpointStandard:
+		return "standard"
+	case EndpointDualStack:
+		return "dualstack"
+	case EndpointFIPS:
+		return "FIPS"
+	}
+
+	return fmt.Sprintf("EndpointVariant(%d)", int(v))
+}
+
+// RegionForEndpoint is the inverse of Region.Endpoint, returning the region,
+// service, and variant served by the endpoint with the supplied hostname. Case
+// and any port number are ignored. Older alternative S3 hostnames, such as
+// both s3-eu-west-1.amazonaws.com and s3.eu-west-1.amazonaws.com, are
+// recognized.
+func RegionForEndpoint(host string) (
+	r Region,
+	service Service,
+	variant EndpointVariant,
+	err error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	services := []Service{ServiceS3, ServiceSimpleDB}
+	variants := []EndpointVariant{
+		EndpointStandard,
+		EndpointDualStack,
+		EndpointFIPS,
+	}
+
+	for _, r = range regions {
+		for _, service = range services {
+			for _, variant = range variants {
+				h, endpointErr := r.Endpoint(service, variant)
+				if endpointErr == nil && h == host {
+					return
+				}
+			}
+		}
+
+		// Alternative forms of the standard S3 endpoint.
+		if host == fmt.Sprintf("s3.%s.%s", r.Name, r.DNSSuffix) ||
+			host == fmt.Sprintf("s3-%s.%s", r.Name, r.DNSSuffix) {
+			service = ServiceS3
+			variant = EndpointStandard
+			return
+		}
+	}
+
+	err = fmt.Errorf("Unknown endpoint: %q", host)
+	r = Region{}
+	service = ""
+	variant = EndpointStandard
+	return
+}
@@ -0,0 +1,212 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestRegion(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type RegionTest struct {
+}
+
+func init() { RegisterTestSuite(&RegionTest{}) }
+
+func (t *RegionTest) endpoint(
+	name string,
+	service Service,
+	variant EndpointVariant) (host string, err error) {
+	r, err := LookupRegion(name)
+	AssertEq(nil, err)
+
+	return r.Endpoint(service, variant)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *RegionTest) RegionsAreSorted() {
+	regions := Regions()
+	AssertGt(len(regions), 0)
+
+	for i := 1; i < len(regions); i++ {
+		ExpectLt(regions[i-1].Name, regions[i].Name)
+	}
+}
+
+func (t *RegionTest) RegionsReturnsCopy() {
+	Regions()[0].Name = "taco"
+	ExpectNe("taco", Regions()[0].Name)
+}
+
+func (t *RegionTest) LookupUnknownRegion() {
+	_, err := LookupRegion("eu-taco-1")
+
+	ExpectThat(err, Error(HasSubstr("Unknown region")))
+	ExpectThat(err, Error(HasSubstr("eu-taco-1")))
+}
+
+func (t *RegionTest) LookupEmptyName() {
+	_, err := LookupRegion("")
+	ExpectThat(err, Error(HasSubstr("Unknown region")))
+}
+
+func (t *RegionTest) LookupIgnoresCaseAndWhitespace() {
+	r, err := LookupRegion(" EU-West-1\n")
+	AssertEq(nil, err)
+
+	ExpectEq("eu-west-1", r.Name)
+	ExpectEq("Europe (Ireland)", r.Description)
+	ExpectEq("amazonaws.com", r.DNSSuffix)
+}
+
+func (t *RegionTest) ChinaDNSSuffix() {
+	r, err := LookupRegion("cn-north-1")
+	AssertEq(nil, err)
+	ExpectEq("amazonaws.com.cn", r.DNSSuffix)
+
+	host, err := r.Endpoint(ServiceS3, EndpointStandard)
+	AssertEq(nil, err)
+	ExpectEq("s3.cn-north-1.amazonaws.com.cn", host)
+}
+
+func (t *RegionTest) S3Standard() {
+	host, err := t.endpoint("us-east-1", ServiceS3, EndpointStandard)
+	AssertEq(nil, err)
+	ExpectEq("s3.amazonaws.com", host)
+
+	host, err = t.endpoint("eu-west-1", ServiceS3, EndpointStandard)
+	AssertEq(nil, err)
+	ExpectEq("s3-eu-west-1.amazonaws.com", host)
+
+	host, err = t.endpoint("eu-central-1", ServiceS3, EndpointStandard)
+	AssertEq(nil, err)
+	ExpectEq("s3.eu-central-1.amazonaws.com", host)
+}
+
+func (t *RegionTest) S3DualStack() {
+	host, err := t.endpoint("us-east-1", ServiceS3, EndpointDualStack)
+	AssertEq(nil, err)
+	ExpectEq("s3.dualstack.us-east-1.amazonaws.com", host)
+
+	host, err = t.endpoint("eu-west-1", ServiceS3, EndpointDualStack)
+	AssertEq(nil, err)
+	ExpectEq("s3.dualstack.eu-west-1.amazonaws.com", host)
+}
+
+func (t *RegionTest) S3FIPS() {
+	host, err := t.endpoint("us-west-2", ServiceS3, EndpointFIPS)
+	AssertEq(nil, err)
+	ExpectEq("s3-fips.us-west-2.amazonaws.com", host)
+
+	host, err = t.endpoint("us-gov-west-1", ServiceS3, EndpointFIPS)
+	AssertEq(nil, err)
+	ExpectEq("s3-fips.us-gov-west-1.amazonaws.com", host)
+}
+
+func (t *RegionTest) S3FIPSUnavailable() {
+	_, err := t.endpoint("eu-west-1", ServiceS3, EndpointFIPS)
+
+	ExpectThat(err, Error(HasSubstr("No FIPS endpoint")))
+	ExpectThat(err, Error(HasSubstr("s3")))
+	ExpectThat(err, Error(HasSubstr("eu-west-1")))
+}
+
+func (t *RegionTest) SimpleDBStandard() {
+	host, err := t.endpoint("us-east-1", ServiceSimpleDB, EndpointStandard)
+	AssertEq(nil, err)
+	ExpectEq("sdb.amazonaws.com", host)
+
+	host, err = t.endpoint("sa-east-1", ServiceSimpleDB, EndpointStandard)
+	AssertEq(nil, err)
+	ExpectEq("sdb.sa-east-1.amazonaws.com", host)
+}
+
+func (t *RegionTest) SimpleDBUnavailableInRegion() {
+	_, err := t.endpoint("eu-central-1", ServiceSimpleDB, EndpointStandard)
+
+	ExpectThat(err, Error(HasSubstr("No standard endpoint")))
+	ExpectThat(err, Error(HasSubstr("sdb")))
+	ExpectThat(err, Error(HasSubstr("eu-central-1")))
+}
+
+func (t *RegionTest) SimpleDBDualStack() {
+	_, err := t.endpoint("us-east-1", ServiceSimpleDB, EndpointDualStack)
+	ExpectThat(err, Error(HasSubstr("No dualstack endpoint")))
+}
+
+func (t *RegionTest) UnknownService() {
+	_, err := t.endpoint("us-east-1", Service("taco"), EndpointStandard)
+	ExpectThat(err, Error(HasSubstr("Unknown service")))
+}
+
+func (t *RegionTest) EndpointsRoundTrip() {
+	services := []Service{ServiceS3, ServiceSimpleDB}
+	variants := []EndpointVariant{
+		EndpointStandard,
+		EndpointDualStack,
+		EndpointFIPS,
+	}
+
+	for _, r := range Regions() {
+		for _, service := range services {
+			for _, variant := range variants {
+				host, err := r.Endpoint(service, variant)
+				if err != nil {
+					continue
+				}
+
+				r2, s2, v2, err := RegionForEndpoint(host)
+				AssertEq(nil, err, "%s", host)
+				ExpectEq(r.Name, r2.Name, "%s", host)
+				ExpectEq(service, s2, "%s", host)
+				ExpectEq(variant, v2, "%s", host)
+			}
+		}
+	}
+}
+
+func (t *RegionTest) RegionForAlternativeS3Hostnames() {
+	hosts := []string{
+		"s3.eu-west-1.amazonaws.com",
+		"s3-eu-west-1.amazonaws.com",
+		"S3-EU-WEST-1.amazonaws.com",
+		"s3.eu-west-1.amazonaws.com:443",
+	}
+
+	for _, host := range hosts {
+		r, service, variant, err := RegionForEndpoint(host)
+		AssertEq(nil, err, "%s", host)
+		ExpectEq("eu-west-1", r.Name, "%s", host)
+		ExpectEq(ServiceS3, service, "%s", host)
+		ExpectEq(EndpointStandard, variant, "%s", host)
+	}
+}
+
+func (t *RegionTest) RegionForUnknownEndpoint() {
+	_, _, _, err := RegionForEndpoint("www.example.com")
+
+	ExpectThat(err, Error(HasSubstr("Unknown endpoint")))
+	ExpectThat(err, Error(HasSubstr("www.example.com")))
+}
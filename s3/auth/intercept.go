@@ -0,0 +1,51 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws/s3/http"
+)
+
+// InterceptSigner returns a signer that signs requests with the supplied one,
+// calling the interceptor's BeforeSign and AfterSign hooks around it. Use it
+// together with http.InterceptConn.
+func InterceptSigner(s Signer, i http.Interceptor) Signer {
+	return &interceptedSigner{s, i}
+}
+
+type interceptedSigner struct {
+	wrapped     Signer
+	interceptor http.Interceptor
+}
+
+func (s *interceptedSigner) Sign(r *http.Request) (err error) {
+	if err = s.interceptor.BeforeSign(r); err != nil {
+		err = fmt.Errorf("BeforeSign: %v", err)
+		return
+	}
+
+	if err = s.wrapped.Sign(r); err != nil {
+		return
+	}
+
+	if err = s.interceptor.AfterSign(r); err != nil {
+		err = fmt.Errorf("AfterSign: %v", err)
+		return
+	}
+
+	return
+}
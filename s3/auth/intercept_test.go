@@ -0,0 +1,119 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestIntercept(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A signer that records its calls in a log, and returns the configured error.
+type loggingSigner struct {
+	log *[]string
+	err error
+}
+
+func (s *loggingSigner) Sign(r *http.Request) error {
+	*s.log = append(*s.log, "Sign")
+	r.Headers["Authorization"] = "taco"
+	return s.err
+}
+
+type InterceptSignerTest struct {
+	log         []string
+	wrapped     *loggingSigner
+	interceptor *http.InterceptorFuncs
+	signer      Signer
+
+	// The Authorization header seen by AfterSign.
+	authorization string
+}
+
+func init() { RegisterTestSuite(&InterceptSignerTest{}) }
+
+func (t *InterceptSignerTest) SetUp(i *TestInfo) {
+	t.wrapped = &loggingSigner{log: &t.log}
+	t.interceptor = &http.InterceptorFuncs{
+		BeforeSignFunc: func(r *http.Request) error {
+			t.log = append(t.log, "BeforeSign")
+			return nil
+		},
+
+		AfterSignFunc: func(r *http.Request) error {
+			t.log = append(t.log, "AfterSign")
+			t.authorization = r.Headers["Authorization"]
+			return nil
+		},
+	}
+
+	t.signer = InterceptSigner(t.wrapped, t.interceptor)
+}
+
+func (t *InterceptSignerTest) sign() error {
+	return t.signer.Sign(&http.Request{Headers: map[string]string{}})
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *InterceptSignerTest) CallsHooksAroundSigner() {
+	AssertEq(nil, t.sign())
+
+	ExpectThat(t.log, ElementsAre("BeforeSign", "Sign", "AfterSign"))
+	ExpectEq("taco", t.authorization)
+}
+
+func (t *InterceptSignerTest) BeforeSignReturnsError() {
+	t.interceptor.BeforeSignFunc = func(r *http.Request) error {
+		return errors.New("taco")
+	}
+
+	err := t.sign()
+
+	ExpectThat(err, Error(HasSubstr("BeforeSign")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+	ExpectThat(t.log, ElementsAre())
+}
+
+func (t *InterceptSignerTest) SignerReturnsError() {
+	t.wrapped.err = errors.New("taco")
+
+	err := t.sign()
+
+	ExpectThat(err, Error(Equals("taco")))
+	ExpectThat(t.log, ElementsAre("BeforeSign", "Sign"))
+}
+
+func (t *InterceptSignerTest) AfterSignReturnsError() {
+	t.interceptor.AfterSignFunc = func(r *http.Request) error {
+		return errors.New("taco")
+	}
+
+	err := t.sign()
+
+	ExpectThat(err, Error(HasSubstr("AfterSign")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
@@ -0,0 +1,94 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3/http"
+	"strconv"
+	sys_time "time"
+)
+
+// Presign adds query parameters to the supplied request that authenticate it
+// until the given expiration time without the need for an Authorization
+// header, using the access key returned by the supplied provider. A URL for
+// the resulting request may be handed to a third party, who can then make the
+// request without credentials of their own. If the key is temporary, its
+// session token is included in the parameters.
+//
+// Any Content-MD5, Content-Type, and x-amz-* headers in the request are
+// covered by the signature, and must be sent unchanged with the eventual
+// request.
+//
+// Reference:
+//     http://goo.gl/Z8DiC
+func Presign(
+	r *http.Request,
+	creds aws.CredentialsProvider,
+	expires sys_time.Time) error {
+	key, err := creds.Credentials()
+	if err != nil {
+		return fmt.Errorf("Credentials: %v", err)
+	}
+
+	expiresStr := strconv.FormatInt(expires.Unix(), 10)
+
+	// The string to sign is as for an ordinary request, with the expiration
+	// time in place of the date. The session token, if any, is signed as if it
+	// were a header.
+	headers := make(map[string]string)
+	for name, val := range r.Headers {
+		headers[name] = val
+	}
+
+	headers["Date"] = expiresStr
+	if key.SessionToken != "" {
+		headers[securityTokenHeader] = key.SessionToken
+	}
+
+	toSign, err := stringToSign(
+		&http.Request{
+			Verb:       r.Verb,
+			Path:       r.Path,
+			Parameters: r.Parameters,
+			Headers:    headers,
+		})
+
+	if err != nil {
+		return fmt.Errorf("stringToSign: %v", err)
+	}
+
+	signature, err := sign(key.Secret, toSign)
+	if err != nil {
+		return err
+	}
+
+	// Add the appropriate parameters.
+	if r.Parameters == nil {
+		r.Parameters = make(map[string]string)
+	}
+
+	r.Parameters["AWSAccessKeyId"] = key.Id
+	r.Parameters["Expires"] = expiresStr
+	r.Parameters["Signature"] = signature
+
+	if key.SessionToken != "" {
+		r.Parameters[securityTokenHeader] = key.SessionToken
+	}
+
+	return nil
+}
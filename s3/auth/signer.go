@@ -25,6 +25,9 @@ import (
 	"github.com/jacobsa/aws/s3/http"
 )
 
+// The header carrying the session token of temporary credentials.
+const securityTokenHeader = "x-amz-security-token"
+
 // A Signer knows how to create signatures suitable for inclusion in an HTTP
 // request to S3.
 //
@@ -35,9 +38,10 @@ type Signer interface {
 	Sign(r *http.Request) error
 }
 
-// NewSigner creates a Signer using the supplied access key.
-func NewSigner(key *aws.AccessKey) (Signer, error) {
-	return newSigner(stringToSign, key)
+// NewSigner creates a Signer using the access key returned by the supplied
+// provider, which is consulted for each request.
+func NewSigner(creds aws.CredentialsProvider) (Signer, error) {
+	return newSigner(stringToSign, creds)
 }
 
 // newSigner is a helper used by NewSigner, split out for testability. It
@@ -45,16 +49,30 @@ func NewSigner(key *aws.AccessKey) (Signer, error) {
 // sign for any given request.
 func newSigner(
 	sts func(*http.Request) (string, error),
-	key *aws.AccessKey) (Signer, error) {
-	return &signer{sts, key}, nil
+	creds aws.CredentialsProvider) (Signer, error) {
+	return &signer{sts, creds}, nil
 }
 
 type signer struct {
-	sts func(*http.Request) (string, error)
-	key *aws.AccessKey
+	sts   func(*http.Request) (string, error)
+	creds aws.CredentialsProvider
 }
 
 func (s *signer) Sign(r *http.Request) error {
+	// Find out which key to use.
+	key, err := s.creds.Credentials()
+	if err != nil {
+		return fmt.Errorf("Credentials: %v", err)
+	}
+
+	// Temporary credentials must be accompanied by their session token, which
+	// is covered by the signature.
+	if key.SessionToken != "" {
+		r.Headers[securityTokenHeader] = key.SessionToken
+	} else {
+		delete(r.Headers, securityTokenHeader)
+	}
+
 	// Canonicalize the request.
 	toSign, err := s.sts(r)
 	if err != nil {
@@ -62,9 +80,22 @@ func (s *signer) Sign(r *http.Request) error {
 	}
 
 	// Sign the request.
-	h := hmac.New(sha1.New, []byte(s.key.Secret))
+	signature, err := sign(key.Secret, toSign)
+	if err != nil {
+		return err
+	}
+
+	// Add the appropriate header.
+	r.Headers["Authorization"] = fmt.Sprintf("AWS %s:%s", key.Id, signature)
+
+	return nil
+}
+
+// Return the base64-encoded HMAC-SHA1 signature of the supplied string.
+func sign(secret string, toSign string) (string, error) {
+	h := hmac.New(sha1.New, []byte(secret))
 	if _, err := h.Write([]byte(toSign)); err != nil {
-		return fmt.Errorf("hmac.Write: %v", err)
+		return "", fmt.Errorf("hmac.Write: %v", err)
 	}
 
 	signature := h.Sum(nil)
@@ -73,13 +104,10 @@ func (s *signer) Sign(r *http.Request) error {
 	buf := new(bytes.Buffer)
 	encoder := base64.NewEncoder(base64.StdEncoding, buf)
 	if _, err := encoder.Write(signature); err != nil {
-		return fmt.Errorf("encoder.Write: %v", err)
+		return "", fmt.Errorf("encoder.Write: %v", err)
 	}
 
 	encoder.Close()
 
-	// Add the appropriate header.
-	r.Headers["Authorization"] = fmt.Sprintf("AWS %s:%s", s.key.Id, buf.String())
-
-	return nil
+	return buf.String(), nil
 }
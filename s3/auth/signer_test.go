@@ -21,6 +21,7 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/jacobsa/aws"
 	"github.com/jacobsa/aws/s3/http"
 	. "github.com/jacobsa/oglematchers"
@@ -34,6 +35,24 @@ func TestSigner(t *testing.T) { RunTests(t) }
 // Helpers
 ////////////////////////////////////////////////////////////////////////
 
+// A credentials provider that always fails.
+type failingCredentials struct{}
+
+func (c failingCredentials) Credentials() (aws.AccessKey, error) {
+	return aws.AccessKey{}, errors.New("taco")
+}
+
+// A credentials provider that returns a different key each time.
+type rotatingCredentials struct {
+	calls int
+}
+
+func (c *rotatingCredentials) Credentials() (aws.AccessKey, error) {
+	c.calls++
+	id := fmt.Sprintf("key_%d", c.calls)
+	return aws.AccessKey{Id: id, Secret: "secret"}, nil
+}
+
 type SignerTest struct{}
 
 func init() { RegisterTestSuite(&SignerTest{}) }
@@ -58,6 +77,40 @@ func (t *SignerTest) CallsFunction() {
 	ExpectEq(req, stsArg)
 }
 
+func (t *SignerTest) CredentialsReturnError() {
+	// Function
+	sts := func(r *http.Request) (string, error) { return "", nil }
+
+	// Signer
+	signer, err := newSigner(sts, failingCredentials{})
+	AssertEq(nil, err)
+
+	// Call
+	req := &http.Request{Headers: make(map[string]string)}
+	err = signer.Sign(req)
+
+	ExpectThat(err, Error(HasSubstr("Credentials")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+	ExpectEq("", req.Headers["Authorization"])
+}
+
+func (t *SignerTest) PicksUpRotatedKeys() {
+	// Function
+	sts := func(r *http.Request) (string, error) { return "", nil }
+
+	// Signer
+	signer, err := newSigner(sts, &rotatingCredentials{})
+	AssertEq(nil, err)
+
+	// Call twice
+	req := &http.Request{Headers: make(map[string]string)}
+	AssertEq(nil, signer.Sign(req))
+	ExpectThat(req.Headers["Authorization"], HasSubstr("AWS key_1:"))
+
+	AssertEq(nil, signer.Sign(req))
+	ExpectThat(req.Headers["Authorization"], HasSubstr("AWS key_2:"))
+}
+
 func (t *SignerTest) FunctionReturnsError() {
 	// Function
 	sts := func(r *http.Request) (string, error) { return "", errors.New("taco") }
@@ -109,6 +162,48 @@ func (t *SignerTest) FunctionReturnsString() {
 	ExpectEq(expected, req.Headers["Authorization"])
 }
 
+func (t *SignerTest) AddsSessionToken() {
+	// Function
+	var tokenArg string
+	sts := func(r *http.Request) (string, error) {
+		tokenArg = r.Headers["x-amz-security-token"]
+		return "", nil
+	}
+
+	// Signer
+	key := &aws.AccessKey{Id: "a", Secret: "b", SessionToken: "taco"}
+	signer, err := newSigner(sts, key)
+	AssertEq(nil, err)
+
+	// Call
+	req := &http.Request{Headers: make(map[string]string)}
+	err = signer.Sign(req)
+	AssertEq(nil, err)
+
+	ExpectEq("taco", tokenArg)
+	ExpectEq("taco", req.Headers["x-amz-security-token"])
+}
+
+func (t *SignerTest) RemovesStaleSessionToken() {
+	// Function
+	sts := func(r *http.Request) (string, error) { return "", nil }
+
+	// Signer
+	signer, err := newSigner(sts, &aws.AccessKey{Id: "a", Secret: "b"})
+	AssertEq(nil, err)
+
+	// Call
+	req := &http.Request{
+		Headers: map[string]string{"x-amz-security-token": "taco"},
+	}
+
+	err = signer.Sign(req)
+	AssertEq(nil, err)
+
+	_, ok := req.Headers["x-amz-security-token"]
+	ExpectFalse(ok)
+}
+
 func (t *SignerTest) GoldenTests() {
 	type testCase struct {
 		stringToSign        string
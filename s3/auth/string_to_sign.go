@@ -20,8 +20,30 @@ import (
 	"fmt"
 	"github.com/jacobsa/aws/s3/http"
 	"net/url"
+	"sort"
+	"strings"
 )
 
+// Request parameters that name sub-resources, and must therefore be included
+// in the string to sign.
+var subResources = map[string]bool{
+	"acl":            true,
+	"lifecycle":      true,
+	"location":       true,
+	"logging":        true,
+	"notification":   true,
+	"partNumber":     true,
+	"policy":         true,
+	"requestPayment": true,
+	"torrent":        true,
+	"uploadId":       true,
+	"uploads":        true,
+	"versionId":      true,
+	"versioning":     true,
+	"versions":       true,
+	"website":        true,
+}
+
 // Given an HTTP request, return the string that should be signed for that
 // request. The request must include a `Date` header.
 //
@@ -39,14 +61,53 @@ func stringToSign(r *http.Request) (string, error) {
 	contentMd5 := r.Headers["Content-MD5"]
 	contentType := r.Headers["Content-Type"]
 
-	// We don't yet support CanonicalizedAmzHeaders.
+	// Headers beginning with x-amz- (such as x-amz-security-token) must be
+	// included, with lower-case names, sorted by name.
+	var amzNames []string
+	amzValues := make(map[string]string)
+	for name, val := range r.Headers {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			amzNames = append(amzNames, lower)
+			amzValues[lower] = strings.TrimSpace(val)
+		}
+	}
+
+	sort.Strings(amzNames)
+
 	canonicalizedAmzHeaders := ""
+	for _, name := range amzNames {
+		canonicalizedAmzHeaders += name + ":" + amzValues[name] + "\n"
+	}
 
 	// Amazon's signing algorithm is weird -- it requires URL encoding for paths,
 	// but not query parameters. Luckily we currently only support simple
 	// path-style requests.
 	canonicalizedResource := (&url.URL{Path: r.Path}).RequestURI()
 
+	// Parameters that name sub-resources must be included, sorted by name.
+	var subResourceNames []string
+	for name := range r.Parameters {
+		if subResources[name] {
+			subResourceNames = append(subResourceNames, name)
+		}
+	}
+
+	sort.Strings(subResourceNames)
+
+	for i, name := range subResourceNames {
+		if i == 0 {
+			canonicalizedResource += "?"
+		} else {
+			canonicalizedResource += "&"
+		}
+
+		canonicalizedResource += name
+		if val := r.Parameters[name]; val != "" {
+			canonicalizedResource += "=" + val
+		}
+	}
+
 	// Put everything together.
 	return fmt.Sprintf(
 		"%s\n%s\n%s\n%s\n%s%s",
@@ -246,3 +246,118 @@ func (t *StringToSignTest) ComplicatedRequest() {
 				"some_date\n"+
 				"/foo/bar/baz"))
 }
+
+func (t *StringToSignTest) IgnoresOrdinaryParameters() {
+	// Request
+	req := &http.Request{
+		Verb: "GET",
+		Path: "/foo",
+		Headers: map[string]string{
+			"Date": "some_date",
+		},
+		Parameters: map[string]string{
+			"marker":   "taco",
+			"max-keys": "17",
+		},
+	}
+
+	// Call
+	s, err := stringToSign(req)
+	AssertEq(nil, err)
+
+	ExpectThat(
+		s,
+		Equals(
+			"GET\n"+
+				"\n"+ // Content-MD5
+				"\n"+ // Content-Type
+				"some_date\n"+
+				"/foo"))
+}
+
+func (t *StringToSignTest) IncludesSubResources() {
+	// Request
+	req := &http.Request{
+		Verb: "PUT",
+		Path: "/foo/bar/baz",
+		Headers: map[string]string{
+			"Date": "some_date",
+		},
+		Parameters: map[string]string{
+			"uploadId":   "taco",
+			"partNumber": "17",
+			"marker":     "burrito",
+		},
+	}
+
+	// Call
+	s, err := stringToSign(req)
+	AssertEq(nil, err)
+
+	ExpectThat(
+		s,
+		Equals(
+			"PUT\n"+
+				"\n"+ // Content-MD5
+				"\n"+ // Content-Type
+				"some_date\n"+
+				"/foo/bar/baz?partNumber=17&uploadId=taco"))
+}
+
+func (t *StringToSignTest) SubResourceWithoutValue() {
+	// Request
+	req := &http.Request{
+		Verb: "POST",
+		Path: "/foo/bar/baz",
+		Headers: map[string]string{
+			"Date": "some_date",
+		},
+		Parameters: map[string]string{
+			"uploads": "",
+		},
+	}
+
+	// Call
+	s, err := stringToSign(req)
+	AssertEq(nil, err)
+
+	ExpectThat(
+		s,
+		Equals(
+			"POST\n"+
+				"\n"+ // Content-MD5
+				"\n"+ // Content-Type
+				"some_date\n"+
+				"/foo/bar/baz?uploads"))
+}
+
+func (t *StringToSignTest) IncludesAmzHeaders() {
+	// Request
+	req := &http.Request{
+		Verb: "PUT",
+		Path: "/foo/bar/baz",
+		Headers: map[string]string{
+			"Date":                 "some_date",
+			"X-Amz-Meta-Taco":      "  burrito ",
+			"x-amz-security-token": "some_token",
+			"x-amz-acl":            "public-read",
+			"X-Not-Amz":            "enchilada",
+		},
+	}
+
+	// Call
+	s, err := stringToSign(req)
+	AssertEq(nil, err)
+
+	ExpectThat(
+		s,
+		Equals(
+			"PUT\n"+
+				"\n"+ // Content-MD5
+				"\n"+ // Content-Type
+				"some_date\n"+
+				"x-amz-acl:public-read\n"+
+				"x-amz-meta-taco:burrito\n"+
+				"x-amz-security-token:some_token\n"+
+				"/foo/bar/baz"))
+}
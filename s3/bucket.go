@@ -28,6 +28,7 @@ import (
 	"io"
 	sys_http "net/http"
 	"net/url"
+	"sync"
 	sys_time "time"
 	"unicode/utf8"
 )
@@ -46,7 +47,12 @@ import (
 //     http://goo.gl/csem8
 //
 type Bucket interface {
-	// Retrieve data for the object with the given key.
+	// Retrieve data for the object with the given key. The data is checked
+	// against the Content-Length and ETag sent by the server, and an
+	// *IntegrityError returned if it doesn't match. The ETag is used only
+	// where it is the MD5 of the object's contents, so objects assembled from
+	// multipart uploads or encrypted with KMS or customer-supplied keys are
+	// checked for length only.
 	GetObject(key string) (data []byte, err error)
 
 	// Retrieve headr information for the object with the given key.
@@ -63,6 +69,17 @@ type Bucket interface {
 	// version. The object is created with the default ACL of "private".
 	Put(key string, data io.ReadSeeker) error
 
+	// Return a writer that streams data of unknown length to the object with
+	// the given key. Data is buffered into parts of MinPartSize bytes, each
+	// uploaded as part of a multipart upload once full and checked against the
+	// MD5 computed as it was written. The object is committed, overwriting any
+	// previous version, when the writer is closed; objects no bigger than a
+	// single part are stored with one request at that point.
+	//
+	// If any write or the close fails, the upload is aborted and the object is
+	// left untouched. Errors in the key are reported by Write or Close.
+	NewWriter(key string) io.WriteCloser
+
 	// Return an ordered set of contiguous object keys in the bucket that are
 	// strictly greater than prevKey (or at the beginning of the range if prevKey
 	// is empty). It is guaranteed that as some time during the request there
@@ -80,48 +97,213 @@ type Bucket interface {
 	// prevKey must be a valid key, with the sole exception that it is allowed to
 	// be the empty string.
 	ListKeys(prevKey string) (keys []string, err error)
+
+	// Like ListKeys, but return only objects whose keys begin with prefix,
+	// along with their sizes and modification times.
+	//
+	// If delimiter is non-empty, keys that contain it after the prefix are
+	// rolled up: rather than the objects themselves, the portion of their keys
+	// up to and including the first such occurrence of the delimiter is
+	// returned once among prefixes. With a delimiter of "/" this lists a
+	// single level of a directory-like hierarchy.
+	//
+	// To continue listing, pass the greater of the last key and the last
+	// prefix returned as prevKey. No keys with a prefix that has already been
+	// returned are listed again.
+	ListObjects(
+		prefix string,
+		delimiter string,
+		prevKey string) (objects []ObjectInfo, prefixes []string, err error)
+
+	// Retrieve up to length bytes of data for the object with the given key,
+	// starting at the given offset. Fewer bytes are returned if the object ends
+	// first. It is an error for the offset to be at or past the end of a
+	// non-empty object.
+	GetObjectRange(key string, offset int64, length int64) (data []byte, err error)
+
+	// Like GetObjectRange, but fail with a 412 PreconditionFailed error unless
+	// the object's ETag (as returned by GetHeader) is etag. This makes sure that
+	// ranges read with separate requests come from the same version of the
+	// object.
+	GetObjectRangeIfMatch(
+		key string,
+		offset int64,
+		length int64,
+		etag string) (data []byte, err error)
+
+	// Begin a multipart upload to the given key, returning an ID to be passed
+	// to the other multipart upload methods. The object doesn't exist until the
+	// upload is completed.
+	InitiateMultipartUpload(key string) (uploadId string, err error)
+
+	// Upload one part of a multipart upload. Part numbers start at 1 and may be
+	// no greater than MaxParts; uploading a part number again replaces the
+	// previous data. Every part but the last must be at least MinPartSize
+	// bytes long. The returned ETag must be passed to CompleteMultipartUpload.
+	UploadPart(
+		key string,
+		uploadId string,
+		partNumber int,
+		data []byte) (etag string, err error)
+
+	// Finish a multipart upload, assembling the supplied parts (which must be
+	// in increasing order of part number) into an object. Any previous version
+	// of the object is overwritten.
+	CompleteMultipartUpload(key string, uploadId string, parts []Part) error
+
+	// Abandon a multipart upload, discarding any parts already uploaded.
+	AbortMultipartUpload(key string, uploadId string) error
+
+	// Return an ordered set of in-progress multipart uploads, starting after
+	// the upload with the given key and ID. Uploads are ordered by key, and
+	// then by ID. If prevUploadId is empty, listing starts with the first
+	// upload whose key is strictly greater than prevKey. As with ListKeys,
+	// there may be more uploads beyond those returned, and an empty result
+	// means there are no more.
+	ListMultipartUploads(
+		prevKey string,
+		prevUploadId string) (uploads []MultipartUpload, err error)
 }
 
 // OpenBucket returns a Bucket tied to a given name in a given region. You must
-// have previously created the bucket in the region, and the supplied access
-// key must have access to it.
+// have previously created the bucket in the region, and the access key
+// returned by the supplied provider must have access to it. An aws.AccessKey
+// may be passed directly, or use aws.DefaultCredentials to find one in the
+// environment.
+//
+// If the bucket turns out to live in another region, S3's redirect is followed
+// and the bucket's region remembered for subsequent requests.
+//
+// Any interceptors supplied see every request the bucket sends, in the manner
+// of http.Chain.
 //
 // To easily create a bucket, use the AWS Console:
 //
 //     https://console.aws.amazon.com/s3/
 //
-func OpenBucket(name string, region Region, key aws.AccessKey) (Bucket, error) {
-	// Create a connection to the given region's endpoint.
+func OpenBucket(
+	name string,
+	region Region,
+	creds aws.CredentialsProvider,
+	interceptors ...http.Interceptor) (Bucket, error) {
 	endpoint := &url.URL{Scheme: "https", Host: string(region)}
-	httpConn, err := http.NewConn(endpoint)
+	return OpenBucketAtEndpoint(name, endpoint, creds, interceptors...)
+}
+
+// OpenBucketAtEndpoint is like OpenBucket, but talks to the server at the
+// supplied endpoint rather than one of the regional endpoints. Both http and
+// https endpoints are supported. This is mostly useful for testing against a
+// fake server, such as the one in package s3test.
+func OpenBucketAtEndpoint(
+	name string,
+	endpoint *url.URL,
+	creds aws.CredentialsProvider,
+	interceptors ...http.Interceptor) (Bucket, error) {
+	opts := BucketOptions{Interceptors: interceptors}
+	return OpenBucketWithOptions(name, endpoint, creds, opts)
+}
+
+// Options for OpenBucketWithOptions. The zero value gives the behavior of
+// OpenBucketAtEndpoint with no interceptors.
+type BucketOptions struct {
+	// Interceptors that see every request the bucket sends, in the manner of
+	// http.Chain.
+	Interceptors []http.Interceptor
+
+	// If non-nil, reads made with GetObject, GetObjectRange and GetHeader are
+	// hedged according to this policy.
+	Hedging *HedgePolicy
+}
+
+// OpenBucketWithOptions is like OpenBucketAtEndpoint, but accepts options that
+// don't fit in its argument list.
+func OpenBucketWithOptions(
+	name string,
+	endpoint *url.URL,
+	creds aws.CredentialsProvider,
+	opts BucketOptions) (Bucket, error) {
+	chain := http.Chain(opts.Interceptors)
+	clock := time.RealClock()
+
+	// Create a connection to the endpoint, and a way to connect to others.
+	newConn := func(endpoint *url.URL) (http.Conn, error) {
+		c, err := http.NewConn(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		return http.InterceptConn(c, chain, clock), nil
+	}
+
+	httpConn, err := newConn(endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("http.NewConn: %v", err)
 	}
 
 	// Create an appropriate request signer.
-	signer, err := auth.NewSigner(&key)
+	signer, err := auth.NewSigner(creds)
 	if err != nil {
 		return nil, fmt.Errorf("auth.NewSigner: %v", err)
 	}
 
-	return openBucket(name, httpConn, signer, time.RealClock())
+	signer = auth.InterceptSigner(signer, chain)
+
+	return openBucket(
+		name,
+		endpoint,
+		httpConn,
+		newConn,
+		signer,
+		chain,
+		opts.Hedging,
+		clock)
 }
 
 // A version of OpenBucket with the ability to inject dependencies, for
-// testability.
+// testability. newConn is used to connect to the endpoint a redirect points
+// at. interceptor is told about retries; its other hooks must already be
+// applied by the connections and signer. hedging may be nil.
 func openBucket(
 	name string,
+	endpoint *url.URL,
 	httpConn http.Conn,
+	newConn func(endpoint *url.URL) (http.Conn, error),
 	signer auth.Signer,
+	interceptor http.Interceptor,
+	hedging *HedgePolicy,
 	clock time.Clock) (Bucket, error) {
-	return &bucket{name, httpConn, signer, clock}, nil
+	b := &bucket{
+		name:        name,
+		newConn:     newConn,
+		signer:      signer,
+		interceptor: interceptor,
+		clock:       time.NewSkewAdjustingClock(clock),
+		endpoint:    endpoint,
+		httpConn:    httpConn,
+	}
+
+	if hedging != nil {
+		b.hedger = newHedger(*hedging)
+	}
+
+	return b, nil
 }
 
 type bucket struct {
-	name     string
-	httpConn http.Conn
-	signer   auth.Signer
-	clock    time.Clock
+	name        string
+	newConn     func(endpoint *url.URL) (http.Conn, error)
+	signer      auth.Signer
+	interceptor http.Interceptor
+	clock       time.SkewAdjustingClock
+
+	// Non-nil if reads are hedged.
+	hedger *hedger
+
+	// The endpoint requests are sent to, and a connection to it. These change
+	// when the server permanently redirects us to the bucket's region.
+	mutex    sync.Mutex
+	endpoint *url.URL  // Protected by mutex
+	httpConn http.Conn // Protected by mutex
 }
 
 ////////////////////////////////////////////////////////////////////////
@@ -205,12 +387,155 @@ func addMd5Header(r *http.Request, body []byte) error {
 	return nil
 }
 
+// The body of an error response from S3, as far as we need it.
+//
+// Reference:
+//     http://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
+type errorDocument struct {
+	Code string
+}
+
+// ServerError is returned by the methods of Bucket when S3 responds to a
+// request with an error. The buckets returned by NewMemBucket and
+// NewFileBucket return it in the same situations.
+type ServerError struct {
+	// The HTTP status code, e.g. 404.
+	StatusCode int
+
+	// The S3 error code, e.g. "NoSuchKey", or the empty string if the response
+	// didn't contain one.
+	Code string
+
+	// A description of the error, such as the body of the response.
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("Error from server: %d %s", e.StatusCode, e.Message)
+}
+
+// IsRetryable returns true if the supplied error, returned by a method of
+// Bucket, is likely to be temporary: the request couldn't be sent or its
+// response couldn't be read, S3 reported an internal error or asked us to
+// slow down, or the data received was corrupt. Other errors, such as a
+// missing key, a denied request, or a failed precondition, will happen again
+// if the request is repeated.
+func IsRetryable(err error) bool {
+	switch err := err.(type) {
+	case *ServerError:
+		return err.StatusCode >= 500 ||
+			err.Code == "InternalError" ||
+			err.Code == "SlowDown"
+
+	case *http.Error, *IntegrityError:
+		return true
+	}
+
+	return false
+}
+
+// Return a *ServerError for the supplied error document, which S3 sent with
+// the given status code.
+func parseServerError(statusCode int, body []byte) *ServerError {
+	e := &ServerError{StatusCode: statusCode, Message: string(body)}
+
+	var doc errorDocument
+	if xml.Unmarshal(body, &doc) == nil {
+		e.Code = doc.Code
+	}
+
+	return e
+}
+
 func serverError(httpResp *http.Response) (err error) {
 	body, readErr := httpResp.ReadBody()
 	if readErr != nil {
 		return readErr
 	}
-	return fmt.Errorf("Error from server: %d %s", httpResp.StatusCode, body)
+	return parseServerError(httpResp.StatusCode, body)
+}
+
+// Sign the request and send it to the bucket's endpoint. If the server
+// responds that the bucket lives in another region, the request is re-signed
+// and sent there instead, and permanent redirects are remembered for future
+// requests. If the server says that our clock is skewed, the clock is
+// corrected and the request re-stamped, re-signed, and sent again once.
+//
+// A response that can't be acted on, for example because the new endpoint
+// can't be determined or the request body can't be rewound, is returned as
+// is.
+func (b *bucket) sendRequest(r *http.Request) (resp *http.Response, err error) {
+	b.mutex.Lock()
+	endpoint := b.endpoint
+	httpConn := b.httpConn
+	b.mutex.Unlock()
+
+	redirects := 0
+	correctedSkew := false
+
+	for {
+		// Sign the request.
+		if err = b.signer.Sign(r); err != nil {
+			err = fmt.Errorf("Sign: %v", err)
+			return
+		}
+
+		// Send the request.
+		resp, err = httpConn.SendRequest(r)
+		if err != nil {
+			err = &http.Error{Operation: "SendRequest", OriginalErr: err}
+			return
+		}
+
+		// We can send the request again only if we can rewind its body.
+		seeker, seekable := r.Body.(io.Seeker)
+		if r.Body != nil && !seekable {
+			return
+		}
+
+		// Should we?
+		switch {
+		case isRedirect(resp) && redirects < maxRedirects:
+			newEndpoint := b.redirectEndpoint(endpoint, resp)
+			if newEndpoint == nil {
+				return
+			}
+
+			resp.Body.Close()
+			redirects++
+
+			endpoint = newEndpoint
+			if httpConn, err = b.newConn(endpoint); err != nil {
+				err = fmt.Errorf("http.NewConn: %v", err)
+				return
+			}
+
+			if resp.StatusCode == 301 {
+				b.mutex.Lock()
+				b.endpoint = endpoint
+				b.httpConn = httpConn
+				b.mutex.Unlock()
+			}
+
+		case !correctedSkew && b.correctSkew(resp):
+			resp.Body.Close()
+			correctedSkew = true
+
+			r.Headers["Date"] = b.clock.Now().UTC().Format(sys_time.RFC1123)
+
+		default:
+			return
+		}
+
+		if seekable {
+			if _, err = seeker.Seek(0, 0); err != nil {
+				err = fmt.Errorf("Seek: %v", err)
+				return
+			}
+		}
+
+		b.interceptor.BeforeRetry(r)
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////
@@ -235,15 +560,10 @@ func (b *bucket) GetObject(key string) (data []byte, err error) {
 		},
 	}
 
-	// Sign the request.
-	if err := b.signer.Sign(httpReq); err != nil {
-		return nil, fmt.Errorf("Sign: %v", err)
-	}
-
-	// Send the request.
-	httpResp, err := b.httpConn.SendRequest(httpReq)
+	// Sign and send the request.
+	httpResp, err := b.sendHedged(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("SendRequest: %v", err)
+		return nil, err
 	}
 
 	// Check the response.
@@ -256,9 +576,95 @@ func (b *bucket) GetObject(key string) (data []byte, err error) {
 		return
 	}
 
+	// Make sure the data is what the server meant to send.
+	if err = verifyObject(key, httpResp.Header, data); err != nil {
+		return nil, err
+	}
+
 	return
 }
 
+////////////////////////////////////////////////////////////////////////
+// GetObjectRange
+////////////////////////////////////////////////////////////////////////
+
+func (b *bucket) GetObjectRange(
+	key string,
+	offset int64,
+	length int64) (data []byte, err error) {
+	return b.GetObjectRangeIfMatch(key, offset, length, "")
+}
+
+func (b *bucket) GetObjectRangeIfMatch(
+	key string,
+	offset int64,
+	length int64,
+	etag string) (data []byte, err error) {
+	// Validate the key and range.
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("Invalid range: %d, %d", offset, length)
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTObjectGET.html
+	httpReq := &http.Request{
+		Verb: "GET",
+		Path: fmt.Sprintf("/%s/%s", b.name, key),
+		Headers: map[string]string{
+			"Date":  b.clock.Now().UTC().Format(sys_time.RFC1123),
+			"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1),
+		},
+	}
+
+	if etag != "" {
+		httpReq.Headers["If-Match"] = etag
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendHedged(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the response. A server may ignore the Range header and return the
+	// entire object with a 200 (S3 does so for empty objects), in which case we
+	// find the range ourselves.
+	if httpResp.StatusCode != 206 && httpResp.StatusCode != 200 {
+		return nil, serverError(httpResp)
+	}
+
+	data, err = httpResp.ReadBody()
+	if err != nil {
+		return
+	}
+
+	if httpResp.StatusCode == 200 {
+		data = sliceRange(data, offset, length)
+	}
+
+	return
+}
+
+// Return the portion of data within the supplied range, clipped to its end.
+func sliceRange(data []byte, offset int64, length int64) []byte {
+	if offset >= int64(len(data)) {
+		return []byte{}
+	}
+
+	end := offset + length
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+
+	return data[offset:end]
+}
+
 ////////////////////////////////////////////////////////////////////////
 // GetHeader
 ////////////////////////////////////////////////////////////////////////
@@ -281,15 +687,10 @@ func (b *bucket) GetHeader(key string) (header sys_http.Header, err error) {
 		},
 	}
 
-	// Sign the request.
-	if err := b.signer.Sign(httpReq); err != nil {
-		return nil, fmt.Errorf("Sign: %v", err)
-	}
-
-	// Send the request.
-	httpResp, err := b.httpConn.SendRequest(httpReq)
+	// Sign and send the request.
+	httpResp, err := b.sendHedged(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("SendRequest: %v", err)
+		return nil, err
 	}
 
 	// Check the response.
@@ -297,6 +698,9 @@ func (b *bucket) GetHeader(key string) (header sys_http.Header, err error) {
 		return httpResp.Header, serverError(httpResp)
 	}
 
+	// The body is empty, but closing it releases the request.
+	httpResp.Body.Close()
+
 	return httpResp.Header, nil
 }
 
@@ -320,7 +724,7 @@ func (b *bucket) StoreObject(key string, data []byte) error {
 		Headers: map[string]string{
 			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
 		},
-		Body: bytes.NewBuffer(data),
+		Body: bytes.NewReader(data),
 	}
 
 	// Add a Content-MD5 header, as advised in the Amazon docs.
@@ -328,15 +732,10 @@ func (b *bucket) StoreObject(key string, data []byte) error {
 		return err
 	}
 
-	// Sign the request.
-	if err := b.signer.Sign(httpReq); err != nil {
-		return fmt.Errorf("Sign: %v", err)
-	}
-
-	// Send the request.
-	httpResp, err := b.httpConn.SendRequest(httpReq)
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
 	if err != nil {
-		return fmt.Errorf("SendRequest: %v", err)
+		return err
 	}
 
 	// Check the response.
@@ -374,15 +773,10 @@ func (b *bucket) DeleteObject(key string) error {
 		return err
 	}
 
-	// Sign the request.
-	if err := b.signer.Sign(httpReq); err != nil {
-		return fmt.Errorf("Sign: %v", err)
-	}
-
-	// Send the request.
-	httpResp, err := b.httpConn.SendRequest(httpReq)
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
 	if err != nil {
-		return fmt.Errorf("SendRequest: %v", err)
+		return err
 	}
 
 	// Check the response.
@@ -424,15 +818,10 @@ func (b *bucket) Put(key string, data io.ReadSeeker) error {
 		Body: data,
 	}
 
-	// Sign the request.
-	if err := b.signer.Sign(httpReq); err != nil {
-		return fmt.Errorf("Sign: %v", err)
-	}
-
-	// Send the request.
-	httpResp, err := b.httpConn.SendRequest(httpReq)
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
 	if err != nil {
-		return fmt.Errorf("SendRequest: %v", err)
+		return err
 	}
 
 	// Check the response.
@@ -443,17 +832,33 @@ func (b *bucket) Put(key string, data io.ReadSeeker) error {
 	return nil
 }
 
+////////////////////////////////////////////////////////////////////////
+// NewWriter
+////////////////////////////////////////////////////////////////////////
+
+func (b *bucket) NewWriter(key string) io.WriteCloser {
+	return newObjectWriter(b, key)
+}
+
 ////////////////////////////////////////////////////////////////////////
 // ListKeys
 ////////////////////////////////////////////////////////////////////////
 
 type bucketContents struct {
-	Key string
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+type commonPrefix struct {
+	Prefix string
 }
 
 type listBucketResult struct {
-	XMLName  xml.Name
-	Contents []bucketContents
+	XMLName        xml.Name
+	Contents       []bucketContents
+	CommonPrefixes []commonPrefix
 }
 
 func (b *bucket) ListKeys(prevKey string) (keys []string, err error) {
@@ -479,15 +884,10 @@ func (b *bucket) ListKeys(prevKey string) (keys []string, err error) {
 		httpReq.Parameters["marker"] = prevKey
 	}
 
-	// Sign the request.
-	if err := b.signer.Sign(httpReq); err != nil {
-		return nil, fmt.Errorf("Sign: %v", err)
-	}
-
-	// Send the request.
-	httpResp, err := b.httpConn.SendRequest(httpReq)
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("SendRequest: %v", err)
+		return nil, err
 	}
 
 	// Check the response.
@@ -0,0 +1,183 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3/http"
+	"net/url"
+	"regexp"
+	sys_time "time"
+)
+
+// The names S3 accepts for new buckets.
+//
+// Reference:
+//     http://docs.aws.amazon.com/AmazonS3/latest/dev/BucketRestrictions.html
+var bucketNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// CreateBucket creates a bucket with the given name in the given region,
+// using the supplied credentials. Bucket names are global, so this fails if
+// anybody already owns a bucket with the name.
+func CreateBucket(
+	name string,
+	region Region,
+	creds aws.CredentialsProvider) error {
+	location, err := region.Name()
+	if err != nil {
+		return err
+	}
+
+	// Buckets in the original region are created without a location
+	// constraint.
+	if location == "us-east-1" {
+		location = ""
+	}
+
+	endpoint := &url.URL{Scheme: "https", Host: string(region)}
+	return CreateBucketAtEndpoint(name, endpoint, location, creds)
+}
+
+// CreateBucketAtEndpoint is like CreateBucket, but talks to the server at the
+// supplied endpoint, as with OpenBucketAtEndpoint. The bucket is created in
+// the named region (e.g. "eu-west-1"), or in the endpoint's default region if
+// location is empty.
+func CreateBucketAtEndpoint(
+	name string,
+	endpoint *url.URL,
+	location string,
+	creds aws.CredentialsProvider) error {
+	if !bucketNameRe.MatchString(name) {
+		return fmt.Errorf("Invalid bucket name: %q", name)
+	}
+
+	b, err := OpenBucketAtEndpoint(name, endpoint, creds)
+	if err != nil {
+		return err
+	}
+
+	return b.(*bucket).create(location)
+}
+
+// DeleteBucket deletes the bucket with the given name in the given region,
+// using the supplied credentials. The bucket must be empty.
+func DeleteBucket(
+	name string,
+	region Region,
+	creds aws.CredentialsProvider) error {
+	endpoint := &url.URL{Scheme: "https", Host: string(region)}
+	return DeleteBucketAtEndpoint(name, endpoint, creds)
+}
+
+// DeleteBucketAtEndpoint is like DeleteBucket, but talks to the server at the
+// supplied endpoint, as with OpenBucketAtEndpoint.
+func DeleteBucketAtEndpoint(
+	name string,
+	endpoint *url.URL,
+	creds aws.CredentialsProvider) error {
+	b, err := OpenBucketAtEndpoint(name, endpoint, creds)
+	if err != nil {
+		return err
+	}
+
+	return b.(*bucket).delete()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket methods
+////////////////////////////////////////////////////////////////////////
+
+type createBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	Xmlns              string   `xml:"xmlns,attr"`
+	LocationConstraint string
+}
+
+// Create the bucket in the named region, or the endpoint's default region if
+// location is empty.
+func (b *bucket) create(location string) error {
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketPUT.html
+	httpReq := &http.Request{
+		Verb: "PUT",
+		Path: fmt.Sprintf("/%s", b.name),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+	}
+
+	if location != "" {
+		body, err := xml.Marshal(createBucketConfiguration{
+			Xmlns:              "http://s3.amazonaws.com/doc/2006-03-01/",
+			LocationConstraint: location,
+		})
+
+		if err != nil {
+			return fmt.Errorf("xml.Marshal: %v", err)
+		}
+
+		httpReq.Body = bytes.NewReader(body)
+		if err := addMd5Header(httpReq, body); err != nil {
+			return err
+		}
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 200 {
+		return serverError(httpResp)
+	}
+
+	return nil
+}
+
+// Delete the bucket, which must be empty.
+func (b *bucket) delete() error {
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketDELETE.html
+	httpReq := &http.Request{
+		Verb: "DELETE",
+		Path: fmt.Sprintf("/%s", b.name),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 204 {
+		return serverError(httpResp)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	"net/url"
+	"testing"
+)
+
+func TestBucketAdmin(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// CreateBucket
+////////////////////////////////////////////////////////////////////////
+
+type CreateBucketTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&CreateBucketTest{}) }
+
+func (t *CreateBucketTest) InvalidName() {
+	endpoint := &url.URL{Scheme: "https", Host: "s3.amazonaws.com"}
+	key := aws.AccessKey{Id: "id", Secret: "secret"}
+
+	// Call
+	err := CreateBucketAtEndpoint("Taco_Burrito", endpoint, "", key)
+
+	ExpectThat(err, Error(HasSubstr("Invalid bucket name")))
+	ExpectThat(err, Error(HasSubstr("Taco_Burrito")))
+}
+
+func (t *CreateBucketTest) NoLocation() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.(*bucket).create("")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("PUT", httpReq.Verb)
+	ExpectEq("/some.bucket", httpReq.Path)
+	ExpectEq(nil, httpReq.Body)
+}
+
+func (t *CreateBucketTest) Location() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.(*bucket).create("eu-west-1")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("PUT", httpReq.Verb)
+	ExpectEq("/some.bucket", httpReq.Path)
+	ExpectNe("", httpReq.Headers["Content-MD5"])
+
+	body, err := ioutil.ReadAll(httpReq.Body)
+	AssertEq(nil, err)
+	ExpectThat(
+		string(body),
+		HasSubstr("<LocationConstraint>eu-west-1</LocationConstraint>"))
+}
+
+func (t *CreateBucketTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 409,
+		Body:       stringReadCloser("BucketAlreadyExists"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.(*bucket).create("")
+
+	ExpectThat(err, Error(HasSubstr("409")))
+	ExpectThat(err, Error(HasSubstr("BucketAlreadyExists")))
+}
+
+func (t *CreateBucketTest) ServerSaysOk() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       stringReadCloser(""),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.(*bucket).create("")
+
+	ExpectEq(nil, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// DeleteBucket
+////////////////////////////////////////////////////////////////////////
+
+type DeleteBucketTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&DeleteBucketTest{}) }
+
+func (t *DeleteBucketTest) CallsSigner() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.(*bucket).delete()
+
+	AssertNe(nil, httpReq)
+	ExpectEq("DELETE", httpReq.Verb)
+	ExpectEq("/some.bucket", httpReq.Path)
+}
+
+func (t *DeleteBucketTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 409,
+		Body:       stringReadCloser("BucketNotEmpty"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.(*bucket).delete()
+
+	ExpectThat(err, Error(HasSubstr("409")))
+	ExpectThat(err, Error(HasSubstr("BucketNotEmpty")))
+}
+
+func (t *DeleteBucketTest) ServerSaysNoContent() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 204,
+		Body:       stringReadCloser(""),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.(*bucket).delete()
+
+	ExpectEq(nil, err)
+}
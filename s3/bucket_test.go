@@ -23,12 +23,14 @@ import (
 	"github.com/jacobsa/aws/s3/auth/mock"
 	"github.com/jacobsa/aws/s3/http"
 	"github.com/jacobsa/aws/s3/http/mock"
+	aws_time "github.com/jacobsa/aws/time"
 	. "github.com/jacobsa/oglematchers"
 	"github.com/jacobsa/oglemock"
 	. "github.com/jacobsa/ogletest"
 	"io"
 	"io/ioutil"
 	sys_http "net/http"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -56,32 +58,54 @@ func computeBase64Md5(d []byte) string {
 	return buf.String()
 }
 
-type fakeClock struct {
-	now time.Time
-}
+type bucketTest struct {
+	endpoint  *url.URL
+	httpConn  mock_http.MockConn
+	otherConn mock_http.MockConn
+	signer    mock_auth.MockSigner
+	bucket    Bucket
+	clock     *aws_time.SimulatedClock
 
-func (c *fakeClock) Now() time.Time {
-	return c.now
-}
+	// Told about retries, which it records in retried.
+	interceptor http.Interceptor
+	retried     []*http.Request
 
-type bucketTest struct {
-	httpConn mock_http.MockConn
-	signer   mock_auth.MockSigner
-	bucket   Bucket
-	clock    *fakeClock
+	// The endpoints for which the bucket has asked for a connection, which is
+	// always otherConn.
+	newConnEndpoints []*url.URL
 }
 
 func (t *bucketTest) SetUp(i *TestInfo) {
 	var err error
 
+	t.endpoint = &url.URL{Scheme: "https", Host: "s3.amazonaws.com"}
 	t.httpConn = mock_http.NewMockConn(i.MockController, "httpConn")
+	t.otherConn = mock_http.NewMockConn(i.MockController, "otherConn")
 	t.signer = mock_auth.NewMockSigner(i.MockController, "signer")
-	t.clock = &fakeClock{}
+	t.clock = aws_time.NewSimulatedClock(time.Time{})
+	t.interceptor = &http.InterceptorFuncs{
+		BeforeRetryFunc: func(r *http.Request) {
+			t.retried = append(t.retried, r)
+		},
+	}
 
-	t.bucket, err = openBucket("some.bucket", t.httpConn, t.signer, t.clock)
+	t.bucket, err = openBucket(
+		"some.bucket",
+		t.endpoint,
+		t.httpConn,
+		t.newConn,
+		t.signer,
+		t.interceptor,
+		nil,
+		t.clock)
 	AssertEq(nil, err)
 }
 
+func (t *bucketTest) newConn(endpoint *url.URL) (http.Conn, error) {
+	t.newConnEndpoints = append(t.newConnEndpoints, endpoint)
+	return t.otherConn, nil
+}
+
 func stringReadCloser(s string) io.ReadCloser {
 	return ioutil.NopCloser(strings.NewReader(s))
 }
@@ -147,7 +171,7 @@ func (t *GetObjectTest) CallsSigner() {
 	key := "foo/bar/baz"
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -247,6 +271,71 @@ func (t *GetObjectTest) ServerReturnsError() {
 	ExpectThat(err, Error(HasSubstr("taco")))
 }
 
+func (t *GetObjectTest) ServerReturnsErrorCode() {
+	key := "a"
+
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	body := "<Error><Code>NoSuchKey</Code><Message>taco</Message></Error>"
+	resp := &http.Response{
+		StatusCode: 404,
+		Body:       stringReadCloser(body),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.GetObject(key)
+
+	serverErr, ok := err.(*ServerError)
+	AssertTrue(ok, "%v", err)
+	ExpectEq(404, serverErr.StatusCode)
+	ExpectEq("NoSuchKey", serverErr.Code)
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *GetObjectTest) ServerErrorIsRetryable() {
+	key := "a"
+
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(nil, errors.New("taco"))).
+		WillOnce(oglemock.Return(
+		&http.Response{StatusCode: 503, Body: stringReadCloser("")},
+		nil)).
+		WillOnce(oglemock.Return(
+		&http.Response{
+			StatusCode: 404,
+			Body:       stringReadCloser("<Error><Code>NoSuchKey</Code></Error>"),
+		},
+		nil))
+
+	// Transport errors, 5xx responses and requests to slow down are worth
+	// retrying; a missing key isn't.
+	_, err := t.bucket.GetObject(key)
+	ExpectTrue(IsRetryable(err), "%v", err)
+
+	_, err = t.bucket.GetObject(key)
+	ExpectTrue(IsRetryable(err), "%v", err)
+
+	_, err = t.bucket.GetObject(key)
+	ExpectFalse(IsRetryable(err), "%v", err)
+
+	// S3 may also ask us to slow down after sending a 200 status.
+	err = parseServerError(200, []byte("<Error><Code>SlowDown</Code></Error>"))
+	ExpectTrue(IsRetryable(err), "%v", err)
+
+	ExpectFalse(IsRetryable(errors.New("taco")))
+}
+
 func (t *GetObjectTest) ReturnsResponseBody() {
 	key := "a"
 
@@ -270,6 +359,262 @@ func (t *GetObjectTest) ReturnsResponseBody() {
 	ExpectThat(data, DeepEquals([]byte("taco")))
 }
 
+// Call GetObject with a server response containing the given headers and
+// body.
+func (t *GetObjectTest) getWithHeaders(
+	header sys_http.Header,
+	body string) ([]byte, error) {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       stringReadCloser(body),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	return t.bucket.GetObject("a")
+}
+
+func (t *GetObjectTest) BodyIsTruncated() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "5")
+
+	_, err := t.getWithHeaders(header, "taco")
+
+	_, ok := err.(*IntegrityError)
+	ExpectTrue(ok, "%v", err)
+	ExpectThat(err, Error(HasSubstr("got 4 bytes; expected 5")))
+}
+
+func (t *GetObjectTest) ContentLengthIsJunk() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "taco")
+
+	_, err := t.getWithHeaders(header, "taco")
+
+	ExpectThat(err, Error(HasSubstr("invalid Content-Length")))
+}
+
+func (t *GetObjectTest) ETagDoesntMatch() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "4")
+	header.Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+
+	_, err := t.getWithHeaders(header, "taco")
+
+	_, ok := err.(*IntegrityError)
+	ExpectTrue(ok, "%v", err)
+	ExpectThat(err, Error(HasSubstr("f869ce1c8414a264bb11e14a2c8850ed")))
+	ExpectThat(err, Error(HasSubstr("doesn't match ETag")))
+}
+
+func (t *GetObjectTest) ETagMatches() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "4")
+	header.Set("ETag", `"f869ce1c8414a264bb11e14a2c8850ed"`)
+
+	data, err := t.getWithHeaders(header, "taco")
+
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *GetObjectTest) MultipartETagIsNotChecked() {
+	header := sys_http.Header{}
+	header.Set("ETag", `"0123456789abcdef0123456789abcdef-2"`)
+
+	data, err := t.getWithHeaders(header, "taco")
+
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *GetObjectTest) MultipartObjectIsCheckedForLength() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "5")
+	header.Set("ETag", `"0123456789abcdef0123456789abcdef-2"`)
+
+	_, err := t.getWithHeaders(header, "taco")
+
+	_, ok := err.(*IntegrityError)
+	ExpectTrue(ok, "%v", err)
+	ExpectThat(err, Error(HasSubstr("got 4 bytes; expected 5")))
+}
+
+func (t *GetObjectTest) KmsEncryptedETagIsNotChecked() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "4")
+	header.Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+	header.Set("x-amz-server-side-encryption", "aws:kms")
+
+	data, err := t.getWithHeaders(header, "taco")
+
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *GetObjectTest) CustomerEncryptedETagIsNotChecked() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "4")
+	header.Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+	header.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+
+	data, err := t.getWithHeaders(header, "taco")
+
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *GetObjectTest) S3EncryptedETagIsChecked() {
+	header := sys_http.Header{}
+	header.Set("Content-Length", "4")
+	header.Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+	header.Set("x-amz-server-side-encryption", "AES256")
+
+	_, err := t.getWithHeaders(header, "taco")
+
+	_, ok := err.(*IntegrityError)
+	ExpectTrue(ok, "%v", err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// GetObjectRange
+////////////////////////////////////////////////////////////////////////
+
+type GetObjectRangeTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&GetObjectRangeTest{}) }
+
+func (t *GetObjectRangeTest) KeyIsEmpty() {
+	// Call
+	_, err := t.bucket.GetObjectRange("", 0, 1)
+
+	ExpectThat(err, Error(HasSubstr("empty")))
+}
+
+func (t *GetObjectRangeTest) InvalidRange() {
+	// Call
+	_, err := t.bucket.GetObjectRange("a", 0, 0)
+	ExpectThat(err, Error(HasSubstr("Invalid range")))
+
+	_, err = t.bucket.GetObjectRange("a", -1, 1)
+	ExpectThat(err, Error(HasSubstr("Invalid range")))
+}
+
+func (t *GetObjectRangeTest) CallsSigner() {
+	key := "foo/bar/baz"
+
+	// Clock
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
+
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.GetObjectRange(key, 17, 10)
+
+	AssertNe(nil, httpReq)
+	ExpectEq("GET", httpReq.Verb)
+	ExpectEq("/some.bucket/foo/bar/baz", httpReq.Path)
+	ExpectEq("Mon, 18 Mar 1985 15:33:17 UTC", httpReq.Headers["Date"])
+	ExpectEq("bytes=17-26", httpReq.Headers["Range"])
+}
+
+func (t *GetObjectRangeTest) SendsIfMatch() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.GetObjectRangeIfMatch("a", 17, 10, "\"taco\"")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("bytes=17-26", httpReq.Headers["Range"])
+	ExpectEq("\"taco\"", httpReq.Headers["If-Match"])
+}
+
+func (t *GetObjectRangeTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 416,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.GetObjectRange("a", 0, 1)
+
+	ExpectThat(err, Error(HasSubstr("server")))
+	ExpectThat(err, Error(HasSubstr("416")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *GetObjectRangeTest) ReturnsPartialContent() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 206,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	data, err := t.bucket.GetObjectRange("a", 100, 4)
+	AssertEq(nil, err)
+
+	ExpectThat(data, DeepEquals([]byte("taco")))
+}
+
+func (t *GetObjectRangeTest) ServerIgnoresRange() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       stringReadCloser("tacoburrito"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	data, err := t.bucket.GetObjectRange("a", 4, 3)
+	AssertEq(nil, err)
+
+	ExpectThat(data, DeepEquals([]byte("bur")))
+}
+
 ////////////////////////////////////////////////////////////////////////
 // GetHeader
 ////////////////////////////////////////////////////////////////////////
@@ -331,7 +676,7 @@ func (t *GetHeaderTest) CallsSigner() {
 	key := "foo/bar/baz"
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -442,6 +787,7 @@ func (t *GetHeaderTest) ReturnsResponseHeader() {
 	resp := &http.Response{
 		StatusCode: 200,
 		Header:     sys_http.Header{"x-amz-expiration": {"foobar"}},
+		Body:       stringReadCloser(""),
 	}
 
 	ExpectCall(t.httpConn, "SendRequest")(Any()).
@@ -521,7 +867,7 @@ func (t *StoreObjectTest) CallsSigner() {
 	data := []byte{0x00, 0xde, 0xad, 0xbe, 0xef}
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -715,7 +1061,7 @@ func (t *DeleteObjectTest) CallsSigner() {
 	key := "taco burrito"
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -904,7 +1250,7 @@ func (t *PutTest) CallsSigner() {
 	data := bytes.NewReader(content)
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -1089,7 +1435,7 @@ func (t *ListKeysTest) CallsSignerWithEmptyMin() {
 	prevKey := ""
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -1115,7 +1461,7 @@ func (t *ListKeysTest) CallsSignerWithNonEmptyMin() {
 	prevKey := "taco burrito"
 
 	// Clock
-	t.clock.now = time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC)
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
 
 	// Signer
 	var httpReq *http.Request
@@ -0,0 +1,589 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"github.com/jacobsa/aws/time"
+	"io"
+	"io/ioutil"
+	sys_http "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	sys_time "time"
+)
+
+// Suffixes for the files that make up a stored object: one containing the
+// object's data, and a sidecar containing its metadata.
+const (
+	fileDataSuffix = ".data"
+	fileMetaSuffix = ".meta"
+)
+
+// The maximum length of a single file name on common file systems.
+const maxFileNameLen = 255
+
+// NewFileBucket returns a Bucket that stores its objects as files within the
+// supplied directory, which must already exist. This is useful for running
+// code that uses S3 on a machine with no AWS credentials.
+//
+// Each object is stored as a pair of files: one containing the data and a
+// sidecar containing metadata such as the MD5 hash and modification time.
+// File names are derived from keys by escaping any byte other than a
+// lower-case ASCII letter, digit, '-', '_', or non-leading '.', so keys may
+// contain '/' and the layout works on case-insensitive file systems. Writes
+// go to a temporary file that is then atomically renamed into place.
+//
+// Because of file name length limits, keys whose escaped form is too long are
+// rejected. Otherwise the bucket applies the same key validation as the one
+// returned by OpenBucket, lists keys in the same order with the same page
+// size, and returns 404 errors for missing keys.
+func NewFileBucket(dir string) (Bucket, error) {
+	return newFileBucket(dir, time.RealClock())
+}
+
+// A version of NewFileBucket with the ability to inject dependencies, for
+// testability.
+func newFileBucket(dir string, clock time.Clock) (Bucket, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Stat: %v", err)
+	}
+
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("Not a directory: %s", dir)
+	}
+
+	return &fileBucket{dir: dir, clock: clock}, nil
+}
+
+type fileBucket struct {
+	dir   string
+	clock time.Clock
+
+	// In-progress multipart uploads are held in memory, and are lost if the
+	// process exits.
+	uploads localUploads
+
+	// Held for reading while reading objects and for writing while modifying
+	// them, so that data and metadata are seen consistently within this
+	// process.
+	mutex sync.RWMutex
+}
+
+// The contents of a metadata sidecar file.
+type fileMetadata struct {
+	MD5          []byte
+	LastModified sys_time.Time
+}
+
+////////////////////////////////////////////////////////////////////////
+// Common
+////////////////////////////////////////////////////////////////////////
+
+func isUnescapedFileByte(c byte, i int) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= '0' && c <= '9') ||
+		c == '-' ||
+		c == '_' ||
+		(c == '.' && i > 0)
+}
+
+// Return the escaped file name stem for the supplied key. Escaped names never
+// begin with a '.', so they can't collide with temporary files.
+func escapeKey(key string) string {
+	const hex = "0123456789ABCDEF"
+
+	var buf []byte
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if isUnescapedFileByte(c, i) {
+			buf = append(buf, c)
+			continue
+		}
+
+		buf = append(buf, '%', hex[c>>4], hex[c&0xF])
+	}
+
+	return string(buf)
+}
+
+func unhex(c byte) (b byte, ok bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+// Invert escapeKey, returning false if the input is not a legal escaped key.
+func unescapeKey(name string) (key string, ok bool) {
+	var buf []byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '%' {
+			buf = append(buf, c)
+			continue
+		}
+
+		if i+2 >= len(name) {
+			return "", false
+		}
+
+		hi, ok0 := unhex(name[i+1])
+		lo, ok1 := unhex(name[i+2])
+		if !ok0 || !ok1 {
+			return "", false
+		}
+
+		buf = append(buf, hi<<4|lo)
+		i += 2
+	}
+
+	return string(buf), true
+}
+
+// Validate the supplied key, returning the path stem for its files.
+func (b *fileBucket) pathForKey(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	name := escapeKey(key)
+	if len(name)+len(fileDataSuffix) > maxFileNameLen {
+		return "", fmt.Errorf("Key is too long to store in a file: %q", key)
+	}
+
+	return filepath.Join(b.dir, name), nil
+}
+
+// Atomically replace the file at the supplied path with the given contents.
+func (b *fileBucket) writeFile(path string, r io.Reader) error {
+	f, err := ioutil.TempFile(b.dir, ".tmp")
+	if err != nil {
+		return fmt.Errorf("TempFile: %v", err)
+	}
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("Writing %s: %v", f.Name(), err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("Rename: %v", err)
+	}
+
+	return nil
+}
+
+func (b *fileBucket) readMetadata(path string) (md *fileMetadata, err error) {
+	contents, err := ioutil.ReadFile(path + fileMetaSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	md = &fileMetadata{}
+	if err := json.Unmarshal(contents, md); err != nil {
+		return nil, fmt.Errorf("Invalid metadata in %s: %v", path, err)
+	}
+
+	return md, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket methods
+////////////////////////////////////////////////////////////////////////
+
+func (b *fileBucket) GetObject(key string) (data []byte, err error) {
+	path, err := b.pathForKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	data, err = ioutil.ReadFile(path + fileDataSuffix)
+	if os.IsNotExist(err) {
+		return nil, noSuchKeyError(key)
+	} else if err != nil {
+		return nil, fmt.Errorf("ReadFile: %v", err)
+	}
+
+	return
+}
+
+func (b *fileBucket) GetHeader(key string) (header sys_http.Header, err error) {
+	path, err := b.pathForKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	fi, err := os.Stat(path + fileDataSuffix)
+	if os.IsNotExist(err) {
+		return sys_http.Header{}, noSuchKeyError(key)
+	} else if err != nil {
+		return nil, fmt.Errorf("Stat: %v", err)
+	}
+
+	md, err := b.readMetadata(path)
+	if err != nil {
+		return nil, fmt.Errorf("readMetadata: %v", err)
+	}
+
+	header = localHeader(fi.Size(), md.MD5, md.LastModified)
+	return
+}
+
+func (b *fileBucket) StoreObject(key string, data []byte) error {
+	return b.Put(key, bytes.NewReader(data))
+}
+
+func (b *fileBucket) DeleteObject(key string) error {
+	path, err := b.pathForKey(key)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Like S3, deleting a non-existent object is not an error. Remove the data
+	// file first so that the object disappears from listings immediately.
+	for _, suffix := range []string{fileDataSuffix, fileMetaSuffix} {
+		err := os.Remove(path + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Remove: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (b *fileBucket) Put(key string, data io.ReadSeeker) error {
+	path, err := b.pathForKey(key)
+	if err != nil {
+		return err
+	}
+
+	// Compute the MD5 hash of the data, then rewind.
+	if _, err := data.Seek(0, 0); err != nil {
+		return fmt.Errorf("Seek: %v", err)
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, data); err != nil {
+		return fmt.Errorf("io.Copy: %v", err)
+	}
+
+	if _, err := data.Seek(0, 0); err != nil {
+		return fmt.Errorf("Seek: %v", err)
+	}
+
+	md := fileMetadata{
+		MD5:          hash.Sum(nil),
+		LastModified: b.clock.Now().UTC(),
+	}
+
+	mdBytes, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Write the metadata first, so that an object that appears in listings
+	// always has a sidecar. Keep the old metadata (if any) so that it can be
+	// put back if the data can't be written.
+	oldMdBytes, err := ioutil.ReadFile(path + fileMetaSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ReadFile: %v", err)
+	}
+
+	err = b.writeFile(path+fileMetaSuffix, bytes.NewReader(mdBytes))
+	if err != nil {
+		return err
+	}
+
+	if err := b.writeFile(path+fileDataSuffix, data); err != nil {
+		b.restoreMetadata(path, oldMdBytes)
+		return err
+	}
+
+	return nil
+}
+
+// Put back the metadata that an object had before a failed write, removing it
+// if there was none. There's not much we can do if this fails.
+//
+// REQUIRES: b.mutex is held
+func (b *fileBucket) restoreMetadata(path string, oldMdBytes []byte) {
+	if oldMdBytes == nil {
+		os.Remove(path + fileMetaSuffix)
+		return
+	}
+
+	b.writeFile(path+fileMetaSuffix, bytes.NewReader(oldMdBytes))
+}
+
+func (b *fileBucket) NewWriter(key string) io.WriteCloser {
+	return newObjectWriter(b, key)
+}
+
+// Return the keys of all objects in the bucket, in no particular order.
+//
+// REQUIRES: b.mutex is held for reading
+func (b *fileBucket) allKeys() (keys []string, err error) {
+	d, err := os.Open(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %v", err)
+	}
+
+	defer d.Close()
+
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("Readdirnames: %v", err)
+	}
+
+	// Find the data files, ignoring temporary files and anything else that
+	// doesn't look like one of ours.
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") ||
+			!strings.HasSuffix(name, fileDataSuffix) {
+			continue
+		}
+
+		key, ok := unescapeKey(strings.TrimSuffix(name, fileDataSuffix))
+		if !ok {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return
+}
+
+func (b *fileBucket) ListKeys(prevKey string) (keys []string, err error) {
+	// Make sure the previous key is empty or valid.
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	all, err := b.allKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	keys = localListPage(all, prevKey)
+	return
+}
+
+func (b *fileBucket) ListObjects(
+	prefix string,
+	delimiter string,
+	prevKey string) (objects []ObjectInfo, prefixes []string, err error) {
+	// Make sure the prefix and previous key are empty or valid.
+	if err := validateKey(prefix); err != nil && prefix != "" {
+		return nil, nil, err
+	}
+
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	all, err := b.allKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := func(key string) (o ObjectInfo, err error) {
+		path := filepath.Join(b.dir, escapeKey(key))
+
+		fi, err := os.Stat(path + fileDataSuffix)
+		if err != nil {
+			return o, fmt.Errorf("Stat: %v", err)
+		}
+
+		md, err := b.readMetadata(path)
+		if err != nil {
+			return o, fmt.Errorf("readMetadata: %v", err)
+		}
+
+		o = ObjectInfo{
+			Key:          key,
+			Size:         fi.Size(),
+			LastModified: md.LastModified,
+			ETag:         fmt.Sprintf("\"%x\"", md.MD5),
+		}
+
+		return
+	}
+
+	return localListObjects(all, prefix, delimiter, prevKey, info)
+}
+
+func (b *fileBucket) GetObjectRange(
+	key string,
+	offset int64,
+	length int64) (data []byte, err error) {
+	return b.GetObjectRangeIfMatch(key, offset, length, "")
+}
+
+func (b *fileBucket) GetObjectRangeIfMatch(
+	key string,
+	offset int64,
+	length int64,
+	etag string) (data []byte, err error) {
+	path, err := b.pathForKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("Invalid range: %d, %d", offset, length)
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	f, err := os.Open(path + fileDataSuffix)
+	if os.IsNotExist(err) {
+		return nil, noSuchKeyError(key)
+	} else if err != nil {
+		return nil, fmt.Errorf("Open: %v", err)
+	}
+
+	defer f.Close()
+
+	if etag != "" {
+		md, err := b.readMetadata(path)
+		if err != nil {
+			return nil, fmt.Errorf("readMetadata: %v", err)
+		}
+
+		if fmt.Sprintf("\"%x\"", md.MD5) != etag {
+			return nil, preconditionFailedError(key)
+		}
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Stat: %v", err)
+	}
+
+	size := fi.Size()
+	if size > 0 && offset >= size {
+		return nil, invalidRangeError(offset, int(size))
+	}
+
+	if offset+length > size {
+		length = size - offset
+	}
+
+	if length <= 0 {
+		return []byte{}, nil
+	}
+
+	data = make([]byte, length)
+	if _, err := f.ReadAt(data, offset); err != nil {
+		return nil, fmt.Errorf("ReadAt: %v", err)
+	}
+
+	return
+}
+
+func (b *fileBucket) InitiateMultipartUpload(
+	key string) (uploadId string, err error) {
+	if _, err := b.pathForKey(key); err != nil {
+		return "", err
+	}
+
+	return b.uploads.initiate(key, b.clock.Now()), nil
+}
+
+func (b *fileBucket) UploadPart(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte) (etag string, err error) {
+	if _, err := b.pathForKey(key); err != nil {
+		return "", err
+	}
+
+	return b.uploads.uploadPart(key, uploadId, partNumber, data)
+}
+
+func (b *fileBucket) CompleteMultipartUpload(
+	key string,
+	uploadId string,
+	parts []Part) error {
+	if _, err := b.pathForKey(key); err != nil {
+		return err
+	}
+
+	data, err := b.uploads.complete(key, uploadId, parts)
+	if err != nil {
+		return err
+	}
+
+	return b.StoreObject(key, data)
+}
+
+func (b *fileBucket) AbortMultipartUpload(key string, uploadId string) error {
+	if _, err := b.pathForKey(key); err != nil {
+		return err
+	}
+
+	return b.uploads.abort(key, uploadId)
+}
+
+func (b *fileBucket) ListMultipartUploads(
+	prevKey string,
+	prevUploadId string) (uploads []MultipartUpload, err error) {
+	// Make sure the previous key is empty or valid.
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, err
+	}
+
+	uploads = b.uploads.list(prevKey, prevUploadId)
+	return
+}
@@ -0,0 +1,383 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"fmt"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileBucket(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A reader whose contents can be read once, after which reads fail. This
+// lets Put hash the data but not write it.
+type readOnceReader struct {
+	r      *strings.Reader
+	passes int
+}
+
+func (r *readOnceReader) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == 0 {
+		r.passes++
+	}
+
+	return r.r.Seek(offset, whence)
+}
+
+func (r *readOnceReader) Read(p []byte) (int, error) {
+	if r.passes > 1 {
+		return 0, errors.New("taco")
+	}
+
+	return r.r.Read(p)
+}
+
+type FileBucketTest struct {
+	dir    string
+	clock  *aws_time.SimulatedClock
+	bucket Bucket
+}
+
+func init() { RegisterTestSuite(&FileBucketTest{}) }
+
+func (t *FileBucketTest) SetUp(i *TestInfo) {
+	var err error
+
+	t.dir, err = ioutil.TempDir("", "file_bucket_test")
+	AssertEq(nil, err)
+
+	t.clock = aws_time.NewSimulatedClock(time.Time{})
+	t.bucket, err = newFileBucket(t.dir, t.clock)
+	AssertEq(nil, err)
+}
+
+func (t *FileBucketTest) TearDown() {
+	os.RemoveAll(t.dir)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *FileBucketTest) DirectoryDoesntExist() {
+	_, err := NewFileBucket(filepath.Join(t.dir, "foo"))
+	ExpectThat(err, Error(HasSubstr("no such file")))
+}
+
+func (t *FileBucketTest) NotADirectory() {
+	path := filepath.Join(t.dir, "foo")
+	AssertEq(nil, ioutil.WriteFile(path, []byte{}, 0600))
+
+	_, err := NewFileBucket(path)
+	ExpectThat(err, Error(HasSubstr("Not a directory")))
+}
+
+func (t *FileBucketTest) EscapeKey() {
+	keys := []string{
+		"taco",
+		"foo/bar/baz",
+		".hidden",
+		"..",
+		"Taco",
+		"100%",
+		"ünicode",
+		"a.data",
+	}
+
+	for _, key := range keys {
+		name := escapeKey(key)
+		ExpectFalse(strings.HasPrefix(name, "."), "Key: %q", key)
+		ExpectFalse(strings.Contains(name, "/"), "Key: %q", key)
+
+		unescaped, ok := unescapeKey(name)
+		ExpectTrue(ok, "Key: %q", key)
+		ExpectEq(key, unescaped)
+	}
+
+	ExpectEq("foo%2Fbar", escapeKey("foo/bar"))
+	ExpectEq("%54aco", escapeKey("Taco"))
+}
+
+func (t *FileBucketTest) InvalidKeys() {
+	keys := []string{
+		"",
+		"\x80\x81\x82",
+		strings.Repeat("a", 1025),
+		"taco\x00burrito",
+	}
+
+	for _, key := range keys {
+		_, err := t.bucket.GetObject(key)
+		ExpectNe(nil, err, "Key: %q", key)
+
+		err = t.bucket.StoreObject(key, []byte{})
+		ExpectNe(nil, err, "Key: %q", key)
+
+		err = t.bucket.DeleteObject(key)
+		ExpectNe(nil, err, "Key: %q", key)
+	}
+}
+
+func (t *FileBucketTest) KeyTooLongForFileSystem() {
+	key := strings.Repeat("/", 100)
+
+	err := t.bucket.StoreObject(key, []byte{})
+	ExpectThat(err, Error(HasSubstr("too long")))
+}
+
+func (t *FileBucketTest) GetNonExistentObject() {
+	_, err := t.bucket.GetObject("taco")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	_, err = t.bucket.GetHeader("taco")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *FileBucketTest) StoreThenGet() {
+	AssertEq(nil, t.bucket.StoreObject("foo/bar", []byte("taco")))
+
+	returned, err := t.bucket.GetObject("foo/bar")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(returned))
+}
+
+func (t *FileBucketTest) KeysDifferingOnlyInCase() {
+	AssertEq(nil, t.bucket.StoreObject("taco", []byte("lower")))
+	AssertEq(nil, t.bucket.StoreObject("TACO", []byte("upper")))
+
+	returned, err := t.bucket.GetObject("taco")
+	AssertEq(nil, err)
+	ExpectEq("lower", string(returned))
+
+	returned, err = t.bucket.GetObject("TACO")
+	AssertEq(nil, err)
+	ExpectEq("upper", string(returned))
+}
+
+func (t *FileBucketTest) PutThenGetHeader() {
+	t.clock.SetTime(time.Date(2012, 8, 15, 22, 56, 0, 0, time.Local))
+
+	err := t.bucket.Put("some_key", strings.NewReader("taco"))
+	AssertEq(nil, err)
+
+	header, err := t.bucket.GetHeader("some_key")
+	AssertEq(nil, err)
+
+	ExpectEq("4", header.Get("Content-Length"))
+	ExpectEq("\"f869ce1c8414a264bb11e14a2c8850ed\"", header.Get("ETag"))
+	ExpectEq(
+		t.clock.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"),
+		header.Get("Last-Modified"))
+}
+
+func (t *FileBucketTest) ObjectsPersistAcrossBuckets() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("taco")))
+
+	bucket, err := NewFileBucket(t.dir)
+	AssertEq(nil, err)
+
+	returned, err := bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(returned))
+
+	header, err := bucket.GetHeader("some_key")
+	AssertEq(nil, err)
+	ExpectEq("\"f869ce1c8414a264bb11e14a2c8850ed\"", header.Get("ETag"))
+}
+
+func (t *FileBucketTest) OverwriteLeavesNoTemporaryFiles() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("taco")))
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("burrito")))
+
+	returned, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(returned))
+
+	entries, err := ioutil.ReadDir(t.dir)
+	AssertEq(nil, err)
+
+	var names []string
+	for _, fi := range entries {
+		names = append(names, fi.Name())
+	}
+
+	ExpectThat(names, ElementsAre("some_key.data", "some_key.meta"))
+}
+
+func (t *FileBucketTest) FailedOverwriteKeepsMetadata() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("taco")))
+
+	r := &readOnceReader{r: strings.NewReader("burrito")}
+	err := t.bucket.Put("some_key", r)
+	ExpectThat(err, Error(HasSubstr("taco")))
+
+	returned, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(returned))
+
+	header, err := t.bucket.GetHeader("some_key")
+	AssertEq(nil, err)
+	ExpectEq("\"f869ce1c8414a264bb11e14a2c8850ed\"", header.Get("ETag"))
+}
+
+func (t *FileBucketTest) FailedPutLeavesNoFiles() {
+	r := &readOnceReader{r: strings.NewReader("burrito")}
+	err := t.bucket.Put("some_key", r)
+	ExpectThat(err, Error(HasSubstr("taco")))
+
+	entries, err := ioutil.ReadDir(t.dir)
+	AssertEq(nil, err)
+	ExpectEq(0, len(entries))
+}
+
+func (t *FileBucketTest) Delete() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("taco")))
+	AssertEq(nil, t.bucket.DeleteObject("some_key"))
+
+	_, err := t.bucket.GetObject("some_key")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	// Deleting again should succeed, as with S3.
+	ExpectEq(nil, t.bucket.DeleteObject("some_key"))
+}
+
+func (t *FileBucketTest) ListKeysInOrder() {
+	for _, key := range []string{"taco", "a/b", "a.meta", "Queso"} {
+		AssertEq(nil, t.bucket.StoreObject(key, []byte{}))
+	}
+
+	// Stray files should be ignored.
+	stray := filepath.Join(t.dir, ".tmp123")
+	AssertEq(nil, ioutil.WriteFile(stray, []byte{}, 0600))
+
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("Queso", "a.meta", "a/b", "taco"))
+
+	keys, err = t.bucket.ListKeys("a.meta")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("a/b", "taco"))
+}
+
+func (t *FileBucketTest) ListKeysPaginates() {
+	const numKeys = 1500
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("%08d", i)
+		AssertEq(nil, t.bucket.StoreObject(key, []byte{}))
+	}
+
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	AssertEq(1000, len(keys))
+	ExpectEq("00000999", keys[999])
+
+	keys, err = t.bucket.ListKeys(keys[999])
+	AssertEq(nil, err)
+	AssertEq(500, len(keys))
+	ExpectEq("00001000", keys[0])
+	ExpectEq("00001499", keys[499])
+}
+
+func (t *FileBucketTest) ListObjects() {
+	now := time.Date(2012, time.August, 15, 22, 56, 1, 0, time.UTC)
+	t.clock.SetTime(now)
+
+	toCreate := []string{"a/b/c", "a/d", "a/b/e", "f", "a/g"}
+	for _, key := range toCreate {
+		AssertEq(nil, t.bucket.StoreObject(key, []byte("taco")))
+	}
+
+	objects, prefixes, err := t.bucket.ListObjects("a/", "/", "")
+	AssertEq(nil, err)
+	AssertEq(2, len(objects))
+	ExpectEq("a/d", objects[0].Key)
+	ExpectEq(4, objects[0].Size)
+	ExpectNe("", objects[0].ETag)
+	ExpectTrue(objects[0].LastModified.Equal(now))
+	ExpectEq("a/g", objects[1].Key)
+	ExpectThat(prefixes, ElementsAre("a/b/"))
+
+	objects, prefixes, err = t.bucket.ListObjects("", "/", "a/")
+	AssertEq(nil, err)
+	AssertEq(1, len(objects))
+	ExpectEq("f", objects[0].Key)
+	ExpectThat(prefixes, ElementsAre())
+}
+
+func (t *FileBucketTest) GetObjectRange() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("tacoburrito")))
+
+	data, err := t.bucket.GetObjectRange("some_key", 4, 3)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	data, err = t.bucket.GetObjectRange("some_key", 8, 100)
+	AssertEq(nil, err)
+	ExpectEq("ito", string(data))
+
+	_, err = t.bucket.GetObjectRange("some_key", 11, 1)
+	ExpectThat(err, Error(HasSubstr("416")))
+
+	_, err = t.bucket.GetObjectRange("other_key", 0, 1)
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *FileBucketTest) GetObjectRangeIfMatch() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("tacoburrito")))
+
+	header, err := t.bucket.GetHeader("some_key")
+	AssertEq(nil, err)
+	etag := header.Get("ETag")
+
+	data, err := t.bucket.GetObjectRangeIfMatch("some_key", 4, 3, etag)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("enchilada")))
+
+	_, err = t.bucket.GetObjectRangeIfMatch("some_key", 4, 3, etag)
+	ExpectThat(err, Error(HasSubstr("412")))
+	ExpectThat(err, Error(HasSubstr("PreconditionFailed")))
+}
+
+func (t *FileBucketTest) MultipartUpload() {
+	uploadId, err := t.bucket.InitiateMultipartUpload("some_key")
+	AssertEq(nil, err)
+
+	etag, err := t.bucket.UploadPart("some_key", uploadId, 1, []byte("taco"))
+	AssertEq(nil, err)
+
+	parts := []Part{Part{PartNumber: 1, ETag: etag}}
+	err = t.bucket.CompleteMultipartUpload("some_key", uploadId, parts)
+	AssertEq(nil, err)
+
+	data, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
@@ -0,0 +1,260 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"github.com/jacobsa/aws/s3/http"
+	"io"
+	"sort"
+	"sync"
+	sys_time "time"
+)
+
+// A HedgePolicy configures hedged reads, in which a bucket that has waited too
+// long for a response to GetObject, GetObjectRange or GetHeader sends a
+// duplicate request, takes whichever response arrives first, and abandons the
+// other. This trims the long tail of S3's latencies at the cost of some extra
+// requests. See OpenBucketWithOptions.
+type HedgePolicy struct {
+	// The percentile of recently observed latencies for the operation after
+	// which a duplicate is sent, in (0, 1). For example, 0.95 sends duplicates
+	// for roughly the slowest five percent of requests.
+	Percentile float64
+
+	// The delay used until enough latencies have been observed to compute the
+	// percentile.
+	InitialDelay sys_time.Duration
+
+	// The shortest delay ever used, so that a run of fast responses doesn't
+	// cause every request to be duplicated.
+	MinDelay sys_time.Duration
+}
+
+// The number of recent latencies kept for each operation.
+const hedgeWindowSize = 100
+
+// The number of latencies that must be observed for an operation before the
+// percentile is used.
+const minHedgeSamples = 20
+
+// Keeps track of recent latencies, and decides how long to wait before
+// hedging a request.
+type hedger struct {
+	policy HedgePolicy
+
+	mutex sync.Mutex
+
+	// Recent latencies for each operation, used as ring buffers.
+	//
+	// Protected by mutex
+	latencies map[string][]sys_time.Duration
+
+	// The index in each ring buffer at which the next latency is stored, once
+	// it is full.
+	//
+	// Protected by mutex
+	next map[string]int
+}
+
+func newHedger(policy HedgePolicy) *hedger {
+	return &hedger{
+		policy:    policy,
+		latencies: make(map[string][]sys_time.Duration),
+		next:      make(map[string]int),
+	}
+}
+
+// Return how long to wait for a response to a request for the given
+// operation before sending a duplicate.
+func (h *hedger) delay(operation string) (d sys_time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	d = h.policy.InitialDelay
+
+	latencies := h.latencies[operation]
+	if len(latencies) >= minHedgeSamples {
+		sorted := append([]sys_time.Duration(nil), latencies...)
+		sort.Sort(durationList(sorted))
+
+		i := int(h.policy.Percentile * float64(len(sorted)))
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+
+		d = sorted[i]
+	}
+
+	if d < h.policy.MinDelay {
+		d = h.policy.MinDelay
+	}
+
+	return
+}
+
+// Record the time taken to receive a response to a request for the given
+// operation.
+func (h *hedger) observe(operation string, latency sys_time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	latencies := h.latencies[operation]
+	if len(latencies) < hedgeWindowSize {
+		h.latencies[operation] = append(latencies, latency)
+		return
+	}
+
+	i := h.next[operation]
+	latencies[i] = latency
+	h.next[operation] = (i + 1) % hedgeWindowSize
+}
+
+type durationList []sys_time.Duration
+
+func (l durationList) Len() int           { return len(l) }
+func (l durationList) Less(i, j int) bool { return l[i] < l[j] }
+func (l durationList) Swap(i, j int)      { l[j], l[i] = l[i], l[j] }
+
+// The outcome of one of the requests sent by sendHedged.
+type hedgedResult struct {
+	// The index of the request: zero for the original and one for the hedge.
+	index int
+
+	resp *http.Response
+	err  error
+}
+
+// A response body that abandons the request it belongs to once it is closed,
+// releasing the request's context.
+type cancellingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancellingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Like sendRequest, but if the bucket has a hedging policy and no response has
+// arrived within the delay it dictates, send a duplicate of the request and
+// return whichever response arrives first. The other request is abandoned.
+//
+// The latency observed for the operation is measured from when the original
+// request was sent, so that it includes any time spent waiting before
+// hedging.
+//
+// The request must not have a body.
+func (b *bucket) sendHedged(r *http.Request) (*http.Response, error) {
+	if b.hedger == nil {
+		return b.sendRequest(r)
+	}
+
+	operation := operationName(r)
+	results := make(chan hedgedResult, 2)
+	var cancels []context.CancelFunc
+
+	// Send a copy of the request in the background, so that each copy may be
+	// signed and abandoned separately.
+	send := func() {
+		parent := r.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+
+		index := len(cancels)
+		ctx, cancel := context.WithCancel(parent)
+		cancels = append(cancels, cancel)
+
+		c := &http.Request{
+			Verb:       r.Verb,
+			Path:       r.Path,
+			Parameters: make(map[string]string),
+			Headers:    make(map[string]string),
+			Context:    ctx,
+		}
+
+		for k, v := range r.Parameters {
+			c.Parameters[k] = v
+		}
+
+		for k, v := range r.Headers {
+			c.Headers[k] = v
+		}
+
+		go func() {
+			resp, err := b.sendRequest(c)
+			results <- hedgedResult{index, resp, err}
+		}()
+	}
+
+	// Return the outcome of a request, recording its latency if it succeeded.
+	// Its context is released once the caller closes the response body, or
+	// now if there is no body to read.
+	start := b.clock.Now()
+	finish := func(result hedgedResult) (*http.Response, error) {
+		cancel := cancels[result.index]
+		if result.err != nil || result.resp.Body == nil {
+			cancel()
+		} else {
+			result.resp.Body = &cancellingBody{result.resp.Body, cancel}
+		}
+
+		if result.err == nil {
+			b.hedger.observe(operation, b.clock.Now().Sub(start))
+		}
+
+		return result.resp, result.err
+	}
+
+	// Send the request, waiting a while for a response before sending a
+	// duplicate.
+	send()
+
+	timer := b.clock.NewTimer(b.hedger.delay(operation))
+	defer timer.Stop()
+
+	var result hedgedResult
+	select {
+	case result = <-results:
+		return finish(result)
+
+	case <-timer.C():
+	}
+
+	send()
+
+	// Take the first response, falling back to the second if the first
+	// request failed outright.
+	result = <-results
+	if result.err != nil {
+		cancels[result.index]()
+		return finish(<-results)
+	}
+
+	// Abandon the other request, and clean up after it.
+	cancels[1-result.index]()
+	go func() {
+		loser := <-results
+		if loser.resp != nil {
+			loser.resp.Body.Close()
+		}
+	}()
+
+	return finish(result)
+}
@@ -0,0 +1,263 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestHedge(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// hedger
+////////////////////////////////////////////////////////////////////////
+
+type HedgerTest struct {
+	hedger *hedger
+}
+
+func init() { RegisterTestSuite(&HedgerTest{}) }
+
+func (t *HedgerTest) SetUp(i *TestInfo) {
+	t.hedger = newHedger(
+		HedgePolicy{
+			Percentile:   0.9,
+			InitialDelay: time.Second,
+			MinDelay:     10 * time.Millisecond,
+		})
+}
+
+func (t *HedgerTest) InitialDelay() {
+	for i := 0; i < minHedgeSamples-1; i++ {
+		t.hedger.observe("GetObject", time.Millisecond*time.Duration(100+i))
+	}
+
+	ExpectEq(time.Second, t.hedger.delay("GetObject"))
+}
+
+func (t *HedgerTest) Percentile() {
+	for i := 0; i < 100; i++ {
+		t.hedger.observe("GetObject", time.Millisecond*time.Duration(100-i))
+	}
+
+	ExpectEq(91*time.Millisecond, t.hedger.delay("GetObject"))
+	ExpectEq(time.Second, t.hedger.delay("HeadObject"))
+}
+
+func (t *HedgerTest) MinDelay() {
+	for i := 0; i < 100; i++ {
+		t.hedger.observe("GetObject", time.Millisecond)
+	}
+
+	ExpectEq(10*time.Millisecond, t.hedger.delay("GetObject"))
+}
+
+func (t *HedgerTest) OldLatenciesAreForgotten() {
+	for i := 0; i < hedgeWindowSize; i++ {
+		t.hedger.observe("GetObject", time.Minute)
+	}
+
+	for i := 0; i < hedgeWindowSize; i++ {
+		t.hedger.observe("GetObject", 100*time.Millisecond)
+	}
+
+	ExpectEq(100*time.Millisecond, t.hedger.delay("GetObject"))
+}
+
+////////////////////////////////////////////////////////////////////////
+// sendHedged
+////////////////////////////////////////////////////////////////////////
+
+type HedgedGetTest struct {
+	bucketTest
+
+	// Requests seen by the connection, in order.
+	requests chan *http.Request
+}
+
+func init() { RegisterTestSuite(&HedgedGetTest{}) }
+
+func (t *HedgedGetTest) SetUp(i *TestInfo) {
+	t.bucketTest.SetUp(i)
+	t.requests = make(chan *http.Request, 2)
+
+	var err error
+	t.bucket, err = openBucket(
+		"some.bucket",
+		t.endpoint,
+		t.httpConn,
+		t.newConn,
+		t.signer,
+		t.interceptor,
+		&HedgePolicy{Percentile: 0.95, InitialDelay: time.Second},
+		t.clock)
+	AssertEq(nil, err)
+
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillRepeatedly(oglemock.Return(nil))
+}
+
+// Return an action for SendRequest that records the request, then blocks
+// until it is cancelled.
+func (t *HedgedGetTest) blockUntilCancelled() oglemock.Action {
+	return oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		t.requests <- r
+		<-r.Context.Done()
+		return nil, errors.New("request canceled")
+	})
+}
+
+// Return an action for SendRequest that records the request, then responds
+// with the supplied body.
+func (t *HedgedGetTest) respond(body string) oglemock.Action {
+	return oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		t.requests <- r
+		resp := &http.Response{StatusCode: 200, Body: stringReadCloser(body)}
+		return resp, nil
+	})
+}
+
+func (t *HedgedGetTest) FastResponseIsNotHedged() {
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond("taco"))
+
+	data, err := t.bucket.GetObject("some/key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *HedgedGetTest) FastResponseIsReleased() {
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond("taco"))
+
+	_, err := t.bucket.GetObject("some/key")
+	AssertEq(nil, err)
+
+	// Reading the body should have released the request's context.
+	r := <-t.requests
+	<-r.Context.Done()
+}
+
+func (t *HedgedGetTest) ErrorIsNotHedged() {
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(nil, errors.New("taco")))
+
+	_, err := t.bucket.GetObject("some/key")
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *HedgedGetTest) SlowResponseIsHedged() {
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.blockUntilCancelled()).
+		WillOnce(t.respond("burrito"))
+
+	// Wait for the primary request to be sent, then let the delay pass.
+	go func() {
+		t.clock.WaitForTimers(1)
+		t.clock.AdvanceTime(time.Second)
+	}()
+
+	data, err := t.bucket.GetObject("some/key")
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(data))
+
+	// The requests should be alike, but separately cancellable.
+	primary := <-t.requests
+	hedge := <-t.requests
+
+	ExpectEq(primary.Verb, hedge.Verb)
+	ExpectEq(primary.Path, hedge.Path)
+	ExpectEq(primary.Headers["Date"], hedge.Headers["Date"])
+	ExpectNe(primary.Context, hedge.Context)
+
+	// The primary request should eventually be abandoned, and the hedge
+	// released once its body was read.
+	<-primary.Context.Done()
+	<-hedge.Context.Done()
+}
+
+func (t *HedgedGetTest) LatencyIncludesHedgeDelay() {
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.blockUntilCancelled()).
+		WillOnce(t.respond("burrito"))
+
+	go func() {
+		t.clock.WaitForTimers(1)
+		t.clock.AdvanceTime(time.Second)
+	}()
+
+	_, err := t.bucket.GetObject("some/key")
+	AssertEq(nil, err)
+
+	// The hedge responded at once, but the caller waited for the delay first.
+	h := t.bucket.(*bucket).hedger
+	ExpectThat(h.latencies["GetObject"], ElementsAre(time.Second))
+}
+
+func (t *HedgedGetTest) HedgeFailsAndPrimarySucceeds() {
+	primaryResponds := make(chan struct{})
+
+	// The primary request responds only once the hedge has failed.
+	primary := func(r *http.Request) (*http.Response, error) {
+		<-primaryResponds
+		resp := &http.Response{StatusCode: 200, Body: stringReadCloser("taco")}
+		return resp, nil
+	}
+
+	hedge := func(r *http.Request) (*http.Response, error) {
+		close(primaryResponds)
+		return nil, errors.New("burrito")
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Invoke(primary)).
+		WillOnce(oglemock.Invoke(hedge))
+
+	go func() {
+		t.clock.WaitForTimers(1)
+		t.clock.AdvanceTime(time.Second)
+	}()
+
+	data, err := t.bucket.GetObject("some/key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *HedgedGetTest) GetHeaderIsHedged() {
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.blockUntilCancelled()).
+		WillOnce(t.respond(""))
+
+	go func() {
+		t.clock.WaitForTimers(1)
+		t.clock.AdvanceTime(time.Second)
+	}()
+
+	_, err := t.bucket.GetHeader("some/key")
+	AssertEq(nil, err)
+
+	primary := <-t.requests
+	hedge := <-t.requests
+
+	ExpectEq("HEAD", primary.Verb)
+	ExpectEq("HEAD", hedge.Verb)
+}
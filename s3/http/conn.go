@@ -16,9 +16,14 @@
 package http
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 )
 
 // A connection to a particular server over a particular protocol (HTTP or
@@ -27,9 +32,20 @@ type Conn interface {
 	// Call the server with the supplied request, returning a response if and
 	// only if a response was received from the server. (That is, a 500 error
 	// from the server will be returned here as a response with a nil error).
+	//
+	// Redirects are not followed, since a request must be re-signed before
+	// being sent elsewhere; they are returned like any other response.
 	SendRequest(r *Request) (*Response, error)
 }
 
+// The client used to send requests, which hands redirects back to us rather
+// than following them.
+var client = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
 // Return a connection to the supplied endpoint, based on its scheme and host
 // fields.
 func NewConn(endpoint *url.URL) (c Conn, err error) {
@@ -49,12 +65,26 @@ type conn struct {
 }
 
 func makeRawQuery(r *Request) string {
-	values := url.Values{}
-	for key, val := range r.Parameters {
-		values.Set(key, val)
+	// Parameters with empty values are sent as a bare name (e.g. "?uploads"),
+	// which is the form S3 documents for sub-resources.
+	var keys []string
+	for key := range r.Parameters {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		part := url.QueryEscape(key)
+		if val := r.Parameters[key]; val != "" {
+			part += "=" + url.QueryEscape(val)
+		}
+
+		parts = append(parts, part)
 	}
 
-	return values.Encode()
+	return strings.Join(parts, "&")
 }
 
 func (c *conn) SendRequest(r *Request) (resp *Response, err error) {
@@ -68,22 +98,43 @@ func (c *conn) SendRequest(r *Request) (resp *Response, err error) {
 
 	urlStr := url.String()
 
-	// Create a request to the system HTTP library.
-	sysReq, err := http.NewRequest(r.Verb, urlStr, r.Body)
+	// Create a request to the system HTTP library. The body is wrapped so that
+	// the library doesn't close it, leaving the caller free to rewind it and
+	// send it again.
+	var body io.Reader
+	if r.Body != nil {
+		body = ioutil.NopCloser(r.Body)
+	}
+
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	sysReq, err := http.NewRequestWithContext(ctx, r.Verb, urlStr, body)
 	if err != nil {
-		err = &Error{"http.NewRequest", err}
+		err = &Error{"http.NewRequestWithContext", err}
 		return
 	}
 
+	// S3 refuses uploads with chunked transfer encoding, so send the length of
+	// the body when it can be found.
+	if _, length, ok := PeekBody(r.Body, 0); ok {
+		sysReq.ContentLength = length
+		if length == 0 {
+			sysReq.Body = http.NoBody
+		}
+	}
+
 	// Copy headers.
 	for key, val := range r.Headers {
 		sysReq.Header.Set(key, val)
 	}
 
 	// Call the system HTTP library.
-	sysResp, err := http.DefaultClient.Do(sysReq)
+	sysResp, err := client.Do(sysReq)
 	if err != nil {
-		err = &Error{"http.DefaultClient.Do", err}
+		err = &Error{"client.Do", err}
 		return
 	}
 
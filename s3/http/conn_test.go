@@ -16,12 +16,18 @@
 package http_test
 
 import (
+	"bytes"
+	"context"
 	"github.com/jacobsa/aws/s3/http"
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
+	"io"
+	"io/ioutil"
 	sys_http "net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -33,7 +39,8 @@ func TestConn(t *testing.T) { RunTests(t) }
 
 type localHandler struct {
 	// Input seen.
-	req *sys_http.Request
+	req     *sys_http.Request
+	reqBody []byte
 
 	// To be returned.
 	statusCode int
@@ -48,6 +55,13 @@ func (h *localHandler) ServeHTTP(w sys_http.ResponseWriter, r *sys_http.Request)
 
 	h.req = r
 
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	h.reqBody = body
+
 	// Write out the response.
 	w.WriteHeader(h.statusCode)
 	if _, err := w.Write(h.body); err != nil {
@@ -248,6 +262,135 @@ func (t *ConnTest) PathAndParametersNeedEscaping() {
 	ExpectEq("qu?x", query.Get("b&az"))
 }
 
+func (t *ConnTest) ParameterWithEmptyValue() {
+	// Handler
+	t.handler.statusCode = 200
+
+	// Connection
+	conn, err := http.NewConn(t.endpoint)
+	AssertEq(nil, err)
+
+	// Request
+	req := &http.Request{
+		Verb:    "POST",
+		Path:    "/foo",
+		Headers: map[string]string{},
+		Parameters: map[string]string{
+			"uploads": "",
+		},
+	}
+
+	// Call
+	_, err = conn.SendRequest(req)
+	AssertEq(nil, err)
+
+	AssertNe(nil, t.handler.req)
+	sysReq := t.handler.req
+
+	ExpectEq("/foo?uploads", sysReq.RequestURI)
+}
+
+func (t *ConnTest) SeekableBodySentWithLength() {
+	// Handler
+	t.handler.statusCode = 200
+
+	// Connection
+	conn, err := http.NewConn(t.endpoint)
+	AssertEq(nil, err)
+
+	// Request, with a body that has already been partly read.
+	body := io.NewSectionReader(strings.NewReader("tacoburrito"), 0, 11)
+	_, err = body.Seek(4, 0)
+	AssertEq(nil, err)
+
+	req := &http.Request{
+		Verb:    "PUT",
+		Path:    "/foo",
+		Headers: map[string]string{},
+		Body:    body,
+	}
+
+	// Call
+	_, err = conn.SendRequest(req)
+	AssertEq(nil, err)
+
+	AssertNe(nil, t.handler.req)
+	sysReq := t.handler.req
+
+	ExpectEq(7, sysReq.ContentLength)
+	ExpectThat(sysReq.TransferEncoding, ElementsAre())
+	ExpectEq("burrito", string(t.handler.reqBody))
+}
+
+func (t *ConnTest) EmptyBodySentWithLength() {
+	// Handler
+	t.handler.statusCode = 200
+
+	// Connection
+	conn, err := http.NewConn(t.endpoint)
+	AssertEq(nil, err)
+
+	// Request
+	req := &http.Request{
+		Verb:    "PUT",
+		Path:    "/foo",
+		Headers: map[string]string{},
+		Body:    bytes.NewReader([]byte{}),
+	}
+
+	// Call
+	_, err = conn.SendRequest(req)
+	AssertEq(nil, err)
+
+	AssertNe(nil, t.handler.req)
+	sysReq := t.handler.req
+
+	ExpectEq(0, sysReq.ContentLength)
+	ExpectThat(sysReq.TransferEncoding, ElementsAre())
+	ExpectThat(sysReq.Header["Content-Length"], ElementsAre("0"))
+}
+
+func (t *ConnTest) FileBodyNotClosed() {
+	// Handler
+	t.handler.statusCode = 200
+
+	// File
+	f, err := ioutil.TempFile("", "conn_test")
+	AssertEq(nil, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = f.WriteString("taco")
+	AssertEq(nil, err)
+
+	_, err = f.Seek(0, 0)
+	AssertEq(nil, err)
+
+	// Connection
+	conn, err := http.NewConn(t.endpoint)
+	AssertEq(nil, err)
+
+	// Request
+	req := &http.Request{
+		Verb:    "PUT",
+		Path:    "/foo",
+		Headers: map[string]string{},
+		Body:    f,
+	}
+
+	// Call
+	_, err = conn.SendRequest(req)
+	AssertEq(nil, err)
+
+	AssertNe(nil, t.handler.req)
+	ExpectEq(4, t.handler.req.ContentLength)
+	ExpectEq("taco", string(t.handler.reqBody))
+
+	// The file should still be usable.
+	_, err = f.Seek(0, 0)
+	ExpectEq(nil, err)
+}
+
 func (t *ConnTest) ReturnsStatusCode() {
 	// Handler
 	t.handler.statusCode = 123
@@ -327,3 +470,37 @@ func (t *ConnTest) HttpsAllowed() {
 	_, err := http.NewConn(t.endpoint)
 	AssertEq(nil, err)
 }
+
+func (t *ConnTest) CancelledRequest() {
+	// A server that doesn't respond until the client goes away.
+	server := httptest.NewServer(
+		sys_http.HandlerFunc(
+			func(w sys_http.ResponseWriter, r *sys_http.Request) {
+				<-r.Context().Done()
+			}))
+
+	defer server.Close()
+
+	endpoint, err := url.Parse(server.URL)
+	AssertEq(nil, err)
+
+	// Connection
+	conn, err := http.NewConn(endpoint)
+	AssertEq(nil, err)
+
+	// Request
+	ctx, cancel := context.WithCancel(context.Background())
+	req := &http.Request{
+		Verb:    "GET",
+		Path:    "/",
+		Headers: map[string]string{},
+		Context: ctx,
+	}
+
+	// Call
+	cancel()
+	_, err = conn.SendRequest(req)
+
+	ExpectThat(err, Error(HasSubstr("client.Do")))
+	ExpectThat(err, Error(HasSubstr("cancel")))
+}
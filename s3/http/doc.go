@@ -13,5 +13,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package http is an implementation detail. You should not use it directly.
+// Package http is mostly an implementation detail of package s3. Its
+// Interceptor type may be used to observe and alter the requests a bucket
+// sends; see s3.OpenBucket.
 package http
@@ -0,0 +1,201 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"github.com/jacobsa/aws/time"
+	"io"
+	sys_time "time"
+)
+
+// An Interceptor observes or alters the requests sent to S3 and their
+// responses, for example to log traffic, collect metrics, add headers, or
+// inject faults. Pass interceptors to s3.OpenBucket.
+//
+// A request that is retried, for example after a redirect, is passed to
+// BeforeRetry and then passes through the other hooks again.
+type Interceptor interface {
+	// Called with a request that is about to be sent again, before it is
+	// re-signed.
+	BeforeRetry(r *Request)
+
+	// Called with each request before it is signed, so changes to its headers
+	// are covered by the signature. Returning an error abandons the request.
+	BeforeSign(r *Request) error
+
+	// Called with each request after it is signed. Returning an error abandons
+	// the request.
+	AfterSign(r *Request) error
+
+	// Called with each request immediately before it is sent. Every call is
+	// followed by a call to AfterResponse for the same request.
+	BeforeSend(r *Request)
+
+	// Called with the outcome of sending the request: either a response or an
+	// error from the connection, along with the time it took to arrive (not
+	// counting any time spent in BeforeSend). A body that implements io.Seeker
+	// has been rewound to where it was when the request was sent. The returned
+	// values are used in its place, so the interceptor may for example turn an
+	// error into a response or vice versa.
+	AfterResponse(
+		r *Request,
+		latency sys_time.Duration,
+		resp *Response,
+		err error) (*Response, error)
+}
+
+// InterceptorFuncs implements Interceptor with optional functions, so that
+// an interceptor needing only some of the hooks can leave the others nil.
+type InterceptorFuncs struct {
+	BeforeRetryFunc   func(r *Request)
+	BeforeSignFunc    func(r *Request) error
+	AfterSignFunc     func(r *Request) error
+	BeforeSendFunc    func(r *Request)
+	AfterResponseFunc func(
+		r *Request,
+		latency sys_time.Duration,
+		resp *Response,
+		err error) (*Response, error)
+}
+
+func (f *InterceptorFuncs) BeforeRetry(r *Request) {
+	if f.BeforeRetryFunc != nil {
+		f.BeforeRetryFunc(r)
+	}
+}
+
+func (f *InterceptorFuncs) BeforeSign(r *Request) error {
+	if f.BeforeSignFunc == nil {
+		return nil
+	}
+
+	return f.BeforeSignFunc(r)
+}
+
+func (f *InterceptorFuncs) AfterSign(r *Request) error {
+	if f.AfterSignFunc == nil {
+		return nil
+	}
+
+	return f.AfterSignFunc(r)
+}
+
+func (f *InterceptorFuncs) BeforeSend(r *Request) {
+	if f.BeforeSendFunc != nil {
+		f.BeforeSendFunc(r)
+	}
+}
+
+func (f *InterceptorFuncs) AfterResponse(
+	r *Request,
+	latency sys_time.Duration,
+	resp *Response,
+	err error) (*Response, error) {
+	if f.AfterResponseFunc == nil {
+		return resp, err
+	}
+
+	return f.AfterResponseFunc(r, latency, resp, err)
+}
+
+// Chain composes interceptors into one. Requests pass through its members in
+// order, stopping at the first error, and responses pass through them in
+// reverse order, so the first member sees the request first and the response
+// last.
+type Chain []Interceptor
+
+func (c Chain) BeforeRetry(r *Request) {
+	for _, i := range c {
+		i.BeforeRetry(r)
+	}
+}
+
+func (c Chain) BeforeSign(r *Request) error {
+	for _, i := range c {
+		if err := i.BeforeSign(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c Chain) AfterSign(r *Request) error {
+	for _, i := range c {
+		if err := i.AfterSign(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c Chain) BeforeSend(r *Request) {
+	for _, i := range c {
+		i.BeforeSend(r)
+	}
+}
+
+func (c Chain) AfterResponse(
+	r *Request,
+	latency sys_time.Duration,
+	resp *Response,
+	err error) (*Response, error) {
+	for j := len(c) - 1; j >= 0; j-- {
+		resp, err = c[j].AfterResponse(r, latency, resp, err)
+	}
+
+	return resp, err
+}
+
+// InterceptConn returns a connection that sends requests with the supplied
+// one, calling the interceptor's BeforeSend and AfterResponse hooks around it
+// and timing each request with the supplied clock. BeforeSign and AfterSign
+// are the responsibility of the signer; see auth.InterceptSigner.
+func InterceptConn(c Conn, i Interceptor, clock time.Clock) Conn {
+	return &interceptedConn{c, i, clock}
+}
+
+type interceptedConn struct {
+	wrapped     Conn
+	interceptor Interceptor
+	clock       time.Clock
+}
+
+func (c *interceptedConn) SendRequest(r *Request) (*Response, error) {
+	c.interceptor.BeforeSend(r)
+
+	// Note where the body starts, so that AfterResponse can see it as sent.
+	seeker, seekable := r.Body.(io.Seeker)
+	var bodyStart int64
+	if seekable {
+		var err error
+		if bodyStart, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	start := c.clock.Now()
+	resp, err := c.wrapped.SendRequest(r)
+	latency := c.clock.Now().Sub(start)
+
+	// A body that can't be rewound is left where the connection left it.
+	if seekable {
+		seeker.Seek(bodyStart, io.SeekStart)
+	}
+
+	return c.interceptor.AfterResponse(r, latency, resp, err)
+}
@@ -0,0 +1,291 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jacobsa/aws/s3/http"
+	"github.com/jacobsa/aws/s3/http/mock"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestInterceptor(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// An interceptor that records the hooks called on it in a shared log, and
+// returns the configured errors.
+type recordingInterceptor struct {
+	name string
+	log  *[]string
+
+	beforeSignErr error
+	afterSignErr  error
+}
+
+func (i *recordingInterceptor) BeforeRetry(r *http.Request) {
+	*i.log = append(*i.log, i.name+".BeforeRetry")
+}
+
+func (i *recordingInterceptor) BeforeSign(r *http.Request) error {
+	*i.log = append(*i.log, i.name+".BeforeSign")
+	return i.beforeSignErr
+}
+
+func (i *recordingInterceptor) AfterSign(r *http.Request) error {
+	*i.log = append(*i.log, i.name+".AfterSign")
+	return i.afterSignErr
+}
+
+func (i *recordingInterceptor) BeforeSend(r *http.Request) {
+	*i.log = append(*i.log, i.name+".BeforeSend")
+}
+
+func (i *recordingInterceptor) AfterResponse(
+	r *http.Request,
+	latency time.Duration,
+	resp *http.Response,
+	err error) (*http.Response, error) {
+	*i.log = append(*i.log, fmt.Sprintf("%s.AfterResponse(%v)", i.name, err))
+	return resp, fmt.Errorf("%s(%v)", i.name, err)
+}
+
+type InterceptorTest struct {
+	wrapped mock_http.MockConn
+	clock   *aws_time.SimulatedClock
+
+	log   []string
+	a     *recordingInterceptor
+	b     *recordingInterceptor
+	chain http.Chain
+}
+
+func init() { RegisterTestSuite(&InterceptorTest{}) }
+
+func (t *InterceptorTest) SetUp(i *TestInfo) {
+	t.wrapped = mock_http.NewMockConn(i.MockController, "wrapped")
+	t.clock = aws_time.NewSimulatedClock(time.Unix(1325376000, 0))
+
+	t.a = &recordingInterceptor{name: "a", log: &t.log}
+	t.b = &recordingInterceptor{name: "b", log: &t.log}
+	t.chain = http.Chain{t.a, t.b}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *InterceptorTest) EmptyChain() {
+	r := &http.Request{}
+	resp := &http.Response{}
+	err := errors.New("taco")
+
+	ExpectEq(nil, http.Chain{}.BeforeSign(r))
+	ExpectEq(nil, http.Chain{}.AfterSign(r))
+	http.Chain{}.BeforeSend(r)
+
+	gotResp, gotErr := http.Chain{}.AfterResponse(r, time.Second, resp, err)
+	ExpectEq(resp, gotResp)
+	ExpectEq(err, gotErr)
+}
+
+func (t *InterceptorTest) ChainOrder() {
+	r := &http.Request{}
+
+	t.chain.BeforeRetry(r)
+	AssertEq(nil, t.chain.BeforeSign(r))
+	AssertEq(nil, t.chain.AfterSign(r))
+	t.chain.BeforeSend(r)
+	_, err := t.chain.AfterResponse(r, time.Second, nil, errors.New("taco"))
+
+	ExpectThat(err, Error(Equals("a(b(taco))")))
+	ExpectThat(
+		t.log,
+		ElementsAre(
+			"a.BeforeRetry",
+			"b.BeforeRetry",
+			"a.BeforeSign",
+			"b.BeforeSign",
+			"a.AfterSign",
+			"b.AfterSign",
+			"a.BeforeSend",
+			"b.BeforeSend",
+			"b.AfterResponse(taco)",
+			"a.AfterResponse(b(taco))",
+		))
+}
+
+func (t *InterceptorTest) ChainStopsAtFirstError() {
+	r := &http.Request{}
+	t.a.beforeSignErr = errors.New("taco")
+	t.a.afterSignErr = errors.New("burrito")
+
+	ExpectThat(t.chain.BeforeSign(r), Error(Equals("taco")))
+	ExpectThat(t.chain.AfterSign(r), Error(Equals("burrito")))
+	ExpectThat(t.log, ElementsAre("a.BeforeSign", "a.AfterSign"))
+}
+
+func (t *InterceptorTest) InterceptorFuncsWithNilFuncs() {
+	i := &http.InterceptorFuncs{}
+	r := &http.Request{}
+	resp := &http.Response{}
+	err := errors.New("taco")
+
+	ExpectEq(nil, i.BeforeSign(r))
+	ExpectEq(nil, i.AfterSign(r))
+	i.BeforeSend(r)
+
+	gotResp, gotErr := i.AfterResponse(r, time.Second, resp, err)
+	ExpectEq(resp, gotResp)
+	ExpectEq(err, gotErr)
+}
+
+func (t *InterceptorTest) InterceptorFuncsCallsFuncs() {
+	r := &http.Request{}
+	replacement := &http.Response{StatusCode: 503}
+	var sent *http.Request
+
+	i := &http.InterceptorFuncs{
+		BeforeSignFunc: func(r *http.Request) error {
+			return errors.New("taco")
+		},
+
+		AfterSignFunc: func(r *http.Request) error {
+			return errors.New("burrito")
+		},
+
+		BeforeSendFunc: func(r *http.Request) {
+			sent = r
+		},
+
+		AfterResponseFunc: func(
+			r *http.Request,
+			latency time.Duration,
+			resp *http.Response,
+			err error) (*http.Response, error) {
+			return replacement, nil
+		},
+	}
+
+	ExpectThat(i.BeforeSign(r), Error(Equals("taco")))
+	ExpectThat(i.AfterSign(r), Error(Equals("burrito")))
+
+	i.BeforeSend(r)
+	ExpectEq(r, sent)
+
+	resp, err := i.AfterResponse(r, time.Second, nil, errors.New("enchilada"))
+	ExpectEq(replacement, resp)
+	ExpectEq(nil, err)
+}
+
+func (t *InterceptorTest) InterceptConn() {
+	c := http.InterceptConn(t.wrapped, t.chain, t.clock)
+
+	r := &http.Request{}
+	resp := &http.Response{StatusCode: 200}
+
+	ExpectCall(t.wrapped, "SendRequest")(r).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	gotResp, err := c.SendRequest(r)
+
+	ExpectEq(resp, gotResp)
+	ExpectThat(err, Error(Equals("a(b(<nil>))")))
+	ExpectThat(
+		t.log,
+		ElementsAre(
+			"a.BeforeSend",
+			"b.BeforeSend",
+			"b.AfterResponse(<nil>)",
+			"a.AfterResponse(b(<nil>))",
+		))
+}
+
+func (t *InterceptorTest) InterceptConnTimesRequest() {
+	var latency time.Duration
+
+	i := &http.InterceptorFuncs{
+		// Time spent here shouldn't count.
+		BeforeSendFunc: func(r *http.Request) {
+			t.clock.AdvanceTime(100 * time.Millisecond)
+		},
+
+		AfterResponseFunc: func(
+			r *http.Request,
+			l time.Duration,
+			resp *http.Response,
+			err error) (*http.Response, error) {
+			latency = l
+			return resp, err
+		},
+	}
+
+	c := http.InterceptConn(t.wrapped, i, t.clock)
+
+	ExpectCall(t.wrapped, "SendRequest")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		t.clock.AdvanceTime(10 * time.Millisecond)
+		return &http.Response{StatusCode: 200}, nil
+	}))
+
+	_, err := c.SendRequest(&http.Request{})
+	AssertEq(nil, err)
+
+	ExpectEq(10*time.Millisecond, latency)
+}
+
+func (t *InterceptorTest) InterceptConnRewindsBody() {
+	var seen []byte
+
+	i := &http.InterceptorFuncs{
+		AfterResponseFunc: func(
+			r *http.Request,
+			latency time.Duration,
+			resp *http.Response,
+			err error) (*http.Response, error) {
+			seen, _ = ioutil.ReadAll(r.Body)
+			return resp, err
+		},
+	}
+
+	c := http.InterceptConn(t.wrapped, i, t.clock)
+
+	body := bytes.NewReader([]byte("tacoburrito"))
+	body.Seek(4, io.SeekStart)
+
+	ExpectCall(t.wrapped, "SendRequest")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		ioutil.ReadAll(r.Body)
+		return &http.Response{StatusCode: 200}, nil
+	}))
+
+	_, err := c.SendRequest(&http.Request{Body: body})
+	AssertEq(nil, err)
+
+	ExpectEq("burrito", string(seen))
+}
@@ -16,6 +16,7 @@
 package http
 
 import (
+	"context"
 	"io"
 )
 
@@ -45,6 +46,56 @@ type Request struct {
 	// HTTP headers to be included in the request.
 	Headers map[string]string
 
-	// The body of the request.
+	// The body of the request. If it implements io.Seeker, its length is sent
+	// in the Content-Length header; otherwise it is sent with chunked transfer
+	// encoding, which S3 refuses for uploads. The body is never closed.
 	Body io.Reader
+
+	// If non-nil, the request is abandoned once this context is done.
+	// SendRequest returns an error if the response has not yet arrived, and
+	// otherwise reading the response body fails.
+	Context context.Context
+}
+
+// PeekBody returns up to n bytes from the start of a request body and the
+// number of bytes remaining in it, leaving it where it was. ok is false if the
+// body doesn't implement io.Seeker or can't be rewound, in which case nothing
+// is read. The prefix is nil if n is zero or the body is empty.
+func PeekBody(body io.Reader, n int) (prefix []byte, length int64, ok bool) {
+	seeker, isSeeker := body.(io.Seeker)
+	if !isSeeker {
+		return
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+
+	if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+		return
+	}
+
+	length = end - start
+	if int64(n) > length {
+		n = int(length)
+	}
+
+	if n > 0 {
+		prefix = make([]byte, n)
+		n, _ = io.ReadFull(body, prefix)
+		prefix = prefix[:n]
+
+		if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, 0, false
+		}
+	}
+
+	ok = true
+	return
 }
@@ -0,0 +1,90 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http_test
+
+import (
+	"bytes"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/ogletest"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRequest(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// PeekBody
+////////////////////////////////////////////////////////////////////////
+
+type PeekBodyTest struct {
+}
+
+func init() { RegisterTestSuite(&PeekBodyTest{}) }
+
+func (t *PeekBodyTest) NilBody() {
+	prefix, length, ok := http.PeekBody(nil, 10)
+
+	ExpectFalse(ok)
+	ExpectEq(0, length)
+	ExpectEq(nil, prefix)
+}
+
+func (t *PeekBodyTest) BodyNotSeekable() {
+	body := ioutil.NopCloser(strings.NewReader("taco"))
+	prefix, _, ok := http.PeekBody(body, 10)
+
+	ExpectFalse(ok)
+	ExpectEq(nil, prefix)
+}
+
+func (t *PeekBodyTest) LengthOnly() {
+	body := bytes.NewReader([]byte("tacoburrito"))
+	body.Seek(4, io.SeekStart)
+
+	prefix, length, ok := http.PeekBody(body, 0)
+
+	AssertTrue(ok)
+	ExpectEq(7, length)
+	ExpectEq(nil, prefix)
+}
+
+func (t *PeekBodyTest) ShortBody() {
+	body := bytes.NewReader([]byte("tacoburrito"))
+	body.Seek(4, io.SeekStart)
+
+	prefix, length, ok := http.PeekBody(body, 100)
+
+	AssertTrue(ok)
+	ExpectEq(7, length)
+	ExpectEq("burrito", string(prefix))
+}
+
+func (t *PeekBodyTest) LongBody() {
+	body := bytes.NewReader([]byte("tacoburrito"))
+
+	prefix, length, ok := http.PeekBody(body, 4)
+
+	AssertTrue(ok)
+	ExpectEq(11, length)
+	ExpectEq("taco", string(prefix))
+
+	// The body should be where it was.
+	data, err := ioutil.ReadAll(body)
+	AssertEq(nil, err)
+	ExpectEq("tacoburrito", string(data))
+}
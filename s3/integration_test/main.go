@@ -20,8 +20,16 @@
 //         -bucket <bucket> \
 //         -region s3-ap-northeast-1.amazonaws.com
 //
+// The secret for the key ID is read from the terminal. If -key_id is omitted,
+// credentials are taken from the environment or ~/.aws/credentials instead.
+//
 // Before doing this, create an empty bucket (or delete the contents of an
 // existing bucket) using the S3 management console.
+//
+// Alternatively, run the tests against an in-process fake server with no
+// need for an AWS account:
+//
+//     go run integration_test/*.go -fake
 
 package main
 
@@ -29,6 +37,9 @@ import (
 	"flag"
 	"fmt"
 	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/http"
+	"github.com/jacobsa/aws/s3/s3test"
 	"github.com/jacobsa/ogletest"
 	"os"
 	"regexp"
@@ -42,8 +53,31 @@ import (
 var g_keyId = flag.String("key_id", "", "Access key ID.")
 var g_bucketName = flag.String("bucket", "", "Bucket name.")
 var g_region = flag.String("region", "", "Region endpoint server.")
+var g_fake = flag.Bool("fake", false, "Use an in-process fake server.")
 var g_accessKey aws.AccessKey
 
+// Set only if -fake is set.
+var g_fakeServer *s3test.Server
+
+// Open the bucket under test using the supplied key and interceptors.
+func openBucket(
+	key aws.AccessKey,
+	interceptors ...http.Interceptor) (s3.Bucket, error) {
+	if g_fakeServer != nil {
+		return s3.OpenBucketAtEndpoint(
+			*g_bucketName,
+			g_fakeServer.Endpoint(),
+			key,
+			interceptors...)
+	}
+
+	return s3.OpenBucket(
+		*g_bucketName,
+		s3.Region(*g_region),
+		key,
+		interceptors...)
+}
+
 ////////////////////////////////////////////////////////////////////////
 // main
 ////////////////////////////////////////////////////////////////////////
@@ -51,11 +85,9 @@ var g_accessKey aws.AccessKey
 func main() {
 	flag.Parse()
 
-	if *g_keyId == "" {
-		fmt.Println("You must set the -key_id flag.")
-		fmt.Println("Find a key ID here:")
-		fmt.Println("    https://portal.aws.amazon.com/gp/aws/securityCredentials")
-		os.Exit(1)
+	if *g_fake {
+		runFake()
+		return
 	}
 
 	if *g_bucketName == "" {
@@ -70,11 +102,37 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Read in the access key.
-	g_accessKey.Id = *g_keyId
-	g_accessKey.Secret = readPassword("Access key secret: ")
+	// Read in the access key, or find one in the environment.
+	if *g_keyId != "" {
+		g_accessKey.Id = *g_keyId
+		g_accessKey.Secret = readPassword("Access key secret: ")
+	} else {
+		var err error
+		g_accessKey, err = aws.DefaultCredentials().Credentials()
+		if err != nil {
+			fmt.Println("Couldn't find credentials:", err)
+			fmt.Println("Set the -key_id flag, or see aws.DefaultCredentials.")
+			os.Exit(1)
+		}
+	}
+
+	runTests()
+}
+
+// Run the tests against a fake server, using a made-up access key.
+func runFake() {
+	if *g_bucketName == "" {
+		*g_bucketName = "fake-bucket"
+	}
+
+	g_accessKey = aws.AccessKey{Id: "fake_id", Secret: "fake_secret"}
+	g_fakeServer = s3test.NewServer(g_accessKey)
+
+	g_fakeServer.CreateBucket(*g_bucketName)
+	runTests()
+}
 
-	// Run the tests.
+func runTests() {
 	matchString := func(pat, str string) (bool, error) {
 		re, err := regexp.Compile(pat)
 		if err != nil {
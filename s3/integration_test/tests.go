@@ -17,12 +17,15 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/http"
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
 	"strings"
 	"sync"
+	"time"
 )
 
 ////////////////////////////////////////////////////////////////////////
@@ -103,7 +106,7 @@ func (t *BucketTest) SetUp(i *TestInfo) {
 	var err error
 
 	// Open a bucket.
-	t.bucket, err = s3.OpenBucket(*g_bucketName, s3.Region(*g_region), g_accessKey)
+	t.bucket, err = openBucket(g_accessKey)
 	AssertEq(nil, err)
 }
 
@@ -132,7 +135,7 @@ func (t *BucketTest) WrongAccessKeySecret() {
 	wrongKey := g_accessKey
 	wrongKey.Secret += "taco"
 
-	bucket, err := s3.OpenBucket(*g_bucketName, s3.Region(*g_region), wrongKey)
+	bucket, err := openBucket(wrongKey)
 	AssertEq(nil, err)
 
 	// Attempt to do something.
@@ -636,3 +639,211 @@ func (t *BucketTest) DeleteThenListAndGetObject() {
 	AssertEq(nil, err)
 	ExpectThat(keys, ElementsAre())
 }
+
+func (t *BucketTest) GetObjectRange() {
+	key := "some_key"
+	t.ensureDeleted(key)
+
+	// Store
+	err := t.bucket.StoreObject(key, []byte("tacoburrito"))
+	AssertEq(nil, err)
+
+	// Middle
+	data, err := t.bucket.GetObjectRange(key, 4, 3)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	// Past the end
+	data, err = t.bucket.GetObjectRange(key, 8, 100)
+	AssertEq(nil, err)
+	ExpectEq("ito", string(data))
+
+	// Starting past the end
+	_, err = t.bucket.GetObjectRange(key, 11, 1)
+	ExpectThat(err, Error(HasSubstr("416")))
+}
+
+func (t *BucketTest) GetObjectRangeIfMatch() {
+	key := "some_key"
+	t.ensureDeleted(key)
+
+	// Store
+	err := t.bucket.StoreObject(key, []byte("tacoburrito"))
+	AssertEq(nil, err)
+
+	header, err := t.bucket.GetHeader(key)
+	AssertEq(nil, err)
+	etag := header.Get("ETag")
+
+	// Unchanged
+	data, err := t.bucket.GetObjectRangeIfMatch(key, 4, 3, etag)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	// Overwritten
+	err = t.bucket.StoreObject(key, []byte("enchilada"))
+	AssertEq(nil, err)
+
+	_, err = t.bucket.GetObjectRangeIfMatch(key, 4, 3, etag)
+	ExpectThat(err, Error(HasSubstr("412")))
+}
+
+func (t *BucketTest) MultipartUpload() {
+	key := "some_key"
+	t.ensureDeleted(key)
+
+	part1 := bytes.Repeat([]byte("a"), s3.MinPartSize)
+	part2 := []byte("taco")
+
+	// Initiate
+	uploadId, err := t.bucket.InitiateMultipartUpload(key)
+	AssertEq(nil, err)
+
+	// Upload parts
+	etag1, err := t.bucket.UploadPart(key, uploadId, 1, part1)
+	AssertEq(nil, err)
+
+	etag2, err := t.bucket.UploadPart(key, uploadId, 2, part2)
+	AssertEq(nil, err)
+
+	// Complete
+	parts := []s3.Part{
+		s3.Part{PartNumber: 1, ETag: etag1},
+		s3.Part{PartNumber: 2, ETag: etag2},
+	}
+
+	err = t.bucket.CompleteMultipartUpload(key, uploadId, parts)
+	AssertEq(nil, err)
+
+	// Get
+	data, err := t.bucket.GetObject(key)
+	AssertEq(nil, err)
+	ExpectEq(len(part1)+len(part2), len(data))
+	ExpectEq("taco", string(data[len(part1):]))
+}
+
+func (t *BucketTest) AbortMultipartUpload() {
+	key := "some_key"
+
+	// Initiate
+	uploadId, err := t.bucket.InitiateMultipartUpload(key)
+	AssertEq(nil, err)
+
+	// Upload a part
+	_, err = t.bucket.UploadPart(key, uploadId, 1, []byte("taco"))
+	AssertEq(nil, err)
+
+	// Abort
+	err = t.bucket.AbortMultipartUpload(key, uploadId)
+	AssertEq(nil, err)
+
+	// The object should not exist.
+	_, err = t.bucket.GetObject(key)
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *BucketTest) ListMultipartUploads() {
+	// Initiate
+	uploadId, err := t.bucket.InitiateMultipartUpload("some_key")
+	AssertEq(nil, err)
+
+	defer t.bucket.AbortMultipartUpload("some_key", uploadId)
+
+	// List
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+
+	var found *s3.MultipartUpload
+	for i := range uploads {
+		if uploads[i].UploadId == uploadId {
+			found = &uploads[i]
+		}
+	}
+
+	AssertNe(nil, found)
+	ExpectEq("some_key", found.Key)
+	ExpectFalse(found.Initiated.IsZero())
+}
+
+func (t *BucketTest) NewWriter() {
+	key := "some_key"
+	t.ensureDeleted(key)
+
+	contents := bytes.Repeat([]byte("taco"), s3.MinPartSize/4+17)
+
+	// Write
+	w := t.bucket.NewWriter(key)
+	_, err := w.Write(contents[:1000])
+	AssertEq(nil, err)
+
+	_, err = w.Write(contents[1000:])
+	AssertEq(nil, err)
+
+	AssertEq(nil, w.Close())
+
+	// Read
+	data, err := t.bucket.GetObject(key)
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(contents, data))
+}
+
+func (t *BucketTest) Interceptors() {
+	key := "some_key"
+	t.ensureDeleted(key)
+
+	// Set up an interceptor that sets the content type of objects stored,
+	// records response status codes, and refuses to send deletions.
+	var mutex sync.Mutex
+	var statusCodes []int
+
+	interceptor := &http.InterceptorFuncs{
+		BeforeSignFunc: func(r *http.Request) error {
+			if r.Verb == "PUT" {
+				r.Headers["Content-Type"] = "text/taco"
+			}
+
+			return nil
+		},
+
+		AfterSignFunc: func(r *http.Request) error {
+			if r.Verb == "DELETE" {
+				return errors.New("injected fault")
+			}
+
+			return nil
+		},
+
+		AfterResponseFunc: func(
+			r *http.Request,
+			latency time.Duration,
+			resp *http.Response,
+			err error) (*http.Response, error) {
+			if err == nil {
+				mutex.Lock()
+				statusCodes = append(statusCodes, resp.StatusCode)
+				mutex.Unlock()
+			}
+
+			return resp, err
+		},
+	}
+
+	bucket, err := openBucket(g_accessKey, interceptor)
+	AssertEq(nil, err)
+
+	// Store and look at the object.
+	AssertEq(nil, bucket.StoreObject(key, []byte("burrito")))
+
+	header, err := bucket.GetHeader(key)
+	AssertEq(nil, err)
+	ExpectEq("text/taco", header.Get("Content-Type"))
+
+	// Attempt to delete it.
+	err = bucket.DeleteObject(key)
+	ExpectThat(err, Error(HasSubstr("injected fault")))
+
+	_, err = t.bucket.GetHeader(key)
+	ExpectEq(nil, err)
+
+	ExpectThat(statusCodes, ElementsAre(200, 200))
+}
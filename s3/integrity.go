@@ -0,0 +1,102 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	sys_http "net/http"
+	"strconv"
+	"strings"
+)
+
+// IntegrityError is returned by GetObject when the data received doesn't
+// match what the server said it sent, for example because the body was
+// truncated or corrupted in transit.
+type IntegrityError struct {
+	Key    string
+	Reason string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("Integrity check failed for %s: %s", e.Key, e.Reason)
+}
+
+// ETagIsMD5 returns true if the ETag in the supplied response headers for an
+// object may be the MD5 of its contents. It isn't for objects assembled from
+// multipart uploads, whose ETags contain a dash, or for objects encrypted with
+// a KMS key or a key supplied by the customer, even when it looks like one.
+func ETagIsMD5(header sys_http.Header) bool {
+	if strings.Contains(header.Get("ETag"), "-") {
+		return false
+	}
+
+	sse := header.Get("x-amz-server-side-encryption")
+	if strings.HasPrefix(sse, "aws:kms") {
+		return false
+	}
+
+	if header.Get("x-amz-server-side-encryption-customer-algorithm") != "" {
+		return false
+	}
+
+	return true
+}
+
+// Check the supplied object data against the Content-Length and ETag headers
+// in the response, where present. Objects assembled from multipart uploads or
+// encrypted with a KMS key or a key supplied by the customer have no usable
+// MD5, so they are checked for length only.
+func verifyObject(key string, header sys_http.Header, data []byte) error {
+	// Make sure we got the whole body.
+	if contentLength := header.Get("Content-Length"); contentLength != "" {
+		expected, err := strconv.ParseInt(contentLength, 10, 64)
+		if err != nil {
+			return &IntegrityError{
+				key,
+				fmt.Sprintf("invalid Content-Length: %s", contentLength),
+			}
+		}
+
+		if int64(len(data)) != expected {
+			return &IntegrityError{
+				key,
+				fmt.Sprintf("got %d bytes; expected %d", len(data), expected),
+			}
+		}
+	}
+
+	sum := md5.Sum(data)
+
+	// The ETag of an object uploaded in a single request is the hex-encoded MD5
+	// of its contents, unless the object is encrypted with a KMS key or a key
+	// supplied by the customer. Other ETags (e.g. those of multipart objects,
+	// which contain a dash) are opaque.
+	etag := strings.Trim(header.Get("ETag"), `"`)
+	expected, err := hex.DecodeString(etag)
+	if err == nil && len(expected) == md5.Size && ETagIsMD5(header) {
+		if !bytes.Equal(expected, sum[:]) {
+			return &IntegrityError{
+				key,
+				fmt.Sprintf("MD5 %x doesn't match ETag %s", sum, etag),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/jacobsa/aws/s3/http"
+	"sort"
+	"strings"
+	sys_time "time"
+)
+
+// ObjectInfo describes an object returned by ListObjects.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified sys_time.Time
+
+	// The object's ETag, as sent by S3 (including quotes).
+	ETag string
+}
+
+func (b *bucket) ListObjects(
+	prefix string,
+	delimiter string,
+	prevKey string) (objects []ObjectInfo, prefixes []string, err error) {
+	// Make sure the prefix and previous key are empty or valid.
+	if err := validateKey(prefix); err != nil && prefix != "" {
+		return nil, nil, err
+	}
+
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, nil, err
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTBucketGET.html
+	httpReq := &http.Request{
+		Verb: "GET",
+		Path: fmt.Sprintf("/%s", b.name),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+		Parameters: map[string]string{},
+	}
+
+	if prefix != "" {
+		httpReq.Parameters["prefix"] = prefix
+	}
+
+	if delimiter != "" {
+		httpReq.Parameters["delimiter"] = delimiter
+	}
+
+	if prevKey != "" {
+		httpReq.Parameters["marker"] = prevKey
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 200 {
+		return nil, nil, serverError(httpResp)
+	}
+
+	// Attempt to parse the body.
+	body, err := httpResp.ReadBody()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := listBucketResult{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, nil, fmt.Errorf(
+			"Invalid data from server (%s): %s",
+			err.Error(),
+			body)
+	}
+
+	if result.XMLName.Local != "ListBucketResult" {
+		return nil, nil, fmt.Errorf("Invalid data from server: %s", body)
+	}
+
+	for _, elem := range result.Contents {
+		lastModified, err := sys_time.Parse(sys_time.RFC3339, elem.LastModified)
+		if err != nil {
+			return nil, nil, fmt.Errorf(
+				"Invalid LastModified from server: %q",
+				elem.LastModified)
+		}
+
+		objects = append(objects, ObjectInfo{
+			Key:          elem.Key,
+			Size:         elem.Size,
+			LastModified: lastModified,
+			ETag:         elem.ETag,
+		})
+	}
+
+	for _, elem := range result.CommonPrefixes {
+		prefixes = append(prefixes, elem.Prefix)
+	}
+
+	return
+}
+
+// Return the page of objects and prefixes that S3 would list for a bucket
+// containing the supplied unordered keys, calling info to describe each object
+// listed. The page holds at most localPageSize objects and prefixes in all.
+func localListObjects(
+	all []string,
+	prefix string,
+	delimiter string,
+	prevKey string,
+	info func(key string) (ObjectInfo, error)) (
+	objects []ObjectInfo,
+	prefixes []string,
+	err error) {
+	var keys []string
+	for _, key := range all {
+		if key > prevKey && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if len(objects)+len(prefixes) >= localPageSize {
+			break
+		}
+
+		// Roll up keys containing the delimiter, skipping prefixes listed
+		// previously.
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				if p > prevKey &&
+					(len(prefixes) == 0 || prefixes[len(prefixes)-1] != p) {
+					prefixes = append(prefixes, p)
+				}
+
+				continue
+			}
+		}
+
+		var o ObjectInfo
+		if o, err = info(key); err != nil {
+			return nil, nil, err
+		}
+
+		objects = append(objects, o)
+	}
+
+	return
+}
@@ -0,0 +1,298 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListObjects(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// ListObjects
+////////////////////////////////////////////////////////////////////////
+
+type ListObjectsTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&ListObjectsTest{}) }
+
+func (t *ListObjectsTest) PrefixNotValidUtf8() {
+	// Call
+	_, _, err := t.bucket.ListObjects("\x80\x81\x82", "", "")
+
+	ExpectThat(err, Error(HasSubstr("UTF-8")))
+}
+
+func (t *ListObjectsTest) PrevKeyTooLong() {
+	// Call
+	_, _, err := t.bucket.ListObjects("", "", strings.Repeat("a", 1025))
+
+	ExpectThat(err, Error(HasSubstr("1024")))
+}
+
+func (t *ListObjectsTest) CallsSignerWithNoParameters() {
+	// Clock
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
+
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.ListObjects("", "", "")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("GET", httpReq.Verb)
+	ExpectEq("/some.bucket", httpReq.Path)
+	ExpectEq("Mon, 18 Mar 1985 15:33:17 UTC", httpReq.Headers["Date"])
+	ExpectEq(0, len(httpReq.Parameters))
+}
+
+func (t *ListObjectsTest) CallsSignerWithParameters() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.ListObjects("taco/", "/", "taco/burrito")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("taco/", httpReq.Parameters["prefix"])
+	ExpectEq("/", httpReq.Parameters["delimiter"])
+	ExpectEq("taco/burrito", httpReq.Parameters["marker"])
+}
+
+func (t *ListObjectsTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 500,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, _, err := t.bucket.ListObjects("", "", "")
+
+	ExpectThat(err, Error(HasSubstr("500")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *ListObjectsTest) WrongRootTag() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body: stringReadCloser(`
+			<?xml version="1.0" encoding="UTF-8"?>
+			<FooBar xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+			</FooBar>`),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, _, err := t.bucket.ListObjects("", "", "")
+
+	ExpectThat(err, Error(HasSubstr("Invalid")))
+	ExpectThat(err, Error(HasSubstr("FooBar")))
+}
+
+func (t *ListObjectsTest) InvalidLastModified() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body: stringReadCloser(`
+			<?xml version="1.0" encoding="UTF-8"?>
+			<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+				<Contents>
+					<Key>taco</Key>
+					<LastModified>yesterday</LastModified>
+				</Contents>
+			</ListBucketResult>`),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, _, err := t.bucket.ListObjects("", "", "")
+
+	ExpectThat(err, Error(HasSubstr("LastModified")))
+	ExpectThat(err, Error(HasSubstr("yesterday")))
+}
+
+func (t *ListObjectsTest) ResponseContainsObjectsAndPrefixes() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body: stringReadCloser(`
+			<?xml version="1.0" encoding="UTF-8"?>
+			<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+				<Contents>
+					<Key>a/bar</Key>
+					<LastModified>2012-08-15T22:56:01.000Z</LastModified>
+					<ETag>"deadbeef"</ETag>
+					<Size>17</Size>
+				</Contents>
+				<Contents>
+					<Key>a/foo</Key>
+					<LastModified>2013-01-02T03:04:05.000Z</LastModified>
+					<ETag>"feedface"</ETag>
+					<Size>0</Size>
+				</Contents>
+				<CommonPrefixes>
+					<Prefix>a/baz/</Prefix>
+				</CommonPrefixes>
+			</ListBucketResult>`),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	objects, prefixes, err := t.bucket.ListObjects("a/", "/", "")
+	AssertEq(nil, err)
+
+	AssertEq(2, len(objects))
+
+	ExpectEq("a/bar", objects[0].Key)
+	ExpectEq(17, objects[0].Size)
+	ExpectEq(`"deadbeef"`, objects[0].ETag)
+	ExpectTrue(
+		objects[0].LastModified.Equal(
+			time.Date(2012, time.August, 15, 22, 56, 1, 0, time.UTC)))
+
+	ExpectEq("a/foo", objects[1].Key)
+	ExpectEq(0, objects[1].Size)
+
+	ExpectThat(prefixes, ElementsAre("a/baz/"))
+}
+
+////////////////////////////////////////////////////////////////////////
+// localListObjects
+////////////////////////////////////////////////////////////////////////
+
+type LocalListObjectsTest struct {
+	keys []string
+}
+
+func init() { RegisterTestSuite(&LocalListObjectsTest{}) }
+
+func (t *LocalListObjectsTest) SetUp(i *TestInfo) {
+	t.keys = []string{"b/c", "a", "b/d/e", "b/d/f", "b/", "c/g", "b/h"}
+}
+
+func (t *LocalListObjectsTest) list(
+	prefix string,
+	delimiter string,
+	prevKey string) (keys []string, prefixes []string) {
+	info := func(key string) (ObjectInfo, error) {
+		return ObjectInfo{Key: key}, nil
+	}
+
+	objects, prefixes, err := localListObjects(
+		t.keys,
+		prefix,
+		delimiter,
+		prevKey,
+		info)
+
+	AssertEq(nil, err)
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+
+	return
+}
+
+func (t *LocalListObjectsTest) NoPrefixOrDelimiter() {
+	keys, prefixes := t.list("", "", "")
+
+	ExpectThat(keys, ElementsAre("a", "b/", "b/c", "b/d/e", "b/d/f", "b/h", "c/g"))
+	ExpectThat(prefixes, ElementsAre())
+}
+
+func (t *LocalListObjectsTest) Prefix() {
+	keys, prefixes := t.list("b/d", "", "")
+
+	ExpectThat(keys, ElementsAre("b/d/e", "b/d/f"))
+	ExpectThat(prefixes, ElementsAre())
+}
+
+func (t *LocalListObjectsTest) Delimiter() {
+	keys, prefixes := t.list("", "/", "")
+
+	ExpectThat(keys, ElementsAre("a"))
+	ExpectThat(prefixes, ElementsAre("b/", "c/"))
+}
+
+func (t *LocalListObjectsTest) PrefixAndDelimiter() {
+	keys, prefixes := t.list("b/", "/", "")
+
+	ExpectThat(keys, ElementsAre("b/", "b/c", "b/h"))
+	ExpectThat(prefixes, ElementsAre("b/d/"))
+}
+
+func (t *LocalListObjectsTest) PrevKeyIsPrefix() {
+	keys, prefixes := t.list("b/", "/", "b/d/")
+
+	ExpectThat(keys, ElementsAre("b/h"))
+	ExpectThat(prefixes, ElementsAre())
+}
+
+func (t *LocalListObjectsTest) InfoReturnsError() {
+	info := func(key string) (ObjectInfo, error) {
+		return ObjectInfo{}, errors.New("taco")
+	}
+
+	_, _, err := localListObjects(t.keys, "", "", "", info)
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
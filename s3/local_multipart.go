@@ -0,0 +1,218 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"sync"
+	sys_time "time"
+)
+
+// Multipart upload state for the local (in-memory and filesystem-backed)
+// buckets. Uploads are held in memory until completed.
+type localUploads struct {
+	mutex   sync.Mutex
+	nextId  uint64                  // Protected by mutex
+	uploads map[string]*localUpload // Protected by mutex
+}
+
+type localUpload struct {
+	key       string
+	initiated sys_time.Time
+	parts     map[int][]byte
+}
+
+func localError(statusCode int, code string, message string) error {
+	return &ServerError{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    fmt.Sprintf("%s: %s", code, message),
+	}
+}
+
+func noSuchUploadError(uploadId string) error {
+	return localError(
+		404,
+		"NoSuchUpload",
+		fmt.Sprintf("The specified upload does not exist: %s", uploadId))
+}
+
+func (u *localUploads) initiate(
+	key string,
+	now sys_time.Time) (uploadId string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.uploads == nil {
+		u.uploads = make(map[string]*localUpload)
+	}
+
+	// Pad the ID so that IDs sort in order of creation.
+	u.nextId++
+	uploadId = fmt.Sprintf("upload-%016d", u.nextId)
+	u.uploads[uploadId] = &localUpload{
+		key:       key,
+		initiated: now,
+		parts:     make(map[int][]byte),
+	}
+
+	return
+}
+
+// Find the upload with the supplied ID and key. u.mutex must be held.
+func (u *localUploads) find(
+	key string,
+	uploadId string) (upload *localUpload, err error) {
+	upload, ok := u.uploads[uploadId]
+	if !ok || upload.key != key {
+		return nil, noSuchUploadError(uploadId)
+	}
+
+	return upload, nil
+}
+
+func partETag(data []byte) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum(data))
+}
+
+func (u *localUploads) uploadPart(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte) (etag string, err error) {
+	if partNumber < 1 || partNumber > MaxParts {
+		return "", fmt.Errorf("Invalid part number: %d", partNumber)
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	upload, err := u.find(key, uploadId)
+	if err != nil {
+		return
+	}
+
+	// Copy the data so that the caller can't modify our version.
+	upload.parts[partNumber] = append([]byte{}, data...)
+	etag = partETag(data)
+	return
+}
+
+// Remove the upload and return the assembled object data.
+func (u *localUploads) complete(
+	key string,
+	uploadId string,
+	parts []Part) (data []byte, err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	upload, err := u.find(key, uploadId)
+	if err != nil {
+		return
+	}
+
+	if len(parts) == 0 {
+		return nil, localError(
+			400,
+			"MalformedXML",
+			"You must specify at least one part.")
+	}
+
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber <= parts[i-1].PartNumber {
+			return nil, localError(
+				400,
+				"InvalidPartOrder",
+				"The list of parts was not in ascending order.")
+		}
+	}
+
+	for i, p := range parts {
+		partData, ok := upload.parts[p.PartNumber]
+		if !ok || partETag(partData) != p.ETag {
+			return nil, localError(
+				400,
+				"InvalidPart",
+				fmt.Sprintf("Part %d could not be found.", p.PartNumber))
+		}
+
+		if i < len(parts)-1 && len(partData) < MinPartSize {
+			return nil, localError(
+				400,
+				"EntityTooSmall",
+				fmt.Sprintf("Part %d is smaller than the minimum.", p.PartNumber))
+		}
+
+		data = append(data, partData...)
+	}
+
+	delete(u.uploads, uploadId)
+	return
+}
+
+func (u *localUploads) abort(key string, uploadId string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if _, err := u.find(key, uploadId); err != nil {
+		return err
+	}
+
+	delete(u.uploads, uploadId)
+	return nil
+}
+
+type multipartUploadsByKey []MultipartUpload
+
+func (s multipartUploadsByKey) Len() int      { return len(s) }
+func (s multipartUploadsByKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s multipartUploadsByKey) Less(i, j int) bool {
+	if s[i].Key != s[j].Key {
+		return s[i].Key < s[j].Key
+	}
+
+	return s[i].UploadId < s[j].UploadId
+}
+
+func (u *localUploads) list(
+	prevKey string,
+	prevUploadId string) (uploads []MultipartUpload) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for id, upload := range u.uploads {
+		if upload.key < prevKey ||
+			(upload.key == prevKey && (prevUploadId == "" || id <= prevUploadId)) {
+			continue
+		}
+
+		uploads = append(uploads, MultipartUpload{
+			Key:       upload.key,
+			UploadId:  id,
+			Initiated: upload.initiated,
+		})
+	}
+
+	sort.Sort(multipartUploadsByKey(uploads))
+	if len(uploads) > localPageSize {
+		uploads = uploads[:localPageSize]
+	}
+
+	return
+}
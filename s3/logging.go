@@ -0,0 +1,105 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"github.com/jacobsa/aws/logging"
+	"github.com/jacobsa/aws/s3/http"
+	"io"
+	sys_time "time"
+)
+
+// The header in which S3 reports the ID it assigned to a request.
+const requestIdHeader = "x-amz-request-id"
+
+// NewLoggingInterceptor returns an interceptor that logs the requests a bucket
+// sends, as much as the level asks for. Pass it to OpenBucket.
+func NewLoggingInterceptor(
+	logger logging.Logger,
+	level logging.Level) http.Interceptor {
+	return &loggingInterceptor{logger, level}
+}
+
+type loggingInterceptor struct {
+	logger logging.Logger
+	level  logging.Level
+}
+
+func (l *loggingInterceptor) BeforeRetry(r *http.Request) {
+}
+
+func (l *loggingInterceptor) BeforeSign(r *http.Request) error {
+	return nil
+}
+
+func (l *loggingInterceptor) AfterSign(r *http.Request) error {
+	return nil
+}
+
+func (l *loggingInterceptor) BeforeSend(r *http.Request) {
+}
+
+func (l *loggingInterceptor) AfterResponse(
+	r *http.Request,
+	latency sys_time.Duration,
+	resp *http.Response,
+	err error) (*http.Response, error) {
+	e := &logging.Entry{
+		Service:    "s3",
+		Operation:  operationName(r),
+		Verb:       r.Verb,
+		Path:       r.Path,
+		Parameters: logging.Redact(r.Parameters),
+		Headers:    logging.Redact(r.Headers),
+		Latency:    latency,
+		Err:        err,
+	}
+
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		e.RequestId = resp.Header.Get(requestIdHeader)
+	}
+
+	if l.level == logging.LevelErrors && !e.Failed() {
+		return resp, err
+	}
+
+	if l.level >= logging.LevelBodies {
+		e.RequestBody, _, _ = http.PeekBody(r.Body, logging.MaxBodyBytes)
+		if resp != nil && resp.Body != nil {
+			e.ResponseBody = peekResponseBody(resp)
+		}
+	}
+
+	l.logger.Log(e)
+	return resp, err
+}
+
+// Return the start of the response body, arranging for the caller to still be
+// able to read all of it.
+func peekResponseBody(resp *http.Response) []byte {
+	prefix := make([]byte, logging.MaxBodyBytes)
+	n, _ := io.ReadFull(resp.Body, prefix)
+	prefix = prefix[:n]
+
+	resp.Body = &struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+
+	return prefix
+}
@@ -0,0 +1,191 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jacobsa/aws/logging"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	sys_http "net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogging(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A logger that records the entries it is given.
+type recordingLogger struct {
+	entries []*logging.Entry
+}
+
+func (l *recordingLogger) Log(e *logging.Entry) {
+	l.entries = append(l.entries, e)
+}
+
+type LoggingTest struct {
+	logger recordingLogger
+}
+
+func init() { RegisterTestSuite(&LoggingTest{}) }
+
+// Send the request through an interceptor with the supplied level, taking
+// the supplied time and returning the supplied outcome.
+func (t *LoggingTest) send(
+	level logging.Level,
+	r *http.Request,
+	latency time.Duration,
+	resp *http.Response,
+	err error) (*http.Response, error) {
+	i := NewLoggingInterceptor(&t.logger, level)
+
+	AssertEq(nil, i.BeforeSign(r))
+	AssertEq(nil, i.AfterSign(r))
+	i.BeforeSend(r)
+	return i.AfterResponse(r, latency, resp, err)
+}
+
+func newRequest() *http.Request {
+	return &http.Request{
+		Verb:       "PUT",
+		Path:       "/bucket/foo",
+		Parameters: map[string]string{},
+		Headers: map[string]string{
+			"Date":                 "some_date",
+			"Authorization":        "AWS foo:bar",
+			"x-amz-security-token": "taco",
+		},
+		Body: bytes.NewReader([]byte("burrito")),
+	}
+}
+
+func newResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     sys_http.Header{"X-Amz-Request-Id": []string{"ABC123"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *LoggingTest) ErrorsLevelSkipsSuccesses() {
+	_, err := t.send(
+		logging.LevelErrors,
+		newRequest(),
+		time.Second,
+		newResponse(200, ""),
+		nil)
+
+	AssertEq(nil, err)
+	ExpectEq(0, len(t.logger.entries))
+}
+
+func (t *LoggingTest) ErrorsLevelLogsFailures() {
+	_, err := t.send(
+		logging.LevelErrors,
+		newRequest(),
+		time.Second,
+		newResponse(403, ""),
+		nil)
+
+	AssertEq(nil, err)
+	AssertEq(1, len(t.logger.entries))
+	ExpectEq(403, t.logger.entries[0].StatusCode)
+
+	_, err = t.send(
+		logging.LevelErrors,
+		newRequest(),
+		time.Second,
+		nil,
+		errors.New("taco"))
+
+	ExpectThat(err, Error(Equals("taco")))
+	AssertEq(2, len(t.logger.entries))
+	ExpectThat(t.logger.entries[1].Err, Error(Equals("taco")))
+}
+
+func (t *LoggingTest) RequestsLevel() {
+	r := newRequest()
+	r.Parameters["uploadId"] = "enchilada"
+
+	_, err := t.send(
+		logging.LevelRequests,
+		r,
+		3*time.Second,
+		newResponse(200, "queso"),
+		nil)
+
+	AssertEq(nil, err)
+	AssertEq(1, len(t.logger.entries))
+	e := t.logger.entries[0]
+
+	ExpectEq("s3", e.Service)
+	ExpectEq("UploadPart", e.Operation)
+	ExpectEq("PUT", e.Verb)
+	ExpectEq("/bucket/foo", e.Path)
+	ExpectEq("enchilada", e.Parameters["uploadId"])
+	ExpectEq("some_date", e.Headers["Date"])
+	ExpectEq(logging.Redacted, e.Headers["Authorization"])
+	ExpectEq(logging.Redacted, e.Headers["x-amz-security-token"])
+	ExpectEq(200, e.StatusCode)
+	ExpectEq("ABC123", e.RequestId)
+	ExpectEq(3*time.Second, e.Latency)
+	ExpectEq(nil, e.Err)
+	ExpectEq(nil, e.RequestBody)
+	ExpectEq(nil, e.ResponseBody)
+
+	// The request itself should be untouched.
+	ExpectEq("AWS foo:bar", r.Headers["Authorization"])
+}
+
+func (t *LoggingTest) BodiesLevel() {
+	r := newRequest()
+	longBody := strings.Repeat("x", logging.MaxBodyBytes+17)
+
+	resp, err := t.send(
+		logging.LevelBodies,
+		r,
+		time.Second,
+		newResponse(200, longBody),
+		nil)
+
+	AssertEq(nil, err)
+	AssertEq(1, len(t.logger.entries))
+	e := t.logger.entries[0]
+
+	ExpectEq("burrito", string(e.RequestBody))
+	ExpectEq(longBody[:logging.MaxBodyBytes], string(e.ResponseBody))
+
+	// Both bodies should still be readable in full.
+	data, err := ioutil.ReadAll(r.Body)
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(data))
+
+	data, err = resp.ReadBody()
+	AssertEq(nil, err)
+	ExpectEq(longBody, string(data))
+}
@@ -0,0 +1,391 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"crypto/md5"
+	"fmt"
+	"github.com/jacobsa/aws/time"
+	"io"
+	"io/ioutil"
+	sys_http "net/http"
+	"sort"
+	"strconv"
+	"sync"
+	sys_time "time"
+)
+
+// The maximum number of keys returned by a single call to ListKeys on a local
+// (in-memory or filesystem-backed) bucket. This matches the default page size
+// of real S3.
+const localPageSize = 1000
+
+// NewMemBucket returns a Bucket that stores its objects in memory, useful for
+// unit tests and local tooling that want real bucket behavior without talking
+// to S3. The bucket is initially empty, and is safe for concurrent use.
+//
+// The bucket applies the same key validation as the one returned by
+// OpenBucket, lists keys in the same order with the same page size, and
+// returns 404 errors for missing keys.
+func NewMemBucket() Bucket {
+	return newMemBucket(time.RealClock())
+}
+
+// A version of NewMemBucket with the ability to inject dependencies, for
+// testability.
+func newMemBucket(clock time.Clock) Bucket {
+	return &memBucket{
+		clock:   clock,
+		objects: make(map[string]*memObject),
+	}
+}
+
+type memObject struct {
+	data         []byte
+	lastModified sys_time.Time
+}
+
+type memBucket struct {
+	clock   time.Clock
+	uploads localUploads
+
+	mutex   sync.RWMutex
+	objects map[string]*memObject // Protected by mutex
+}
+
+func noSuchKeyError(key string) error {
+	return localError(
+		404,
+		"NoSuchKey",
+		fmt.Sprintf("The specified key does not exist: %s", key))
+}
+
+func invalidRangeError(offset int64, size int) error {
+	return localError(
+		416,
+		"InvalidRange",
+		fmt.Sprintf("Offset %d is not within object of size %d.", offset, size))
+}
+
+func preconditionFailedError(key string) error {
+	return localError(
+		412,
+		"PreconditionFailed",
+		fmt.Sprintf("The object has changed: %s", key))
+}
+
+// Return the portion of data within the supplied range, as GetObjectRange.
+func localRange(
+	data []byte,
+	offset int64,
+	length int64) (result []byte, err error) {
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("Invalid range: %d, %d", offset, length)
+	}
+
+	if len(data) > 0 && offset >= int64(len(data)) {
+		return nil, invalidRangeError(offset, len(data))
+	}
+
+	return sliceRange(data, offset, length), nil
+}
+
+// Return headers mimicking those returned by S3 for a HEAD request on an
+// object with the supplied properties.
+func localHeader(
+	size int64,
+	md5Sum []byte,
+	lastModified sys_time.Time) sys_http.Header {
+	header := sys_http.Header{}
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	header.Set("Content-Type", "binary/octet-stream")
+	header.Set("ETag", fmt.Sprintf("\"%x\"", md5Sum))
+	header.Set(
+		"Last-Modified",
+		lastModified.UTC().Format(sys_http.TimeFormat))
+
+	return header
+}
+
+// Return the keys in the supplied unordered list that are strictly greater
+// than prevKey, in order, truncated to a single page.
+func localListPage(all []string, prevKey string) (keys []string) {
+	for _, key := range all {
+		if key > prevKey {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	if len(keys) > localPageSize {
+		keys = keys[:localPageSize]
+	}
+
+	return
+}
+
+func (b *memBucket) GetObject(key string) (data []byte, err error) {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, noSuchKeyError(key)
+	}
+
+	// Copy the data so that the caller can't modify our version.
+	data = make([]byte, len(obj.data))
+	copy(data, obj.data)
+
+	return
+}
+
+func (b *memBucket) GetHeader(key string) (header sys_http.Header, err error) {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	obj, ok := b.objects[key]
+	if !ok {
+		return sys_http.Header{}, noSuchKeyError(key)
+	}
+
+	md5Sum := md5.Sum(obj.data)
+	header = localHeader(int64(len(obj.data)), md5Sum[:], obj.lastModified)
+	return
+}
+
+func (b *memBucket) StoreObject(key string, data []byte) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	// Copy the data so that the caller can't modify our version.
+	obj := &memObject{
+		data:         make([]byte, len(data)),
+		lastModified: b.clock.Now(),
+	}
+
+	copy(obj.data, data)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.objects[key] = obj
+	return nil
+}
+
+func (b *memBucket) DeleteObject(key string) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Like S3, deleting a non-existent object is not an error.
+	delete(b.objects, key)
+	return nil
+}
+
+func (b *memBucket) Put(key string, data io.ReadSeeker) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	// Read all of the data, starting at the beginning.
+	if _, err := data.Seek(0, 0); err != nil {
+		return fmt.Errorf("Seek: %v", err)
+	}
+
+	contents, err := ioutil.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("ReadAll: %v", err)
+	}
+
+	return b.StoreObject(key, contents)
+}
+
+func (b *memBucket) NewWriter(key string) io.WriteCloser {
+	return newObjectWriter(b, key)
+}
+
+func (b *memBucket) ListKeys(prevKey string) (keys []string, err error) {
+	// Make sure the previous key is empty or valid.
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	all := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		all = append(all, key)
+	}
+
+	keys = localListPage(all, prevKey)
+	return
+}
+
+func (b *memBucket) ListObjects(
+	prefix string,
+	delimiter string,
+	prevKey string) (objects []ObjectInfo, prefixes []string, err error) {
+	// Make sure the prefix and previous key are empty or valid.
+	if err := validateKey(prefix); err != nil && prefix != "" {
+		return nil, nil, err
+	}
+
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	all := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		all = append(all, key)
+	}
+
+	info := func(key string) (ObjectInfo, error) {
+		obj := b.objects[key]
+		md5Sum := md5.Sum(obj.data)
+		o := ObjectInfo{
+			Key:          key,
+			Size:         int64(len(obj.data)),
+			LastModified: obj.lastModified,
+			ETag:         fmt.Sprintf("\"%x\"", md5Sum),
+		}
+
+		return o, nil
+	}
+
+	return localListObjects(all, prefix, delimiter, prevKey, info)
+}
+
+func (b *memBucket) GetObjectRange(
+	key string,
+	offset int64,
+	length int64) (data []byte, err error) {
+	return b.GetObjectRangeIfMatch(key, offset, length, "")
+}
+
+func (b *memBucket) GetObjectRangeIfMatch(
+	key string,
+	offset int64,
+	length int64,
+	etag string) (data []byte, err error) {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, noSuchKeyError(key)
+	}
+
+	if etag != "" && fmt.Sprintf("\"%x\"", md5.Sum(obj.data)) != etag {
+		return nil, preconditionFailedError(key)
+	}
+
+	data, err = localRange(obj.data, offset, length)
+	if err != nil {
+		return
+	}
+
+	// Copy the data so that the caller can't modify our version.
+	data = append([]byte{}, data...)
+	return
+}
+
+func (b *memBucket) InitiateMultipartUpload(
+	key string) (uploadId string, err error) {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	return b.uploads.initiate(key, b.clock.Now()), nil
+}
+
+func (b *memBucket) UploadPart(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte) (etag string, err error) {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	return b.uploads.uploadPart(key, uploadId, partNumber, data)
+}
+
+func (b *memBucket) CompleteMultipartUpload(
+	key string,
+	uploadId string,
+	parts []Part) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	data, err := b.uploads.complete(key, uploadId, parts)
+	if err != nil {
+		return err
+	}
+
+	return b.StoreObject(key, data)
+}
+
+func (b *memBucket) AbortMultipartUpload(key string, uploadId string) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	return b.uploads.abort(key, uploadId)
+}
+
+func (b *memBucket) ListMultipartUploads(
+	prevKey string,
+	prevUploadId string) (uploads []MultipartUpload, err error) {
+	// Make sure the previous key is empty or valid.
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, err
+	}
+
+	uploads = b.uploads.list(prevKey, prevUploadId)
+	return
+}
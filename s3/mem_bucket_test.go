@@ -0,0 +1,387 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemBucket(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type MemBucketTest struct {
+	clock  *aws_time.SimulatedClock
+	bucket Bucket
+}
+
+func init() { RegisterTestSuite(&MemBucketTest{}) }
+
+func (t *MemBucketTest) SetUp(i *TestInfo) {
+	t.clock = aws_time.NewSimulatedClock(time.Time{})
+	t.bucket = newMemBucket(t.clock)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *MemBucketTest) InvalidKeys() {
+	keys := []string{
+		"",
+		"\x80\x81\x82",
+		strings.Repeat("a", 1025),
+		"taco\x00burrito",
+	}
+
+	for _, key := range keys {
+		_, err := t.bucket.GetObject(key)
+		ExpectNe(nil, err, "Key: %q", key)
+
+		_, err = t.bucket.GetHeader(key)
+		ExpectNe(nil, err, "Key: %q", key)
+
+		err = t.bucket.StoreObject(key, []byte{})
+		ExpectNe(nil, err, "Key: %q", key)
+
+		err = t.bucket.Put(key, bytes.NewReader([]byte{}))
+		ExpectNe(nil, err, "Key: %q", key)
+
+		err = t.bucket.DeleteObject(key)
+		ExpectNe(nil, err, "Key: %q", key)
+	}
+
+	_, err := t.bucket.ListKeys("\x80\x81\x82")
+	ExpectThat(err, Error(HasSubstr("UTF-8")))
+}
+
+func (t *MemBucketTest) GetNonExistentObject() {
+	_, err := t.bucket.GetObject("taco")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	_, err = t.bucket.GetHeader("taco")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *MemBucketTest) StoreThenGet() {
+	data := []byte("taco")
+	AssertEq(nil, t.bucket.StoreObject("some_key", data))
+
+	// Modifying the caller's buffer should have no effect.
+	data[0] = 'x'
+
+	returned, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(returned))
+
+	// Nor should modifying the returned buffer.
+	returned[0] = 'x'
+
+	returned, err = t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(returned))
+}
+
+func (t *MemBucketTest) PutThenGetHeader() {
+	t.clock.SetTime(time.Date(2012, 8, 15, 22, 56, 0, 0, time.Local))
+
+	err := t.bucket.Put("some_key", strings.NewReader("taco"))
+	AssertEq(nil, err)
+
+	header, err := t.bucket.GetHeader("some_key")
+	AssertEq(nil, err)
+
+	ExpectEq("4", header.Get("Content-Length"))
+	ExpectEq("\"f869ce1c8414a264bb11e14a2c8850ed\"", header.Get("ETag"))
+	ExpectEq(
+		t.clock.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"),
+		header.Get("Last-Modified"))
+}
+
+func (t *MemBucketTest) PutReadsFromBeginning() {
+	r := strings.NewReader("taco")
+	_, err := r.Seek(2, 0)
+	AssertEq(nil, err)
+
+	AssertEq(nil, t.bucket.Put("some_key", r))
+
+	returned, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(returned))
+}
+
+func (t *MemBucketTest) Overwrite() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("taco")))
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("burrito")))
+
+	returned, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(returned))
+}
+
+func (t *MemBucketTest) Delete() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("taco")))
+	AssertEq(nil, t.bucket.DeleteObject("some_key"))
+
+	_, err := t.bucket.GetObject("some_key")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	// Deleting again should succeed, as with S3.
+	ExpectEq(nil, t.bucket.DeleteObject("some_key"))
+}
+
+func (t *MemBucketTest) ListEmptyBucket() {
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre())
+}
+
+func (t *MemBucketTest) ListKeysInOrder() {
+	for _, key := range []string{"taco", "burrito", "enchilada", "queso"} {
+		AssertEq(nil, t.bucket.StoreObject(key, []byte{}))
+	}
+
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("burrito", "enchilada", "queso", "taco"))
+
+	keys, err = t.bucket.ListKeys("enchilada")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("queso", "taco"))
+
+	keys, err = t.bucket.ListKeys("foo")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("queso", "taco"))
+
+	keys, err = t.bucket.ListKeys("taco")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre())
+}
+
+func (t *MemBucketTest) ListKeysPaginates() {
+	const numKeys = 2500
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("%08d", i)
+		AssertEq(nil, t.bucket.StoreObject(key, []byte{}))
+	}
+
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	AssertEq(1000, len(keys))
+	ExpectEq("00000000", keys[0])
+	ExpectEq("00000999", keys[999])
+
+	keys, err = t.bucket.ListKeys(keys[999])
+	AssertEq(nil, err)
+	AssertEq(1000, len(keys))
+	ExpectEq("00001000", keys[0])
+
+	keys, err = t.bucket.ListKeys("00001999")
+	AssertEq(nil, err)
+	AssertEq(500, len(keys))
+	ExpectEq("00002499", keys[499])
+}
+
+func (t *MemBucketTest) ListObjects() {
+	toCreate := []string{"a/b/c", "a/d", "a/b/e", "f", "a/g"}
+	for _, key := range toCreate {
+		AssertEq(nil, t.bucket.StoreObject(key, []byte("taco")))
+	}
+
+	objects, prefixes, err := t.bucket.ListObjects("a/", "/", "")
+	AssertEq(nil, err)
+	AssertEq(2, len(objects))
+	ExpectEq("a/d", objects[0].Key)
+	ExpectEq(4, objects[0].Size)
+	ExpectEq(`"f869ce1c8414a264bb11e14a2c8850ed"`, objects[0].ETag)
+	ExpectEq("a/g", objects[1].Key)
+	ExpectThat(prefixes, ElementsAre("a/b/"))
+
+	// Continuing from a rolled-up prefix should skip it.
+	objects, prefixes, err = t.bucket.ListObjects("a/", "/", "a/b/")
+	AssertEq(nil, err)
+	AssertEq(2, len(objects))
+	ExpectEq("a/d", objects[0].Key)
+	ExpectThat(prefixes, ElementsAre())
+}
+
+func (t *MemBucketTest) GetObjectRange() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("tacoburrito")))
+
+	data, err := t.bucket.GetObjectRange("some_key", 4, 3)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	data, err = t.bucket.GetObjectRange("some_key", 8, 100)
+	AssertEq(nil, err)
+	ExpectEq("ito", string(data))
+
+	_, err = t.bucket.GetObjectRange("some_key", 11, 1)
+	ExpectThat(err, Error(HasSubstr("416")))
+
+	_, err = t.bucket.GetObjectRange("other_key", 0, 1)
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *MemBucketTest) GetObjectRangeIfMatch() {
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("tacoburrito")))
+
+	header, err := t.bucket.GetHeader("some_key")
+	AssertEq(nil, err)
+	etag := header.Get("ETag")
+
+	data, err := t.bucket.GetObjectRangeIfMatch("some_key", 4, 3, etag)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	AssertEq(nil, t.bucket.StoreObject("some_key", []byte("enchilada")))
+
+	_, err = t.bucket.GetObjectRangeIfMatch("some_key", 4, 3, etag)
+	ExpectThat(err, Error(HasSubstr("412")))
+	ExpectThat(err, Error(HasSubstr("PreconditionFailed")))
+}
+
+func (t *MemBucketTest) MultipartUpload() {
+	part1 := bytes.Repeat([]byte("a"), MinPartSize)
+
+	uploadId, err := t.bucket.InitiateMultipartUpload("some_key")
+	AssertEq(nil, err)
+
+	etag2, err := t.bucket.UploadPart("some_key", uploadId, 2, []byte("taco"))
+	AssertEq(nil, err)
+	ExpectEq("\"f869ce1c8414a264bb11e14a2c8850ed\"", etag2)
+
+	etag1, err := t.bucket.UploadPart("some_key", uploadId, 1, part1)
+	AssertEq(nil, err)
+
+	// Parts out of order should be rejected.
+	parts := []Part{
+		Part{PartNumber: 2, ETag: etag2},
+		Part{PartNumber: 1, ETag: etag1},
+	}
+
+	err = t.bucket.CompleteMultipartUpload("some_key", uploadId, parts)
+	ExpectThat(err, Error(HasSubstr("InvalidPartOrder")))
+
+	// In order, they should be assembled.
+	parts[0], parts[1] = parts[1], parts[0]
+	err = t.bucket.CompleteMultipartUpload("some_key", uploadId, parts)
+	AssertEq(nil, err)
+
+	data, err := t.bucket.GetObject("some_key")
+	AssertEq(nil, err)
+	ExpectEq(MinPartSize+4, len(data))
+	ExpectEq("taco", string(data[MinPartSize:]))
+
+	// The upload is now gone.
+	err = t.bucket.AbortMultipartUpload("some_key", uploadId)
+	ExpectThat(err, Error(HasSubstr("NoSuchUpload")))
+}
+
+func (t *MemBucketTest) MultipartUploadErrors() {
+	uploadId, err := t.bucket.InitiateMultipartUpload("some_key")
+	AssertEq(nil, err)
+
+	// Wrong key
+	_, err = t.bucket.UploadPart("other_key", uploadId, 1, []byte{})
+	ExpectThat(err, Error(HasSubstr("NoSuchUpload")))
+
+	// Small non-final part
+	etag1, err := t.bucket.UploadPart("some_key", uploadId, 1, []byte("a"))
+	AssertEq(nil, err)
+
+	etag2, err := t.bucket.UploadPart("some_key", uploadId, 2, []byte("b"))
+	AssertEq(nil, err)
+
+	parts := []Part{
+		Part{PartNumber: 1, ETag: etag1},
+		Part{PartNumber: 2, ETag: etag2},
+	}
+
+	err = t.bucket.CompleteMultipartUpload("some_key", uploadId, parts)
+	ExpectThat(err, Error(HasSubstr("EntityTooSmall")))
+
+	// Wrong ETag
+	parts = []Part{Part{PartNumber: 1, ETag: etag2}}
+	err = t.bucket.CompleteMultipartUpload("some_key", uploadId, parts)
+	ExpectThat(err, Error(HasSubstr("InvalidPart")))
+
+	// Abort
+	AssertEq(nil, t.bucket.AbortMultipartUpload("some_key", uploadId))
+
+	_, err = t.bucket.GetObject("some_key")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *MemBucketTest) ListMultipartUploads() {
+	t0 := time.Date(2012, time.August, 15, 22, 56, 0, 0, time.UTC)
+
+	t.clock.SetTime(t0)
+	id0, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+
+	t.clock.SetTime(t0.Add(time.Second))
+	id1, err := t.bucket.InitiateMultipartUpload("bar")
+	AssertEq(nil, err)
+
+	id2, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+
+	// Everything
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	AssertEq(3, len(uploads))
+
+	ExpectEq("bar", uploads[0].Key)
+	ExpectEq(id1, uploads[0].UploadId)
+	ExpectTrue(t0.Add(time.Second).Equal(uploads[0].Initiated))
+
+	ExpectEq("foo", uploads[1].Key)
+	ExpectEq(id0, uploads[1].UploadId)
+	ExpectTrue(t0.Equal(uploads[1].Initiated))
+
+	ExpectEq("foo", uploads[2].Key)
+	ExpectEq(id2, uploads[2].UploadId)
+
+	// After a key
+	uploads, err = t.bucket.ListMultipartUploads("bar", "")
+	AssertEq(nil, err)
+	AssertEq(2, len(uploads))
+	ExpectEq(id0, uploads[0].UploadId)
+	ExpectEq(id2, uploads[1].UploadId)
+
+	// After an upload
+	uploads, err = t.bucket.ListMultipartUploads("foo", id0)
+	AssertEq(nil, err)
+	AssertEq(1, len(uploads))
+	ExpectEq(id2, uploads[0].UploadId)
+
+	// Aborted uploads disappear.
+	AssertEq(nil, t.bucket.AbortMultipartUpload("foo", id2))
+
+	uploads, err = t.bucket.ListMultipartUploads("foo", id0)
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
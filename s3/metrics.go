@@ -0,0 +1,134 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/jacobsa/aws/metrics"
+	"github.com/jacobsa/aws/s3/http"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	sys_time "time"
+)
+
+// NewMetricsInterceptor returns an interceptor that reports every request a
+// bucket sends to the supplied sink, under the service name "s3" and the name
+// of the S3 API operation (e.g. "GetObject"). Pass it to OpenBucket.
+func NewMetricsInterceptor(sink metrics.Sink) http.Interceptor {
+	return &metricsInterceptor{sink}
+}
+
+type metricsInterceptor struct {
+	sink metrics.Sink
+}
+
+func (m *metricsInterceptor) BeforeRetry(r *http.Request) {
+	m.sink.RecordRetry("s3", operationName(r))
+}
+
+func (m *metricsInterceptor) BeforeSign(r *http.Request) error {
+	return nil
+}
+
+func (m *metricsInterceptor) AfterSign(r *http.Request) error {
+	return nil
+}
+
+func (m *metricsInterceptor) BeforeSend(r *http.Request) {
+}
+
+func (m *metricsInterceptor) AfterResponse(
+	r *http.Request,
+	latency sys_time.Duration,
+	resp *http.Response,
+	err error) (*http.Response, error) {
+	_, bytesSent, _ := http.PeekBody(r.Body, 0)
+
+	a := metrics.Attempt{
+		Service:   "s3",
+		Operation: operationName(r),
+		BytesSent: bytesSent,
+		Latency:   latency,
+	}
+
+	if resp != nil {
+		a.StatusCode = resp.StatusCode
+
+		// Responses to HEAD requests give the length of the body they would
+		// have had.
+		if r.Verb != "HEAD" {
+			a.BytesReceived, _ = strconv.ParseInt(
+				resp.Header.Get("Content-Length"), 10, 64)
+		}
+
+		// Error responses carry a code in their body, which is small. Leave it
+		// available for the caller to read again.
+		if resp.StatusCode >= 300 && resp.Body != nil {
+			body, readErr := resp.ReadBody()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			var doc errorDocument
+			if readErr == nil && xml.Unmarshal(body, &doc) == nil {
+				a.ErrorCode = doc.Code
+			}
+
+			a.BytesReceived = int64(len(body))
+		}
+	}
+
+	m.sink.RecordAttempt(a)
+	return resp, err
+}
+
+// Return the name of the S3 API operation that the request performs.
+func operationName(r *http.Request) string {
+	_, uploads := r.Parameters["uploads"]
+	_, uploadId := r.Parameters["uploadId"]
+	hasKey := strings.Contains(strings.TrimPrefix(r.Path, "/"), "/")
+
+	switch {
+	case r.Verb == "GET" && uploads:
+		return "ListMultipartUploads"
+	case r.Verb == "GET" && uploadId:
+		return "ListParts"
+	case r.Verb == "GET" && !hasKey:
+		return "ListObjects"
+	case r.Verb == "GET":
+		return "GetObject"
+	case r.Verb == "HEAD":
+		return "HeadObject"
+	case r.Verb == "PUT" && uploadId:
+		return "UploadPart"
+	case r.Verb == "PUT" && !hasKey:
+		return "CreateBucket"
+	case r.Verb == "PUT":
+		return "PutObject"
+	case r.Verb == "POST" && uploads:
+		return "CreateMultipartUpload"
+	case r.Verb == "POST" && uploadId:
+		return "CompleteMultipartUpload"
+	case r.Verb == "DELETE" && uploadId:
+		return "AbortMultipartUpload"
+	case r.Verb == "DELETE" && !hasKey:
+		return "DeleteBucket"
+	case r.Verb == "DELETE":
+		return "DeleteObject"
+	}
+
+	return r.Verb
+}
@@ -0,0 +1,260 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jacobsa/aws/metrics"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	sys_http "net/http"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A sink that records what it is given.
+type recordingSink struct {
+	attempts []metrics.Attempt
+	retries  []string
+}
+
+func (s *recordingSink) RecordAttempt(a metrics.Attempt) {
+	s.attempts = append(s.attempts, a)
+}
+
+func (s *recordingSink) RecordRetry(service string, operation string) {
+	s.retries = append(s.retries, service+"/"+operation)
+}
+
+type MetricsTest struct {
+	sink        recordingSink
+	interceptor http.Interceptor
+}
+
+func init() { RegisterTestSuite(&MetricsTest{}) }
+
+func (t *MetricsTest) SetUp(i *TestInfo) {
+	t.interceptor = NewMetricsInterceptor(&t.sink)
+}
+
+// Send the request through the interceptor, taking the supplied time and
+// returning the supplied outcome.
+func (t *MetricsTest) send(
+	r *http.Request,
+	latency time.Duration,
+	resp *http.Response,
+	err error) (*http.Response, error) {
+	AssertEq(nil, t.interceptor.BeforeSign(r))
+	AssertEq(nil, t.interceptor.AfterSign(r))
+	t.interceptor.BeforeSend(r)
+	return t.interceptor.AfterResponse(r, latency, resp, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *MetricsTest) OperationNames() {
+	cases := []struct {
+		verb     string
+		path     string
+		param    string
+		expected string
+	}{
+		{"GET", "/bucket", "", "ListObjects"},
+		{"GET", "/bucket", "uploads", "ListMultipartUploads"},
+		{"GET", "/bucket/foo/bar", "", "GetObject"},
+		{"GET", "/bucket/foo", "uploadId", "ListParts"},
+		{"HEAD", "/bucket/foo", "", "HeadObject"},
+		{"PUT", "/bucket/foo", "", "PutObject"},
+		{"PUT", "/bucket", "", "CreateBucket"},
+		{"PUT", "/bucket/foo", "uploadId", "UploadPart"},
+		{"POST", "/bucket/foo", "uploads", "CreateMultipartUpload"},
+		{"POST", "/bucket/foo", "uploadId", "CompleteMultipartUpload"},
+		{"DELETE", "/bucket/foo", "", "DeleteObject"},
+		{"DELETE", "/bucket", "", "DeleteBucket"},
+		{"DELETE", "/bucket/foo", "uploadId", "AbortMultipartUpload"},
+		{"PATCH", "/bucket/foo", "", "PATCH"},
+	}
+
+	for _, c := range cases {
+		r := &http.Request{
+			Verb:       c.verb,
+			Path:       c.path,
+			Parameters: map[string]string{},
+		}
+
+		if c.param != "" {
+			r.Parameters[c.param] = ""
+		}
+
+		ExpectEq(
+			c.expected,
+			operationName(r),
+			"%s %s %s",
+			c.verb,
+			c.path,
+			c.param)
+	}
+}
+
+func (t *MetricsTest) SuccessfulRequest() {
+	r := &http.Request{
+		Verb:    "PUT",
+		Path:    "/bucket/foo",
+		Headers: map[string]string{},
+		Body:    bytes.NewReader([]byte("taco")),
+	}
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     sys_http.Header{"Content-Length": []string{"17"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}
+
+	// Call
+	gotResp, err := t.send(r, 3*time.Second, resp, nil)
+	AssertEq(nil, err)
+	ExpectEq(resp, gotResp)
+
+	AssertEq(1, len(t.sink.attempts))
+	a := t.sink.attempts[0]
+
+	ExpectEq("s3", a.Service)
+	ExpectEq("PutObject", a.Operation)
+	ExpectEq(200, a.StatusCode)
+	ExpectEq("", a.ErrorCode)
+	ExpectEq(4, a.BytesSent)
+	ExpectEq(17, a.BytesReceived)
+	ExpectEq(3*time.Second, a.Latency)
+
+	ExpectThat(t.sink.retries, ElementsAre())
+
+	// The body should still be readable from the start.
+	data, err := ioutil.ReadAll(r.Body)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+}
+
+func (t *MetricsTest) ErrorResponse() {
+	r := &http.Request{Verb: "GET", Path: "/bucket/foo"}
+
+	body := "<Error><Code>NoSuchKey</Code></Error>"
+	resp := &http.Response{
+		StatusCode: 404,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+	}
+
+	// Call
+	gotResp, err := t.send(r, time.Second, resp, nil)
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.sink.attempts))
+	a := t.sink.attempts[0]
+
+	ExpectEq("GetObject", a.Operation)
+	ExpectEq(404, a.StatusCode)
+	ExpectEq("NoSuchKey", a.ErrorCode)
+	ExpectEq(len(body), a.BytesReceived)
+
+	// The body should still be readable.
+	data, err := gotResp.ReadBody()
+	AssertEq(nil, err)
+	ExpectEq(body, string(data))
+}
+
+func (t *MetricsTest) HeadRequest() {
+	r := &http.Request{Verb: "HEAD", Path: "/bucket/foo"}
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     sys_http.Header{"Content-Length": []string{"17"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}
+
+	// Call
+	_, err := t.send(r, time.Second, resp, nil)
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.sink.attempts))
+	ExpectEq(0, t.sink.attempts[0].BytesReceived)
+}
+
+func (t *MetricsTest) ConnectionError() {
+	r := &http.Request{Verb: "GET", Path: "/bucket/foo"}
+
+	// Call
+	resp, err := t.send(r, time.Second, nil, errors.New("taco"))
+
+	ExpectEq(nil, resp)
+	ExpectThat(err, Error(Equals("taco")))
+
+	AssertEq(1, len(t.sink.attempts))
+	a := t.sink.attempts[0]
+
+	ExpectEq("GetObject", a.Operation)
+	ExpectEq(0, a.StatusCode)
+	ExpectEq(time.Second, a.Latency)
+}
+
+func (t *MetricsTest) Retry() {
+	r := &http.Request{
+		Verb:    "DELETE",
+		Path:    "/bucket/foo",
+		Headers: map[string]string{},
+	}
+
+	// The first attempt is redirected, and the request is retried.
+	_, err := t.send(r, time.Second, &http.Response{StatusCode: 301}, nil)
+	AssertEq(nil, err)
+
+	t.interceptor.BeforeRetry(r)
+
+	// The second succeeds.
+	_, err = t.send(r, time.Second, &http.Response{StatusCode: 204}, nil)
+	AssertEq(nil, err)
+
+	ExpectThat(t.sink.retries, ElementsAre("s3/DeleteObject"))
+
+	AssertEq(2, len(t.sink.attempts))
+	ExpectEq(301, t.sink.attempts[0].StatusCode)
+	ExpectEq(204, t.sink.attempts[1].StatusCode)
+}
+
+func (t *MetricsTest) RecordsIntoRegistry() {
+	registry := metrics.NewRegistry()
+	i := NewMetricsInterceptor(registry)
+
+	r := &http.Request{Verb: "GET", Path: "/bucket/foo"}
+	i.BeforeSend(r)
+	i.AfterResponse(r, 7*time.Millisecond, &http.Response{StatusCode: 200}, nil)
+
+	stats := registry.Snapshot()
+	AssertEq(1, len(stats))
+
+	ExpectEq("s3", stats[0].Service)
+	ExpectEq("GetObject", stats[0].Operation)
+	ExpectEq(1, stats[0].Attempts[metrics.Outcome{StatusCode: 200}])
+	ExpectEq(7*time.Millisecond, stats[0].Latency.Sum)
+}
@@ -43,6 +43,54 @@ func (m *mockBucket) Oglemock_Description() string {
 	return m.description
 }
 
+func (m *mockBucket) AbortMultipartUpload(p0 string, p1 string) (o0 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"AbortMultipartUpload",
+		file,
+		line,
+		[]interface{}{p0, p1})
+
+	if len(retVals) != 1 {
+		panic(fmt.Sprintf("mockBucket.AbortMultipartUpload: invalid return values: %v", retVals))
+	}
+
+	// o0 error
+	if retVals[0] != nil {
+		o0 = retVals[0].(error)
+	}
+
+	return
+}
+
+func (m *mockBucket) CompleteMultipartUpload(p0 string, p1 string, p2 []s3.Part) (o0 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"CompleteMultipartUpload",
+		file,
+		line,
+		[]interface{}{p0, p1, p2})
+
+	if len(retVals) != 1 {
+		panic(fmt.Sprintf("mockBucket.CompleteMultipartUpload: invalid return values: %v", retVals))
+	}
+
+	// o0 error
+	if retVals[0] != nil {
+		o0 = retVals[0].(error)
+	}
+
+	return
+}
+
 func (m *mockBucket) DeleteObject(p0 string) (o0 error) {
 	// Get a file name and line number for the caller.
 	_, file, line, _ := runtime.Caller(1)
@@ -125,6 +173,93 @@ func (m *mockBucket) GetObject(p0 string) (o0 []uint8, o1 error) {
 	return
 }
 
+func (m *mockBucket) GetObjectRange(p0 string, p1 int64, p2 int64) (o0 []uint8, o1 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"GetObjectRange",
+		file,
+		line,
+		[]interface{}{p0, p1, p2})
+
+	if len(retVals) != 2 {
+		panic(fmt.Sprintf("mockBucket.GetObjectRange: invalid return values: %v", retVals))
+	}
+
+	// o0 []uint8
+	if retVals[0] != nil {
+		o0 = retVals[0].([]uint8)
+	}
+
+	// o1 error
+	if retVals[1] != nil {
+		o1 = retVals[1].(error)
+	}
+
+	return
+}
+
+func (m *mockBucket) GetObjectRangeIfMatch(p0 string, p1 int64, p2 int64, p3 string) (o0 []uint8, o1 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"GetObjectRangeIfMatch",
+		file,
+		line,
+		[]interface{}{p0, p1, p2, p3})
+
+	if len(retVals) != 2 {
+		panic(fmt.Sprintf("mockBucket.GetObjectRangeIfMatch: invalid return values: %v", retVals))
+	}
+
+	// o0 []uint8
+	if retVals[0] != nil {
+		o0 = retVals[0].([]uint8)
+	}
+
+	// o1 error
+	if retVals[1] != nil {
+		o1 = retVals[1].(error)
+	}
+
+	return
+}
+
+func (m *mockBucket) InitiateMultipartUpload(p0 string) (o0 string, o1 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"InitiateMultipartUpload",
+		file,
+		line,
+		[]interface{}{p0})
+
+	if len(retVals) != 2 {
+		panic(fmt.Sprintf("mockBucket.InitiateMultipartUpload: invalid return values: %v", retVals))
+	}
+
+	// o0 string
+	if retVals[0] != nil {
+		o0 = retVals[0].(string)
+	}
+
+	// o1 error
+	if retVals[1] != nil {
+		o1 = retVals[1].(error)
+	}
+
+	return
+}
+
 func (m *mockBucket) ListKeys(p0 string) (o0 []string, o1 error) {
 	// Get a file name and line number for the caller.
 	_, file, line, _ := runtime.Caller(1)
@@ -154,6 +289,93 @@ func (m *mockBucket) ListKeys(p0 string) (o0 []string, o1 error) {
 	return
 }
 
+func (m *mockBucket) ListMultipartUploads(p0 string, p1 string) (o0 []s3.MultipartUpload, o1 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"ListMultipartUploads",
+		file,
+		line,
+		[]interface{}{p0, p1})
+
+	if len(retVals) != 2 {
+		panic(fmt.Sprintf("mockBucket.ListMultipartUploads: invalid return values: %v", retVals))
+	}
+
+	// o0 []s3.MultipartUpload
+	if retVals[0] != nil {
+		o0 = retVals[0].([]s3.MultipartUpload)
+	}
+
+	// o1 error
+	if retVals[1] != nil {
+		o1 = retVals[1].(error)
+	}
+
+	return
+}
+
+func (m *mockBucket) ListObjects(p0 string, p1 string, p2 string) (o0 []s3.ObjectInfo, o1 []string, o2 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"ListObjects",
+		file,
+		line,
+		[]interface{}{p0, p1, p2})
+
+	if len(retVals) != 3 {
+		panic(fmt.Sprintf("mockBucket.ListObjects: invalid return values: %v", retVals))
+	}
+
+	// o0 []s3.ObjectInfo
+	if retVals[0] != nil {
+		o0 = retVals[0].([]s3.ObjectInfo)
+	}
+
+	// o1 []string
+	if retVals[1] != nil {
+		o1 = retVals[1].([]string)
+	}
+
+	// o2 error
+	if retVals[2] != nil {
+		o2 = retVals[2].(error)
+	}
+
+	return
+}
+
+func (m *mockBucket) NewWriter(p0 string) (o0 io.WriteCloser) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"NewWriter",
+		file,
+		line,
+		[]interface{}{p0})
+
+	if len(retVals) != 1 {
+		panic(fmt.Sprintf("mockBucket.NewWriter: invalid return values: %v", retVals))
+	}
+
+	// o0 io.WriteCloser
+	if retVals[0] != nil {
+		o0 = retVals[0].(io.WriteCloser)
+	}
+
+	return
+}
+
 func (m *mockBucket) Put(p0 string, p1 io.ReadSeeker) (o0 error) {
 	// Get a file name and line number for the caller.
 	_, file, line, _ := runtime.Caller(1)
@@ -201,3 +423,32 @@ func (m *mockBucket) StoreObject(p0 string, p1 []uint8) (o0 error) {
 
 	return
 }
+
+func (m *mockBucket) UploadPart(p0 string, p1 string, p2 int, p3 []uint8) (o0 string, o1 error) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"UploadPart",
+		file,
+		line,
+		[]interface{}{p0, p1, p2, p3})
+
+	if len(retVals) != 2 {
+		panic(fmt.Sprintf("mockBucket.UploadPart: invalid return values: %v", retVals))
+	}
+
+	// o0 string
+	if retVals[0] != nil {
+		o0 = retVals[0].(string)
+	}
+
+	// o1 error
+	if retVals[1] != nil {
+		o1 = retVals[1].(error)
+	}
+
+	return
+}
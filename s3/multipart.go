@@ -0,0 +1,373 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/jacobsa/aws/s3/http"
+	"strconv"
+	sys_time "time"
+)
+
+// The minimum size in bytes of every part of a multipart upload but the last.
+const MinPartSize = 5 * 1024 * 1024
+
+// The maximum number of parts in a multipart upload.
+const MaxParts = 10000
+
+// Part describes a part of a multipart upload, for CompleteMultipartUpload.
+type Part struct {
+	// The part number passed to UploadPart.
+	PartNumber int
+
+	// The ETag returned by UploadPart.
+	ETag string
+}
+
+// MultipartUpload describes an in-progress multipart upload, as returned by
+// ListMultipartUploads.
+type MultipartUpload struct {
+	Key       string
+	UploadId  string
+	Initiated sys_time.Time
+}
+
+////////////////////////////////////////////////////////////////////////
+// InitiateMultipartUpload
+////////////////////////////////////////////////////////////////////////
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name
+	UploadId string
+}
+
+func (b *bucket) InitiateMultipartUpload(
+	key string) (uploadId string, err error) {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/mpUploadInitiate.html
+	httpReq := &http.Request{
+		Verb: "POST",
+		Path: fmt.Sprintf("/%s/%s", b.name, key),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+		Parameters: map[string]string{
+			"uploads": "",
+		},
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 200 {
+		return "", serverError(httpResp)
+	}
+
+	// Attempt to parse the body.
+	body, err := httpResp.ReadBody()
+	if err != nil {
+		return "", err
+	}
+
+	result := initiateMultipartUploadResult{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf(
+			"Invalid data from server (%s): %s",
+			err.Error(),
+			body)
+	}
+
+	if result.XMLName.Local != "InitiateMultipartUploadResult" ||
+		result.UploadId == "" {
+		return "", fmt.Errorf("Invalid data from server: %s", body)
+	}
+
+	return result.UploadId, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// UploadPart
+////////////////////////////////////////////////////////////////////////
+
+func (b *bucket) UploadPart(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte) (etag string, err error) {
+	md5Sum := md5.Sum(data)
+	return b.uploadPartWithMd5(key, uploadId, partNumber, data, md5Sum[:])
+}
+
+// Like UploadPart, but with the MD5 of the data already computed, as it is by
+// objectWriter.
+func (b *bucket) uploadPartWithMd5(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte,
+	md5Sum []byte) (etag string, err error) {
+	// Validate the key and part number.
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	if partNumber < 1 || partNumber > MaxParts {
+		return "", fmt.Errorf("Invalid part number: %d", partNumber)
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/mpUploadUploadPart.html
+	httpReq := &http.Request{
+		Verb: "PUT",
+		Path: fmt.Sprintf("/%s/%s", b.name, key),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+
+			// Send the MD5 of the data, as advised in the Amazon docs.
+			"Content-MD5": base64.StdEncoding.EncodeToString(md5Sum),
+		},
+		Parameters: map[string]string{
+			"partNumber": strconv.Itoa(partNumber),
+			"uploadId":   uploadId,
+		},
+		Body: bytes.NewReader(data),
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 200 {
+		return "", serverError(httpResp)
+	}
+
+	httpResp.Body.Close()
+
+	etag = httpResp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("No ETag in response from server.")
+	}
+
+	return etag, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// CompleteMultipartUpload
+////////////////////////////////////////////////////////////////////////
+
+type completeMultipartUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []Part   `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name
+}
+
+func (b *bucket) CompleteMultipartUpload(
+	key string,
+	uploadId string,
+	parts []Part) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	// Build the request body.
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("xml.Marshal: %v", err)
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/mpUploadComplete.html
+	httpReq := &http.Request{
+		Verb: "POST",
+		Path: fmt.Sprintf("/%s/%s", b.name, key),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+		Parameters: map[string]string{
+			"uploadId": uploadId,
+		},
+		Body: bytes.NewReader(body),
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 200 {
+		return serverError(httpResp)
+	}
+
+	// S3 may report an error after sending a 200 status code, so we must check
+	// the body too.
+	respBody, err := httpResp.ReadBody()
+	if err != nil {
+		return err
+	}
+
+	result := completeMultipartUploadResult{}
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf(
+			"Invalid data from server (%s): %s",
+			err.Error(),
+			respBody)
+	}
+
+	// S3 may report an error after it has sent a 200 status.
+	if result.XMLName.Local != "CompleteMultipartUploadResult" {
+		return parseServerError(200, respBody)
+	}
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// AbortMultipartUpload
+////////////////////////////////////////////////////////////////////////
+
+func (b *bucket) AbortMultipartUpload(key string, uploadId string) error {
+	// Validate the key.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/mpUploadAbort.html
+	httpReq := &http.Request{
+		Verb: "DELETE",
+		Path: fmt.Sprintf("/%s/%s", b.name, key),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+		Parameters: map[string]string{
+			"uploadId": uploadId,
+		},
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 204 {
+		return serverError(httpResp)
+	}
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// ListMultipartUploads
+////////////////////////////////////////////////////////////////////////
+
+type listMultipartUploadsResult struct {
+	XMLName xml.Name
+	Uploads []MultipartUpload `xml:"Upload"`
+}
+
+func (b *bucket) ListMultipartUploads(
+	prevKey string,
+	prevUploadId string) (uploads []MultipartUpload, err error) {
+	// Make sure the previous key is empty or valid.
+	if err := validateKey(prevKey); err != nil && prevKey != "" {
+		return nil, err
+	}
+
+	// Build an appropriate HTTP request.
+	//
+	// Reference:
+	//     http://docs.amazonwebservices.com/AmazonS3/latest/API/mpUploadListMPUpload.html
+	httpReq := &http.Request{
+		Verb: "GET",
+		Path: fmt.Sprintf("/%s", b.name),
+		Headers: map[string]string{
+			"Date": b.clock.Now().UTC().Format(sys_time.RFC1123),
+		},
+		Parameters: map[string]string{
+			"uploads": "",
+		},
+	}
+
+	if prevKey != "" {
+		httpReq.Parameters["key-marker"] = prevKey
+		if prevUploadId != "" {
+			httpReq.Parameters["upload-id-marker"] = prevUploadId
+		}
+	}
+
+	// Sign and send the request.
+	httpResp, err := b.sendRequest(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the response.
+	if httpResp.StatusCode != 200 {
+		return nil, serverError(httpResp)
+	}
+
+	// Attempt to parse the body.
+	body, err := httpResp.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+
+	result := listMultipartUploadsResult{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf(
+			"Invalid data from server (%s): %s",
+			err.Error(),
+			body)
+	}
+
+	if result.XMLName.Local != "ListMultipartUploadsResult" {
+		return nil, fmt.Errorf("Invalid data from server: %s", body)
+	}
+
+	return result.Uploads, nil
+}
@@ -0,0 +1,586 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"crypto/md5"
+	"errors"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	sys_http "net/http"
+	"testing"
+	"time"
+)
+
+func TestMultipart(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// InitiateMultipartUpload
+////////////////////////////////////////////////////////////////////////
+
+type InitiateMultipartUploadTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&InitiateMultipartUploadTest{}) }
+
+func (t *InitiateMultipartUploadTest) KeyIsEmpty() {
+	// Call
+	_, err := t.bucket.InitiateMultipartUpload("")
+
+	ExpectThat(err, Error(HasSubstr("empty")))
+}
+
+func (t *InitiateMultipartUploadTest) CallsSigner() {
+	// Clock
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC))
+
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.InitiateMultipartUpload("foo/bar")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("POST", httpReq.Verb)
+	ExpectEq("/some.bucket/foo/bar", httpReq.Path)
+	ExpectEq("Mon, 18 Mar 1985 15:33:17 UTC", httpReq.Headers["Date"])
+	ExpectThat(httpReq.Parameters, DeepEquals(map[string]string{"uploads": ""}))
+}
+
+func (t *InitiateMultipartUploadTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 500,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.InitiateMultipartUpload("a")
+
+	ExpectThat(err, Error(HasSubstr("500")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *InitiateMultipartUploadTest) ResponseBodyIsJunk() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       stringReadCloser("<Foo><UploadId>bar</UploadId></Foo>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.InitiateMultipartUpload("a")
+
+	ExpectThat(err, Error(HasSubstr("Invalid data")))
+	ExpectThat(err, Error(HasSubstr("Foo")))
+}
+
+func (t *InitiateMultipartUploadTest) ReturnsUploadId() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body: stringReadCloser(
+			"<InitiateMultipartUploadResult>" +
+				"<Bucket>some.bucket</Bucket>" +
+				"<Key>a</Key>" +
+				"<UploadId>taco</UploadId>" +
+				"</InitiateMultipartUploadResult>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	uploadId, err := t.bucket.InitiateMultipartUpload("a")
+	AssertEq(nil, err)
+
+	ExpectEq("taco", uploadId)
+}
+
+////////////////////////////////////////////////////////////////////////
+// UploadPart
+////////////////////////////////////////////////////////////////////////
+
+type UploadPartTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&UploadPartTest{}) }
+
+func (t *UploadPartTest) InvalidPartNumber() {
+	// Call
+	_, err := t.bucket.UploadPart("a", "b", 0, []byte{})
+	ExpectThat(err, Error(HasSubstr("part number")))
+
+	_, err = t.bucket.UploadPart("a", "b", MaxParts+1, []byte{})
+	ExpectThat(err, Error(HasSubstr("part number")))
+}
+
+func (t *UploadPartTest) CallsSigner() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.UploadPart("foo/bar", "taco", 17, []byte("burrito"))
+
+	AssertNe(nil, httpReq)
+	ExpectEq("PUT", httpReq.Verb)
+	ExpectEq("/some.bucket/foo/bar", httpReq.Path)
+	ExpectEq(computeBase64Md5([]byte("burrito")), httpReq.Headers["Content-MD5"])
+	ExpectThat(
+		httpReq.Parameters,
+		DeepEquals(map[string]string{"partNumber": "17", "uploadId": "taco"}))
+
+	body, err := ioutil.ReadAll(httpReq.Body)
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(body))
+}
+
+func (t *UploadPartTest) SendsSuppliedMd5() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	md5Sum := md5.Sum([]byte("taco"))
+	t.bucket.(md5PartUploader).uploadPartWithMd5(
+		"foo/bar",
+		"taco",
+		17,
+		[]byte("burrito"),
+		md5Sum[:])
+
+	AssertNe(nil, httpReq)
+	ExpectEq(computeBase64Md5([]byte("taco")), httpReq.Headers["Content-MD5"])
+}
+
+func (t *UploadPartTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 404,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.UploadPart("a", "b", 1, []byte{})
+
+	ExpectThat(err, Error(HasSubstr("404")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *UploadPartTest) ReturnsETag() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     sys_http.Header{"Etag": []string{`"deadbeef"`}},
+		Body:       stringReadCloser(""),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	etag, err := t.bucket.UploadPart("a", "b", 1, []byte{})
+	AssertEq(nil, err)
+
+	ExpectEq(`"deadbeef"`, etag)
+}
+
+func (t *UploadPartTest) MissingETag() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     sys_http.Header{},
+		Body:       stringReadCloser(""),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.UploadPart("a", "b", 1, []byte{})
+
+	ExpectThat(err, Error(HasSubstr("ETag")))
+}
+
+////////////////////////////////////////////////////////////////////////
+// CompleteMultipartUpload
+////////////////////////////////////////////////////////////////////////
+
+type CompleteMultipartUploadTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&CompleteMultipartUploadTest{}) }
+
+func (t *CompleteMultipartUploadTest) CallsSigner() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	parts := []Part{
+		Part{PartNumber: 1, ETag: "burrito"},
+		Part{PartNumber: 3, ETag: "enchilada"},
+	}
+
+	t.bucket.CompleteMultipartUpload("foo/bar", "taco", parts)
+
+	AssertNe(nil, httpReq)
+	ExpectEq("POST", httpReq.Verb)
+	ExpectEq("/some.bucket/foo/bar", httpReq.Path)
+	ExpectThat(
+		httpReq.Parameters,
+		DeepEquals(map[string]string{"uploadId": "taco"}))
+
+	body, err := ioutil.ReadAll(httpReq.Body)
+	AssertEq(nil, err)
+	ExpectEq(
+		"<CompleteMultipartUpload>"+
+			"<Part><PartNumber>1</PartNumber><ETag>burrito</ETag></Part>"+
+			"<Part><PartNumber>3</PartNumber><ETag>enchilada</ETag></Part>"+
+			"</CompleteMultipartUpload>",
+		string(body))
+}
+
+func (t *CompleteMultipartUploadTest) ServerReturnsErrorStatus() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 400,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.CompleteMultipartUpload("a", "b", []Part{})
+
+	ExpectThat(err, Error(HasSubstr("400")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *CompleteMultipartUploadTest) ServerReturnsErrorInBody() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       stringReadCloser("<Error><Code>InternalError</Code></Error>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.CompleteMultipartUpload("a", "b", []Part{})
+
+	ExpectThat(err, Error(HasSubstr("server")))
+	ExpectThat(err, Error(HasSubstr("InternalError")))
+}
+
+func (t *CompleteMultipartUploadTest) ServerSaysOkay() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body: stringReadCloser(
+			"<CompleteMultipartUploadResult>" +
+				"<ETag>taco</ETag>" +
+				"</CompleteMultipartUploadResult>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.CompleteMultipartUpload("a", "b", []Part{})
+
+	ExpectEq(nil, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// AbortMultipartUpload
+////////////////////////////////////////////////////////////////////////
+
+type AbortMultipartUploadTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&AbortMultipartUploadTest{}) }
+
+func (t *AbortMultipartUploadTest) CallsSigner() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.AbortMultipartUpload("foo/bar", "taco")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("DELETE", httpReq.Verb)
+	ExpectEq("/some.bucket/foo/bar", httpReq.Path)
+	ExpectThat(
+		httpReq.Parameters,
+		DeepEquals(map[string]string{"uploadId": "taco"}))
+}
+
+func (t *AbortMultipartUploadTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 404,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.AbortMultipartUpload("a", "b")
+
+	ExpectThat(err, Error(HasSubstr("404")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *AbortMultipartUploadTest) ServerSaysOkay() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 204,
+		Body:       stringReadCloser(""),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	err := t.bucket.AbortMultipartUpload("a", "b")
+
+	ExpectEq(nil, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// ListMultipartUploads
+////////////////////////////////////////////////////////////////////////
+
+type ListMultipartUploadsTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&ListMultipartUploadsTest{}) }
+
+func (t *ListMultipartUploadsTest) CallsSignerWithoutMarkers() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.ListMultipartUploads("", "")
+
+	AssertNe(nil, httpReq)
+	ExpectEq("GET", httpReq.Verb)
+	ExpectEq("/some.bucket", httpReq.Path)
+	ExpectThat(
+		httpReq.Parameters,
+		DeepEquals(map[string]string{"uploads": ""}))
+}
+
+func (t *ListMultipartUploadsTest) CallsSignerWithMarkers() {
+	// Signer
+	var httpReq *http.Request
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) error {
+		httpReq = r
+		return errors.New("")
+	}))
+
+	// Call
+	t.bucket.ListMultipartUploads("foo", "taco")
+
+	AssertNe(nil, httpReq)
+	ExpectThat(
+		httpReq.Parameters,
+		DeepEquals(
+			map[string]string{
+				"uploads":          "",
+				"key-marker":       "foo",
+				"upload-id-marker": "taco",
+			}))
+}
+
+func (t *ListMultipartUploadsTest) ServerReturnsError() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 500,
+		Body:       stringReadCloser("taco"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.ListMultipartUploads("", "")
+
+	ExpectThat(err, Error(HasSubstr("500")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *ListMultipartUploadsTest) ResponseBodyIsJunk() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       stringReadCloser("<Foo>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.ListMultipartUploads("", "")
+
+	ExpectThat(err, Error(HasSubstr("Invalid data")))
+}
+
+func (t *ListMultipartUploadsTest) ReturnsUploads() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	body := `
+	<ListMultipartUploadsResult>
+	  <Bucket>some.bucket</Bucket>
+	  <Upload>
+	    <Key>bar</Key>
+	    <UploadId>taco</UploadId>
+	    <Initiated>2012-08-15T22:56:00.000Z</Initiated>
+	  </Upload>
+	  <Upload>
+	    <Key>foo</Key>
+	    <UploadId>burrito</UploadId>
+	    <Initiated>2012-08-16T01:02:03.000Z</Initiated>
+	  </Upload>
+	</ListMultipartUploadsResult>`
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       stringReadCloser(body),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	AssertEq(2, len(uploads))
+
+	ExpectEq("bar", uploads[0].Key)
+	ExpectEq("taco", uploads[0].UploadId)
+	ExpectTrue(
+		time.Date(2012, time.August, 15, 22, 56, 0, 0, time.UTC).Equal(
+			uploads[0].Initiated))
+
+	ExpectEq("foo", uploads[1].Key)
+	ExpectEq("burrito", uploads[1].UploadId)
+}
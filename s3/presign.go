@@ -0,0 +1,80 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3/auth"
+	"github.com/jacobsa/aws/s3/http"
+	"net/url"
+	sys_time "time"
+)
+
+// PresignedURL returns a URL that allows anyone holding it to make a request
+// with the given HTTP verb (e.g. "GET" or "PUT") for the object with the
+// supplied key in the named bucket until the expiration time, without
+// credentials of their own. The URL is signed using the access key returned
+// by the supplied provider; if that key is temporary, the URL stops working
+// when the key expires.
+//
+// The endpoint is as for OpenBucketAtEndpoint. For one of the regional
+// endpoints, use:
+//
+//     &url.URL{Scheme: "https", Host: string(region)}
+//
+// Requests made with the URL must not include a Content-MD5 or Content-Type
+// header, since neither is covered by the signature.
+func PresignedURL(
+	endpoint *url.URL,
+	bucketName string,
+	key string,
+	verb string,
+	expires sys_time.Time,
+	creds aws.CredentialsProvider) (u *url.URL, err error) {
+	// Validate the key.
+	if err = validateKey(key); err != nil {
+		return
+	}
+
+	// Sign an appropriate request.
+	httpReq := &http.Request{
+		Verb:       verb,
+		Path:       fmt.Sprintf("/%s/%s", bucketName, key),
+		Headers:    map[string]string{},
+		Parameters: map[string]string{},
+	}
+
+	if err = auth.Presign(httpReq, creds, expires); err != nil {
+		err = fmt.Errorf("Presign: %v", err)
+		return
+	}
+
+	// Turn it into a URL.
+	query := url.Values{}
+	for name, val := range httpReq.Parameters {
+		query.Set(name, val)
+	}
+
+	u = &url.URL{
+		Scheme:   endpoint.Scheme,
+		Host:     endpoint.Host,
+		Path:     httpReq.Path,
+		RawQuery: query.Encode(),
+	}
+
+	return
+}
@@ -0,0 +1,91 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"github.com/jacobsa/aws"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPresign(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type PresignedURLTest struct {
+	endpoint *url.URL
+	key      aws.AccessKey
+	expires  time.Time
+}
+
+func init() { RegisterTestSuite(&PresignedURLTest{}) }
+
+func (t *PresignedURLTest) SetUp(i *TestInfo) {
+	t.endpoint = &url.URL{Scheme: "https", Host: "s3.amazonaws.com"}
+	t.key = aws.AccessKey{Id: "some_id", Secret: "some_secret"}
+	t.expires = time.Unix(1175139620, 0)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *PresignedURLTest) KeyIsEmpty() {
+	_, err := PresignedURL(t.endpoint, "some.bucket", "", "GET", t.expires, t.key)
+
+	ExpectThat(err, Error(HasSubstr("empty")))
+}
+
+func (t *PresignedURLTest) CredentialsReturnError() {
+	_, err := PresignedURL(
+		t.endpoint,
+		"some.bucket",
+		"foo",
+		"GET",
+		t.expires,
+		aws.CredentialsChain())
+
+	ExpectThat(err, Error(HasSubstr("Presign")))
+	ExpectThat(err, Error(HasSubstr("No credentials")))
+}
+
+func (t *PresignedURLTest) ReturnsURL() {
+	t.key.SessionToken = "taco"
+	u, err := PresignedURL(
+		t.endpoint,
+		"some.bucket",
+		"foo/bar baz",
+		"GET",
+		t.expires,
+		t.key)
+
+	AssertEq(nil, err)
+
+	ExpectEq("https", u.Scheme)
+	ExpectEq("s3.amazonaws.com", u.Host)
+	ExpectEq("/some.bucket/foo/bar%20baz", u.EscapedPath())
+
+	query := u.Query()
+	ExpectEq("some_id", query.Get("AWSAccessKeyId"))
+	ExpectEq("1175139620", query.Get("Expires"))
+	ExpectEq("taco", query.Get("x-amz-security-token"))
+	ExpectNe("", query.Get("Signature"))
+}
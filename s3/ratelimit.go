@@ -0,0 +1,48 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"github.com/jacobsa/aws/ratelimit"
+	"github.com/jacobsa/aws/s3/http"
+	sys_time "time"
+)
+
+// NewRateLimitInterceptor returns an interceptor that makes a bucket consult
+// the supplied limiter before sending each request, under the name of the S3
+// API operation (e.g. "PutObject"), and tells it when S3 responds with 503
+// Slow Down. Pass it to OpenBucket. Time spent waiting isn't counted in the
+// latency seen by other interceptors.
+func NewRateLimitInterceptor(l ratelimit.Limiter) http.Interceptor {
+	return &http.InterceptorFuncs{
+		BeforeSendFunc: func(r *http.Request) {
+			_, size, _ := http.PeekBody(r.Body, 0)
+			l.Wait(operationName(r), size)
+		},
+
+		AfterResponseFunc: func(
+			r *http.Request,
+			latency sys_time.Duration,
+			resp *http.Response,
+			err error) (*http.Response, error) {
+			if resp != nil {
+				l.Observe(operationName(r), resp.StatusCode == 503)
+			}
+
+			return resp, err
+		},
+	}
+}
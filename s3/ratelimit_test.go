@@ -0,0 +1,104 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A limiter that records the calls made to it.
+type recordingLimiter struct {
+	calls []string
+}
+
+func (l *recordingLimiter) Wait(operation string, bytes int64) {
+	l.calls = append(l.calls, fmt.Sprintf("Wait(%s, %d)", operation, bytes))
+}
+
+func (l *recordingLimiter) Observe(operation string, throttled bool) {
+	l.calls = append(
+		l.calls,
+		fmt.Sprintf("Observe(%s, %v)", operation, throttled))
+}
+
+type RateLimitTest struct {
+	limiter     recordingLimiter
+	interceptor http.Interceptor
+}
+
+func init() { RegisterTestSuite(&RateLimitTest{}) }
+
+func (t *RateLimitTest) SetUp(i *TestInfo) {
+	t.interceptor = NewRateLimitInterceptor(&t.limiter)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *RateLimitTest) WaitsBeforeSending() {
+	r := &http.Request{
+		Verb: "PUT",
+		Path: "/bucket/foo",
+		Body: bytes.NewReader([]byte("taco")),
+	}
+
+	t.interceptor.BeforeSend(r)
+
+	ExpectThat(t.limiter.calls, ElementsAre("Wait(PutObject, 4)"))
+}
+
+func (t *RateLimitTest) ObservesResponses() {
+	r := &http.Request{Verb: "GET", Path: "/bucket/foo"}
+
+	for _, code := range []int{200, 503, 404} {
+		resp := &http.Response{StatusCode: code}
+		gotResp, err := t.interceptor.AfterResponse(r, time.Second, resp, nil)
+
+		AssertEq(nil, err)
+		ExpectEq(resp, gotResp)
+	}
+
+	ExpectThat(
+		t.limiter.calls,
+		ElementsAre(
+			"Observe(GetObject, false)",
+			"Observe(GetObject, true)",
+			"Observe(GetObject, false)",
+		))
+}
+
+func (t *RateLimitTest) IgnoresConnectionErrors() {
+	r := &http.Request{Verb: "GET", Path: "/bucket/foo"}
+
+	_, err := t.interceptor.AfterResponse(r, time.Second, nil, errors.New("taco"))
+
+	ExpectThat(err, Error(Equals("taco")))
+	ExpectThat(t.limiter.calls, ElementsAre())
+}
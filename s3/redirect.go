@@ -0,0 +1,105 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3/http"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// The maximum number of redirects followed for a single request.
+const maxRedirects = 3
+
+// The header in which S3 reports the region a bucket lives in.
+const bucketRegionHeader = "x-amz-bucket-region"
+
+// The body of a redirect response from S3.
+//
+// Reference:
+//     http://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
+type redirectError struct {
+	Endpoint string
+}
+
+// Is the response one that redirects us to another endpoint?
+func isRedirect(resp *http.Response) bool {
+	return resp.StatusCode == 301 || resp.StatusCode == 307
+}
+
+// Return the endpoint to which the redirect response points, or nil if it
+// can't be determined. The response body is consumed, but is left available
+// for the caller to read again.
+func (b *bucket) redirectEndpoint(
+	current *url.URL,
+	resp *http.Response) (endpoint *url.URL) {
+	// Prefer the region header, which S3 sends even in response to HEAD
+	// requests. Stay with the same kind of endpoint (e.g. dualstack) if the
+	// current one is known and the new region offers it.
+	if name := resp.Header.Get(bucketRegionHeader); name != "" {
+		variant := aws.EndpointStandard
+		if _, _, v, err := aws.RegionForEndpoint(current.Host); err == nil {
+			variant = v
+		}
+
+		region, err := RegionEndpoint(name, variant)
+		if err != nil {
+			region, err = RegionEndpoint(name, aws.EndpointStandard)
+		}
+
+		if err == nil {
+			if endpoint = b.withHost(current, string(region)); endpoint != nil {
+				return
+			}
+		}
+	}
+
+	// Fall back to the endpoint in the error document.
+	body, err := resp.ReadBody()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var doc redirectError
+	if err := xml.Unmarshal(body, &doc); err == nil && doc.Endpoint != "" {
+		return b.withHost(current, doc.Endpoint)
+	}
+
+	// Finally try the Location header, sent with temporary redirects.
+	if location, err := url.Parse(resp.Header.Get("Location")); err == nil &&
+		location.Host != "" {
+		return b.withHost(current, location.Host)
+	}
+
+	return nil
+}
+
+// Return a copy of the supplied endpoint with the given host. S3 reports
+// virtual-hosted endpoints such as "bucket.s3-eu-west-1.amazonaws.com", but we
+// address buckets by path, so any bucket name prefix is removed.
+func (b *bucket) withHost(endpoint *url.URL, host string) *url.URL {
+	host = strings.TrimPrefix(host, b.name+".")
+	if host == "" || host == endpoint.Host {
+		return nil
+	}
+
+	return &url.URL{Scheme: endpoint.Scheme, Host: host}
+}
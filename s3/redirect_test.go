@@ -0,0 +1,338 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	sys_http "net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type RedirectTest struct {
+	bucketTest
+}
+
+func init() { RegisterTestSuite(&RedirectTest{}) }
+
+func redirectResponse(
+	statusCode int,
+	header sys_http.Header,
+	body string) *http.Response {
+	if header == nil {
+		header = sys_http.Header{}
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       stringReadCloser(body),
+	}
+}
+
+func regionHeader(name string) sys_http.Header {
+	return sys_http.Header{"X-Amz-Bucket-Region": []string{name}}
+}
+
+func okResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: 200,
+		Header:     sys_http.Header{},
+		Body:       stringReadCloser(body),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *RedirectTest) PermanentRedirectWithRegionHeader() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, regionHeader("eu-west-1"), ""),
+		nil))
+
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(okResponse("taco"), nil))
+
+	// Call
+	data, err := t.bucket.GetObject("a")
+	AssertEq(nil, err)
+
+	ExpectEq("taco", string(data))
+	AssertEq(1, len(t.newConnEndpoints))
+	ExpectEq("https://s3-eu-west-1.amazonaws.com", t.newConnEndpoints[0].String())
+	ExpectEq(1, len(t.retried))
+}
+
+func (t *RedirectTest) PermanentRedirectWithEndpointInBody() {
+	body := `
+		<Error>
+			<Code>PermanentRedirect</Code>
+			<Message>Use the specified endpoint.</Message>
+			<Endpoint>some.bucket.s3-ap-northeast-1.amazonaws.com</Endpoint>
+			<Bucket>some.bucket</Bucket>
+		</Error>`
+
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(redirectResponse(301, nil, body), nil))
+
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(okResponse("taco"), nil))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.newConnEndpoints))
+	ExpectEq(
+		"https://s3-ap-northeast-1.amazonaws.com",
+		t.newConnEndpoints[0].String())
+}
+
+func (t *RedirectTest) PermanentRedirectIsRemembered() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(3).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, regionHeader("eu-west-1"), ""),
+		nil))
+
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(okResponse("taco"), nil)).
+		WillOnce(oglemock.Return(okResponse("burrito"), nil))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+	AssertEq(nil, err)
+
+	data, err := t.bucket.GetObject("b")
+	AssertEq(nil, err)
+
+	ExpectEq("burrito", string(data))
+	ExpectEq(1, len(t.newConnEndpoints))
+}
+
+func (t *RedirectTest) TemporaryRedirectIsNotRemembered() {
+	header := sys_http.Header{
+		"Location": []string{"https://some.bucket.s3-eu-west-1.amazonaws.com/a"},
+	}
+
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(3).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(redirectResponse(307, header, ""), nil)).
+		WillOnce(oglemock.Return(okResponse("burrito"), nil))
+
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(okResponse("taco"), nil))
+
+	// Call
+	data, err := t.bucket.GetObject("a")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+
+	data, err = t.bucket.GetObject("b")
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(data))
+
+	AssertEq(1, len(t.newConnEndpoints))
+	ExpectEq("https://s3-eu-west-1.amazonaws.com", t.newConnEndpoints[0].String())
+}
+
+func (t *RedirectTest) KeepsEndpointVariant() {
+	t.endpoint = &url.URL{
+		Scheme: "http",
+		Host:   "s3.dualstack.us-east-1.amazonaws.com",
+	}
+
+	var err error
+	t.bucket, err = openBucket(
+		"some.bucket",
+		t.endpoint,
+		t.httpConn,
+		t.newConn,
+		t.signer,
+		t.interceptor,
+		nil,
+		t.clock)
+	AssertEq(nil, err)
+
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, regionHeader("eu-west-1"), ""),
+		nil))
+
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(okResponse("taco"), nil))
+
+	// Call
+	_, err = t.bucket.GetObject("a")
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.newConnEndpoints))
+	ExpectEq(
+		"http://s3.dualstack.eu-west-1.amazonaws.com",
+		t.newConnEndpoints[0].String())
+}
+
+func (t *RedirectTest) ResendsBody() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		ioutil.ReadAll(r.Body)
+		return redirectResponse(301, regionHeader("eu-west-1"), ""), nil
+	}))
+
+	var body []byte
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		body, _ = ioutil.ReadAll(r.Body)
+		return okResponse(""), nil
+	}))
+
+	// Call
+	err := t.bucket.StoreObject("a", []byte("taco"))
+	AssertEq(nil, err)
+
+	ExpectEq("taco", string(body))
+}
+
+func (t *RedirectTest) UnknownEndpoint() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, nil, "<Error><Code>taco</Code></Error>"),
+		nil))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("301")))
+	ExpectThat(err, Error(HasSubstr("<Code>taco</Code>")))
+	ExpectEq(0, len(t.newConnEndpoints))
+}
+
+func (t *RedirectTest) RedirectToSameEndpoint() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, regionHeader("us-east-1"), ""),
+		nil))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("301")))
+	ExpectEq(0, len(t.newConnEndpoints))
+}
+
+func (t *RedirectTest) TooManyRedirects() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		Times(4).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// Conns
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, regionHeader("eu-west-1"), ""),
+		nil))
+
+	regions := []string{"us-west-2", "eu-west-1", "us-west-2"}
+	ExpectCall(t.otherConn, "SendRequest")(Any()).
+		Times(3).
+		WillRepeatedly(oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		name := regions[0]
+		regions = regions[1:]
+		return redirectResponse(301, regionHeader(name), ""), nil
+	}))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("301")))
+	ExpectEq(3, len(t.newConnEndpoints))
+}
+
+func (t *RedirectTest) SignReturnsErrorAfterRedirect() {
+	// Signer
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillOnce(oglemock.Return(nil)).
+		WillOnce(oglemock.Return(errors.New("taco")))
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(
+		redirectResponse(301, regionHeader("eu-west-1"), ""),
+		nil))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("Sign")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
@@ -15,10 +15,15 @@
 
 package s3
 
+import (
+	"fmt"
+	"github.com/jacobsa/aws"
+)
+
 // Region represents a regional endpoint to S3. Resources created within one
 // region are entirely independent of those created in others. You should use
-// one of the region constants defined by this package when referring to
-// regions.
+// one of the region constants defined by this package or ParseRegion when
+// referring to regions.
 //
 // See here for more info:
 //
@@ -36,3 +41,56 @@ const (
 	RegionApacTokyo            Region = "s3-ap-northeast-1.amazonaws.com"
 	RegionSouthAmericaSaoPaulo Region = "s3-sa-east-1.amazonaws.com"
 )
+
+// ParseRegion returns the S3 endpoint for the region with the supplied name,
+// e.g. "eu-west-1". A string that is already the hostname of a standard S3
+// endpoint is also accepted.
+func ParseRegion(s string) (r Region, err error) {
+	if info, lookupErr := aws.LookupRegion(s); lookupErr == nil {
+		return RegionEndpoint(info.Name, aws.EndpointStandard)
+	}
+
+	info, service, variant, err := aws.RegionForEndpoint(s)
+	if err != nil || service != aws.ServiceS3 ||
+		variant != aws.EndpointStandard {
+		err = fmt.Errorf("Unknown S3 region: %q", s)
+		return
+	}
+
+	return RegionEndpoint(info.Name, aws.EndpointStandard)
+}
+
+// RegionEndpoint returns the supplied variant of the S3 endpoint for the
+// region with the given name.
+func RegionEndpoint(
+	name string,
+	variant aws.EndpointVariant) (r Region, err error) {
+	info, err := aws.LookupRegion(name)
+	if err != nil {
+		return
+	}
+
+	host, err := info.Endpoint(aws.ServiceS3, variant)
+	if err != nil {
+		return
+	}
+
+	r = Region(host)
+	return
+}
+
+// Name returns the name of the AWS region served by the endpoint, e.g.
+// "eu-west-1", as used to scope Signature Version 4 credentials.
+func (r Region) Name() (name string, err error) {
+	info, service, _, err := aws.RegionForEndpoint(string(r))
+	if err == nil && service != aws.ServiceS3 {
+		err = fmt.Errorf("Not a S3 endpoint: %q", string(r))
+	}
+
+	if err != nil {
+		return
+	}
+
+	name = info.Name
+	return
+}
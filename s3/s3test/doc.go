@@ -0,0 +1,37 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3test contains an in-process fake S3 server, useful for testing
+// code that uses package s3 without access to a real AWS account.
+//
+// The server speaks the subset of the S3 REST API used by package s3: getting
+// (optionally by byte range), heading, storing, and deleting objects, listing
+// the keys in a bucket, and multipart uploads. Requests are authenticated
+// using the same signing scheme as real S3, and Content-MD5 headers are
+// checked.
+//
+// For example:
+//
+//     key := aws.AccessKey{Id: "some_id", Secret: "some_secret"}
+//     server := s3test.NewServer(key)
+//     defer server.Close()
+//
+//     server.CreateBucket("some_bucket")
+//     bucket, err := s3.OpenBucketAtEndpoint(
+//         "some_bucket",
+//         server.Endpoint(),
+//         key)
+//
+package s3test
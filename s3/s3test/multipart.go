@@ -0,0 +1,348 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	sys_http "net/http"
+	"sort"
+	"strconv"
+	sys_time "time"
+)
+
+// The minimum size of every part of a multipart upload but the last.
+const minPartSize = 5 * 1024 * 1024
+
+// The maximum part number for a multipart upload.
+const maxPartNumber = 10000
+
+type uploadedPart struct {
+	data []byte
+	etag string
+}
+
+type upload struct {
+	key       string
+	initiated sys_time.Time
+	parts     map[int]*uploadedPart
+}
+
+func noSuchUpload() *errorResponse {
+	return &errorResponse{
+		StatusCode: 404,
+		Code:       "NoSuchUpload",
+		Message: "The specified upload does not exist. The upload ID may be " +
+			"invalid, or the upload may have been aborted or completed.",
+	}
+}
+
+// Find the upload named by the request's uploadId parameter.
+func findUpload(
+	r *sys_http.Request,
+	key string,
+	b *bucket) (uploadId string, u *upload, e *errorResponse) {
+	uploadId = r.URL.Query().Get("uploadId")
+	u, ok := b.uploads[uploadId]
+	if !ok || u.key != key {
+		e = noSuchUpload()
+		return
+	}
+
+	return
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+func (s *Server) initiateMultipartUpload(
+	w sys_http.ResponseWriter,
+	r *sys_http.Request,
+	key string,
+	b *bucket) *errorResponse {
+	// Use the request ID, which is unique, as the basis for the upload ID.
+	uploadId := fmt.Sprintf(
+		"upload-%s",
+		w.Header().Get("x-amz-request-id"))
+
+	b.uploads[uploadId] = &upload{
+		key:       key,
+		initiated: s.clock.Now(),
+		parts:     make(map[int]*uploadedPart),
+	}
+
+	bucketName, _ := splitPath(r.URL.Path)
+	s.writeXml(w, &initiateMultipartUploadResult{
+		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:   bucketName,
+		Key:      key,
+		UploadId: uploadId,
+	})
+
+	return nil
+}
+
+func (s *Server) uploadPart(
+	w sys_http.ResponseWriter,
+	r *sys_http.Request,
+	key string,
+	body []byte,
+	b *bucket) *errorResponse {
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		return &errorResponse{
+			StatusCode: 400,
+			Code:       "InvalidArgument",
+			Message: "Part number must be an integer between 1 and 10000, " +
+				"inclusive",
+		}
+	}
+
+	_, u, e := findUpload(r, key, b)
+	if e != nil {
+		return e
+	}
+
+	if e := checkContentMd5(r, body); e != nil {
+		return e
+	}
+
+	sum := md5.Sum(body)
+	part := &uploadedPart{
+		data: body,
+		etag: fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:])),
+	}
+
+	u.parts[partNumber] = part
+
+	w.Header().Set("ETag", part.etag)
+	w.WriteHeader(200)
+	return nil
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int
+		ETag       string
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+func (s *Server) completeMultipartUpload(
+	w sys_http.ResponseWriter,
+	r *sys_http.Request,
+	key string,
+	body []byte,
+	b *bucket) *errorResponse {
+	uploadId, u, e := findUpload(r, key, b)
+	if e != nil {
+		return e
+	}
+
+	var req completeMultipartUpload
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		return &errorResponse{
+			StatusCode: 400,
+			Code:       "MalformedXML",
+			Message: "The XML you provided was not well-formed or did not " +
+				"validate against our published schema",
+		}
+	}
+
+	// Assemble the object. Its ETag is the MD5 of the concatenated binary MD5s
+	// of the parts, followed by the number of parts.
+	for i := 1; i < len(req.Parts); i++ {
+		if req.Parts[i].PartNumber <= req.Parts[i-1].PartNumber {
+			return &errorResponse{
+				StatusCode: 400,
+				Code:       "InvalidPartOrder",
+				Message: "The list of parts was not in ascending order. Parts " +
+					"must be ordered by part number.",
+			}
+		}
+	}
+
+	var data []byte
+	etagHash := md5.New()
+	for i, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		if !ok || part.etag != p.ETag {
+			return &errorResponse{
+				StatusCode: 400,
+				Code:       "InvalidPart",
+				Message: "One or more of the specified parts could not be " +
+					"found. The part might not have been uploaded, or the " +
+					"specified entity tag might not have matched the part's " +
+					"entity tag.",
+			}
+		}
+
+		if i < len(req.Parts)-1 && len(part.data) < minPartSize {
+			return &errorResponse{
+				StatusCode: 400,
+				Code:       "EntityTooSmall",
+				Message: "Your proposed upload is smaller than the minimum " +
+					"allowed object size.",
+			}
+		}
+
+		sum := md5.Sum(part.data)
+		etagHash.Write(sum[:])
+		data = append(data, part.data...)
+	}
+
+	o := &object{
+		data: data,
+		etag: fmt.Sprintf(
+			`"%s-%d"`,
+			hex.EncodeToString(etagHash.Sum(nil)),
+			len(req.Parts)),
+		contentType:  "binary/octet-stream",
+		lastModified: s.clock.Now(),
+	}
+
+	b.objects[key] = o
+	delete(b.uploads, uploadId)
+
+	bucketName, _ := splitPath(r.URL.Path)
+	s.writeXml(w, &completeMultipartUploadResult{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket: bucketName,
+		Key:    key,
+		ETag:   o.etag,
+	})
+
+	return nil
+}
+
+func (s *Server) abortMultipartUpload(
+	w sys_http.ResponseWriter,
+	r *sys_http.Request,
+	key string,
+	b *bucket) *errorResponse {
+	uploadId, _, e := findUpload(r, key, b)
+	if e != nil {
+		return e
+	}
+
+	delete(b.uploads, uploadId)
+	w.WriteHeader(204)
+	return nil
+}
+
+type listedUpload struct {
+	Key          string
+	UploadId     string
+	StorageClass string
+	Initiated    string
+}
+
+type listedUploads []listedUpload
+
+func (s listedUploads) Len() int      { return len(s) }
+func (s listedUploads) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s listedUploads) Less(i, j int) bool {
+	if s[i].Key != s[j].Key {
+		return s[i].Key < s[j].Key
+	}
+
+	return s[i].UploadId < s[j].UploadId
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name `xml:"ListMultipartUploadsResult"`
+	Xmlns              string   `xml:"xmlns,attr"`
+	Bucket             string
+	KeyMarker          string
+	UploadIdMarker     string
+	NextKeyMarker      string
+	NextUploadIdMarker string
+	MaxUploads         int
+	IsTruncated        bool
+	Uploads            listedUploads `xml:"Upload"`
+}
+
+func (s *Server) listMultipartUploads(
+	w sys_http.ResponseWriter,
+	r *sys_http.Request,
+	bucketName string,
+	b *bucket) *errorResponse {
+	query := r.URL.Query()
+	keyMarker := query.Get("key-marker")
+	uploadIdMarker := query.Get("upload-id-marker")
+
+	result := listMultipartUploadsResult{
+		Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:         bucketName,
+		KeyMarker:      keyMarker,
+		UploadIdMarker: uploadIdMarker,
+		MaxUploads:     maxKeysPerList,
+	}
+
+	// Find the uploads after the markers. Upload IDs are assigned in
+	// increasing order, so sorting by ID sorts by initiation time.
+	var uploads listedUploads
+	for id, u := range b.uploads {
+		if u.key < keyMarker {
+			continue
+		}
+
+		if u.key == keyMarker && (uploadIdMarker == "" || id <= uploadIdMarker) {
+			continue
+		}
+
+		uploads = append(uploads, listedUpload{
+			Key:          u.key,
+			UploadId:     id,
+			StorageClass: "STANDARD",
+			Initiated:    u.initiated.UTC().Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+
+	sort.Sort(uploads)
+
+	if len(uploads) > maxKeysPerList {
+		uploads = uploads[:maxKeysPerList]
+		result.IsTruncated = true
+	}
+
+	if len(uploads) > 0 {
+		last := uploads[len(uploads)-1]
+		result.NextKeyMarker = last.Key
+		result.NextUploadIdMarker = last.UploadId
+	}
+
+	result.Uploads = uploads
+
+	s.writeXml(w, &result)
+	return nil
+}
@@ -0,0 +1,740 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3test_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/s3test"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type ServerTest struct {
+	key    aws.AccessKey
+	server *s3test.Server
+	bucket s3.Bucket
+}
+
+func init() { RegisterTestSuite(&ServerTest{}) }
+
+func (t *ServerTest) SetUp(i *TestInfo) {
+	var err error
+
+	t.key = aws.AccessKey{Id: "some_id", Secret: "some_secret"}
+	t.server = s3test.NewServer(t.key)
+	t.server.CreateBucket("some_bucket")
+
+	t.bucket, err = s3.OpenBucketAtEndpoint(
+		"some_bucket",
+		t.server.Endpoint(),
+		t.key)
+
+	AssertEq(nil, err)
+}
+
+func (t *ServerTest) TearDown() {
+	t.server.Close()
+}
+
+func signForTest(toSign string, secret string) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write([]byte(toSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Send an unsigned request to the server, returning the response.
+func (t *ServerTest) sendUnsigned(
+	verb string,
+	path string,
+	header map[string]string,
+	body []byte) (statusCode int, respBody []byte) {
+	url := t.server.Endpoint().String() + path
+	req, err := http.NewRequest(verb, url, bytes.NewReader(body))
+	AssertEq(nil, err)
+
+	for key, val := range header {
+		req.Header.Set(key, val)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	AssertEq(nil, err)
+	defer resp.Body.Close()
+
+	respBody, err = ioutil.ReadAll(resp.Body)
+	AssertEq(nil, err)
+
+	return resp.StatusCode, respBody
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *ServerTest) UnknownBucket() {
+	bucket, err := s3.OpenBucketAtEndpoint(
+		"other.bucket",
+		t.server.Endpoint(),
+		t.key)
+
+	AssertEq(nil, err)
+
+	_, err = bucket.ListKeys("")
+	ExpectThat(err, Error(HasSubstr("404")))
+	ExpectThat(err, Error(HasSubstr("NoSuchBucket")))
+}
+
+func (t *ServerTest) WrongSecret() {
+	wrongKey := t.key
+	wrongKey.Secret += "taco"
+
+	bucket, err := s3.OpenBucketAtEndpoint(
+		"some_bucket",
+		t.server.Endpoint(),
+		wrongKey)
+
+	AssertEq(nil, err)
+
+	_, err = bucket.ListKeys("")
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("signature")))
+}
+
+func (t *ServerTest) UnknownKeyId() {
+	wrongKey := t.key
+	wrongKey.Id += "taco"
+
+	bucket, err := s3.OpenBucketAtEndpoint(
+		"some_bucket",
+		t.server.Endpoint(),
+		wrongKey)
+
+	AssertEq(nil, err)
+
+	_, err = bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("InvalidAccessKeyId")))
+}
+
+func (t *ServerTest) MissingDate() {
+	statusCode, body := t.sendUnsigned("GET", "/some_bucket", nil, nil)
+
+	ExpectEq(403, statusCode)
+	ExpectThat(string(body), HasSubstr("Date"))
+}
+
+func (t *ServerTest) SkewedDate() {
+	header := map[string]string{
+		"Date":          time.Now().Add(-time.Hour).UTC().Format(time.RFC1123),
+		"Authorization": "AWS some_id:taco",
+	}
+
+	statusCode, body := t.sendUnsigned("GET", "/some_bucket", header, nil)
+
+	ExpectEq(403, statusCode)
+	ExpectThat(string(body), HasSubstr("RequestTimeTooSkewed"))
+}
+
+func (t *ServerTest) MismatchedContentMd5() {
+	// Sign the request with the MD5 of some other data, so that the request
+	// gets past authentication.
+	header := map[string]string{
+		"Date":        time.Now().UTC().Format(time.RFC1123),
+		"Content-MD5": "rL0Y20zC+Fzt72VPzMSk2A==",
+	}
+
+	toSign := fmt.Sprintf(
+		"PUT\n%s\n\n%s\n/some_bucket/foo",
+		header["Content-MD5"],
+		header["Date"])
+
+	header["Authorization"] = "AWS some_id:" + signForTest(toSign, t.key.Secret)
+
+	statusCode, body := t.sendUnsigned(
+		"PUT",
+		"/some_bucket/foo",
+		header,
+		[]byte("taco"))
+
+	ExpectEq(400, statusCode)
+	ExpectThat(string(body), HasSubstr("BadDigest"))
+
+	// Nothing should have been stored.
+	_, err := t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *ServerTest) MissingContentLength() {
+	// A request whose body has an unknown length is sent with chunked
+	// transfer encoding.
+	url := t.server.Endpoint().String() + "/some_bucket/foo"
+	body := ioutil.NopCloser(bytes.NewReader([]byte("taco")))
+	req, err := http.NewRequest("PUT", url, body)
+	AssertEq(nil, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	AssertEq(nil, err)
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	AssertEq(nil, err)
+
+	ExpectEq(411, resp.StatusCode)
+	ExpectThat(string(respBody), HasSubstr("MissingContentLength"))
+}
+
+func (t *ServerTest) GetNonExistentObject() {
+	_, err := t.bucket.GetObject("some_key")
+
+	ExpectThat(err, Error(HasSubstr("404")))
+	ExpectThat(err, Error(HasSubstr("some_key")))
+	ExpectThat(err, Error(HasSubstr("exist")))
+}
+
+func (t *ServerTest) StoreThenGet() {
+	data := []byte{0x17, 0x19, 0x00, 0x02, 0x03}
+
+	// Store
+	err := t.bucket.StoreObject("타코/&bar ?", data)
+	AssertEq(nil, err)
+
+	// Get
+	returnedData, err := t.bucket.GetObject("타코/&bar ?")
+	AssertEq(nil, err)
+	ExpectThat(returnedData, DeepEquals(data))
+}
+
+func (t *ServerTest) PutThenGetHeader() {
+	data := []byte("taco")
+
+	// Put
+	err := t.bucket.Put("foo", bytes.NewReader(data))
+	AssertEq(nil, err)
+
+	// Head
+	header, err := t.bucket.GetHeader("foo")
+	AssertEq(nil, err)
+
+	ExpectEq("4", header.Get("Content-Length"))
+	ExpectEq(`"f869ce1c8414a264bb11e14a2c8850ed"`, header.Get("ETag"))
+	ExpectNe("", header.Get("Last-Modified"))
+	ExpectNe("", header.Get("X-Amz-Request-Id"))
+}
+
+func (t *ServerTest) GetHeaderForNonExistentObject() {
+	_, err := t.bucket.GetHeader("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *ServerTest) DeleteThenGet() {
+	// Store
+	err := t.bucket.StoreObject("foo", []byte("taco"))
+	AssertEq(nil, err)
+
+	// Delete (twice)
+	err = t.bucket.DeleteObject("foo")
+	AssertEq(nil, err)
+
+	err = t.bucket.DeleteObject("foo")
+	AssertEq(nil, err)
+
+	// Get
+	_, err = t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *ServerTest) ListKeys() {
+	toCreate := []string{"foo", "bar", "bar\x09", "baz"}
+	for _, key := range toCreate {
+		AssertEq(nil, t.bucket.StoreObject(key, []byte{}))
+	}
+
+	// From start.
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("bar", "bar\x09", "baz", "foo"))
+
+	// From middle.
+	keys, err = t.bucket.ListKeys("bar\x09")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("baz", "foo"))
+
+	// From end.
+	keys, err = t.bucket.ListKeys("foo")
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre())
+}
+
+func (t *ServerTest) ListManyKeys() {
+	const numKeys = 1200
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("%08x", i)
+		AssertEq(nil, t.bucket.StoreObject(key, []byte{}))
+	}
+
+	// The first page should be truncated.
+	keys, err := t.bucket.ListKeys("")
+	AssertEq(nil, err)
+	AssertEq(1000, len(keys))
+	ExpectEq("00000000", keys[0])
+	ExpectEq("000003e7", keys[999])
+
+	// The second page should contain the rest.
+	keys, err = t.bucket.ListKeys(keys[999])
+	AssertEq(nil, err)
+	AssertEq(200, len(keys))
+	ExpectEq("000003e8", keys[0])
+	ExpectEq("000004af", keys[199])
+}
+
+func (t *ServerTest) ListObjects() {
+	toCreate := []string{"a/b/c", "a/d", "a/b/e", "f"}
+	for _, key := range toCreate {
+		AssertEq(nil, t.bucket.StoreObject(key, []byte("taco")))
+	}
+
+	// No delimiter.
+	objects, prefixes, err := t.bucket.ListObjects("a/", "", "")
+	AssertEq(nil, err)
+	AssertEq(3, len(objects))
+	ExpectEq("a/b/c", objects[0].Key)
+	ExpectEq(4, objects[0].Size)
+	ExpectFalse(objects[0].LastModified.IsZero())
+	ExpectEq("a/b/e", objects[1].Key)
+	ExpectEq("a/d", objects[2].Key)
+	ExpectThat(prefixes, ElementsAre())
+
+	// Delimiter.
+	objects, prefixes, err = t.bucket.ListObjects("", "/", "")
+	AssertEq(nil, err)
+	AssertEq(1, len(objects))
+	ExpectEq("f", objects[0].Key)
+	ExpectThat(prefixes, ElementsAre("a/"))
+
+	// Continuing past a prefix.
+	objects, prefixes, err = t.bucket.ListObjects("", "/", "a/")
+	AssertEq(nil, err)
+	AssertEq(1, len(objects))
+	ExpectEq("f", objects[0].Key)
+	ExpectThat(prefixes, ElementsAre())
+}
+
+func (t *ServerTest) CreateAndDeleteBucket() {
+	endpoint := t.server.Endpoint()
+
+	// Create.
+	err := s3.CreateBucketAtEndpoint("other.bucket", endpoint, "", t.key)
+	AssertEq(nil, err)
+
+	// Creating again should fail.
+	err = s3.CreateBucketAtEndpoint("other.bucket", endpoint, "", t.key)
+	ExpectThat(err, Error(HasSubstr("BucketAlreadyOwnedByYou")))
+
+	// The new bucket should be usable.
+	bucket, err := s3.OpenBucketAtEndpoint("other.bucket", endpoint, t.key)
+	AssertEq(nil, err)
+	AssertEq(nil, bucket.StoreObject("foo", []byte("taco")))
+
+	// Deleting a non-empty bucket should fail.
+	err = s3.DeleteBucketAtEndpoint("other.bucket", endpoint, t.key)
+	ExpectThat(err, Error(HasSubstr("BucketNotEmpty")))
+
+	// Once empty, it can be deleted.
+	AssertEq(nil, bucket.DeleteObject("foo"))
+	err = s3.DeleteBucketAtEndpoint("other.bucket", endpoint, t.key)
+	AssertEq(nil, err)
+
+	// Deleting again should fail.
+	err = s3.DeleteBucketAtEndpoint("other.bucket", endpoint, t.key)
+	ExpectThat(err, Error(HasSubstr("NoSuchBucket")))
+}
+
+func (t *ServerTest) GetObjectRange() {
+	err := t.bucket.StoreObject("foo", []byte("tacoburrito"))
+	AssertEq(nil, err)
+
+	// Middle
+	data, err := t.bucket.GetObjectRange("foo", 4, 3)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	// Past the end
+	data, err = t.bucket.GetObjectRange("foo", 8, 100)
+	AssertEq(nil, err)
+	ExpectEq("ito", string(data))
+
+	// Starting past the end
+	_, err = t.bucket.GetObjectRange("foo", 11, 1)
+	ExpectThat(err, Error(HasSubstr("416")))
+	ExpectThat(err, Error(HasSubstr("InvalidRange")))
+}
+
+func (t *ServerTest) GetObjectRangeIfMatch() {
+	err := t.bucket.StoreObject("foo", []byte("tacoburrito"))
+	AssertEq(nil, err)
+
+	header, err := t.bucket.GetHeader("foo")
+	AssertEq(nil, err)
+	etag := header.Get("ETag")
+
+	// Unchanged
+	data, err := t.bucket.GetObjectRangeIfMatch("foo", 4, 3, etag)
+	AssertEq(nil, err)
+	ExpectEq("bur", string(data))
+
+	// Overwritten
+	err = t.bucket.StoreObject("foo", []byte("enchilada"))
+	AssertEq(nil, err)
+
+	_, err = t.bucket.GetObjectRangeIfMatch("foo", 4, 3, etag)
+	ExpectThat(err, Error(HasSubstr("412")))
+	ExpectThat(err, Error(HasSubstr("PreconditionFailed")))
+}
+
+func (t *ServerTest) GetObjectRangeForEmptyObject() {
+	err := t.bucket.StoreObject("foo", []byte{})
+	AssertEq(nil, err)
+
+	data, err := t.bucket.GetObjectRange("foo", 0, 10)
+	AssertEq(nil, err)
+	ExpectEq(0, len(data))
+}
+
+func (t *ServerTest) MultipartUpload() {
+	part1 := bytes.Repeat([]byte("a"), s3.MinPartSize)
+	part2 := []byte("taco")
+
+	uploadId, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+	AssertNe("", uploadId)
+
+	// Upload the parts out of order, replacing one.
+	etag2, err := t.bucket.UploadPart("foo", uploadId, 2, []byte("burrito"))
+	AssertEq(nil, err)
+
+	etag2, err = t.bucket.UploadPart("foo", uploadId, 2, part2)
+	AssertEq(nil, err)
+	ExpectEq(`"f869ce1c8414a264bb11e14a2c8850ed"`, etag2)
+
+	etag1, err := t.bucket.UploadPart("foo", uploadId, 1, part1)
+	AssertEq(nil, err)
+
+	// The object shouldn't exist until the upload is complete.
+	_, err = t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	parts := []s3.Part{
+		s3.Part{PartNumber: 1, ETag: etag1},
+		s3.Part{PartNumber: 2, ETag: etag2},
+	}
+
+	err = t.bucket.CompleteMultipartUpload("foo", uploadId, parts)
+	AssertEq(nil, err)
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectEq(len(part1)+len(part2), len(data))
+	ExpectEq("taco", string(data[len(part1):]))
+
+	header, err := t.bucket.GetHeader("foo")
+	AssertEq(nil, err)
+	ExpectThat(header.Get("ETag"), HasSubstr("-2\""))
+
+	// The upload should be gone.
+	err = t.bucket.AbortMultipartUpload("foo", uploadId)
+	ExpectThat(err, Error(HasSubstr("NoSuchUpload")))
+}
+
+func (t *ServerTest) MultipartUploadPartTooSmall() {
+	uploadId, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+
+	etag1, err := t.bucket.UploadPart("foo", uploadId, 1, []byte("taco"))
+	AssertEq(nil, err)
+
+	etag2, err := t.bucket.UploadPart("foo", uploadId, 2, []byte("burrito"))
+	AssertEq(nil, err)
+
+	parts := []s3.Part{
+		s3.Part{PartNumber: 1, ETag: etag1},
+		s3.Part{PartNumber: 2, ETag: etag2},
+	}
+
+	err = t.bucket.CompleteMultipartUpload("foo", uploadId, parts)
+	ExpectThat(err, Error(HasSubstr("EntityTooSmall")))
+}
+
+func (t *ServerTest) MultipartUploadWrongETag() {
+	uploadId, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+
+	_, err = t.bucket.UploadPart("foo", uploadId, 1, []byte("taco"))
+	AssertEq(nil, err)
+
+	parts := []s3.Part{s3.Part{PartNumber: 1, ETag: `"deadbeef"`}}
+	err = t.bucket.CompleteMultipartUpload("foo", uploadId, parts)
+	ExpectThat(err, Error(HasSubstr("InvalidPart")))
+}
+
+func (t *ServerTest) AbortMultipartUpload() {
+	uploadId, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+
+	_, err = t.bucket.UploadPart("foo", uploadId, 1, []byte("taco"))
+	AssertEq(nil, err)
+
+	err = t.bucket.AbortMultipartUpload("foo", uploadId)
+	AssertEq(nil, err)
+
+	_, err = t.bucket.UploadPart("foo", uploadId, 2, []byte("taco"))
+	ExpectThat(err, Error(HasSubstr("NoSuchUpload")))
+
+	_, err = t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *ServerTest) ListMultipartUploads() {
+	id0, err := t.bucket.InitiateMultipartUpload("foo")
+	AssertEq(nil, err)
+
+	id1, err := t.bucket.InitiateMultipartUpload("bar")
+	AssertEq(nil, err)
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	AssertEq(2, len(uploads))
+
+	ExpectEq("bar", uploads[0].Key)
+	ExpectEq(id1, uploads[0].UploadId)
+	ExpectFalse(uploads[0].Initiated.IsZero())
+
+	ExpectEq("foo", uploads[1].Key)
+	ExpectEq(id0, uploads[1].UploadId)
+	ExpectFalse(uploads[1].Initiated.IsZero())
+
+	uploads, err = t.bucket.ListMultipartUploads("bar", id1)
+	AssertEq(nil, err)
+	AssertEq(1, len(uploads))
+	ExpectEq("foo", uploads[0].Key)
+
+	AssertEq(nil, t.bucket.AbortMultipartUpload("foo", id0))
+
+	uploads, err = t.bucket.ListMultipartUploads("bar", id1)
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
+
+func (t *ServerTest) SessionToken() {
+	tempKey := aws.AccessKey{
+		Id:           "temp_id",
+		Secret:       "temp_secret",
+		SessionToken: "some_token",
+	}
+
+	server := s3test.NewServer(tempKey)
+	defer server.Close()
+	server.CreateBucket("some_bucket")
+
+	// The right token
+	bucket, err := s3.OpenBucketAtEndpoint(
+		"some_bucket",
+		server.Endpoint(),
+		tempKey)
+
+	AssertEq(nil, err)
+	AssertEq(nil, bucket.StoreObject("foo", []byte("taco")))
+
+	// The wrong token
+	wrongKey := tempKey
+	wrongKey.SessionToken = "other_token"
+
+	bucket, err = s3.OpenBucketAtEndpoint(
+		"some_bucket",
+		server.Endpoint(),
+		wrongKey)
+
+	AssertEq(nil, err)
+
+	_, err = bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("400")))
+	ExpectThat(err, Error(HasSubstr("InvalidToken")))
+
+	// No token
+	wrongKey.SessionToken = ""
+
+	bucket, err = s3.OpenBucketAtEndpoint(
+		"some_bucket",
+		server.Endpoint(),
+		wrongKey)
+
+	AssertEq(nil, err)
+
+	_, err = bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("InvalidToken")))
+}
+
+// Make a request to a URL presigned for the supplied verb, key, and
+// expiration time.
+func (t *ServerTest) sendPresigned(
+	verb string,
+	key aws.AccessKey,
+	expires time.Time,
+	body []byte) (statusCode int, respBody []byte) {
+	u, err := s3.PresignedURL(
+		t.server.Endpoint(),
+		"some_bucket",
+		"foo",
+		verb,
+		expires,
+		key)
+
+	AssertEq(nil, err)
+
+	req, err := http.NewRequest(verb, u.String(), bytes.NewReader(body))
+	AssertEq(nil, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	AssertEq(nil, err)
+	defer resp.Body.Close()
+
+	respBody, err = ioutil.ReadAll(resp.Body)
+	AssertEq(nil, err)
+
+	return resp.StatusCode, respBody
+}
+
+func (t *ServerTest) PresignedGet() {
+	AssertEq(nil, t.bucket.StoreObject("foo", []byte("taco")))
+
+	statusCode, body := t.sendPresigned(
+		"GET",
+		t.key,
+		time.Now().Add(time.Hour),
+		nil)
+
+	ExpectEq(200, statusCode)
+	ExpectEq("taco", string(body))
+}
+
+func (t *ServerTest) PresignedPut() {
+	statusCode, _ := t.sendPresigned(
+		"PUT",
+		t.key,
+		time.Now().Add(time.Hour),
+		[]byte("burrito"))
+
+	AssertEq(200, statusCode)
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(data))
+}
+
+func (t *ServerTest) PresignedUrlHasExpired() {
+	AssertEq(nil, t.bucket.StoreObject("foo", []byte("taco")))
+
+	statusCode, body := t.sendPresigned(
+		"GET",
+		t.key,
+		time.Now().Add(-time.Minute),
+		nil)
+
+	ExpectEq(403, statusCode)
+	ExpectThat(string(body), HasSubstr("expired"))
+}
+
+func (t *ServerTest) PresignedWithWrongSecret() {
+	AssertEq(nil, t.bucket.StoreObject("foo", []byte("taco")))
+
+	wrongKey := t.key
+	wrongKey.Secret += "taco"
+
+	statusCode, body := t.sendPresigned(
+		"GET",
+		wrongKey,
+		time.Now().Add(time.Hour),
+		nil)
+
+	ExpectEq(403, statusCode)
+	ExpectThat(string(body), HasSubstr("SignatureDoesNotMatch"))
+}
+
+func (t *ServerTest) PresignedWithWrongVerb() {
+	u, err := s3.PresignedURL(
+		t.server.Endpoint(),
+		"some_bucket",
+		"foo",
+		"GET",
+		time.Now().Add(time.Hour),
+		t.key)
+
+	AssertEq(nil, err)
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	AssertEq(nil, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	AssertEq(nil, err)
+	resp.Body.Close()
+
+	ExpectEq(403, resp.StatusCode)
+}
+
+func (t *ServerTest) PresignedWithSessionToken() {
+	tempKey := aws.AccessKey{
+		Id:           "temp_id",
+		Secret:       "temp_secret",
+		SessionToken: "some_token",
+	}
+
+	t.server.Close()
+	t.server = s3test.NewServer(tempKey)
+	t.server.CreateBucket("some_bucket")
+
+	statusCode, _ := t.sendPresigned(
+		"PUT",
+		tempKey,
+		time.Now().Add(time.Hour),
+		[]byte("taco"))
+
+	ExpectEq(200, statusCode)
+
+	// Without the token, the request should be rejected.
+	tempKey.SessionToken = ""
+	statusCode, body := t.sendPresigned(
+		"GET",
+		tempKey,
+		time.Now().Add(time.Hour),
+		nil)
+
+	ExpectEq(400, statusCode)
+	ExpectThat(string(body), HasSubstr("InvalidToken"))
+}
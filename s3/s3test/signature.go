@@ -0,0 +1,117 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	sys_http "net/http"
+	"sort"
+	"strings"
+)
+
+// Query parameters that name sub-resources, and must therefore be included
+// in the string to sign.
+var subResources = map[string]bool{
+	"acl":            true,
+	"lifecycle":      true,
+	"location":       true,
+	"logging":        true,
+	"notification":   true,
+	"partNumber":     true,
+	"policy":         true,
+	"requestPayment": true,
+	"torrent":        true,
+	"uploadId":       true,
+	"uploads":        true,
+	"versionId":      true,
+	"versioning":     true,
+	"versions":       true,
+	"website":        true,
+}
+
+// Compute the string to sign for the supplied request, as the server sees it.
+//
+// Reference:
+//     http://docs.amazonwebservices.com/AmazonS3/latest/dev/RESTAuthentication.html
+func stringToSign(r *sys_http.Request) string {
+	// If an x-amz-date header is present, it is included with the other amz
+	// headers below and the Date line is left empty.
+	date := r.Header.Get("Date")
+	if r.Header.Get("x-amz-date") != "" {
+		date = ""
+	}
+
+	// Canonicalize the amz headers.
+	var amzNames []string
+	amzValues := make(map[string][]string)
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			amzNames = append(amzNames, lower)
+			amzValues[lower] = append(amzValues[lower], values...)
+		}
+	}
+
+	sort.Strings(amzNames)
+
+	canonicalizedAmzHeaders := ""
+	for _, name := range amzNames {
+		canonicalizedAmzHeaders +=
+			name + ":" + strings.Join(amzValues[name], ",") + "\n"
+	}
+
+	// Canonicalize the resource, including any sub-resources.
+	canonicalizedResource := r.URL.EscapedPath()
+
+	query := r.URL.Query()
+	var subResourceNames []string
+	for name, _ := range query {
+		if subResources[name] {
+			subResourceNames = append(subResourceNames, name)
+		}
+	}
+
+	sort.Strings(subResourceNames)
+
+	for i, name := range subResourceNames {
+		if i == 0 {
+			canonicalizedResource += "?"
+		} else {
+			canonicalizedResource += "&"
+		}
+
+		canonicalizedResource += name
+		if val := query.Get(name); val != "" {
+			canonicalizedResource += "=" + val
+		}
+	}
+
+	return r.Method + "\n" +
+		r.Header.Get("Content-MD5") + "\n" +
+		r.Header.Get("Content-Type") + "\n" +
+		date + "\n" +
+		canonicalizedAmzHeaders +
+		canonicalizedResource
+}
+
+// Compute the base64-encoded HMAC-SHA1 signature of the supplied string.
+func sign(toSign string, secret string) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write([]byte(toSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
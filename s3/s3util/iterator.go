@@ -0,0 +1,151 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+)
+
+// A KeyIterator walks the keys of a bucket in order, one page of keys at a
+// time, so that the full list never needs to be held in memory. Use it like
+// this:
+//
+//     it := s3util.NewKeyIterator(bucket, "")
+//     for it.Next() {
+//       fmt.Println(it.Key())
+//     }
+//
+//     if err := it.Err(); err != nil {
+//       ...
+//     }
+//
+// Iteration may be stopped at any time by simply ceasing to call Next. To
+// resume later, perhaps in another process, save the result of Position and
+// pass it to NewKeyIterator.
+//
+// A KeyIterator is not safe for concurrent use.
+type KeyIterator struct {
+	bucket   s3.Bucket
+	prefetch bool
+
+	// The current page of keys, and the index within it of the next key to be
+	// returned.
+	page  []string
+	index int
+
+	// The most recent key returned by Next, or the starting position if none.
+	key string
+
+	// If non-nil, a background fetch of the page following the current one is
+	// in flight, and its result will be delivered on this channel.
+	pending chan keyPage
+
+	// Set when the end of the bucket has been reached or an error encountered.
+	done bool
+	err  error
+}
+
+type keyPage struct {
+	keys []string
+	err  error
+}
+
+// NewKeyIterator returns an iterator over the keys in the bucket that are
+// strictly greater than prevKey, which may be empty to start at the beginning.
+func NewKeyIterator(bucket s3.Bucket, prevKey string) *KeyIterator {
+	return &KeyIterator{bucket: bucket, key: prevKey}
+}
+
+// NewPrefetchingKeyIterator is like NewKeyIterator, but while the caller is
+// consuming one page of keys the iterator fetches the next in the background.
+// This hides the latency of ListKeys when the caller does non-trivial work
+// for each key, at the cost of at most one extra request if iteration is
+// abandoned.
+func NewPrefetchingKeyIterator(bucket s3.Bucket, prevKey string) *KeyIterator {
+	return &KeyIterator{bucket: bucket, key: prevKey, prefetch: true}
+}
+
+func (it *KeyIterator) fetch(prevKey string) (p keyPage) {
+	p.keys, p.err = it.bucket.ListKeys(prevKey)
+	if p.err != nil {
+		p.err = fmt.Errorf("ListKeys: %v", p.err)
+	}
+
+	return
+}
+
+func (it *KeyIterator) startPrefetch(prevKey string) {
+	// Buffer the result so that the goroutine exits even if the caller never
+	// asks for it.
+	it.pending = make(chan keyPage, 1)
+	go func(c chan<- keyPage) {
+		c <- it.fetch(prevKey)
+	}(it.pending)
+}
+
+// Next advances to the next key, returning false if there are no more keys or
+// an error occurred.
+func (it *KeyIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	// Move to the next page if necessary.
+	if it.index == len(it.page) {
+		var p keyPage
+		if it.pending != nil {
+			p = <-it.pending
+			it.pending = nil
+		} else {
+			p = it.fetch(it.key)
+		}
+
+		if p.err != nil || len(p.keys) == 0 {
+			it.err = p.err
+			it.done = true
+			return false
+		}
+
+		it.page = p.keys
+		it.index = 0
+
+		if it.prefetch {
+			it.startPrefetch(it.page[len(it.page)-1])
+		}
+	}
+
+	it.key = it.page[it.index]
+	it.index++
+
+	return true
+}
+
+// Key returns the key found by the most recent successful call to Next.
+func (it *KeyIterator) Key() string {
+	return it.key
+}
+
+// Err returns the error that caused Next to return false, if any.
+func (it *KeyIterator) Err() error {
+	return it.err
+}
+
+// Position returns a value that may be passed to NewKeyIterator to resume
+// iteration immediately after the most recent key returned by Next.
+func (it *KeyIterator) Position() string {
+	return it.key
+}
@@ -0,0 +1,147 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/mock"
+	"github.com/jacobsa/aws/s3/s3util"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestIterator(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type KeyIteratorTest struct {
+	bucket mock_s3.MockBucket
+}
+
+func init() { RegisterTestSuite(&KeyIteratorTest{}) }
+
+func (t *KeyIteratorTest) SetUp(i *TestInfo) {
+	t.bucket = mock_s3.NewMockBucket(i.MockController, "bucket")
+}
+
+// Consume the iterator, returning the keys it yields.
+func drain(it *s3util.KeyIterator) (keys []string) {
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *KeyIteratorTest) EmptyBucket() {
+	ExpectCall(t.bucket, "ListKeys")("").
+		WillOnce(oglemock.Return([]string{}, nil))
+
+	it := s3util.NewKeyIterator(t.bucket, "")
+	ExpectFalse(it.Next())
+	ExpectEq(nil, it.Err())
+
+	// Further calls should not contact the bucket.
+	ExpectFalse(it.Next())
+}
+
+func (t *KeyIteratorTest) CallsListKeysOnePageAtATime() {
+	ExpectCall(t.bucket, "ListKeys")("burrito").
+		WillOnce(oglemock.Return([]string{"enchilada", "queso"}, nil))
+
+	it := s3util.NewKeyIterator(t.bucket, "burrito")
+
+	AssertTrue(it.Next())
+	ExpectEq("enchilada", it.Key())
+
+	AssertTrue(it.Next())
+	ExpectEq("queso", it.Key())
+
+	// Only now should the next page be requested.
+	ExpectCall(t.bucket, "ListKeys")("queso").
+		WillOnce(oglemock.Return([]string{"taco"}, nil))
+
+	AssertTrue(it.Next())
+	ExpectEq("taco", it.Key())
+
+	ExpectCall(t.bucket, "ListKeys")("taco").
+		WillOnce(oglemock.Return([]string{}, nil))
+
+	ExpectFalse(it.Next())
+	ExpectEq(nil, it.Err())
+}
+
+func (t *KeyIteratorTest) ListKeysReturnsError() {
+	ExpectCall(t.bucket, "ListKeys")(Any()).
+		WillOnce(oglemock.Return([]string{"a"}, nil)).
+		WillOnce(oglemock.Return(nil, errors.New("taco")))
+
+	it := s3util.NewKeyIterator(t.bucket, "")
+	AssertTrue(it.Next())
+	ExpectFalse(it.Next())
+
+	ExpectThat(it.Err(), Error(HasSubstr("ListKeys")))
+	ExpectThat(it.Err(), Error(HasSubstr("taco")))
+
+	// The position should be unaffected, so the caller can retry.
+	ExpectEq("a", it.Position())
+}
+
+func (t *KeyIteratorTest) Prefetching() {
+	ExpectCall(t.bucket, "ListKeys")("").
+		WillOnce(oglemock.Return([]string{"a", "b"}, nil))
+
+	ExpectCall(t.bucket, "ListKeys")("b").
+		WillOnce(oglemock.Return([]string{"c"}, nil))
+
+	ExpectCall(t.bucket, "ListKeys")("c").
+		WillOnce(oglemock.Return([]string{}, nil))
+
+	it := s3util.NewPrefetchingKeyIterator(t.bucket, "")
+	ExpectThat(drain(it), ElementsAre("a", "b", "c"))
+	ExpectEq(nil, it.Err())
+}
+
+func (t *KeyIteratorTest) ResumeFromPosition() {
+	bucket := s3.NewMemBucket()
+	for i := 0; i < 2500; i++ {
+		AssertEq(nil, bucket.StoreObject(fmt.Sprintf("%08d", i), []byte{}))
+	}
+
+	// Read part of the way through.
+	it := s3util.NewPrefetchingKeyIterator(bucket, "")
+	for i := 0; i < 1234; i++ {
+		AssertTrue(it.Next())
+	}
+
+	ExpectEq("00001233", it.Position())
+
+	// Resume with a new iterator.
+	keys := drain(s3util.NewKeyIterator(bucket, it.Position()))
+	AssertEq(2500-1234, len(keys))
+	ExpectEq("00001234", keys[0])
+	ExpectEq("00002499", keys[len(keys)-1])
+}
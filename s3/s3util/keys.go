@@ -16,31 +16,19 @@
 package s3util
 
 import (
-	"fmt"
 	"github.com/jacobsa/aws/s3"
 )
 
 // List all keys currently contained by the bucket.
+//
+// This holds every key in memory at once. For large buckets, or when you may
+// want to stop early, use a KeyIterator instead.
 func ListAllKeys(bucket s3.Bucket) (keys []string, err error) {
-	for {
-		var prevKey string
-		if len(keys) > 0 {
-			prevKey = keys[len(keys)-1]
-		}
-
-		var partialKeys []string
-		partialKeys, err = bucket.ListKeys(prevKey)
-		if err != nil {
-			err = fmt.Errorf("ListKeys: %v", err)
-			return
-		}
-
-		if len(partialKeys) == 0 {
-			break
-		}
-
-		keys = append(keys, partialKeys...)
+	it := NewKeyIterator(bucket, "")
+	for it.Next() {
+		keys = append(keys, it.Key())
 	}
 
+	err = it.Err()
 	return
 }
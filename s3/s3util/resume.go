@@ -0,0 +1,377 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	sys_time "time"
+)
+
+// The progress of a resumable upload, persisted as JSON in a checkpoint file.
+type checkpoint struct {
+	Key      string
+	Size     int64
+	PartSize int64
+	UploadId string
+	Parts    []checkpointPart
+}
+
+type checkpointPart struct {
+	PartNumber int
+	ETag       string
+
+	// The hex-encoded MD5 of the part's data, used to detect changes to the
+	// source between attempts.
+	MD5 string
+}
+
+// Read the checkpoint at the given path, returning nil if it doesn't exist.
+func readCheckpoint(path string) (cp *checkpoint, err error) {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cp = &checkpoint{}
+	if err = json.Unmarshal(contents, cp); err != nil {
+		return nil, fmt.Errorf("Invalid checkpoint in %s: %v", path, err)
+	}
+
+	return
+}
+
+// Atomically replace the checkpoint at the given path.
+func writeCheckpoint(path string, cp *checkpoint) (err error) {
+	contents, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".checkpoint")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return
+}
+
+// UploadResumable is like Upload, but records its progress in a checkpoint
+// file at the supplied path. If the upload fails or the process dies, calling
+// UploadResumable again with the same key, data, and checkpoint path resumes
+// where the previous attempt left off, re-uploading only the parts that
+// didn't finish. The checkpoint file is removed once the upload completes.
+//
+// Unlike Upload, a failed attempt leaves the multipart upload in place so
+// that it can be resumed. Use AbortCheckpointedUpload to give up on it.
+//
+// Parts whose data has changed since the previous attempt are uploaded again.
+// If the key or size no longer match the checkpoint, the old upload is
+// aborted and a new one started. If the upload recorded in the checkpoint no
+// longer exists, for example because it was aborted by AbortStaleUploads or a
+// lifecycle rule, or S3 no longer has the parts it records, the checkpoint is
+// discarded and a new upload started.
+func (u *Uploader) UploadResumable(
+	key string,
+	r io.ReaderAt,
+	size int64,
+	checkpointPath string) (err error) {
+	// Small objects can be uploaded in a single request.
+	if size <= u.opts.PartSize {
+		return u.Upload(key, r, size)
+	}
+
+	partSize := u.partSize(size)
+
+	// Load any existing checkpoint, discarding it if it's for something else.
+	cp, err := readCheckpoint(checkpointPath)
+	if err != nil {
+		err = fmt.Errorf("readCheckpoint: %v", err)
+		return
+	}
+
+	if cp != nil &&
+		(cp.Key != key || cp.Size != size || cp.PartSize != partSize) {
+		err = u.bucket.AbortMultipartUpload(cp.Key, cp.UploadId)
+		if err != nil && errorCode(err) != "NoSuchUpload" {
+			err = fmt.Errorf("AbortMultipartUpload: %v", err)
+			return
+		}
+
+		cp = nil
+	}
+
+	// Resume the existing upload, if any. If it has gone away, start again,
+	// cleaning up whatever is left of it. There's not much we can do if the
+	// abort fails.
+	if cp != nil {
+		err = u.resumeUpload(key, r, size, cp, checkpointPath)
+		if _, ok := err.(*uploadGoneError); !ok {
+			return
+		}
+
+		u.bucket.AbortMultipartUpload(cp.Key, cp.UploadId)
+	}
+
+	// Start a new upload.
+	cp = &checkpoint{Key: key, Size: size, PartSize: partSize}
+	err = u.opts.retry(func() (err error) {
+		cp.UploadId, err = u.bucket.InitiateMultipartUpload(key)
+		return
+	})
+
+	if err != nil {
+		err = fmt.Errorf("InitiateMultipartUpload: %v", err)
+		return
+	}
+
+	if err = writeCheckpoint(checkpointPath, cp); err != nil {
+		err = fmt.Errorf("writeCheckpoint: %v", err)
+		return
+	}
+
+	return u.resumeUpload(key, r, size, cp, checkpointPath)
+}
+
+// Return the S3 error code carried by the supplied error, or the empty string
+// if there is none.
+func errorCode(err error) string {
+	if serverErr, ok := err.(*s3.ServerError); ok {
+		return serverErr.Code
+	}
+
+	return ""
+}
+
+// An error returned by resumeUpload when the multipart upload in the
+// checkpoint can't be finished, because it no longer exists or S3 no longer
+// has the parts recorded for it.
+type uploadGoneError struct {
+	err error
+}
+
+func (e *uploadGoneError) Error() string {
+	return e.err.Error()
+}
+
+// Return err, marked as an *uploadGoneError if the error from S3 that caused
+// it says that the upload can't be finished.
+func checkGone(cause error, err error) error {
+	switch errorCode(cause) {
+	case "NoSuchUpload", "InvalidPart":
+		return &uploadGoneError{err}
+	}
+
+	return err
+}
+
+// Upload the parts of the object not already recorded in the checkpoint and
+// complete the upload, removing the checkpoint file on success.
+func (u *Uploader) resumeUpload(
+	key string,
+	r io.ReaderAt,
+	size int64,
+	cp *checkpoint,
+	checkpointPath string) (err error) {
+	// Upload each part that isn't already done, recording progress as we go.
+	var mutex sync.Mutex
+	done := make(map[int]checkpointPart)
+	for _, p := range cp.Parts {
+		done[p.PartNumber] = p
+	}
+
+	offsets, lengths := splitParts(size, cp.PartSize)
+	parts := make([]s3.Part, len(offsets))
+	ops := make([]func() error, len(offsets))
+
+	for i := range offsets {
+		i := i
+		parts[i].PartNumber = i + 1
+		ops[i] = func() error {
+			data, err := readPart(r, offsets[i], lengths[i])
+			if err != nil {
+				return err
+			}
+
+			sum := fmt.Sprintf("%x", md5.Sum(data))
+
+			mutex.Lock()
+			prev, ok := done[parts[i].PartNumber]
+			mutex.Unlock()
+
+			if ok && prev.MD5 == sum {
+				parts[i].ETag = prev.ETag
+				return nil
+			}
+
+			if err := u.uploadPart(key, cp.UploadId, data, &parts[i]); err != nil {
+				return checkGone(
+					err,
+					fmt.Errorf("UploadPart(%d): %v", parts[i].PartNumber, err))
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			done[parts[i].PartNumber] = checkpointPart{
+				PartNumber: parts[i].PartNumber,
+				ETag:       parts[i].ETag,
+				MD5:        sum,
+			}
+
+			return recordProgress(checkpointPath, cp, done)
+		}
+	}
+
+	if err = runConcurrently(ops, u.opts.Concurrency); err != nil {
+		return
+	}
+
+	// Assemble the parts.
+	err = u.opts.retry(func() error {
+		return u.bucket.CompleteMultipartUpload(key, cp.UploadId, parts)
+	})
+
+	if err != nil {
+		err = checkGone(err, fmt.Errorf("CompleteMultipartUpload: %v", err))
+		return
+	}
+
+	if err = os.Remove(checkpointPath); err != nil {
+		err = fmt.Errorf("Remove: %v", err)
+		return
+	}
+
+	return
+}
+
+// Update the checkpoint with the supplied completed parts and write it out.
+func recordProgress(
+	path string,
+	cp *checkpoint,
+	done map[int]checkpointPart) error {
+	cp.Parts = cp.Parts[:0]
+	for _, p := range done {
+		cp.Parts = append(cp.Parts, p)
+	}
+
+	sort.Sort(checkpointPartsByNumber(cp.Parts))
+
+	if err := writeCheckpoint(path, cp); err != nil {
+		return fmt.Errorf("writeCheckpoint: %v", err)
+	}
+
+	return nil
+}
+
+type checkpointPartsByNumber []checkpointPart
+
+func (s checkpointPartsByNumber) Len() int      { return len(s) }
+func (s checkpointPartsByNumber) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s checkpointPartsByNumber) Less(i, j int) bool {
+	return s[i].PartNumber < s[j].PartNumber
+}
+
+// AbortCheckpointedUpload gives up on the resumable upload recorded in the
+// checkpoint file at the supplied path, aborting it and removing the file. It
+// does nothing if the file doesn't exist.
+func AbortCheckpointedUpload(bucket s3.Bucket, checkpointPath string) error {
+	cp, err := readCheckpoint(checkpointPath)
+	if err != nil {
+		return fmt.Errorf("readCheckpoint: %v", err)
+	}
+
+	if cp == nil {
+		return nil
+	}
+
+	if err := bucket.AbortMultipartUpload(cp.Key, cp.UploadId); err != nil {
+		return fmt.Errorf("AbortMultipartUpload: %v", err)
+	}
+
+	if err := os.Remove(checkpointPath); err != nil {
+		return fmt.Errorf("Remove: %v", err)
+	}
+
+	return nil
+}
+
+// AbortStaleUploads aborts every in-progress multipart upload in the bucket
+// that was initiated before the supplied time, such as those left behind by
+// processes that died without cleaning up. It returns the uploads aborted.
+//
+// Take care not to choose a time so recent that uploads still in progress are
+// aborted.
+func AbortStaleUploads(
+	bucket s3.Bucket,
+	initiatedBefore sys_time.Time) (aborted []s3.MultipartUpload, err error) {
+	var prevKey, prevUploadId string
+	for {
+		var uploads []s3.MultipartUpload
+		uploads, err = bucket.ListMultipartUploads(prevKey, prevUploadId)
+		if err != nil {
+			err = fmt.Errorf("ListMultipartUploads: %v", err)
+			return
+		}
+
+		if len(uploads) == 0 {
+			break
+		}
+
+		for _, upload := range uploads {
+			if !upload.Initiated.Before(initiatedBefore) {
+				continue
+			}
+
+			err = bucket.AbortMultipartUpload(upload.Key, upload.UploadId)
+			if err != nil {
+				err = fmt.Errorf("AbortMultipartUpload: %v", err)
+				return
+			}
+
+			aborted = append(aborted, upload)
+		}
+
+		last := uploads[len(uploads)-1]
+		prevKey, prevUploadId = last.Key, last.UploadId
+	}
+
+	return
+}
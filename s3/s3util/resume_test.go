@@ -0,0 +1,335 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/s3util"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type ResumeTest struct {
+	bucket   *flakyBucket
+	opts     s3util.TransferOptions
+	contents []byte
+
+	dir            string
+	checkpointPath string
+}
+
+func init() { RegisterTestSuite(&ResumeTest{}) }
+
+func (t *ResumeTest) SetUp(i *TestInfo) {
+	var err error
+
+	t.bucket = &flakyBucket{Bucket: s3.NewMemBucket()}
+	t.opts = s3util.TransferOptions{
+		PartSize:    s3.MinPartSize,
+		Concurrency: 1,
+		MaxAttempts: 1,
+	}
+
+	// Contents that span two full parts and a bit more.
+	t.contents = make([]byte, 2*s3.MinPartSize+17)
+	for i := range t.contents {
+		t.contents[i] = byte(i % 251)
+	}
+
+	t.dir, err = ioutil.TempDir("", "resume_test")
+	AssertEq(nil, err)
+
+	t.checkpointPath = path.Join(t.dir, "checkpoint")
+}
+
+func (t *ResumeTest) TearDown() {
+	os.RemoveAll(t.dir)
+}
+
+func (t *ResumeTest) uploadResumable(key string, data []byte) error {
+	u, err := s3util.NewUploader(t.bucket, t.opts)
+	AssertEq(nil, err)
+
+	return u.UploadResumable(
+		key,
+		bytes.NewReader(data),
+		int64(len(data)),
+		t.checkpointPath)
+}
+
+// Attempt to upload t.contents to the key, failing on the last part.
+func (t *ResumeTest) failedUpload(key string) {
+	t.bucket.failPart = 3
+	err := t.uploadResumable(key, t.contents)
+	AssertThat(err, Error(HasSubstr("UploadPart(3)")))
+
+	t.bucket.failPart = 0
+	t.bucket.uploadCalls = 0
+}
+
+func (t *ResumeTest) checkpointExists() bool {
+	_, err := os.Stat(t.checkpointPath)
+	return err == nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *ResumeTest) SmallObjectUsesSingleRequest() {
+	AssertEq(nil, t.uploadResumable("foo", []byte("taco")))
+
+	ExpectEq(1, t.bucket.putCalls)
+	ExpectEq(0, t.bucket.uploadCalls)
+	ExpectFalse(t.checkpointExists())
+}
+
+func (t *ResumeTest) FailureLeavesCheckpoint() {
+	t.failedUpload("foo")
+
+	ExpectTrue(t.checkpointExists())
+	ExpectEq(0, t.bucket.abortCalls)
+
+	_, err := t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	AssertEq(1, len(uploads))
+	ExpectEq("foo", uploads[0].Key)
+}
+
+func (t *ResumeTest) ResumesWhereItLeftOff() {
+	t.failedUpload("foo")
+
+	AssertEq(nil, t.uploadResumable("foo", t.contents))
+
+	ExpectEq(1, t.bucket.uploadCalls)
+	ExpectEq(0, t.bucket.abortCalls)
+	ExpectFalse(t.checkpointExists())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
+
+func (t *ResumeTest) ChangedPartsAreUploadedAgain() {
+	t.failedUpload("foo")
+
+	t.contents[17]++
+	AssertEq(nil, t.uploadResumable("foo", t.contents))
+
+	ExpectEq(2, t.bucket.uploadCalls)
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+}
+
+func (t *ResumeTest) DifferentKeyStartsOver() {
+	t.failedUpload("foo")
+
+	AssertEq(nil, t.uploadResumable("bar", t.contents))
+
+	ExpectEq(3, t.bucket.uploadCalls)
+	ExpectEq(1, t.bucket.abortCalls)
+	ExpectFalse(t.checkpointExists())
+
+	data, err := t.bucket.GetObject("bar")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
+
+func (t *ResumeTest) DifferentKeyAbortFails() {
+	t.failedUpload("foo")
+
+	t.bucket.failAbort = true
+	err := t.uploadResumable("bar", t.contents)
+
+	ExpectThat(err, Error(HasSubstr("AbortMultipartUpload")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+	ExpectEq(0, t.bucket.uploadCalls)
+	ExpectTrue(t.checkpointExists())
+}
+
+func (t *ResumeTest) DifferentKeyUploadAlreadyGone() {
+	t.failedUpload("foo")
+
+	_, err := s3util.AbortStaleUploads(t.bucket, time.Now().Add(time.Hour))
+	AssertEq(nil, err)
+
+	AssertEq(nil, t.uploadResumable("bar", t.contents))
+
+	data, err := t.bucket.GetObject("bar")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+}
+
+func (t *ResumeTest) UploadAbortedElsewhereStartsOver() {
+	t.failedUpload("foo")
+
+	_, err := s3util.AbortStaleUploads(t.bucket, time.Now().Add(time.Hour))
+	AssertEq(nil, err)
+
+	AssertEq(nil, t.uploadResumable("foo", t.contents))
+
+	// The last part was attempted against the old upload, then all three
+	// uploaded again.
+	ExpectEq(4, t.bucket.uploadCalls)
+	ExpectFalse(t.checkpointExists())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
+
+func (t *ResumeTest) UploadAbortedBeforeCompletionStartsOver() {
+	// Upload every part, but fail to complete.
+	t.bucket.failComplete = true
+	err := t.uploadResumable("foo", t.contents)
+	AssertThat(err, Error(HasSubstr("CompleteMultipartUpload")))
+
+	t.bucket.failComplete = false
+	t.bucket.uploadCalls = 0
+
+	_, err = s3util.AbortStaleUploads(t.bucket, time.Now().Add(time.Hour))
+	AssertEq(nil, err)
+
+	AssertEq(nil, t.uploadResumable("foo", t.contents))
+
+	ExpectEq(3, t.bucket.uploadCalls)
+	ExpectFalse(t.checkpointExists())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+}
+
+func (t *ResumeTest) MissingPartStartsOver() {
+	t.failedUpload("foo")
+
+	// Record an ETag for the first part that S3 doesn't know, so that
+	// CompleteMultipartUpload fails with InvalidPart.
+	contents, err := ioutil.ReadFile(t.checkpointPath)
+	AssertEq(nil, err)
+
+	var cp map[string]interface{}
+	AssertEq(nil, json.Unmarshal(contents, &cp))
+
+	parts := cp["Parts"].([]interface{})
+	parts[0].(map[string]interface{})["ETag"] = `"taco"`
+
+	contents, err = json.Marshal(cp)
+	AssertEq(nil, err)
+	AssertEq(nil, ioutil.WriteFile(t.checkpointPath, contents, 0600))
+
+	AssertEq(nil, t.uploadResumable("foo", t.contents))
+
+	// The last part was uploaded to the old upload, then all three to a new
+	// one.
+	ExpectEq(4, t.bucket.uploadCalls)
+	ExpectEq(1, t.bucket.abortCalls)
+	ExpectFalse(t.checkpointExists())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
+
+func (t *ResumeTest) CorruptCheckpoint() {
+	AssertEq(nil, ioutil.WriteFile(t.checkpointPath, []byte("taco"), 0600))
+
+	err := t.uploadResumable("foo", t.contents)
+
+	ExpectThat(err, Error(HasSubstr("Invalid checkpoint")))
+	ExpectEq(0, t.bucket.uploadCalls)
+}
+
+func (t *ResumeTest) AbortCheckpointedUpload() {
+	t.failedUpload("foo")
+
+	AssertEq(nil, s3util.AbortCheckpointedUpload(t.bucket, t.checkpointPath))
+
+	ExpectFalse(t.checkpointExists())
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
+
+func (t *ResumeTest) AbortCheckpointedUploadWithoutCheckpoint() {
+	err := s3util.AbortCheckpointedUpload(t.bucket, t.checkpointPath)
+
+	ExpectEq(nil, err)
+	ExpectEq(0, t.bucket.abortCalls)
+}
+
+func (t *ResumeTest) AbortStaleUploads() {
+	for _, key := range []string{"foo", "bar", "foo"} {
+		_, err := t.bucket.InitiateMultipartUpload(key)
+		AssertEq(nil, err)
+	}
+
+	// Nothing was initiated before the distant past.
+	aborted, err := s3util.AbortStaleUploads(
+		t.bucket,
+		time.Now().Add(-time.Hour))
+
+	AssertEq(nil, err)
+	ExpectEq(0, len(aborted))
+
+	// Everything was initiated before the near future.
+	aborted, err = s3util.AbortStaleUploads(
+		t.bucket,
+		time.Now().Add(time.Hour))
+
+	AssertEq(nil, err)
+	AssertEq(3, len(aborted))
+	ExpectEq("bar", aborted[0].Key)
+	ExpectEq("foo", aborted[1].Key)
+	ExpectEq("foo", aborted[2].Key)
+	ExpectEq(3, t.bucket.abortCalls)
+
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	ExpectEq(0, len(uploads))
+}
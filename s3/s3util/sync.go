@@ -0,0 +1,490 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util
+
+import (
+	"crypto/md5"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"io"
+	"io/ioutil"
+	sys_http "net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	sys_time "time"
+)
+
+// SyncDirection says which way Sync copies objects.
+type SyncDirection int
+
+const (
+	// Copy files from the local directory to the bucket.
+	SyncUp SyncDirection = iota
+
+	// Copy objects from the bucket to the local directory.
+	SyncDown
+)
+
+// The number of concurrent transfers used by Sync when SyncOptions.Parallelism
+// is zero.
+const defaultSyncParallelism = 8
+
+// SyncOptions controls the behavior of Sync.
+type SyncOptions struct {
+	// The local directory to sync.
+	Dir string
+
+	// The prefix within the bucket to sync. The file with relative path
+	// "foo/bar" within Dir corresponds to the key Prefix + "foo/bar". The
+	// prefix commonly ends in a slash, but this is not required.
+	Prefix string
+
+	// Which way to copy.
+	Direction SyncDirection
+
+	// If true, remove objects or files at the destination that don't exist at
+	// the source.
+	Delete bool
+
+	// The maximum number of concurrent operations. Zero means a reasonable
+	// default.
+	Parallelism int
+
+	// If true, work out what would be done and report it, but don't modify the
+	// destination.
+	DryRun bool
+}
+
+// SyncReport describes the work done (or, for a dry run, the work that would
+// be done) by Sync. Each list contains slash-separated paths relative to the
+// directory and prefix, in sorted order.
+type SyncReport struct {
+	// Paths that were missing or different at the destination and were copied.
+	Copied []string
+
+	// Paths that were removed from the destination because they didn't exist
+	// at the source.
+	Deleted []string
+
+	// Paths that already matched at the destination.
+	Unchanged []string
+}
+
+// Sync mirrors a local directory to a prefix within a bucket, or the reverse,
+// according to opts.Direction.
+//
+// A file and an object are considered the same if they have the same size
+// and, when the object's ETag is the MD5 of its contents, the same MD5 hash.
+// Objects uploaded in multiple parts or encrypted with a KMS key or a key
+// supplied by the customer have other ETags; for those, the destination is
+// considered up to date if it was modified no earlier than the source. Only
+// paths that differ are copied.
+//
+// When syncing down, keys that would map to a path outside of opts.Dir cause
+// an error, and keys ending in a slash are ignored.
+func Sync(bucket s3.Bucket, opts SyncOptions) (report *SyncReport, err error) {
+	// Find what's on each side.
+	localPaths, err := listLocalFiles(opts.Dir)
+	if err != nil {
+		err = fmt.Errorf("listLocalFiles: %v", err)
+		return
+	}
+
+	remotePaths, err := listRemoteObjects(bucket, opts.Prefix)
+	if err != nil {
+		err = fmt.Errorf("listRemoteObjects: %v", err)
+		return
+	}
+
+	src, dst := localPaths, remotePaths
+	if opts.Direction == SyncDown {
+		src, dst = remotePaths, localPaths
+	}
+
+	s := &syncer{
+		bucket: bucket,
+		opts:   opts,
+		report: &SyncReport{},
+	}
+
+	// Copy or compare everything at the source, and delete anything extraneous
+	// at the destination if requested.
+	var ops []func() error
+	for p := range src {
+		p := p
+		ops = append(ops, func() error { return s.syncPath(p, dst[p]) })
+	}
+
+	if opts.Delete {
+		for p := range dst {
+			if src[p] {
+				continue
+			}
+
+			p := p
+			ops = append(ops, func() error { return s.deletePath(p) })
+		}
+	}
+
+	if err = runConcurrently(ops, opts.Parallelism); err != nil {
+		return
+	}
+
+	sort.Strings(s.report.Copied)
+	sort.Strings(s.report.Deleted)
+	sort.Strings(s.report.Unchanged)
+
+	report = s.report
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// Return the set of slash-separated relative paths of regular files within
+// the supplied directory.
+func listLocalFiles(dir string) (paths map[string]bool, err error) {
+	paths = make(map[string]bool)
+	err = filepath.Walk(
+		dir,
+		func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+
+			paths[filepath.ToSlash(rel)] = true
+			return nil
+		})
+
+	return
+}
+
+// Return the set of relative paths of objects with the supplied prefix.
+func listRemoteObjects(
+	bucket s3.Bucket,
+	prefix string) (paths map[string]bool, err error) {
+	paths = make(map[string]bool)
+
+	// Keys are returned in order, and every key with the prefix is greater
+	// than the prefix itself, so we can stop at the first one without it.
+	it := NewKeyIterator(bucket, prefix)
+	for it.Next() {
+		key := it.Key()
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+
+		rel := key[len(prefix):]
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+
+		paths[rel] = true
+	}
+
+	if err = it.Err(); err != nil {
+		err = fmt.Errorf("KeyIterator: %v", err)
+		return
+	}
+
+	return
+}
+
+// Run the supplied operations with at most the given number in flight at a
+// time, returning the first error encountered. Once an operation fails, no
+// further operations are started.
+func runConcurrently(ops []func() error, parallelism int) (err error) {
+	if parallelism <= 0 {
+		parallelism = defaultSyncParallelism
+	}
+
+	var mutex sync.Mutex
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return err != nil
+	}
+
+	opChan := make(chan func() error)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range opChan {
+				if failed() {
+					continue
+				}
+
+				if opErr := op(); opErr != nil {
+					mutex.Lock()
+					if err == nil {
+						err = opErr
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, op := range ops {
+		opChan <- op
+	}
+
+	close(opChan)
+	wg.Wait()
+
+	return
+}
+
+type syncer struct {
+	bucket s3.Bucket
+	opts   SyncOptions
+
+	mutex  sync.Mutex
+	report *SyncReport // Protected by mutex
+}
+
+func (s *syncer) record(list *[]string, p string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	*list = append(*list, p)
+}
+
+// Return the local file system path for the supplied relative path, making
+// sure that it doesn't escape the directory.
+func (s *syncer) localPath(p string) (string, error) {
+	cleaned := path.Clean("/" + p)
+	if cleaned[1:] != p {
+		return "", fmt.Errorf("Illegal relative path: %q", p)
+	}
+
+	return filepath.Join(s.opts.Dir, filepath.FromSlash(p)), nil
+}
+
+// Compute the size and hex-encoded MD5 hash of a local file, and find when it
+// was last modified.
+func localFileInfo(localPath string) (
+	size int64,
+	md5Hex string,
+	modTime sys_time.Time,
+	err error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	modTime = fi.ModTime()
+
+	h := md5.New()
+	if size, err = io.Copy(h, f); err != nil {
+		return
+	}
+
+	md5Hex = fmt.Sprintf("%x", h.Sum(nil))
+	return
+}
+
+// Return true if the local file and remote object with the supplied relative
+// path have the same contents, as far as we can tell.
+func (s *syncer) same(p string) (same bool, err error) {
+	localPath, err := s.localPath(p)
+	if err != nil {
+		return
+	}
+
+	size, md5Hex, modTime, err := localFileInfo(localPath)
+	if err != nil {
+		err = fmt.Errorf("localFileInfo: %v", err)
+		return
+	}
+
+	header, err := s.bucket.GetHeader(s.opts.Prefix + p)
+	if err != nil {
+		err = fmt.Errorf("GetHeader: %v", err)
+		return
+	}
+
+	remoteSize, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Invalid Content-Length for %s: %v", p, err)
+		return
+	}
+
+	if size != remoteSize {
+		return false, nil
+	}
+
+	// If the ETag isn't an MD5 hash of the content, fall back to comparing
+	// modification times. S3's are accurate only to the second.
+	if !s3.ETagIsMD5(header) {
+		var remoteTime sys_time.Time
+		remoteTime, err = sys_http.ParseTime(header.Get("Last-Modified"))
+		if err != nil {
+			err = fmt.Errorf("Invalid Last-Modified for %s: %v", p, err)
+			return
+		}
+
+		localTime := modTime.Truncate(sys_time.Second)
+		if s.opts.Direction == SyncDown {
+			return !localTime.Before(remoteTime), nil
+		}
+
+		return !remoteTime.Before(localTime), nil
+	}
+
+	etag := strings.Trim(header.Get("ETag"), "\"")
+	return strings.ToLower(etag) == md5Hex, nil
+}
+
+// Sync the supplied path from the source to the destination. existsAtDst says
+// whether the destination already has something at that path.
+func (s *syncer) syncPath(p string, existsAtDst bool) (err error) {
+	if existsAtDst {
+		var same bool
+		if same, err = s.same(p); err != nil {
+			return
+		}
+
+		if same {
+			s.record(&s.report.Unchanged, p)
+			return
+		}
+	}
+
+	if !s.opts.DryRun {
+		if s.opts.Direction == SyncDown {
+			err = s.download(p)
+		} else {
+			err = s.upload(p)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	s.record(&s.report.Copied, p)
+	return
+}
+
+func (s *syncer) upload(p string) (err error) {
+	localPath, err := s.localPath(p)
+	if err != nil {
+		return
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+
+	if err = s.bucket.Put(s.opts.Prefix+p, f); err != nil {
+		err = fmt.Errorf("Put: %v", err)
+		return
+	}
+
+	return
+}
+
+func (s *syncer) download(p string) (err error) {
+	localPath, err := s.localPath(p)
+	if err != nil {
+		return
+	}
+
+	data, err := s.bucket.GetObject(s.opts.Prefix + p)
+	if err != nil {
+		err = fmt.Errorf("GetObject: %v", err)
+		return
+	}
+
+	// Write to a temporary file, then atomically rename it into place.
+	dir := filepath.Dir(localPath)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	f, err := ioutil.TempFile(dir, ".sync")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), localPath)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return
+}
+
+func (s *syncer) deletePath(p string) (err error) {
+	if !s.opts.DryRun {
+		if s.opts.Direction == SyncDown {
+			var localPath string
+			if localPath, err = s.localPath(p); err != nil {
+				return
+			}
+
+			err = os.Remove(localPath)
+		} else {
+			if err = s.bucket.DeleteObject(s.opts.Prefix + p); err != nil {
+				err = fmt.Errorf("DeleteObject: %v", err)
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	s.record(&s.report.Deleted, p)
+	return
+}
@@ -0,0 +1,324 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util_test
+
+import (
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/s3util"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	sys_http "net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	sys_time "time"
+)
+
+func TestSync(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A bucket that wraps another, recording the prevKey of each call to ListKeys.
+type listRecordingBucket struct {
+	s3.Bucket
+
+	mutex    sync.Mutex
+	prevKeys []string
+}
+
+func (b *listRecordingBucket) ListKeys(prevKey string) ([]string, error) {
+	b.mutex.Lock()
+	b.prevKeys = append(b.prevKeys, prevKey)
+	b.mutex.Unlock()
+
+	return b.Bucket.ListKeys(prevKey)
+}
+
+// A bucket that wraps another, reporting every object as encrypted with a KMS
+// key, so that its ETag isn't the MD5 of its contents.
+type kmsBucket struct {
+	s3.Bucket
+}
+
+func (b *kmsBucket) GetHeader(key string) (sys_http.Header, error) {
+	header, err := b.Bucket.GetHeader(key)
+	if header != nil {
+		header.Set("x-amz-server-side-encryption", "aws:kms")
+		header.Set("ETag", "\"0123456789abcdef0123456789abcdef\"")
+	}
+
+	return header, err
+}
+
+type SyncTest struct {
+	dir    string
+	bucket s3.Bucket
+	opts   s3util.SyncOptions
+
+	report *s3util.SyncReport
+	err    error
+}
+
+func init() { RegisterTestSuite(&SyncTest{}) }
+
+func (t *SyncTest) SetUp(i *TestInfo) {
+	var err error
+
+	t.dir, err = ioutil.TempDir("", "sync_test")
+	AssertEq(nil, err)
+
+	t.bucket = s3.NewMemBucket()
+	t.opts = s3util.SyncOptions{
+		Dir:    t.dir,
+		Prefix: "some/prefix/",
+	}
+}
+
+func (t *SyncTest) TearDown() {
+	os.RemoveAll(t.dir)
+}
+
+func (t *SyncTest) call() {
+	t.report, t.err = s3util.Sync(t.bucket, t.opts)
+}
+
+func (t *SyncTest) writeFile(rel string, contents string) {
+	p := filepath.Join(t.dir, filepath.FromSlash(rel))
+	AssertEq(nil, os.MkdirAll(filepath.Dir(p), 0755))
+	AssertEq(nil, ioutil.WriteFile(p, []byte(contents), 0644))
+}
+
+func (t *SyncTest) readFile(rel string) string {
+	contents, err := ioutil.ReadFile(filepath.Join(t.dir, filepath.FromSlash(rel)))
+	AssertEq(nil, err)
+	return string(contents)
+}
+
+func (t *SyncTest) fileExists(rel string) bool {
+	_, err := os.Stat(filepath.Join(t.dir, filepath.FromSlash(rel)))
+	return err == nil
+}
+
+func (t *SyncTest) storeObject(key string, contents string) {
+	AssertEq(nil, t.bucket.StoreObject(key, []byte(contents)))
+}
+
+func (t *SyncTest) getObject(key string) string {
+	data, err := t.bucket.GetObject(key)
+	AssertEq(nil, err)
+	return string(data)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *SyncTest) UpToEmptyBucket() {
+	t.writeFile("taco", "a")
+	t.writeFile("foo/burrito", "bb")
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("foo/burrito", "taco"))
+	ExpectThat(t.report.Deleted, ElementsAre())
+	ExpectThat(t.report.Unchanged, ElementsAre())
+
+	ExpectEq("a", t.getObject("some/prefix/taco"))
+	ExpectEq("bb", t.getObject("some/prefix/foo/burrito"))
+}
+
+func (t *SyncTest) UpOnlyCopiesChangedFiles() {
+	t.writeFile("same", "taco")
+	t.writeFile("different_size", "taco")
+	t.writeFile("different_contents", "taco")
+	t.storeObject("some/prefix/same", "taco")
+	t.storeObject("some/prefix/different_size", "burrito")
+	t.storeObject("some/prefix/different_contents", "tacx")
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(
+		t.report.Copied,
+		ElementsAre("different_contents", "different_size"))
+	ExpectThat(t.report.Unchanged, ElementsAre("same"))
+
+	ExpectEq("taco", t.getObject("some/prefix/different_size"))
+	ExpectEq("taco", t.getObject("some/prefix/different_contents"))
+}
+
+func (t *SyncTest) UpComparesTimesWhenETagIsntMD5() {
+	t.bucket = &kmsBucket{t.bucket}
+
+	t.writeFile("same", "taco")
+	t.writeFile("modified_since", "taco")
+	t.storeObject("some/prefix/same", "taco")
+	t.storeObject("some/prefix/modified_since", "tacx")
+
+	// The ETags don't match either file, but only one was modified after the
+	// objects were stored.
+	now := sys_time.Now()
+	for name, modTime := range map[string]sys_time.Time{
+		"same":           now.Add(-sys_time.Hour),
+		"modified_since": now.Add(sys_time.Hour),
+	} {
+		p := filepath.Join(t.dir, name)
+		AssertEq(nil, os.Chtimes(p, modTime, modTime))
+	}
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("modified_since"))
+	ExpectThat(t.report.Unchanged, ElementsAre("same"))
+
+	ExpectEq("taco", t.getObject("some/prefix/modified_since"))
+}
+
+func (t *SyncTest) UpIgnoresKeysOutsidePrefix() {
+	t.writeFile("taco", "a")
+	t.storeObject("other/taco", "b")
+	t.storeObject("some/prefix/burrito", "c")
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("taco"))
+	ExpectThat(t.report.Deleted, ElementsAre())
+
+	ExpectEq("b", t.getObject("other/taco"))
+	ExpectEq("c", t.getObject("some/prefix/burrito"))
+}
+
+func (t *SyncTest) ListingStartsAtPrefix() {
+	bucket := &listRecordingBucket{Bucket: t.bucket}
+	t.bucket = bucket
+
+	t.storeObject("other/taco", "a")
+	t.storeObject("some/prefix/burrito", "b")
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	AssertLt(0, len(bucket.prevKeys))
+	ExpectEq("some/prefix/", bucket.prevKeys[0])
+}
+
+func (t *SyncTest) UpWithDelete() {
+	t.writeFile("taco", "a")
+	t.storeObject("other/taco", "b")
+	t.storeObject("some/prefix/burrito", "c")
+	t.opts.Delete = true
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("taco"))
+	ExpectThat(t.report.Deleted, ElementsAre("burrito"))
+
+	keys, err := s3util.ListAllKeys(t.bucket)
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("other/taco", "some/prefix/taco"))
+}
+
+func (t *SyncTest) UpDryRun() {
+	t.writeFile("taco", "a")
+	t.storeObject("some/prefix/burrito", "c")
+	t.opts.Delete = true
+	t.opts.DryRun = true
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("taco"))
+	ExpectThat(t.report.Deleted, ElementsAre("burrito"))
+
+	keys, err := s3util.ListAllKeys(t.bucket)
+	AssertEq(nil, err)
+	ExpectThat(keys, ElementsAre("some/prefix/burrito"))
+}
+
+func (t *SyncTest) Down() {
+	t.opts.Direction = s3util.SyncDown
+	t.opts.Delete = true
+	t.opts.Parallelism = 1
+
+	t.writeFile("same", "taco")
+	t.writeFile("different", "taco")
+	t.writeFile("extraneous", "taco")
+	t.storeObject("some/prefix/same", "taco")
+	t.storeObject("some/prefix/different", "burrito")
+	t.storeObject("some/prefix/foo/bar/new", "enchilada")
+	t.storeObject("some/prefix/dir_marker/", "")
+	t.storeObject("other", "queso")
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("different", "foo/bar/new"))
+	ExpectThat(t.report.Deleted, ElementsAre("extraneous"))
+	ExpectThat(t.report.Unchanged, ElementsAre("same"))
+
+	ExpectEq("taco", t.readFile("same"))
+	ExpectEq("burrito", t.readFile("different"))
+	ExpectEq("enchilada", t.readFile("foo/bar/new"))
+	ExpectFalse(t.fileExists("extraneous"))
+	ExpectFalse(t.fileExists("dir_marker"))
+}
+
+func (t *SyncTest) DownDryRun() {
+	t.opts.Direction = s3util.SyncDown
+	t.opts.Delete = true
+	t.opts.DryRun = true
+
+	t.writeFile("extraneous", "taco")
+	t.storeObject("some/prefix/new", "burrito")
+
+	t.call()
+	AssertEq(nil, t.err)
+
+	ExpectThat(t.report.Copied, ElementsAre("new"))
+	ExpectThat(t.report.Deleted, ElementsAre("extraneous"))
+
+	ExpectTrue(t.fileExists("extraneous"))
+	ExpectFalse(t.fileExists("new"))
+}
+
+func (t *SyncTest) DownRefusesToEscapeDirectory() {
+	t.opts.Direction = s3util.SyncDown
+	t.storeObject("some/prefix/../taco", "burrito")
+
+	t.call()
+
+	ExpectThat(t.err, Error(HasSubstr("Illegal")))
+	ExpectFalse(t.fileExists("../taco"))
+}
+
+func (t *SyncTest) DirectoryDoesntExist() {
+	t.opts.Dir = filepath.Join(t.dir, "foo")
+
+	t.call()
+
+	ExpectThat(t.err, Error(HasSubstr("no such file")))
+}
@@ -0,0 +1,387 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/time"
+	"io"
+	sys_http "net/http"
+	"strconv"
+	sys_time "time"
+)
+
+// Defaults for the fields of TransferOptions.
+const (
+	defaultPartSize    = 8 * 1024 * 1024
+	defaultConcurrency = 4
+	defaultMaxAttempts = 3
+)
+
+// The delay before the second attempt at an operation, doubling with each
+// further attempt up to the maximum.
+const (
+	minRetryDelay = 100 * sys_time.Millisecond
+	maxRetryDelay = 10 * sys_time.Second
+)
+
+// TransferOptions controls the behavior of an Uploader or Downloader.
+type TransferOptions struct {
+	// The size of each part into which objects are split. Objects no larger
+	// than this are transferred with a single request. Zero means a reasonable
+	// default. For uploads, this must be at least s3.MinPartSize, and is
+	// increased if necessary to keep the number of parts within s3.MaxParts.
+	PartSize int64
+
+	// The maximum number of parts to transfer at once. Zero means a reasonable
+	// default.
+	Concurrency int
+
+	// The maximum number of times to attempt transferring each part before
+	// giving up. Zero means a reasonable default.
+	MaxAttempts int
+
+	// The clock used to wait between attempts. Nil means the real clock.
+	Clock time.Clock
+}
+
+func (o *TransferOptions) setDefaults() {
+	if o.PartSize == 0 {
+		o.PartSize = defaultPartSize
+	}
+
+	if o.Concurrency == 0 {
+		o.Concurrency = defaultConcurrency
+	}
+
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = defaultMaxAttempts
+	}
+
+	if o.Clock == nil {
+		o.Clock = time.RealClock()
+	}
+}
+
+func (o *TransferOptions) validate() error {
+	if o.PartSize < 0 {
+		return fmt.Errorf("Invalid part size: %d", o.PartSize)
+	}
+
+	if o.Concurrency < 0 {
+		return fmt.Errorf("Invalid concurrency: %d", o.Concurrency)
+	}
+
+	if o.MaxAttempts < 0 {
+		return fmt.Errorf("Invalid max attempts: %d", o.MaxAttempts)
+	}
+
+	return nil
+}
+
+// Call f until it succeeds, up to the configured number of attempts,
+// returning the last error if it never does. An error that s3.IsRetryable
+// says will happen again, such as a missing key, is returned at once.
+// Attempts are spaced out
+// exponentially, so that we don't add to the load of a server that is asking
+// us to slow down.
+func (o *TransferOptions) retry(f func() error) (err error) {
+	delay := minRetryDelay
+	for i := 0; i < o.MaxAttempts; i++ {
+		if i > 0 {
+			o.Clock.Sleep(delay)
+			delay *= 2
+			if delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+		}
+
+		if err = f(); err == nil || !s3.IsRetryable(err) {
+			return
+		}
+	}
+
+	return
+}
+
+// Split an object of the given size into parts of at most partSize bytes,
+// returning the offset and length of each.
+func splitParts(size int64, partSize int64) (offsets []int64, lengths []int64) {
+	for offset := int64(0); offset < size; offset += partSize {
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		offsets = append(offsets, offset)
+		lengths = append(lengths, length)
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Uploader
+////////////////////////////////////////////////////////////////////////
+
+// An Uploader stores large objects by splitting them into parts that are
+// uploaded in parallel, using S3's multipart upload API. This is often much
+// faster than a single request. It is safe for concurrent use.
+type Uploader struct {
+	bucket s3.Bucket
+	opts   TransferOptions
+}
+
+// NewUploader creates an Uploader that stores objects in the supplied bucket.
+func NewUploader(bucket s3.Bucket, opts TransferOptions) (*Uploader, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	opts.setDefaults()
+	if opts.PartSize < s3.MinPartSize {
+		return nil, fmt.Errorf(
+			"Part size %d is less than the minimum of %d.",
+			opts.PartSize,
+			s3.MinPartSize)
+	}
+
+	return &Uploader{bucket, opts}, nil
+}
+
+// Upload stores size bytes read from r as the object with the given key,
+// overwriting any previous version. If the upload fails part way through, the
+// previous version (if any) remains.
+func (u *Uploader) Upload(key string, r io.ReaderAt, size int64) (err error) {
+	// Small objects can be uploaded in a single request.
+	if size <= u.opts.PartSize {
+		err = u.opts.retry(func() error {
+			return u.bucket.Put(key, io.NewSectionReader(r, 0, size))
+		})
+
+		if err != nil {
+			err = fmt.Errorf("Put: %v", err)
+		}
+
+		return
+	}
+
+	// Start the upload.
+	var uploadId string
+	err = u.opts.retry(func() (err error) {
+		uploadId, err = u.bucket.InitiateMultipartUpload(key)
+		return
+	})
+
+	if err != nil {
+		err = fmt.Errorf("InitiateMultipartUpload: %v", err)
+		return
+	}
+
+	// Make sure we clean up if anything goes wrong. There's not much we can do
+	// if the abort fails.
+	defer func() {
+		if err != nil {
+			u.bucket.AbortMultipartUpload(key, uploadId)
+		}
+	}()
+
+	// Upload each part.
+	offsets, lengths := splitParts(size, u.partSize(size))
+	parts := make([]s3.Part, len(offsets))
+	ops := make([]func() error, len(offsets))
+
+	for i := range offsets {
+		i := i
+		parts[i].PartNumber = i + 1
+		ops[i] = func() error {
+			data, err := readPart(r, offsets[i], lengths[i])
+			if err != nil {
+				return err
+			}
+
+			if err := u.uploadPart(key, uploadId, data, &parts[i]); err != nil {
+				return fmt.Errorf("UploadPart(%d): %v", parts[i].PartNumber, err)
+			}
+
+			return nil
+		}
+	}
+
+	if err = runConcurrently(ops, u.opts.Concurrency); err != nil {
+		return
+	}
+
+	// Assemble the parts.
+	err = u.opts.retry(func() error {
+		return u.bucket.CompleteMultipartUpload(key, uploadId, parts)
+	})
+
+	if err != nil {
+		err = fmt.Errorf("CompleteMultipartUpload: %v", err)
+		return
+	}
+
+	return
+}
+
+// Return the part size to use for an object of the given size, making sure
+// that we don't exceed the maximum number of parts.
+func (u *Uploader) partSize(size int64) (partSize int64) {
+	partSize = u.opts.PartSize
+	if max := int64(s3.MaxParts); (size+partSize-1)/partSize > max {
+		partSize = (size + max - 1) / max
+	}
+
+	return
+}
+
+func readPart(
+	r io.ReaderAt,
+	offset int64,
+	length int64) (data []byte, err error) {
+	data = make([]byte, length)
+	if _, err = r.ReadAt(data, offset); err != nil {
+		err = fmt.Errorf("ReadAt: %v", err)
+		return
+	}
+
+	return
+}
+
+// Upload the supplied data as the part with part.PartNumber, filling in
+// part.ETag.
+func (u *Uploader) uploadPart(
+	key string,
+	uploadId string,
+	data []byte,
+	part *s3.Part) error {
+	return u.opts.retry(func() (err error) {
+		part.ETag, err = u.bucket.UploadPart(key, uploadId, part.PartNumber, data)
+		return
+	})
+}
+
+////////////////////////////////////////////////////////////////////////
+// Downloader
+////////////////////////////////////////////////////////////////////////
+
+// A Downloader retrieves large objects by splitting them into byte ranges
+// that are downloaded in parallel. It is safe for concurrent use.
+type Downloader struct {
+	bucket s3.Bucket
+	opts   TransferOptions
+}
+
+// NewDownloader creates a Downloader that retrieves objects from the supplied
+// bucket.
+func NewDownloader(
+	bucket s3.Bucket,
+	opts TransferOptions) (*Downloader, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	opts.setDefaults()
+	return &Downloader{bucket, opts}, nil
+}
+
+// Download retrieves the object with the given key, writing its contents to
+// w at the corresponding offsets. Parts may be written in any order and
+// concurrently. It returns the size of the object. If the object is
+// overwritten part way through, the download fails rather than mixing the
+// contents of the two versions.
+func (d *Downloader) Download(
+	key string,
+	w io.WriterAt) (size int64, err error) {
+	// Find the size and ETag of the object.
+	var header sys_http.Header
+	err = d.opts.retry(func() (err error) {
+		header, err = d.bucket.GetHeader(key)
+		return
+	})
+
+	if err != nil {
+		err = fmt.Errorf("GetHeader: %v", err)
+		return
+	}
+
+	size, err = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Invalid Content-Length: %v", err)
+		return
+	}
+
+	etag := header.Get("ETag")
+
+	// Download each part.
+	offsets, lengths := splitParts(size, d.opts.PartSize)
+	ops := make([]func() error, len(offsets))
+
+	for i := range offsets {
+		i := i
+		ops[i] = func() error {
+			return d.downloadPart(key, etag, w, offsets[i], lengths[i])
+		}
+	}
+
+	err = runConcurrently(ops, d.opts.Concurrency)
+	return
+}
+
+// Download a single range of the object, making sure that it still has the
+// supplied ETag.
+func (d *Downloader) downloadPart(
+	key string,
+	etag string,
+	w io.WriterAt,
+	offset int64,
+	length int64) (err error) {
+	var data []byte
+	err = d.opts.retry(func() (err error) {
+		data, err = d.bucket.GetObjectRangeIfMatch(key, offset, length, etag)
+		return
+	})
+
+	if err != nil {
+		err = fmt.Errorf(
+			"GetObjectRangeIfMatch(%d, %d): %v",
+			offset,
+			length,
+			err)
+		return
+	}
+
+	// If the object has shrunk since we found its size, we'll get less data
+	// than we asked for.
+	if int64(len(data)) != length {
+		err = fmt.Errorf(
+			"Object changed during download: got %d bytes at offset %d, "+
+				"expected %d.",
+			len(data),
+			offset,
+			length)
+		return
+	}
+
+	if _, err = w.WriteAt(data, offset); err != nil {
+		err = fmt.Errorf("WriteAt: %v", err)
+		return
+	}
+
+	return
+}
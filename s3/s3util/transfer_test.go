@@ -0,0 +1,437 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3util_test
+
+import (
+	"bytes"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/s3util"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransfer(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A bucket that wraps another, failing a configurable number of calls to
+// the part-level methods (and every upload of failPart, if non-zero) and
+// counting the calls it sees. If non-nil, hook is called at the start of each
+// part upload or range read.
+type flakyBucket struct {
+	s3.Bucket
+
+	mutex         sync.Mutex
+	failuresLeft  int
+	failPart      int
+	failComplete  bool
+	failAbort     bool
+	putCalls      int
+	uploadCalls   int
+	rangeCalls    int
+	abortCalls    int
+	completeCalls int
+	hook          func()
+}
+
+// The error returned by the calls that flakyBucket fails, which is worth
+// retrying.
+var errTaco = &s3.ServerError{
+	StatusCode: 503,
+	Code:       "SlowDown",
+	Message:    "taco",
+}
+
+func (b *flakyBucket) fail() bool {
+	if b.failuresLeft > 0 {
+		b.failuresLeft--
+		return true
+	}
+
+	return false
+}
+
+func (b *flakyBucket) callHook() {
+	b.mutex.Lock()
+	hook := b.hook
+	b.mutex.Unlock()
+
+	if hook != nil {
+		hook()
+	}
+}
+
+func (b *flakyBucket) Put(key string, data io.ReadSeeker) error {
+	b.mutex.Lock()
+	b.putCalls++
+	fail := b.fail()
+	b.mutex.Unlock()
+
+	if fail {
+		return errTaco
+	}
+
+	return b.Bucket.Put(key, data)
+}
+
+func (b *flakyBucket) UploadPart(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte) (string, error) {
+	b.callHook()
+
+	b.mutex.Lock()
+	b.uploadCalls++
+	fail := b.fail() || partNumber == b.failPart
+	b.mutex.Unlock()
+
+	if fail {
+		return "", errTaco
+	}
+
+	return b.Bucket.UploadPart(key, uploadId, partNumber, data)
+}
+
+func (b *flakyBucket) CompleteMultipartUpload(
+	key string,
+	uploadId string,
+	parts []s3.Part) error {
+	b.mutex.Lock()
+	b.completeCalls++
+	fail := b.failComplete
+	b.mutex.Unlock()
+
+	if fail {
+		return errTaco
+	}
+
+	return b.Bucket.CompleteMultipartUpload(key, uploadId, parts)
+}
+
+func (b *flakyBucket) AbortMultipartUpload(key string, uploadId string) error {
+	b.mutex.Lock()
+	b.abortCalls++
+	fail := b.failAbort
+	b.mutex.Unlock()
+
+	if fail {
+		return errTaco
+	}
+
+	return b.Bucket.AbortMultipartUpload(key, uploadId)
+}
+
+func (b *flakyBucket) GetObjectRangeIfMatch(
+	key string,
+	offset int64,
+	length int64,
+	etag string) ([]byte, error) {
+	b.callHook()
+
+	b.mutex.Lock()
+	b.rangeCalls++
+	fail := b.fail()
+	b.mutex.Unlock()
+
+	if fail {
+		return nil, errTaco
+	}
+
+	return b.Bucket.GetObjectRangeIfMatch(key, offset, length, etag)
+}
+
+type TransferTest struct {
+	clock  *aws_time.SimulatedClock
+	bucket *flakyBucket
+	opts   s3util.TransferOptions
+
+	// Contents that span two full parts and a bit more.
+	contents []byte
+}
+
+func init() { RegisterTestSuite(&TransferTest{}) }
+
+func (t *TransferTest) SetUp(i *TestInfo) {
+	t.clock = aws_time.NewSimulatedClock(time.Unix(1325376000, 0))
+	t.bucket = &flakyBucket{Bucket: s3.NewMemBucket()}
+	t.opts = s3util.TransferOptions{
+		PartSize:    s3.MinPartSize,
+		Concurrency: 2,
+		Clock:       t.clock,
+	}
+
+	t.contents = make([]byte, 2*s3.MinPartSize+17)
+	for i := range t.contents {
+		t.contents[i] = byte(i % 251)
+	}
+}
+
+func (t *TransferTest) upload(key string, data []byte) error {
+	u, err := s3util.NewUploader(t.bucket, t.opts)
+	AssertEq(nil, err)
+
+	return t.run(func() error {
+		return u.Upload(key, bytes.NewReader(data), int64(len(data)))
+	})
+}
+
+// Download the object into a temporary file, returning its contents.
+func (t *TransferTest) download(key string) (data []byte, err error) {
+	d, err := s3util.NewDownloader(t.bucket, t.opts)
+	AssertEq(nil, err)
+
+	f, err := ioutil.TempFile("", "transfer_test")
+	AssertEq(nil, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var size int64
+	err = t.run(func() (err error) {
+		size, err = d.Download(key, f)
+		return
+	})
+
+	if err != nil {
+		return
+	}
+
+	data, err = ioutil.ReadFile(f.Name())
+	AssertEq(nil, err)
+	AssertEq(len(data), size)
+
+	return
+}
+
+// Call f, advancing the clock in steps of 100ms (which divide every delay
+// between attempts) whenever someone is sleeping, and return its result.
+func (t *TransferTest) run(f func() error) (err error) {
+	stopped := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			t.clock.WaitForTimers(1)
+			select {
+			case <-stopped:
+				return
+			default:
+			}
+
+			t.clock.AdvanceTime(100 * time.Millisecond)
+		}
+	}()
+
+	err = f()
+
+	// Stop the goroutine above, waking it if it's waiting for a timer.
+	close(stopped)
+	wake := t.clock.NewTimer(time.Hour)
+	<-exited
+	wake.Stop()
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *TransferTest) PartSizeTooSmall() {
+	t.opts.PartSize = s3.MinPartSize - 1
+	_, err := s3util.NewUploader(t.bucket, t.opts)
+
+	ExpectThat(err, Error(HasSubstr("minimum")))
+}
+
+func (t *TransferTest) SmallObjectUsesSingleRequest() {
+	AssertEq(nil, t.upload("foo", []byte("taco")))
+
+	ExpectEq(1, t.bucket.putCalls)
+	ExpectEq(0, t.bucket.uploadCalls)
+
+	data, err := t.download("foo")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+	ExpectEq(1, t.bucket.rangeCalls)
+}
+
+func (t *TransferTest) EmptyObject() {
+	AssertEq(nil, t.upload("foo", []byte{}))
+
+	data, err := t.download("foo")
+	AssertEq(nil, err)
+	ExpectEq(0, len(data))
+	ExpectEq(0, t.bucket.rangeCalls)
+}
+
+func (t *TransferTest) LargeObjectUsesParts() {
+	AssertEq(nil, t.upload("foo", t.contents))
+
+	ExpectEq(0, t.bucket.putCalls)
+	ExpectEq(3, t.bucket.uploadCalls)
+	ExpectEq(1, t.bucket.completeCalls)
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+
+	data, err = t.download("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+	ExpectEq(3, t.bucket.rangeCalls)
+}
+
+func (t *TransferTest) RetriesFailedParts() {
+	t.bucket.failuresLeft = 2
+
+	AssertEq(nil, t.upload("foo", t.contents))
+	ExpectEq(5, t.bucket.uploadCalls)
+	ExpectEq(0, t.bucket.abortCalls)
+
+	t.bucket.failuresLeft = 2
+
+	data, err := t.download("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+	ExpectEq(5, t.bucket.rangeCalls)
+}
+
+func (t *TransferTest) BacksOffBetweenAttempts() {
+	t.opts.Concurrency = 1
+	t.opts.MaxAttempts = 5
+	t.bucket.failuresLeft = 4
+
+	start := t.clock.Now()
+	var times []time.Duration
+	t.bucket.hook = func() {
+		times = append(times, t.clock.Now().Sub(start))
+	}
+
+	AssertEq(nil, t.upload("foo", t.contents))
+
+	ExpectThat(
+		times,
+		ElementsAre(
+			0,
+			100*time.Millisecond,
+			300*time.Millisecond,
+			700*time.Millisecond,
+			1500*time.Millisecond,
+			1500*time.Millisecond,
+			1500*time.Millisecond))
+}
+
+func (t *TransferTest) BackoffIsCapped() {
+	t.opts.Concurrency = 1
+	t.opts.MaxAttempts = 10
+	t.bucket.failuresLeft = 9
+
+	var times []time.Time
+	t.bucket.hook = func() {
+		times = append(times, t.clock.Now())
+	}
+
+	AssertEq(nil, t.upload("foo", t.contents))
+
+	AssertEq(12, len(times))
+	ExpectEq(6400*time.Millisecond, times[7].Sub(times[6]))
+	ExpectEq(10*time.Second, times[8].Sub(times[7]))
+	ExpectEq(10*time.Second, times[9].Sub(times[8]))
+}
+
+func (t *TransferTest) UploadGivesUp() {
+	t.opts.MaxAttempts = 2
+	t.opts.Concurrency = 1
+	t.bucket.failuresLeft = 2
+
+	err := t.upload("foo", t.contents)
+
+	ExpectThat(err, Error(HasSubstr("UploadPart(1)")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+	ExpectEq(1, t.bucket.abortCalls)
+	ExpectEq(0, t.bucket.completeCalls)
+
+	_, err = t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *TransferTest) DownloadGivesUp() {
+	AssertEq(nil, t.upload("foo", t.contents))
+
+	t.opts.MaxAttempts = 2
+	t.opts.Concurrency = 1
+	t.bucket.failuresLeft = 2
+
+	_, err := t.download("foo")
+
+	ExpectThat(err, Error(HasSubstr("GetObjectRange")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *TransferTest) DownloadNonExistentObject() {
+	_, err := t.download("foo")
+
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *TransferTest) ObjectChangesDuringDownload() {
+	AssertEq(nil, t.upload("foo", t.contents))
+
+	// Overwrite the object with different contents of the same size just
+	// before the first range is read.
+	var once sync.Once
+	t.bucket.hook = func() {
+		once.Do(func() {
+			other := make([]byte, len(t.contents))
+			ExpectEq(nil, t.bucket.Bucket.StoreObject("foo", other))
+		})
+	}
+
+	_, err := t.download("foo")
+
+	ExpectThat(err, Error(HasSubstr("412")))
+	ExpectThat(err, Error(HasSubstr("PreconditionFailed")))
+}
+
+func (t *TransferTest) PermanentErrorsAreNotRetried() {
+	AssertEq(nil, t.upload("foo", t.contents))
+
+	// Overwrite the object just before the first range is read, so that the
+	// read fails with 412 Precondition Failed.
+	var once sync.Once
+	t.bucket.hook = func() {
+		once.Do(func() {
+			other := make([]byte, len(t.contents))
+			ExpectEq(nil, t.bucket.Bucket.StoreObject("foo", other))
+		})
+	}
+
+	t.opts.Concurrency = 1
+	_, err := t.download("foo")
+
+	ExpectThat(err, Error(HasSubstr("412")))
+	ExpectEq(1, t.bucket.rangeCalls)
+}
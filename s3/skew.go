@@ -0,0 +1,68 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/jacobsa/aws/s3/http"
+	"io/ioutil"
+	sys_http "net/http"
+	sys_time "time"
+)
+
+// The maximum difference between a request's timestamp and the server's clock
+// that S3 will accept.
+const maxClockSkew = 15 * sys_time.Minute
+
+// If the response says that the request's timestamp was too far from the
+// server's clock, correct the bucket's clock using the response's Date header
+// and return true. The response body is consumed, but is left available for
+// the caller to read again.
+func (b *bucket) correctSkew(resp *http.Response) bool {
+	if resp.StatusCode != 403 {
+		return false
+	}
+
+	serverTime, err := sys_http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+
+	body, err := resp.ReadBody()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	// Responses to HEAD requests have no body to say what went wrong, so there
+	// we must go by the difference in time alone.
+	if len(body) == 0 {
+		skew := serverTime.Sub(b.clock.Now())
+		if skew < maxClockSkew && skew > -maxClockSkew {
+			return false
+		}
+	} else {
+		var doc errorDocument
+		if err := xml.Unmarshal(body, &doc); err != nil ||
+			doc.Code != "RequestTimeTooSkewed" {
+			return false
+		}
+	}
+
+	b.clock.Observe(serverTime)
+	return true
+}
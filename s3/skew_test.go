@@ -0,0 +1,273 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"github.com/jacobsa/aws/s3/http"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"io/ioutil"
+	sys_http "net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSkew(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type SkewTest struct {
+	bucketTest
+
+	// The time according to the server, an hour ahead of our clock.
+	serverTime time.Time
+
+	// The Date headers of requests sent.
+	dates []string
+}
+
+func init() { RegisterTestSuite(&SkewTest{}) }
+
+func (t *SkewTest) SetUp(i *TestInfo) {
+	t.bucketTest.SetUp(i)
+
+	t.clock.SetTime(time.Date(2012, time.August, 15, 22, 56, 0, 0, time.Local))
+	t.serverTime = t.clock.Now().Add(time.Hour)
+
+	ExpectCall(t.signer, "Sign")(Any()).
+		WillRepeatedly(oglemock.Return(nil))
+}
+
+// Record the request's date and return a response with the given status and
+// body, stamped with the server's time.
+func (t *SkewTest) response(
+	r *http.Request,
+	statusCode int,
+	body string) *http.Response {
+	t.dates = append(t.dates, r.Headers["Date"])
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Header: sys_http.Header{
+			"Date": []string{t.serverTime.UTC().Format(sys_http.TimeFormat)},
+		},
+		Body: stringReadCloser(body),
+	}
+}
+
+// Return an action for SendRequest that calls t.response.
+func (t *SkewTest) respond(statusCode int, body string) oglemock.Action {
+	return oglemock.Invoke(func(r *http.Request) (*http.Response, error) {
+		return t.response(r, statusCode, body), nil
+	})
+}
+
+const skewedBody = `
+	<Error>
+		<Code>RequestTimeTooSkewed</Code>
+		<Message>The difference between the request time and the current time
+			is too large.</Message>
+	</Error>`
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *SkewTest) RetriesWithCorrectedDate() {
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond(403, skewedBody)).
+		WillOnce(t.respond(200, "taco"))
+
+	// Call
+	data, err := t.bucket.GetObject("a")
+	AssertEq(nil, err)
+
+	ExpectEq("taco", string(data))
+	AssertEq(2, len(t.dates))
+	ExpectEq("Wed, 15 Aug 2012 22:56:00 UTC", t.dates[0])
+	ExpectEq("Wed, 15 Aug 2012 23:56:00 UTC", t.dates[1])
+	ExpectEq(1, len(t.retried))
+}
+
+func (t *SkewTest) CorrectionIsRemembered() {
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond(403, skewedBody)).
+		WillOnce(t.respond(200, "")).
+		WillOnce(t.respond(200, ""))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+	AssertEq(nil, err)
+
+	_, err = t.bucket.GetObject("b")
+	AssertEq(nil, err)
+
+	AssertEq(3, len(t.dates))
+	ExpectEq("Wed, 15 Aug 2012 23:56:00 UTC", t.dates[2])
+}
+
+func (t *SkewTest) RetriesOnlyOnce() {
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		Times(2).
+		WillRepeatedly(t.respond(403, skewedBody))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("RequestTimeTooSkewed")))
+}
+
+func (t *SkewTest) ResendsBody() {
+	var bodies []string
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Invoke(
+		func(r *http.Request) (*http.Response, error) {
+			data := make([]byte, 16)
+			n, _ := r.Body.Read(data)
+			bodies = append(bodies, string(data[:n]))
+
+			if len(bodies) == 1 {
+				return t.response(r, 403, skewedBody), nil
+			}
+
+			return t.response(r, 200, ""), nil
+		}))
+
+	// Call
+	err := t.bucket.StoreObject("a", []byte("taco"))
+	AssertEq(nil, err)
+
+	ExpectThat(bodies, ElementsAre("taco", "taco"))
+}
+
+func (t *SkewTest) ResendsFileBody() {
+	var bodies []string
+
+	// A real server, which says the first request is skewed.
+	server := httptest.NewServer(sys_http.HandlerFunc(
+		func(w sys_http.ResponseWriter, r *sys_http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(data))
+
+			w.Header().Set(
+				"Date",
+				t.serverTime.UTC().Format(sys_http.TimeFormat))
+
+			if len(bodies) == 1 {
+				w.WriteHeader(403)
+				w.Write([]byte(skewedBody))
+			}
+		}))
+
+	defer server.Close()
+
+	endpoint, err := url.Parse(server.URL)
+	AssertEq(nil, err)
+
+	realConn, err := http.NewConn(endpoint)
+	AssertEq(nil, err)
+
+	// Conn, which sends the requests through the real library so that the
+	// file is treated as it would be in production.
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Invoke(realConn.SendRequest))
+
+	// File
+	f, err := ioutil.TempFile("", "skew_test")
+	AssertEq(nil, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = f.WriteString("taco")
+	AssertEq(nil, err)
+
+	// Call
+	err = t.bucket.Put("a", f)
+	AssertEq(nil, err)
+
+	ExpectThat(bodies, ElementsAre("taco", "taco"))
+}
+
+func (t *SkewTest) OtherForbiddenErrorNotRetried() {
+	body := "<Error><Code>AccessDenied</Code></Error>"
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond(403, body))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("AccessDenied")))
+}
+
+func (t *SkewTest) MissingDateHeader() {
+	resp := &http.Response{
+		StatusCode: 403,
+		Header:     sys_http.Header{},
+		Body:       stringReadCloser(skewedBody),
+	}
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(resp, nil))
+
+	// Call
+	_, err := t.bucket.GetObject("a")
+
+	ExpectThat(err, Error(HasSubstr("RequestTimeTooSkewed")))
+}
+
+func (t *SkewTest) HeadRequestWithLargeSkew() {
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond(403, "")).
+		WillOnce(t.respond(200, ""))
+
+	// Call
+	_, err := t.bucket.GetHeader("a")
+	AssertEq(nil, err)
+
+	AssertEq(2, len(t.dates))
+	ExpectEq("Wed, 15 Aug 2012 23:56:00 UTC", t.dates[1])
+}
+
+func (t *SkewTest) HeadRequestWithSmallSkew() {
+	t.serverTime = t.clock.Now().Add(time.Minute)
+
+	// Conn
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(t.respond(403, ""))
+
+	// Call
+	_, err := t.bucket.GetHeader("a")
+
+	ExpectThat(err, Error(HasSubstr("403")))
+}
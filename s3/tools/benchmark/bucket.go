@@ -62,7 +62,7 @@ func initBucket() {
 	// Open the bucket.
 	g_bucket, err = s3.OpenBucket(*g_bucketName, s3.Region(*g_region), accessKey)
 	if err != nil {
-		log.Fatalf("OpenBucket:", err)
+		log.Fatalf("OpenBucket: %v", err)
 	}
 }
 
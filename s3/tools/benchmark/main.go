@@ -13,8 +13,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Loop indefinitely, storing and fetching data, and reporting on the time it
-// takes to do so.
+// Measure the latency and throughput of storing and fetching objects of
+// various sizes at various levels of parallelism, reporting the results in
+// text, JSON or CSV form so that runs may be compared. For example:
+//
+//     benchmark -bucket foo -region s3.amazonaws.com -key_id AKIA... \
+//         -ops get,head -sizes 16KiB,1MiB -parallelism 1,8 -duration 30s \
+//         -format csv -output results.csv
+//
+// Progress is logged to stderr. The objects read and written are kept under
+// -key_prefix, and deleted when the run finishes unless -cleanup=false.
 
 package main
 
@@ -22,341 +30,241 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"github.com/jacobsa/aws/bench"
 	"github.com/jacobsa/aws/s3"
 	"log"
 	"math/rand"
-	"sync/atomic"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func storeData(
-	bucket s3.Bucket,
-	dataSize uint) (key string, err error) {
-	// Choose a random key and create some data.
-	key = fmt.Sprintf("value_%016x", rand.Int63())
-	data := bytes.Repeat([]byte{byte(rand.Uint32())}, int(dataSize))
-
-	// Store the object.
-	err = bucket.StoreObject(key, data)
-	return
-}
+var g_ops = flag.String(
+	"ops",
+	"put,get,head",
+	"Comma-separated operations to measure: put, get and head.")
+
+var g_sizes = flag.String(
+	"sizes",
+	"1,16KiB,256KiB,1MiB",
+	"Comma-separated object sizes, e.g. 512,16KiB,1MiB.")
+
+var g_parallelism = flag.String(
+	"parallelism",
+	"1,2",
+	"Comma-separated numbers of concurrent requests.")
+
+var g_duration = flag.Duration(
+	"duration",
+	5*time.Second,
+	"How long to measure each combination of operation, size and parallelism.")
+
+var g_keys = flag.Int(
+	"keys",
+	16,
+	"The number of objects of each size to read from and write to.")
+
+var g_keyPrefix = flag.String(
+	"key_prefix",
+	"benchmark/",
+	"A prefix for the keys of the objects used.")
+
+var g_cleanup = flag.Bool(
+	"cleanup",
+	true,
+	"Delete the objects used once finished.")
+
+var g_format = flag.String(
+	"format",
+	"text",
+	"The format in which to report results: text, json or csv.")
+
+var g_output = flag.String(
+	"output",
+	"",
+	"A file to which results are written. The default is stdout.")
 
 ////////////////////////////////////////////////////////////////////////
-// Latency
+// Operations
 ////////////////////////////////////////////////////////////////////////
 
-func measureLatency(bucket s3.Bucket) (avg time.Duration, err error) {
-	// Average over several runs until we've taken at least this much time.
-	const minDuration time.Duration = 4 * time.Second
-
-	var total time.Duration
-	var numRuns int
-
-	for ; total < minDuration; numRuns++ {
-		timeBefore := time.Now()
-		if _, err = storeData(bucket, 1); err != nil {
-			return
-		}
-
-		total += time.Since(timeBefore)
-	}
-
-	avg = time.Duration(float64(total) / float64(numRuns))
-	return
+// An operation that may be measured, given a key that exists and the data to
+// store for puts. Returns the number of bytes transferred.
+type operation struct {
+	name string
+	run  func(bucket s3.Bucket, key string, data []byte) (int64, error)
 }
 
-////////////////////////////////////////////////////////////////////////
-// Downstream
-////////////////////////////////////////////////////////////////////////
-
-func measureDownstreamBandwidth_SingleRun(
-	bucket s3.Bucket,
-	keys []string,
-	parallelism uint) (bytesPerSecond float64, err error) {
-	// Time the whole process.
-	timeBefore := time.Now()
-
-	// Start several workers.
-	errs := make([]error, int(parallelism))
-	done := make(chan bool)
-
-	var totalLoaded uint64
-	for i := 0; i < int(parallelism); i++ {
-		go func(i int) {
-			// Load data from one of the keys.
-			data, err := bucket.GetObject(keys[i%len(keys)])
-			if err != nil {
-				errs[i] = err
-			}
-
-			atomic.AddUint64(&totalLoaded, uint64(len(data)))
-			done <- true
-		}(i)
-	}
-
-	// Wait for all of the workers.
-	for i := 0; i < int(parallelism); i++ {
-		<-done
-	}
-
-	elapsed := time.Since(timeBefore)
+var operations = map[string]operation{
+	"put": operation{
+		"PutObject",
+		func(bucket s3.Bucket, key string, data []byte) (int64, error) {
+			return int64(len(data)), bucket.StoreObject(key, data)
+		},
+	},
+
+	"get": operation{
+		"GetObject",
+		func(bucket s3.Bucket, key string, data []byte) (int64, error) {
+			data, err := bucket.GetObject(key)
+			return int64(len(data)), err
+		},
+	},
+
+	"head": operation{
+		"HeadObject",
+		func(bucket s3.Bucket, key string, data []byte) (int64, error) {
+			_, err := bucket.GetHeader(key)
+			return 0, err
+		},
+	},
+}
 
-	// Did any of the workers fail?
-	for i := 0; i < int(parallelism); i++ {
-		if errs[i] != nil {
-			err = errs[i]
-			return
-		}
+// Return the keys to use for objects of the given size.
+func keysForSize(size int64) (keys []string) {
+	for i := 0; i < *g_keys; i++ {
+		keys = append(keys, fmt.Sprintf("%s%d/%d", *g_keyPrefix, size, i))
 	}
 
-	// Estimate the bandwidth.
-	bytesTransferred := float64(totalLoaded)
-	secondsElapsed := float64(elapsed) / float64(time.Second)
-
-	bytesPerSecond = bytesTransferred / secondsElapsed
 	return
 }
 
-func measureDownstreamBandwidth(
-	bucket s3.Bucket,
-	dataSize uint,
-	parallelism uint) (bytesPerSecond float64, err error) {
-	// Store several objects with the given data size.
-	const minUploadDuration time.Duration = 4 * time.Second
-	keys := []string{}
-	for timeBefore := time.Now(); time.Since(timeBefore) < minUploadDuration; {
-		var key string
-		key, err = storeData(bucket, dataSize)
-		if err != nil {
-			return
+// Store the given data under each of the supplied keys.
+func storeObjects(bucket s3.Bucket, keys []string, data []byte) error {
+	for _, key := range keys {
+		if err := bucket.StoreObject(key, data); err != nil {
+			return fmt.Errorf("StoreObject(%q): %v", key, err)
 		}
-
-		keys = append(keys, key)
 	}
 
-	// Average over several runs until we've taken at least this much time.
-	const minDuration time.Duration = 5 * time.Second
-
-	var bandwidthTotal float64
-	var numRuns int
-
-	for timeBefore := time.Now(); time.Since(timeBefore) < minDuration; numRuns++ {
-		var singleResult float64
-		singleResult, err = measureDownstreamBandwidth_SingleRun(
-			bucket,
-			keys,
-			parallelism,
-		)
+	return nil
+}
 
-		if err != nil {
-			return
+// Delete each of the supplied keys.
+func deleteObjects(bucket s3.Bucket, keys []string) error {
+	for _, key := range keys {
+		if err := bucket.DeleteObject(key); err != nil {
+			return fmt.Errorf("DeleteObject(%q): %v", key, err)
 		}
-
-		bandwidthTotal += singleResult
 	}
 
-	bytesPerSecond = bandwidthTotal / float64(numRuns)
-	return
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////
-// Upstream
+// Main
 ////////////////////////////////////////////////////////////////////////
 
-func measureUpstreamBandwidth_SingleRun(
-	bucket s3.Bucket,
-	dataSize uint,
-	parallelism uint) (bytesPerSecond float64, err error) {
-	// Time the whole process.
-	timeBefore := time.Now()
-
-	// Start several workers.
-	errs := make([]error, int(parallelism))
-	done := make(chan bool)
-
-	for i := 0; i < int(parallelism); i++ {
-		go func(i int) {
-			_, errs[i] = storeData(bucket, dataSize)
-			done <- true
-		}(i)
-	}
-
-	// Wait for all of the workers.
-	for i := 0; i < int(parallelism); i++ {
-		<-done
-	}
-
-	elapsed := time.Since(timeBefore)
-
-	// Did any of the workers fail?
-	for i := 0; i < int(parallelism); i++ {
-		if errs[i] != nil {
-			err = errs[i]
-			return
-		}
-	}
-
-	// Estimate the bandwidth.
-	bytesTransferred := float64(dataSize) * float64(parallelism)
-	secondsElapsed := float64(elapsed) / float64(time.Second)
+func main() {
+	flag.Parse()
 
-	bytesPerSecond = bytesTransferred / secondsElapsed
-	return
-}
+	// Set up bare logging output.
+	log.SetFlags(0)
 
-func measureUpstreamBandwidth(
-	bucket s3.Bucket,
-	dataSize uint,
-	parallelism uint) (bytesPerSecond float64, err error) {
-	// Average over several runs until we've taken at least this much time.
-	const minDuration time.Duration = 5 * time.Second
-
-	var bandwidthTotal float64
-	var numRuns int
-
-	for timeBefore := time.Now(); time.Since(timeBefore) < minDuration; numRuns++ {
-		var singleResult float64
-		singleResult, err = measureUpstreamBandwidth_SingleRun(
-			bucket,
-			dataSize,
-			parallelism)
-
-		if err != nil {
-			return
+	// Sanity-check flags.
+	var ops []operation
+	for _, name := range strings.Split(*g_ops, ",") {
+		op, ok := operations[strings.TrimSpace(name)]
+		if !ok {
+			log.Fatalf("Unknown operation in -ops: %q", name)
 		}
 
-		bandwidthTotal += singleResult
+		ops = append(ops, op)
 	}
 
-	bytesPerSecond = bandwidthTotal / float64(numRuns)
-	return
-}
-
-////////////////////////////////////////////////////////////////////////
-// Main
-////////////////////////////////////////////////////////////////////////
-
-func formattedFloatOrError(
-	v float64,
-	err error) string {
+	sizes, err := bench.ParseSizes(*g_sizes)
 	if err != nil {
-		return err.Error()
+		log.Fatalf("-sizes: %v", err)
 	}
 
-	return fmt.Sprintf("%6f.0", v)
-}
-
-func printHeading(testName string) {
-	fmt.Printf("------------------------------------\n")
-	fmt.Println(testName)
-	fmt.Println()
-}
+	parallelisms, err := bench.ParseInts(*g_parallelism)
+	if err != nil {
+		log.Fatalf("-parallelism: %v", err)
+	}
 
-func formatBytes(n uint64) string {
-	type exponentAndSuffix struct {
-		exponent uint
-		suffix   string
+	if *g_keys <= 0 {
+		log.Fatalln("-keys must be positive.")
 	}
 
-	suffixes := []exponentAndSuffix{
-		{0, "bytes"},
-		{10, "KiB"},
-		{20, "MiB"},
-		{30, "GiB"},
+	switch *g_format {
+	case "text", "json", "csv":
+	default:
+		log.Fatalf("Unknown -format: %q", *g_format)
 	}
 
-	for i, element := range suffixes {
-		exponent := element.exponent
-		nextExponent := exponent + 10
-		if n < (1<<nextExponent) || i == len(suffixes)-1 {
-			scaled := float64(n) / float64(uint(1)<<exponent)
-			return fmt.Sprintf("%.2f %s", scaled, element.suffix)
+	// Open the output before spending time on measurements.
+	output := os.Stdout
+	if *g_output != "" {
+		if output, err = os.Create(*g_output); err != nil {
+			log.Fatalf("os.Create: %v", err)
 		}
 	}
 
-	panic("Shouldn't reach here.")
-}
-
-func main() {
-	flag.Parse()
-
-	// Set up bare logging output.
-	log.SetFlags(0)
-
 	// Grab the bucket.
 	bucket := getBucket()
 
-	/////////////////////////////////////////////
-	// Latency
-	/////////////////////////////////////////////
-
-	printHeading("Latency")
-
-	avgLatency, err := measureLatency(bucket)
-	if err != nil {
-		log.Fatalf("measureLatency: %v", err)
-	}
-
-	log.Printf(
-		"Average latency: %d ms",
-		uint64(float64(avgLatency)/float64(time.Millisecond)),
-	)
-
-	/////////////////////////////////////////////
-	// Downstream bandwidth
-	/////////////////////////////////////////////
-
-	printHeading("Downstream bandwidth")
+	// Measure each combination.
+	var results []bench.Result
+	for _, size := range sizes {
+		keys := keysForSize(size)
+		data := bytes.Repeat([]byte{byte(rand.Uint32())}, int(size))
 
-	downstreamDataSizes := []uint{1 << 18, 1 << 20}
-	downstreamParallelisms := []uint{1, 2}
+		log.Printf(
+			"Storing %d objects of %s.",
+			len(keys),
+			bench.FormatBytes(uint64(size)))
 
-	for _, dataSize := range downstreamDataSizes {
-		for _, parallelism := range downstreamParallelisms {
-			bytesPerSecond, err := measureDownstreamBandwidth(
-				bucket,
-				dataSize,
-				parallelism)
+		if err := storeObjects(bucket, keys, data); err != nil {
+			log.Fatalf("storeObjects: %v", err)
+		}
 
-			if err != nil {
-				log.Fatalf("measureDownstreamBandwidth: %v", err)
+		for _, op := range ops {
+			for _, parallelism := range parallelisms {
+				log.Printf(
+					"Measuring %s, size %s, parallelism %d.",
+					op.name,
+					bench.FormatBytes(uint64(size)),
+					parallelism)
+
+				run := func() (int64, error) {
+					key := keys[rand.Intn(len(keys))]
+					return op.run(bucket, key, data)
+				}
+
+				result, err := bench.Run(parallelism, *g_duration, run)
+				if err != nil {
+					log.Printf("%d of %d %s requests failed; first error: %v",
+						result.Errors,
+						result.Ops,
+						op.name,
+						err)
+				}
+
+				result.Name = op.name
+				result.Params = []bench.Param{
+					bench.Param{Name: "size", Value: strconv.FormatInt(size, 10)},
+					bench.Param{Name: "parallelism", Value: strconv.Itoa(parallelism)},
+					bench.Param{Name: "keys", Value: strconv.Itoa(len(keys))},
+				}
+
+				results = append(results, result)
 			}
+		}
 
-			log.Printf(
-				"%s, parallelism %d: %s/s\n",
-				formatBytes(uint64(dataSize)),
-				parallelism,
-				formatBytes(uint64(bytesPerSecond)),
-			)
+		if *g_cleanup {
+			if err := deleteObjects(bucket, keys); err != nil {
+				log.Fatalf("deleteObjects: %v", err)
+			}
 		}
 	}
 
-	/////////////////////////////////////////////
-	// Upstream bandwidth
-	/////////////////////////////////////////////
-
-	printHeading("Upstream bandwidth")
-
-	upstreamDataSizes := []uint{1 << 14, 1 << 18, 1 << 20}
-	upstreamParallelisms := []uint{1, 2}
-
-	for _, dataSize := range upstreamDataSizes {
-		for _, parallelism := range upstreamParallelisms {
-			bytesPerSecond, err := measureUpstreamBandwidth(
-				bucket,
-				dataSize,
-				parallelism)
-
-			if err != nil {
-				log.Fatalf("measureUpstreamBandwidth: %v", err)
-			}
+	// Report the results.
+	if err := bench.WriteResults(output, *g_format, results); err != nil {
+		log.Fatalf("WriteResults: %v", err)
+	}
 
-			log.Printf(
-				"%s, parallelism %d: %s/s\n",
-				formatBytes(uint64(dataSize)),
-				parallelism,
-				formatBytes(uint64(bytesPerSecond)),
-			)
-		}
+	if err := output.Close(); err != nil {
+		log.Fatalf("Close: %v", err)
 	}
 }
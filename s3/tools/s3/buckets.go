@@ -0,0 +1,97 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+)
+
+var mbFlags = flag.NewFlagSet("mb", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"mb",
+		"s3://bucket",
+		"Create a bucket in the region given by -region.",
+		mbFlags,
+		runMb)
+}
+
+var rbFlags = flag.NewFlagSet("rb", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"rb",
+		"s3://bucket",
+		"Delete a bucket, which must be empty.",
+		rbFlags,
+		runRb)
+}
+
+// Parse an s3:// path that must name a bucket alone.
+func parseBucket(args []string) (bucketName string, err error) {
+	if len(args) != 1 {
+		err = errors.New("Expected exactly one s3:// path")
+		return
+	}
+
+	bucketName, key, err := parseRemote(args[0])
+	if err == nil && key != "" {
+		err = fmt.Errorf("Expected a bucket, not an object: %q", args[0])
+	}
+
+	return
+}
+
+func runMb(args []string) error {
+	bucketName, err := parseBucket(args)
+	if err != nil {
+		return err
+	}
+
+	r, err := region()
+	if err != nil {
+		return err
+	}
+
+	if *g_endpoint == "" {
+		return s3.CreateBucket(bucketName, r, credentials())
+	}
+
+	u, err := endpoint()
+	if err != nil {
+		return err
+	}
+
+	return s3.CreateBucketAtEndpoint(bucketName, u, "", credentials())
+}
+
+func runRb(args []string) error {
+	bucketName, err := parseBucket(args)
+	if err != nil {
+		return err
+	}
+
+	u, err := endpoint()
+	if err != nil {
+		return err
+	}
+
+	return s3.DeleteBucketAtEndpoint(bucketName, u, credentials())
+}
@@ -0,0 +1,85 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+var catFlags = flag.NewFlagSet("cat", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"cat",
+		"s3://bucket/key",
+		"Write the contents of an object to stdout.",
+		catFlags,
+		runCat)
+}
+
+// The number of bytes of an object to fetch with each request, bounding the
+// amount held in memory.
+const catChunkSize = 8 * 1024 * 1024
+
+func runCat(args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("Expected exactly one s3:// path")
+	}
+
+	bucketName, key, err := parseObject(args[0])
+	if err != nil {
+		return
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return
+	}
+
+	// Find the size of the object.
+	header, err := bucket.GetHeader(key)
+	if err != nil {
+		return fmt.Errorf("GetHeader: %v", err)
+	}
+
+	size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid Content-Length: %v", err)
+	}
+
+	// Stream it out a chunk at a time.
+	for offset := int64(0); offset < size; offset += catChunkSize {
+		length := int64(catChunkSize)
+		if offset+length > size {
+			length = size - offset
+		}
+
+		data, err := bucket.GetObjectRange(key, offset, length)
+		if err != nil {
+			return fmt.Errorf("GetObjectRange: %v", err)
+		}
+
+		if _, err := os.Stdout.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"github.com/jacobsa/aws/s3/s3util"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var cpFlags = flag.NewFlagSet("cp", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"cp",
+		"[-concurrency N] <src> <dst>",
+		"Copy a local file to S3, an object to a local file, or one object to "+
+			"another. If the\ndestination ends in a slash (or is a local "+
+			"directory), the source's base name\nis appended to it.",
+		cpFlags,
+		runCp)
+}
+
+var g_cpConcurrency = cpFlags.Int(
+	"concurrency",
+	0,
+	"The number of parts of a large object to transfer at once. Zero means "+
+		"a reasonable default.")
+
+func transferOptions() s3util.TransferOptions {
+	return s3util.TransferOptions{Concurrency: *g_cpConcurrency}
+}
+
+func runCp(args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("Expected a source and a destination")
+	}
+
+	src, dst := args[0], args[1]
+	switch {
+	case !isRemote(src) && isRemote(dst):
+		return upload(src, dst)
+
+	case isRemote(src) && !isRemote(dst):
+		return download(src, dst)
+
+	case isRemote(src) && isRemote(dst):
+		return copyObject(src, dst)
+	}
+
+	return errors.New("At least one of the paths must begin with s3://")
+}
+
+// Return the bucket and key named by an s3:// destination, appending the
+// supplied base name if the key is empty or ends in a slash.
+func remoteDestination(
+	dst string,
+	base string) (bucketName string, key string, err error) {
+	if bucketName, key, err = parseRemote(dst); err != nil {
+		return
+	}
+
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += base
+	}
+
+	return
+}
+
+// Return the local path named by a destination, appending the supplied base
+// name if the destination is a directory.
+func localDestination(dst string, base string) string {
+	if strings.HasSuffix(dst, string(filepath.Separator)) {
+		return filepath.Join(dst, base)
+	}
+
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		return filepath.Join(dst, base)
+	}
+
+	return dst
+}
+
+// Upload the contents of a local file to the given key.
+func uploadFile(bucket s3.Bucket, key string, f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Stat: %v", err)
+	}
+
+	uploader, err := s3util.NewUploader(bucket, transferOptions())
+	if err != nil {
+		return fmt.Errorf("NewUploader: %v", err)
+	}
+
+	if err := uploader.Upload(key, f, fi.Size()); err != nil {
+		return fmt.Errorf("Upload: %v", err)
+	}
+
+	return nil
+}
+
+// Download the object with the given key into a local file.
+func downloadFile(bucket s3.Bucket, key string, f *os.File) error {
+	downloader, err := s3util.NewDownloader(bucket, transferOptions())
+	if err != nil {
+		return fmt.Errorf("NewDownloader: %v", err)
+	}
+
+	if _, err := downloader.Download(key, f); err != nil {
+		return fmt.Errorf("Download: %v", err)
+	}
+
+	return nil
+}
+
+func upload(src string, dst string) error {
+	bucketName, key, err := remoteDestination(dst, filepath.Base(src))
+	if err != nil {
+		return err
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	return uploadFile(bucket, key, f)
+}
+
+func download(src string, dst string) (err error) {
+	bucketName, key, err := parseObject(src)
+	if err != nil {
+		return
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return
+	}
+
+	dst = localDestination(dst, path.Base(key))
+	f, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+
+	// Don't leave a partial file behind on failure.
+	defer func() {
+		closeErr := f.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("Close: %v", closeErr)
+		}
+
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	err = downloadFile(bucket, key, f)
+	return
+}
+
+// Copy one object to another by way of a temporary file, since the objects
+// may live in different buckets or regions.
+func copyObject(src string, dst string) (err error) {
+	srcBucketName, srcKey, err := parseObject(src)
+	if err != nil {
+		return
+	}
+
+	dstBucketName, dstKey, err := remoteDestination(dst, path.Base(srcKey))
+	if err != nil {
+		return
+	}
+
+	srcBucket, err := openBucket(srcBucketName)
+	if err != nil {
+		return
+	}
+
+	dstBucket, err := openBucket(dstBucketName)
+	if err != nil {
+		return
+	}
+
+	f, err := ioutil.TempFile("", "s3_cp")
+	if err != nil {
+		return
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err = downloadFile(srcBucket, srcKey, f); err != nil {
+		return
+	}
+
+	err = uploadFile(dstBucket, dstKey, f)
+	return
+}
@@ -0,0 +1,131 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"os"
+	"text/tabwriter"
+)
+
+var lsFlags = flag.NewFlagSet("ls", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"ls",
+		"[-l] [-r] s3://bucket[/prefix]",
+		"List the objects in a bucket whose keys begin with the given prefix. "+
+			"Unless -r is given,\nkeys containing a further slash are rolled up "+
+			"into a single PRE line.",
+		lsFlags,
+		runLs)
+}
+
+var g_lsLong = lsFlags.Bool(
+	"l",
+	false,
+	"Show the size and modification time of each object.")
+
+var g_lsRecursive = lsFlags.Bool(
+	"r",
+	false,
+	"List all keys beneath the prefix rather than rolling them up.")
+
+func runLs(args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("Expected exactly one s3:// path")
+	}
+
+	bucketName, prefix, err := parseRemote(args[0])
+	if err != nil {
+		return
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return
+	}
+
+	delimiter := "/"
+	if *g_lsRecursive {
+		delimiter = ""
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+
+	return listObjects(
+		bucket,
+		prefix,
+		delimiter,
+		func(o *s3.ObjectInfo, prefix string) {
+			switch {
+			case !*g_lsLong && o == nil:
+				fmt.Fprintln(w, prefix)
+
+			case !*g_lsLong:
+				fmt.Fprintln(w, o.Key)
+
+			case o == nil:
+				fmt.Fprintf(w, "\tPRE\t %s\n", prefix)
+
+			default:
+				fmt.Fprintf(
+					w,
+					"%s\t%d\t %s\n",
+					o.LastModified.Local().Format("2006-01-02 15:04:05"),
+					o.Size,
+					o.Key)
+			}
+		})
+}
+
+// Call f for each object and rolled-up prefix under the supplied prefix, in
+// order. Exactly one of the object and the prefix is set on each call.
+func listObjects(
+	bucket s3.Bucket,
+	prefix string,
+	delimiter string,
+	f func(o *s3.ObjectInfo, prefix string)) error {
+	prevKey := ""
+	for {
+		objects, prefixes, err := bucket.ListObjects(prefix, delimiter, prevKey)
+		if err != nil {
+			return fmt.Errorf("ListObjects: %v", err)
+		}
+
+		if len(objects) == 0 && len(prefixes) == 0 {
+			return nil
+		}
+
+		// Merge the two sorted lists.
+		for len(objects) > 0 || len(prefixes) > 0 {
+			if len(prefixes) == 0 ||
+				(len(objects) > 0 && objects[0].Key < prefixes[0]) {
+				f(&objects[0], "")
+				prevKey = objects[0].Key
+				objects = objects[1:]
+			} else {
+				f(nil, prefixes[0])
+				prevKey = prefixes[0]
+				prefixes = prefixes[1:]
+			}
+		}
+	}
+}
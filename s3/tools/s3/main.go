@@ -0,0 +1,246 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// A command-line client for S3, built on package s3. Usage:
+//
+//     s3 [-region eu-west-1] [-endpoint URL] <command> [flags] [args]
+//
+// The supported commands are:
+//
+//     ls [-l] [-r] s3://bucket[/prefix]
+//     cp [-concurrency N] <src> <dst>
+//     cat s3://bucket/key
+//     stat s3://bucket/key
+//     rm [-r] [-f] s3://bucket/key
+//     mb s3://bucket
+//     rb s3://bucket
+//
+// Paths beginning with s3:// refer to objects; anything else is a local
+// file. Credentials are found as by aws.DefaultCredentials, i.e. in the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables, the
+// shared credentials file, or the EC2 instance metadata service. The -endpoint
+// flag may be used to talk to an S3-compatible server other than AWS.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/s3"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+var g_region = flag.String(
+	"region",
+	"",
+	"The region to use, e.g. eu-west-1 or s3-eu-west-1.amazonaws.com. "+
+		"Defaults to $AWS_REGION, or us-east-1 if that is unset.")
+
+var g_endpoint = flag.String(
+	"endpoint",
+	"",
+	"The URL of an S3-compatible server to use instead of AWS, e.g. "+
+		"http://localhost:9000.")
+
+////////////////////////////////////////////////////////////////////////
+// Commands
+////////////////////////////////////////////////////////////////////////
+
+// A command, with the flag set that parses its flags. The run function
+// receives the arguments remaining after flag parsing.
+type command struct {
+	name  string
+	args  string
+	help  string
+	flags *flag.FlagSet
+	run   func(args []string) error
+}
+
+var commands = map[string]*command{}
+
+// Register a command with the given name, arguments synopsis, help text and
+// flags.
+func registerCommand(
+	name string,
+	args string,
+	help string,
+	flags *flag.FlagSet,
+	run func(args []string) error) {
+	c := &command{
+		name:  name,
+		args:  args,
+		help:  help,
+		flags: flags,
+		run:   run,
+	}
+
+	c.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: s3 %s %s\n\n%s\n", name, args, help)
+		c.flags.PrintDefaults()
+	}
+
+	commands[name] = c
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: s3 [flags] <command> [command flags] [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		c := commands[name]
+		fmt.Fprintf(os.Stderr, "  %s %s\n", c.name, c.args)
+	}
+
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Paths
+////////////////////////////////////////////////////////////////////////
+
+const s3Scheme = "s3://"
+
+// Does the path refer to S3 rather than the local file system?
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, s3Scheme)
+}
+
+// Split an s3:// path into a bucket name and a key, which may be empty.
+func parseRemote(path string) (bucketName string, key string, err error) {
+	if !isRemote(path) {
+		err = fmt.Errorf("Not an S3 path: %q", path)
+		return
+	}
+
+	rest := path[len(s3Scheme):]
+	if i := strings.Index(rest, "/"); i >= 0 {
+		bucketName, key = rest[:i], rest[i+1:]
+	} else {
+		bucketName = rest
+	}
+
+	if bucketName == "" {
+		err = fmt.Errorf("Missing bucket name: %q", path)
+		return
+	}
+
+	return
+}
+
+// Like parseRemote, but also require that the key be non-empty.
+func parseObject(path string) (bucketName string, key string, err error) {
+	if bucketName, key, err = parseRemote(path); err != nil {
+		return
+	}
+
+	if key == "" {
+		err = fmt.Errorf("Missing key: %q", path)
+		return
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Buckets
+////////////////////////////////////////////////////////////////////////
+
+// Return the region selected by flags and the environment.
+func region() (s3.Region, error) {
+	name := *g_region
+	if name == "" {
+		name = os.Getenv("AWS_REGION")
+	}
+
+	if name == "" {
+		name = "us-east-1"
+	}
+
+	return s3.ParseRegion(name)
+}
+
+// Return the URL of the server to talk to.
+func endpoint() (*url.URL, error) {
+	if *g_endpoint != "" {
+		u, err := url.Parse(*g_endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("-endpoint: %v", err)
+		}
+
+		return u, nil
+	}
+
+	r, err := region()
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{Scheme: "https", Host: string(r)}, nil
+}
+
+func credentials() aws.CredentialsProvider {
+	return aws.DefaultCredentials()
+}
+
+// Open the bucket with the given name.
+func openBucket(name string) (s3.Bucket, error) {
+	u, err := endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.OpenBucketAtEndpoint(name, u, credentials())
+}
+
+////////////////////////////////////////////////////////////////////////
+// main
+////////////////////////////////////////////////////////////////////////
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	// Set up bare logging output.
+	log.SetFlags(0)
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	c, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	c.flags.Parse(flag.Args()[1:])
+	if err := c.run(c.flags.Args()); err != nil {
+		log.Fatalf("%s: %v", c.name, err)
+	}
+}
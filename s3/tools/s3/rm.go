@@ -0,0 +1,136 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws/s3"
+	"os"
+	"strings"
+)
+
+var rmFlags = flag.NewFlagSet("rm", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"rm",
+		"[-r] [-f] s3://bucket/key",
+		"Delete an object, or with -r every object whose key begins with the "+
+			"given prefix.",
+		rmFlags,
+		runRm)
+}
+
+var g_rmRecursive = rmFlags.Bool(
+	"r",
+	false,
+	"Treat the key as a prefix, deleting every object beneath it.")
+
+var g_rmForce = rmFlags.Bool(
+	"f",
+	false,
+	"Don't ask for confirmation before a recursive delete.")
+
+func runRm(args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("Expected exactly one s3:// path")
+	}
+
+	if !*g_rmRecursive {
+		return removeObject(args[0])
+	}
+
+	bucketName, prefix, err := parseRemote(args[0])
+	if err != nil {
+		return
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return
+	}
+
+	// Find the keys to delete up front, so that the user knows what they're
+	// agreeing to.
+	var keys []string
+	err = listObjects(
+		bucket,
+		prefix,
+		"",
+		func(o *s3.ObjectInfo, prefix string) {
+			keys = append(keys, o.Key)
+		})
+
+	if err != nil {
+		return
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if !*g_rmForce && !confirm(fmt.Sprintf(
+		"Delete %d objects beneath %s?",
+		len(keys),
+		args[0])) {
+		return errors.New("Aborted")
+	}
+
+	for _, key := range keys {
+		if err := bucket.DeleteObject(key); err != nil {
+			return fmt.Errorf("DeleteObject(%q): %v", key, err)
+		}
+
+		fmt.Printf("Deleted %s%s/%s\n", s3Scheme, bucketName, key)
+	}
+
+	return nil
+}
+
+func removeObject(path string) error {
+	bucketName, key, err := parseObject(path)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return err
+	}
+
+	if err := bucket.DeleteObject(key); err != nil {
+		return fmt.Errorf("DeleteObject: %v", err)
+	}
+
+	return nil
+}
+
+// Ask the user a yes or no question on the terminal, returning true only for
+// an affirmative answer.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+
+	return false
+}
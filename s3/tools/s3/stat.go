@@ -0,0 +1,68 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var statFlags = flag.NewFlagSet("stat", flag.ExitOnError)
+
+func init() {
+	registerCommand(
+		"stat",
+		"s3://bucket/key",
+		"Print the headers of an object, such as its size, type and ETag.",
+		statFlags,
+		runStat)
+}
+
+func runStat(args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("Expected exactly one s3:// path")
+	}
+
+	bucketName, key, err := parseObject(args[0])
+	if err != nil {
+		return
+	}
+
+	bucket, err := openBucket(bucketName)
+	if err != nil {
+		return
+	}
+
+	header, err := bucket.GetHeader(key)
+	if err != nil {
+		return fmt.Errorf("GetHeader: %v", err)
+	}
+
+	var names []string
+	for name := range header {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %s\n", name, strings.Join(header[name], ", "))
+	}
+
+	return nil
+}
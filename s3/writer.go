@@ -0,0 +1,212 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+var errWriterClosed = errors.New("Writer already closed")
+
+// A bucket that can upload a part whose MD5 is already known, rather than
+// hashing the data again, as the bucket returned by OpenBucket can.
+type md5PartUploader interface {
+	uploadPartWithMd5(
+		key string,
+		uploadId string,
+		partNumber int,
+		data []byte,
+		md5Sum []byte) (etag string, err error)
+}
+
+// An io.WriteCloser that streams data to an object using the multipart upload
+// methods of a Bucket, for use in implementing Bucket.NewWriter.
+type objectWriter struct {
+	bucket   Bucket
+	key      string
+	partSize int
+
+	// The data written since the last part was uploaded, and its running MD5.
+	buf    []byte
+	bufMd5 hash.Hash
+
+	// The multipart upload in progress, if one has been initiated, and the
+	// parts uploaded to it so far.
+	uploadId string
+	parts    []Part
+
+	// The first error encountered, if any, returned by all further calls.
+	err error
+}
+
+func newObjectWriter(bucket Bucket, key string) io.WriteCloser {
+	return &objectWriter{
+		bucket:   bucket,
+		key:      key,
+		partSize: MinPartSize,
+		bufMd5:   md5.New(),
+	}
+}
+
+func (w *objectWriter) Write(p []byte) (n int, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	for len(p) > 0 {
+		// Upload the buffer if it's full. We wait until more data arrives before
+		// doing so, so that objects no bigger than a part can be stored with a
+		// single request when closed.
+		if len(w.buf) == w.partSize {
+			if err = w.flush(); err != nil {
+				return
+			}
+		}
+
+		if w.buf == nil {
+			w.buf = make([]byte, 0, w.partSize)
+		}
+
+		count := w.partSize - len(w.buf)
+		if count > len(p) {
+			count = len(p)
+		}
+
+		w.buf = append(w.buf, p[:count]...)
+		w.bufMd5.Write(p[:count])
+
+		n += count
+		p = p[count:]
+	}
+
+	return
+}
+
+func (w *objectWriter) Close() (err error) {
+	if w.err != nil {
+		return w.err
+	}
+
+	defer func() {
+		if err == nil {
+			w.err = errWriterClosed
+		}
+	}()
+
+	// If we never started a multipart upload, the data fits in one request.
+	if w.uploadId == "" {
+		if err = w.bucket.StoreObject(w.key, w.buf); err != nil {
+			err = fmt.Errorf("StoreObject: %v", err)
+			w.fail(err)
+		}
+
+		return
+	}
+
+	// Otherwise upload the final part and assemble the object.
+	if err = w.flush(); err != nil {
+		return
+	}
+
+	err = w.bucket.CompleteMultipartUpload(w.key, w.uploadId, w.parts)
+	if err != nil {
+		err = fmt.Errorf("CompleteMultipartUpload: %v", err)
+		w.fail(err)
+		return
+	}
+
+	return
+}
+
+// Upload the contents of the buffer as the next part, initiating the
+// multipart upload if necessary.
+func (w *objectWriter) flush() (err error) {
+	if w.uploadId == "" {
+		if w.uploadId, err = w.bucket.InitiateMultipartUpload(w.key); err != nil {
+			err = fmt.Errorf("InitiateMultipartUpload: %v", err)
+			w.fail(err)
+			return
+		}
+	}
+
+	partNumber := len(w.parts) + 1
+	if partNumber > MaxParts {
+		err = fmt.Errorf("Object too large: more than %d parts", MaxParts)
+		w.fail(err)
+		return
+	}
+
+	// Send the MD5 we've been computing as data was written, if the bucket can
+	// use it.
+	sum := w.bufMd5.Sum(nil)
+
+	var etag string
+	if u, ok := w.bucket.(md5PartUploader); ok {
+		etag, err = u.uploadPartWithMd5(
+			w.key,
+			w.uploadId,
+			partNumber,
+			w.buf,
+			sum)
+	} else {
+		etag, err = w.bucket.UploadPart(w.key, w.uploadId, partNumber, w.buf)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("UploadPart(%d): %v", partNumber, err)
+		w.fail(err)
+		return
+	}
+
+	// The ETag of a part is normally the hex-encoded MD5 of its data. Make sure
+	// the server received what we sent.
+	expected, decodeErr := hex.DecodeString(strings.Trim(etag, `"`))
+	if decodeErr == nil && len(expected) == md5.Size &&
+		!bytes.Equal(expected, sum) {
+		err = fmt.Errorf(
+			"UploadPart(%d): ETag %s doesn't match MD5 %x",
+			partNumber,
+			etag,
+			sum)
+
+		w.fail(err)
+		return
+	}
+
+	w.parts = append(w.parts, Part{PartNumber: partNumber, ETag: etag})
+	w.buf = w.buf[:0]
+	w.bufMd5.Reset()
+
+	return
+}
+
+// Record the supplied error and abandon any upload in progress.
+func (w *objectWriter) fail(err error) {
+	w.err = err
+	w.buf = nil
+
+	if w.uploadId != "" {
+		// There's not much we can do if the abort fails.
+		w.bucket.AbortMultipartUpload(w.key, w.uploadId)
+	}
+}
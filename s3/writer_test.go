@@ -0,0 +1,283 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A bucket that wraps another, counting calls, recording the MD5s it is given
+// for parts, and optionally sabotaging uploaded parts.
+type writerTestBucket struct {
+	Bucket
+
+	storeCalls    int
+	initiateCalls int
+	uploadCalls   int
+	abortCalls    int
+	md5s          [][]byte
+
+	// If non-zero, fail the upload of this part number.
+	failPart int
+
+	// If non-empty, return this in place of the real ETag.
+	bogusETag string
+}
+
+func (b *writerTestBucket) StoreObject(key string, data []byte) error {
+	b.storeCalls++
+	return b.Bucket.StoreObject(key, data)
+}
+
+func (b *writerTestBucket) InitiateMultipartUpload(
+	key string) (string, error) {
+	b.initiateCalls++
+	return b.Bucket.InitiateMultipartUpload(key)
+}
+
+func (b *writerTestBucket) UploadPart(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte) (string, error) {
+	b.uploadCalls++
+	if partNumber == b.failPart {
+		return "", errors.New("taco")
+	}
+
+	etag, err := b.Bucket.UploadPart(key, uploadId, partNumber, data)
+	if err == nil && b.bogusETag != "" {
+		etag = b.bogusETag
+	}
+
+	return etag, err
+}
+
+func (b *writerTestBucket) uploadPartWithMd5(
+	key string,
+	uploadId string,
+	partNumber int,
+	data []byte,
+	md5Sum []byte) (string, error) {
+	b.md5s = append(b.md5s, md5Sum)
+	return b.UploadPart(key, uploadId, partNumber, data)
+}
+
+func (b *writerTestBucket) AbortMultipartUpload(
+	key string,
+	uploadId string) error {
+	b.abortCalls++
+	return b.Bucket.AbortMultipartUpload(key, uploadId)
+}
+
+type WriterTest struct {
+	bucket *writerTestBucket
+	w      io.WriteCloser
+
+	// Contents that span two full parts and a bit more.
+	contents []byte
+}
+
+func init() { RegisterTestSuite(&WriterTest{}) }
+
+func (t *WriterTest) SetUp(i *TestInfo) {
+	clock := aws_time.NewSimulatedClock(time.Time{})
+	t.bucket = &writerTestBucket{Bucket: newMemBucket(clock)}
+	t.w = newObjectWriter(t.bucket, "foo")
+
+	t.contents = make([]byte, 2*MinPartSize+17)
+	for i := range t.contents {
+		t.contents[i] = byte(i % 251)
+	}
+}
+
+// Write the data in awkwardly-sized chunks.
+func (t *WriterTest) write(data []byte) error {
+	const chunkSize = 1000003
+	for len(data) > 0 {
+		n := chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+
+		if _, err := t.w.Write(data[:n]); err != nil {
+			return err
+		}
+
+		data = data[n:]
+	}
+
+	return nil
+}
+
+func (t *WriterTest) uploadsInProgress() int {
+	uploads, err := t.bucket.ListMultipartUploads("", "")
+	AssertEq(nil, err)
+	return len(uploads)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *WriterTest) EmptyObject() {
+	AssertEq(nil, t.w.Close())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectEq(0, len(data))
+	ExpectEq(1, t.bucket.storeCalls)
+	ExpectEq(0, t.bucket.initiateCalls)
+}
+
+func (t *WriterTest) SmallObject() {
+	_, err := io.WriteString(t.w, "ta")
+	AssertEq(nil, err)
+
+	_, err = io.WriteString(t.w, "co")
+	AssertEq(nil, err)
+
+	AssertEq(nil, t.w.Close())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(data))
+	ExpectEq(1, t.bucket.storeCalls)
+	ExpectEq(0, t.bucket.initiateCalls)
+}
+
+func (t *WriterTest) ExactlyOnePart() {
+	AssertEq(nil, t.write(t.contents[:MinPartSize]))
+	AssertEq(nil, t.w.Close())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents[:MinPartSize], data))
+	ExpectEq(1, t.bucket.storeCalls)
+	ExpectEq(0, t.bucket.initiateCalls)
+}
+
+func (t *WriterTest) LargeObject() {
+	AssertEq(nil, t.write(t.contents))
+
+	// Nothing should be visible before the writer is closed.
+	_, err := t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+
+	AssertEq(nil, t.w.Close())
+
+	data, err := t.bucket.GetObject("foo")
+	AssertEq(nil, err)
+	ExpectTrue(bytes.Equal(t.contents, data))
+
+	ExpectEq(0, t.bucket.storeCalls)
+	ExpectEq(1, t.bucket.initiateCalls)
+	ExpectEq(3, t.bucket.uploadCalls)
+	ExpectEq(0, t.uploadsInProgress())
+}
+
+func (t *WriterTest) PassesMd5OfEachPart() {
+	AssertEq(nil, t.write(t.contents))
+	AssertEq(nil, t.w.Close())
+
+	sum := func(data []byte) []byte {
+		s := md5.Sum(data)
+		return s[:]
+	}
+
+	ExpectThat(
+		t.bucket.md5s,
+		ElementsAre(
+			DeepEquals(sum(t.contents[:MinPartSize])),
+			DeepEquals(sum(t.contents[MinPartSize:2*MinPartSize])),
+			DeepEquals(sum(t.contents[2*MinPartSize:]))))
+}
+
+func (t *WriterTest) InvalidKey() {
+	t.w = newObjectWriter(t.bucket, "")
+
+	_, err := io.WriteString(t.w, "taco")
+	AssertEq(nil, err)
+
+	err = t.w.Close()
+	ExpectThat(err, Error(HasSubstr("StoreObject")))
+	ExpectThat(err, Error(HasSubstr("empty")))
+}
+
+func (t *WriterTest) UploadPartFails() {
+	t.bucket.failPart = 2
+
+	err := t.write(t.contents)
+	ExpectThat(err, Error(HasSubstr("UploadPart(2)")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+
+	// Further calls should return the same error.
+	_, err = t.w.Write([]byte("a"))
+	ExpectThat(err, Error(HasSubstr("UploadPart(2)")))
+
+	err = t.w.Close()
+	ExpectThat(err, Error(HasSubstr("UploadPart(2)")))
+
+	// The upload should have been abandoned.
+	ExpectEq(1, t.bucket.abortCalls)
+	ExpectEq(0, t.uploadsInProgress())
+
+	_, err = t.bucket.GetObject("foo")
+	ExpectThat(err, Error(HasSubstr("404")))
+}
+
+func (t *WriterTest) FinalPartFails() {
+	t.bucket.failPart = 3
+
+	AssertEq(nil, t.write(t.contents))
+
+	err := t.w.Close()
+	ExpectThat(err, Error(HasSubstr("UploadPart(3)")))
+	ExpectEq(1, t.bucket.abortCalls)
+	ExpectEq(0, t.uploadsInProgress())
+}
+
+func (t *WriterTest) ETagDoesntMatch() {
+	t.bucket.bogusETag = `"0123456789abcdef0123456789abcdef"`
+
+	err := t.write(t.contents)
+	ExpectThat(err, Error(HasSubstr("UploadPart(1)")))
+	ExpectThat(err, Error(HasSubstr("doesn't match MD5")))
+	ExpectEq(1, t.bucket.abortCalls)
+}
+
+func (t *WriterTest) WriteAfterClose() {
+	AssertEq(nil, t.w.Close())
+
+	_, err := t.w.Write([]byte("a"))
+	ExpectThat(err, Error(HasSubstr("closed")))
+
+	ExpectThat(t.w.Close(), Error(HasSubstr("closed")))
+}
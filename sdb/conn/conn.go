@@ -17,7 +17,6 @@ package conn
 
 import (
 	"fmt"
-	"github.com/jacobsa/aws"
 	"github.com/jacobsa/aws/time"
 )
 
@@ -32,20 +31,29 @@ type Conn interface {
 	SendRequest(req Request) (resp []byte, err error)
 }
 
-// Create a connection using the supplied dependencies.
+// Create a connection using the supplied dependencies. Every request passes
+// through the interceptor's hooks, which may be an empty Chain. The clock is
+// corrected for skew using the server's responses.
 func NewConn(
-	key aws.AccessKey,
 	httpConn HttpConn,
 	signer Signer,
+	interceptor Interceptor,
 	clock time.Clock) (Conn, error) {
-	return &conn{key, httpConn, signer, clock}, nil
+	c := &conn{
+		httpConn:    InterceptHttpConn(httpConn, interceptor, clock),
+		signer:      InterceptSigner(signer, interceptor),
+		interceptor: interceptor,
+		clock:       time.NewSkewAdjustingClock(clock),
+	}
+
+	return c, nil
 }
 
 type conn struct {
-	key      aws.AccessKey
-	httpConn HttpConn
-	signer   Signer
-	clock    time.Clock
+	httpConn    HttpConn
+	signer      Signer
+	interceptor Interceptor
+	clock       time.SkewAdjustingClock
 }
 
 func (c *conn) SendRequest(req Request) (resp []byte, err error) {
@@ -56,34 +64,45 @@ func (c *conn) SendRequest(req Request) (resp []byte, err error) {
 		req[key] = val
 	}
 
-	// Set authentication info.
-	req["AWSAccessKeyId"] = c.key.Id
-	req["Timestamp"] = c.clock.Now().UTC().Format(iso8601Format)
+	// Set authentication info. The signer fills in the key itself, so that it
+	// matches the one used for the signature.
 	req["SignatureVersion"] = "2"
 	req["SignatureMethod"] = "HmacSHA1"
 
-	// Sign the request.
-	if err = c.signer.SignRequest(req); err != nil {
-		err = fmt.Errorf("SignRequest: %v", err)
-		return
-	}
+	// If the server says that our clock is skewed, correct it and try again
+	// once.
+	for correctedSkew := false; ; correctedSkew = true {
+		req["Timestamp"] = c.clock.Now().UTC().Format(iso8601Format)
 
-	// Send the request.
-	httpResp, err := c.httpConn.SendRequest(req)
-	if err != nil {
-		err = fmt.Errorf("SendRequest: %v", err)
-		return
-	}
+		// Sign the request, replacing any previous signature.
+		if err = c.signer.SignRequest(req); err != nil {
+			err = fmt.Errorf("SignRequest: %v", err)
+			return
+		}
 
-	// Did the server return an error?
-	if httpResp.StatusCode != 200 {
-		err = fmt.Errorf(
-			"Error from server (%d): %s",
-			httpResp.StatusCode,
-			httpResp.Body)
+		// Send the request.
+		var httpResp *HttpResponse
+		httpResp, err = c.httpConn.SendRequest(req)
+		if err != nil {
+			err = fmt.Errorf("SendRequest: %v", err)
+			return
+		}
 
-		return
-	}
+		// Did the server return an error?
+		if httpResp.StatusCode != 200 {
+			if !correctedSkew && c.correctSkew(httpResp) {
+				c.interceptor.BeforeRetry(req)
+				continue
+			}
+
+			err = fmt.Errorf(
+				"Error from server (%d): %s",
+				httpResp.StatusCode,
+				httpResp.Body)
 
-	return httpResp.Body, nil
+			return
+		}
+
+		return httpResp.Body, nil
+	}
 }
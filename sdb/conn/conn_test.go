@@ -17,12 +17,13 @@ package conn_test
 
 import (
 	"errors"
-	"github.com/jacobsa/aws"
 	"github.com/jacobsa/aws/sdb/conn"
 	"github.com/jacobsa/aws/sdb/conn/mock"
+	aws_time "github.com/jacobsa/aws/time"
 	. "github.com/jacobsa/oglematchers"
 	"github.com/jacobsa/oglemock"
 	. "github.com/jacobsa/ogletest"
+	"net/http"
 	"testing"
 	"time"
 )
@@ -33,19 +34,13 @@ func TestConn(t *testing.T) { RunTests(t) }
 // Helpers
 ////////////////////////////////////////////////////////////////////////
 
-type fakeClock struct {
-	now time.Time
-}
-
-func (c *fakeClock) Now() time.Time {
-	return c.now
-}
-
 type ConnTest struct {
-	key      aws.AccessKey
 	httpConn mock_conn.MockHttpConn
 	signer   mock_conn.MockSigner
-	clock    *fakeClock
+	clock    *aws_time.SimulatedClock
+
+	// The requests passed to the interceptor's BeforeRetry hook.
+	retried []conn.Request
 
 	c conn.Conn
 }
@@ -55,12 +50,17 @@ func init() { RegisterTestSuite(&ConnTest{}) }
 func (t *ConnTest) SetUp(i *TestInfo) {
 	var err error
 
-	t.key = aws.AccessKey{Id: "some_id", Secret: "some_secret"}
 	t.httpConn = mock_conn.NewMockHttpConn(i.MockController, "httpConn")
 	t.signer = mock_conn.NewMockSigner(i.MockController, "signer")
-	t.clock = &fakeClock{}
+	t.clock = aws_time.NewSimulatedClock(time.Time{})
+
+	interceptor := &conn.InterceptorFuncs{
+		BeforeRetryFunc: func(req conn.Request) {
+			t.retried = append(t.retried, req)
+		},
+	}
 
-	t.c, err = conn.NewConn(t.key, t.httpConn, t.signer, t.clock)
+	t.c, err = conn.NewConn(t.httpConn, t.signer, interceptor, t.clock)
 	AssertEq(nil, err)
 }
 
@@ -74,8 +74,8 @@ func (t *ConnTest) CallsSigner() {
 	}
 
 	// Clock
-	t.clock.now =
-		time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC).Local()
+	t.clock.SetTime(
+		time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC).Local())
 
 	// Signer
 	var signArg conn.Request
@@ -95,7 +95,6 @@ func (t *ConnTest) CallsSigner() {
 	ExpectEq("1985-03-18T15:33:17Z", signArg["Timestamp"])
 	ExpectEq("2", signArg["SignatureVersion"])
 	ExpectEq("HmacSHA1", signArg["SignatureMethod"])
-	ExpectEq(t.key.Id, signArg["AWSAccessKeyId"])
 }
 
 func (t *ConnTest) SignerReturnsError() {
@@ -118,8 +117,8 @@ func (t *ConnTest) CallsHttpConn() {
 	}
 
 	// Clock
-	t.clock.now =
-		time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC).Local()
+	t.clock.SetTime(
+		time.Date(1985, time.March, 18, 15, 33, 17, 123, time.UTC).Local())
 
 	// Signer
 	ExpectCall(t.signer, "SignRequest")(Any()).
@@ -149,7 +148,6 @@ func (t *ConnTest) CallsHttpConn() {
 	ExpectEq("1985-03-18T15:33:17Z", sendArg["Timestamp"])
 	ExpectEq("2", sendArg["SignatureVersion"])
 	ExpectEq("HmacSHA1", sendArg["SignatureMethod"])
-	ExpectEq(t.key.Id, sendArg["AWSAccessKeyId"])
 }
 
 func (t *ConnTest) HttpConnReturnsError() {
@@ -208,3 +206,110 @@ func (t *ConnTest) ServerSaysOkay() {
 
 	ExpectEq("taco", string(body))
 }
+
+func (t *ConnTest) ServerSaysClockIsSkewed() {
+	req := conn.Request{}
+
+	// Clock
+	t.clock.SetTime(time.Date(1985, time.March, 18, 15, 33, 17, 0, time.UTC))
+	serverTime := t.clock.Now().Add(time.Hour)
+
+	// Signer
+	var timestamps []string
+	var signatures []string
+	ExpectCall(t.signer, "SignRequest")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Invoke(func(r conn.Request) error {
+		timestamps = append(timestamps, r["Timestamp"])
+		signatures = append(signatures, r["Signature"])
+		r["Signature"] = "taco"
+		return nil
+	}))
+
+	// HTTP conn
+	skewed := &conn.HttpResponse{
+		StatusCode: 403,
+		Header: http.Header{
+			"Date": []string{serverTime.Format(http.TimeFormat)},
+		},
+		Body: []byte(
+			"<Response><Errors><Error><Code>RequestExpired</Code>" +
+				"</Error></Errors></Response>"),
+	}
+
+	ok := &conn.HttpResponse{StatusCode: 200, Body: []byte("burrito")}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(skewed, nil)).
+		WillOnce(oglemock.Return(ok, nil))
+
+	// Call
+	body, err := t.c.SendRequest(req)
+	AssertEq(nil, err)
+
+	ExpectEq("burrito", string(body))
+	ExpectThat(
+		timestamps,
+		ElementsAre("1985-03-18T15:33:17Z", "1985-03-18T16:33:17Z"))
+
+	ExpectThat(signatures, ElementsAre("", "taco"))
+	ExpectEq(1, len(t.retried))
+}
+
+func (t *ConnTest) ServerSaysClockIsSkewedTwice() {
+	req := conn.Request{}
+
+	// Signer
+	ExpectCall(t.signer, "SignRequest")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Return(nil))
+
+	// HTTP conn
+	skewed := &conn.HttpResponse{
+		StatusCode: 403,
+		Header: http.Header{
+			"Date": []string{"Mon, 18 Mar 1985 16:33:17 GMT"},
+		},
+		Body: []byte(
+			"<Response><Errors><Error><Code>RequestExpired</Code>" +
+				"</Error></Errors></Response>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		Times(2).
+		WillRepeatedly(oglemock.Return(skewed, nil))
+
+	// Call
+	_, err := t.c.SendRequest(req)
+
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("RequestExpired")))
+}
+
+func (t *ConnTest) OtherErrorWithDateNotRetried() {
+	req := conn.Request{}
+
+	// Signer
+	ExpectCall(t.signer, "SignRequest")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	// HTTP conn
+	httpResp := &conn.HttpResponse{
+		StatusCode: 403,
+		Header: http.Header{
+			"Date": []string{"Mon, 18 Mar 1985 16:33:17 GMT"},
+		},
+		Body: []byte(
+			"<Response><Errors><Error><Code>InvalidClientTokenId</Code>" +
+				"</Error></Errors></Response>"),
+	}
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(httpResp, nil))
+
+	// Call
+	_, err := t.c.SendRequest(req)
+
+	ExpectThat(err, Error(HasSubstr("InvalidClientTokenId")))
+	ExpectEq(0, len(t.retried))
+}
@@ -14,5 +14,6 @@
 // limitations under the License.
 
 // Package conn contains code useful for making requests to the SimpleDB
-// servers. It is an implementation detail and should not be used directly.
+// servers. It is mostly an implementation detail, but its Interceptor type may
+// be used to observe and alter the requests sent; see sdb.NewSimpleDB.
 package conn
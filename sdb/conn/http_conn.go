@@ -27,6 +27,9 @@ type HttpResponse struct {
 	// The HTTP status code, e.g. 200 or 404.
 	StatusCode int
 
+	// The response headers, e.g. Date.
+	Header http.Header
+
 	// The response body. This is the empty slice if the body was empty.
 	Body []byte
 }
@@ -93,6 +96,7 @@ func (c *httpConn) SendRequest(req Request) (resp *HttpResponse, err error) {
 	// Convert the response.
 	resp = &HttpResponse{
 		StatusCode: sysResp.StatusCode,
+		Header:     sysResp.Header,
 	}
 
 	if resp.Body, err = ioutil.ReadAll(sysResp.Body); err != nil {
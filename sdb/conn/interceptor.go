@@ -0,0 +1,219 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws/time"
+	sys_time "time"
+)
+
+// An Interceptor observes or alters the requests sent to SimpleDB and their
+// responses, for example to log traffic, collect metrics, add parameters, or
+// inject faults. Pass interceptors to sdb.NewSimpleDB.
+//
+// A request that is retried, for example after correcting for clock skew, is
+// passed to BeforeRetry and then passes through the other hooks again.
+type Interceptor interface {
+	// Called with a request that is about to be sent again, before it is
+	// re-signed.
+	BeforeRetry(req Request)
+
+	// Called with each request before it is signed, so changes to its
+	// parameters are covered by the signature. Returning an error abandons the
+	// request.
+	BeforeSign(req Request) error
+
+	// Called with each request after it is signed. Returning an error abandons
+	// the request.
+	AfterSign(req Request) error
+
+	// Called with each request immediately before it is sent. Every call is
+	// followed by a call to AfterResponse for the same request.
+	BeforeSend(req Request)
+
+	// Called with the outcome of sending the request: either a response or an
+	// error from the connection, along with the time it took to arrive (not
+	// counting any time spent in BeforeSend). The returned values are used in
+	// its place, so the interceptor may for example turn an error into a
+	// response or vice versa.
+	AfterResponse(
+		req Request,
+		latency sys_time.Duration,
+		resp *HttpResponse,
+		err error) (*HttpResponse, error)
+}
+
+// InterceptorFuncs implements Interceptor with optional functions, so that
+// an interceptor needing only some of the hooks can leave the others nil.
+type InterceptorFuncs struct {
+	BeforeRetryFunc   func(req Request)
+	BeforeSignFunc    func(req Request) error
+	AfterSignFunc     func(req Request) error
+	BeforeSendFunc    func(req Request)
+	AfterResponseFunc func(
+		req Request,
+		latency sys_time.Duration,
+		resp *HttpResponse,
+		err error) (*HttpResponse, error)
+}
+
+func (f *InterceptorFuncs) BeforeRetry(req Request) {
+	if f.BeforeRetryFunc != nil {
+		f.BeforeRetryFunc(req)
+	}
+}
+
+func (f *InterceptorFuncs) BeforeSign(req Request) error {
+	if f.BeforeSignFunc == nil {
+		return nil
+	}
+
+	return f.BeforeSignFunc(req)
+}
+
+func (f *InterceptorFuncs) AfterSign(req Request) error {
+	if f.AfterSignFunc == nil {
+		return nil
+	}
+
+	return f.AfterSignFunc(req)
+}
+
+func (f *InterceptorFuncs) BeforeSend(req Request) {
+	if f.BeforeSendFunc != nil {
+		f.BeforeSendFunc(req)
+	}
+}
+
+func (f *InterceptorFuncs) AfterResponse(
+	req Request,
+	latency sys_time.Duration,
+	resp *HttpResponse,
+	err error) (*HttpResponse, error) {
+	if f.AfterResponseFunc == nil {
+		return resp, err
+	}
+
+	return f.AfterResponseFunc(req, latency, resp, err)
+}
+
+// Chain composes interceptors into one. Requests pass through its members in
+// order, stopping at the first error, and responses pass through them in
+// reverse order, so the first member sees the request first and the response
+// last.
+type Chain []Interceptor
+
+func (c Chain) BeforeRetry(req Request) {
+	for _, i := range c {
+		i.BeforeRetry(req)
+	}
+}
+
+func (c Chain) BeforeSign(req Request) error {
+	for _, i := range c {
+		if err := i.BeforeSign(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c Chain) AfterSign(req Request) error {
+	for _, i := range c {
+		if err := i.AfterSign(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c Chain) BeforeSend(req Request) {
+	for _, i := range c {
+		i.BeforeSend(req)
+	}
+}
+
+func (c Chain) AfterResponse(
+	req Request,
+	latency sys_time.Duration,
+	resp *HttpResponse,
+	err error) (*HttpResponse, error) {
+	for j := len(c) - 1; j >= 0; j-- {
+		resp, err = c[j].AfterResponse(req, latency, resp, err)
+	}
+
+	return resp, err
+}
+
+// InterceptSigner returns a signer that signs requests with the supplied one,
+// calling the interceptor's BeforeSign and AfterSign hooks around it. NewConn
+// uses it together with InterceptHttpConn.
+func InterceptSigner(s Signer, i Interceptor) Signer {
+	return &interceptedSigner{s, i}
+}
+
+type interceptedSigner struct {
+	wrapped     Signer
+	interceptor Interceptor
+}
+
+func (s *interceptedSigner) SignRequest(req Request) (err error) {
+	if err = s.interceptor.BeforeSign(req); err != nil {
+		err = fmt.Errorf("BeforeSign: %v", err)
+		return
+	}
+
+	if err = s.wrapped.SignRequest(req); err != nil {
+		return
+	}
+
+	if err = s.interceptor.AfterSign(req); err != nil {
+		err = fmt.Errorf("AfterSign: %v", err)
+		return
+	}
+
+	return
+}
+
+// InterceptHttpConn returns a connection that sends requests with the
+// supplied one, calling the interceptor's BeforeSend and AfterResponse hooks
+// around it and timing each request with the supplied clock.
+func InterceptHttpConn(
+	c HttpConn,
+	i Interceptor,
+	clock time.Clock) HttpConn {
+	return &interceptedHttpConn{c, i, clock}
+}
+
+type interceptedHttpConn struct {
+	wrapped     HttpConn
+	interceptor Interceptor
+	clock       time.Clock
+}
+
+func (c *interceptedHttpConn) SendRequest(
+	req Request) (*HttpResponse, error) {
+	c.interceptor.BeforeSend(req)
+
+	start := c.clock.Now()
+	resp, err := c.wrapped.SendRequest(req)
+	latency := c.clock.Now().Sub(start)
+
+	return c.interceptor.AfterResponse(req, latency, resp, err)
+}
@@ -0,0 +1,250 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn_test
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/sdb/conn"
+	"github.com/jacobsa/aws/sdb/conn/mock"
+	aws_time "github.com/jacobsa/aws/time"
+	. "github.com/jacobsa/oglematchers"
+	"github.com/jacobsa/oglemock"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestInterceptor(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// An interceptor that records the hooks it sees, tagged with its name.
+func recordingInterceptor(name string, log *[]string) conn.Interceptor {
+	return &conn.InterceptorFuncs{
+		BeforeRetryFunc: func(req conn.Request) {
+			*log = append(*log, name+".BeforeRetry")
+		},
+
+		BeforeSignFunc: func(req conn.Request) error {
+			*log = append(*log, name+".BeforeSign")
+			return nil
+		},
+
+		AfterSignFunc: func(req conn.Request) error {
+			*log = append(*log, name+".AfterSign")
+			return nil
+		},
+
+		BeforeSendFunc: func(req conn.Request) {
+			*log = append(*log, name+".BeforeSend")
+		},
+
+		AfterResponseFunc: func(
+			req conn.Request,
+			latency time.Duration,
+			resp *conn.HttpResponse,
+			err error) (*conn.HttpResponse, error) {
+			*log = append(*log, name+".AfterResponse")
+			return resp, err
+		},
+	}
+}
+
+type InterceptorTest struct {
+	log      []string
+	signer   mock_conn.MockSigner
+	httpConn mock_conn.MockHttpConn
+	clock    *aws_time.SimulatedClock
+}
+
+func init() { RegisterTestSuite(&InterceptorTest{}) }
+
+func (t *InterceptorTest) SetUp(i *TestInfo) {
+	t.signer = mock_conn.NewMockSigner(i.MockController, "signer")
+	t.httpConn = mock_conn.NewMockHttpConn(i.MockController, "httpConn")
+	t.clock = aws_time.NewSimulatedClock(time.Unix(1325376000, 0))
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *InterceptorTest) ChainOrder() {
+	c := conn.Chain{
+		recordingInterceptor("a", &t.log),
+		recordingInterceptor("b", &t.log),
+	}
+
+	req := conn.Request{}
+	c.BeforeRetry(req)
+	AssertEq(nil, c.BeforeSign(req))
+	AssertEq(nil, c.AfterSign(req))
+	c.BeforeSend(req)
+	_, err := c.AfterResponse(req, time.Second, &conn.HttpResponse{}, nil)
+	AssertEq(nil, err)
+
+	ExpectThat(
+		t.log,
+		ElementsAre(
+			"a.BeforeRetry",
+			"b.BeforeRetry",
+			"a.BeforeSign",
+			"b.BeforeSign",
+			"a.AfterSign",
+			"b.AfterSign",
+			"a.BeforeSend",
+			"b.BeforeSend",
+			"b.AfterResponse",
+			"a.AfterResponse",
+		))
+}
+
+func (t *InterceptorTest) ChainStopsAtFirstError() {
+	c := conn.Chain{
+		&conn.InterceptorFuncs{
+			BeforeSignFunc: func(req conn.Request) error {
+				return errors.New("taco")
+			},
+		},
+		recordingInterceptor("b", &t.log),
+	}
+
+	err := c.BeforeSign(conn.Request{})
+
+	ExpectThat(err, Error(Equals("taco")))
+	ExpectThat(t.log, ElementsAre())
+}
+
+func (t *InterceptorTest) SignerCallsHooksAroundSigner() {
+	i := recordingInterceptor("a", &t.log)
+	s := conn.InterceptSigner(t.signer, i)
+
+	ExpectCall(t.signer, "SignRequest")(Any()).
+		WillOnce(oglemock.Invoke(func(req conn.Request) error {
+		t.log = append(t.log, "SignRequest")
+		req["Signature"] = "taco"
+		return nil
+	}))
+
+	req := conn.Request{}
+	AssertEq(nil, s.SignRequest(req))
+
+	ExpectThat(
+		t.log,
+		ElementsAre("a.BeforeSign", "SignRequest", "a.AfterSign"))
+	ExpectEq("taco", req["Signature"])
+}
+
+func (t *InterceptorTest) SignerBeforeSignReturnsError() {
+	i := &conn.InterceptorFuncs{
+		BeforeSignFunc: func(req conn.Request) error {
+			return errors.New("taco")
+		},
+	}
+
+	s := conn.InterceptSigner(t.signer, i)
+	err := s.SignRequest(conn.Request{})
+
+	ExpectThat(err, Error(HasSubstr("BeforeSign")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *InterceptorTest) SignerAfterSignReturnsError() {
+	i := &conn.InterceptorFuncs{
+		AfterSignFunc: func(req conn.Request) error {
+			return errors.New("taco")
+		},
+	}
+
+	s := conn.InterceptSigner(t.signer, i)
+
+	ExpectCall(t.signer, "SignRequest")(Any()).
+		WillOnce(oglemock.Return(nil))
+
+	err := s.SignRequest(conn.Request{})
+
+	ExpectThat(err, Error(HasSubstr("AfterSign")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+}
+
+func (t *InterceptorTest) HttpConnReplacesOutcome() {
+	expected := &conn.HttpResponse{StatusCode: 200}
+	var seenErr error
+
+	var sent bool
+
+	i := &conn.InterceptorFuncs{
+		BeforeSendFunc: func(req conn.Request) {
+			sent = true
+		},
+
+		AfterResponseFunc: func(
+			req conn.Request,
+			latency time.Duration,
+			resp *conn.HttpResponse,
+			err error) (*conn.HttpResponse, error) {
+			seenErr = err
+			return expected, nil
+		},
+	}
+
+	c := conn.InterceptHttpConn(t.httpConn, i, t.clock)
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Return(nil, errors.New("taco")))
+
+	resp, err := c.SendRequest(conn.Request{})
+
+	AssertEq(nil, err)
+	ExpectEq(expected, resp)
+	ExpectTrue(sent)
+	ExpectThat(seenErr, Error(Equals("taco")))
+}
+
+func (t *InterceptorTest) HttpConnTimesRequest() {
+	var latency time.Duration
+
+	i := &conn.InterceptorFuncs{
+		// Time spent here shouldn't count.
+		BeforeSendFunc: func(req conn.Request) {
+			t.clock.AdvanceTime(100 * time.Millisecond)
+		},
+
+		AfterResponseFunc: func(
+			req conn.Request,
+			l time.Duration,
+			resp *conn.HttpResponse,
+			err error) (*conn.HttpResponse, error) {
+			latency = l
+			return resp, err
+		},
+	}
+
+	c := conn.InterceptHttpConn(t.httpConn, i, t.clock)
+
+	ExpectCall(t.httpConn, "SendRequest")(Any()).
+		WillOnce(oglemock.Invoke(func(req conn.Request) (*conn.HttpResponse, error) {
+		t.clock.AdvanceTime(10 * time.Millisecond)
+		return &conn.HttpResponse{StatusCode: 200}, nil
+	}))
+
+	_, err := c.SendRequest(conn.Request{})
+	AssertEq(nil, err)
+
+	ExpectEq(10*time.Millisecond, latency)
+}
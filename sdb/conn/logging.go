@@ -0,0 +1,103 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"encoding/xml"
+	"github.com/jacobsa/aws/logging"
+	sys_time "time"
+)
+
+// The parts of a response from SimpleDB that give the ID it assigned to the
+// request, which are in different places for successes and errors.
+type requestIdDocument struct {
+	SuccessId string `xml:"ResponseMetadata>RequestId"`
+	ErrorId   string `xml:"RequestID"`
+}
+
+// NewLoggingInterceptor returns an interceptor that logs the requests sent to
+// SimpleDB, as much as the level asks for. Pass it to sdb.NewSimpleDB.
+//
+// The request body is never logged, since it consists of the request's
+// parameters, which are logged with secrets redacted.
+func NewLoggingInterceptor(
+	logger logging.Logger,
+	level logging.Level) Interceptor {
+	return &loggingInterceptor{logger, level}
+}
+
+type loggingInterceptor struct {
+	logger logging.Logger
+	level  logging.Level
+}
+
+func (l *loggingInterceptor) BeforeRetry(req Request) {
+}
+
+func (l *loggingInterceptor) BeforeSign(req Request) error {
+	return nil
+}
+
+func (l *loggingInterceptor) AfterSign(req Request) error {
+	return nil
+}
+
+func (l *loggingInterceptor) BeforeSend(req Request) {
+}
+
+func (l *loggingInterceptor) AfterResponse(
+	req Request,
+	latency sys_time.Duration,
+	resp *HttpResponse,
+	err error) (*HttpResponse, error) {
+	e := &logging.Entry{
+		Service:    "sdb",
+		Operation:  req["Action"],
+		Verb:       "POST",
+		Path:       "/",
+		Parameters: logging.Redact(req),
+		Latency:    latency,
+		Err:        err,
+	}
+
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+	}
+
+	if l.level == logging.LevelErrors && !e.Failed() {
+		return resp, err
+	}
+
+	if resp != nil {
+		var doc requestIdDocument
+		if xml.Unmarshal(resp.Body, &doc) == nil {
+			e.RequestId = doc.SuccessId
+			if e.RequestId == "" {
+				e.RequestId = doc.ErrorId
+			}
+		}
+	}
+
+	if l.level >= logging.LevelBodies && resp != nil && len(resp.Body) > 0 {
+		e.ResponseBody = resp.Body
+		if len(e.ResponseBody) > logging.MaxBodyBytes {
+			e.ResponseBody = e.ResponseBody[:logging.MaxBodyBytes]
+		}
+	}
+
+	l.logger.Log(e)
+	return resp, err
+}
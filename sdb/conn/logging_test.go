@@ -0,0 +1,156 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/logging"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogging(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A logger that records the entries it is given.
+type recordingLogger struct {
+	entries []*logging.Entry
+}
+
+func (l *recordingLogger) Log(e *logging.Entry) {
+	l.entries = append(l.entries, e)
+}
+
+type LoggingTest struct {
+	logger recordingLogger
+	req    Request
+}
+
+func init() { RegisterTestSuite(&LoggingTest{}) }
+
+func (t *LoggingTest) SetUp(i *TestInfo) {
+	t.req = Request{
+		"Action":         "PutAttributes",
+		"DomainName":     "foo",
+		"AWSAccessKeyId": "some_id",
+		"SecurityToken":  "taco",
+		"Signature":      "burrito",
+	}
+}
+
+// Send the request through an interceptor with the supplied level, taking
+// the supplied time and returning the supplied outcome.
+func (t *LoggingTest) send(
+	level logging.Level,
+	latency time.Duration,
+	resp *HttpResponse,
+	err error) (*HttpResponse, error) {
+	i := NewLoggingInterceptor(&t.logger, level)
+
+	AssertEq(nil, i.BeforeSign(t.req))
+	AssertEq(nil, i.AfterSign(t.req))
+	i.BeforeSend(t.req)
+	return i.AfterResponse(t.req, latency, resp, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *LoggingTest) ErrorsLevelSkipsSuccesses() {
+	resp := &HttpResponse{StatusCode: 200}
+	_, err := t.send(logging.LevelErrors, time.Second, resp, nil)
+
+	AssertEq(nil, err)
+	ExpectEq(0, len(t.logger.entries))
+}
+
+func (t *LoggingTest) ErrorsLevelLogsFailures() {
+	_, err := t.send(logging.LevelErrors, time.Second, nil, errors.New("taco"))
+
+	ExpectThat(err, Error(Equals("taco")))
+	AssertEq(1, len(t.logger.entries))
+	ExpectThat(t.logger.entries[0].Err, Error(Equals("taco")))
+	ExpectEq(0, t.logger.entries[0].StatusCode)
+}
+
+func (t *LoggingTest) RequestsLevel() {
+	resp := &HttpResponse{
+		StatusCode: 200,
+		Body: []byte(
+			"<PutAttributesResponse><ResponseMetadata>" +
+				"<RequestId>ABC123</RequestId>" +
+				"</ResponseMetadata></PutAttributesResponse>"),
+	}
+
+	_, err := t.send(logging.LevelRequests, 3*time.Second, resp, nil)
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.logger.entries))
+	e := t.logger.entries[0]
+
+	ExpectEq("sdb", e.Service)
+	ExpectEq("PutAttributes", e.Operation)
+	ExpectEq("POST", e.Verb)
+	ExpectEq("/", e.Path)
+	ExpectEq("foo", e.Parameters["DomainName"])
+	ExpectEq(logging.Redacted, e.Parameters["AWSAccessKeyId"])
+	ExpectEq(logging.Redacted, e.Parameters["SecurityToken"])
+	ExpectEq(logging.Redacted, e.Parameters["Signature"])
+	ExpectEq(200, e.StatusCode)
+	ExpectEq("ABC123", e.RequestId)
+	ExpectEq(3*time.Second, e.Latency)
+	ExpectEq(nil, e.ResponseBody)
+
+	// The request itself should be untouched.
+	ExpectEq("burrito", t.req["Signature"])
+}
+
+func (t *LoggingTest) ErrorRequestId() {
+	resp := &HttpResponse{
+		StatusCode: 400,
+		Body: []byte(
+			"<Response><Errors><Error><Code>NoSuchDomain</Code></Error>" +
+				"</Errors><RequestID>DEF456</RequestID></Response>"),
+	}
+
+	_, err := t.send(logging.LevelErrors, time.Second, resp, nil)
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.logger.entries))
+	ExpectEq("DEF456", t.logger.entries[0].RequestId)
+}
+
+func (t *LoggingTest) BodiesLevel() {
+	longBody := strings.Repeat("x", logging.MaxBodyBytes+17)
+	resp := &HttpResponse{StatusCode: 200, Body: []byte(longBody)}
+
+	gotResp, err := t.send(logging.LevelBodies, time.Second, resp, nil)
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.logger.entries))
+	e := t.logger.entries[0]
+
+	ExpectEq(nil, e.RequestBody)
+	ExpectEq(longBody[:logging.MaxBodyBytes], string(e.ResponseBody))
+	ExpectEq(longBody, string(gotResp.Body))
+}
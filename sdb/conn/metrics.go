@@ -0,0 +1,76 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"encoding/xml"
+	"github.com/jacobsa/aws/metrics"
+	sys_time "time"
+)
+
+// NewMetricsInterceptor returns an interceptor that reports every request
+// sent to SimpleDB to the supplied sink, under the service name "sdb" and the
+// request's action (e.g. "PutAttributes"). Pass it to sdb.NewSimpleDB.
+func NewMetricsInterceptor(sink metrics.Sink) Interceptor {
+	return &metricsInterceptor{sink}
+}
+
+type metricsInterceptor struct {
+	sink metrics.Sink
+}
+
+func (m *metricsInterceptor) BeforeRetry(req Request) {
+	m.sink.RecordRetry("sdb", req["Action"])
+}
+
+func (m *metricsInterceptor) BeforeSign(req Request) error {
+	return nil
+}
+
+func (m *metricsInterceptor) AfterSign(req Request) error {
+	return nil
+}
+
+func (m *metricsInterceptor) BeforeSend(req Request) {
+}
+
+func (m *metricsInterceptor) AfterResponse(
+	req Request,
+	latency sys_time.Duration,
+	resp *HttpResponse,
+	err error) (*HttpResponse, error) {
+	a := metrics.Attempt{
+		Service:   "sdb",
+		Operation: req["Action"],
+		BytesSent: int64(len(assemblePostBody(req))),
+		Latency:   latency,
+	}
+
+	if resp != nil {
+		a.StatusCode = resp.StatusCode
+		a.BytesReceived = int64(len(resp.Body))
+
+		var doc errorDocument
+		if resp.StatusCode != 200 &&
+			xml.Unmarshal(resp.Body, &doc) == nil &&
+			len(doc.Codes) > 0 {
+			a.ErrorCode = doc.Codes[0]
+		}
+	}
+
+	m.sink.RecordAttempt(a)
+	return resp, err
+}
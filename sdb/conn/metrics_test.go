@@ -0,0 +1,145 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"errors"
+	"github.com/jacobsa/aws/metrics"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// A sink that records what it is given.
+type recordingSink struct {
+	attempts []metrics.Attempt
+	retries  []string
+}
+
+func (s *recordingSink) RecordAttempt(a metrics.Attempt) {
+	s.attempts = append(s.attempts, a)
+}
+
+func (s *recordingSink) RecordRetry(service string, operation string) {
+	s.retries = append(s.retries, service+"/"+operation)
+}
+
+type MetricsTest struct {
+	sink        recordingSink
+	interceptor Interceptor
+}
+
+func init() { RegisterTestSuite(&MetricsTest{}) }
+
+func (t *MetricsTest) SetUp(i *TestInfo) {
+	t.interceptor = NewMetricsInterceptor(&t.sink)
+}
+
+// Send the request through the interceptor, taking the supplied time and
+// returning the supplied outcome.
+func (t *MetricsTest) send(
+	req Request,
+	latency time.Duration,
+	resp *HttpResponse,
+	err error) (*HttpResponse, error) {
+	AssertEq(nil, t.interceptor.BeforeSign(req))
+	AssertEq(nil, t.interceptor.AfterSign(req))
+	t.interceptor.BeforeSend(req)
+	return t.interceptor.AfterResponse(req, latency, resp, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *MetricsTest) SuccessfulRequest() {
+	req := Request{"Action": "PutAttributes", "DomainName": "foo"}
+	resp := &HttpResponse{StatusCode: 200, Body: []byte("burrito")}
+
+	// Call
+	gotResp, err := t.send(req, 3*time.Second, resp, nil)
+	AssertEq(nil, err)
+	ExpectEq(resp, gotResp)
+
+	AssertEq(1, len(t.sink.attempts))
+	a := t.sink.attempts[0]
+
+	ExpectEq("sdb", a.Service)
+	ExpectEq("PutAttributes", a.Operation)
+	ExpectEq(200, a.StatusCode)
+	ExpectEq("", a.ErrorCode)
+	ExpectEq(len(assemblePostBody(req)), a.BytesSent)
+	ExpectEq(len("burrito"), a.BytesReceived)
+	ExpectEq(3*time.Second, a.Latency)
+
+	ExpectThat(t.sink.retries, ElementsAre())
+}
+
+func (t *MetricsTest) ErrorResponse() {
+	req := Request{"Action": "GetAttributes"}
+	resp := &HttpResponse{
+		StatusCode: 400,
+		Body: []byte(
+			"<Response><Errors><Error><Code>NoSuchDomain</Code>" +
+				"</Error></Errors></Response>"),
+	}
+
+	// Call
+	_, err := t.send(req, time.Second, resp, nil)
+	AssertEq(nil, err)
+
+	AssertEq(1, len(t.sink.attempts))
+	ExpectEq(400, t.sink.attempts[0].StatusCode)
+	ExpectEq("NoSuchDomain", t.sink.attempts[0].ErrorCode)
+}
+
+func (t *MetricsTest) ConnectionError() {
+	req := Request{"Action": "Select"}
+
+	// Call
+	resp, err := t.send(req, time.Second, nil, errors.New("taco"))
+
+	ExpectEq(nil, resp)
+	ExpectThat(err, Error(Equals("taco")))
+
+	AssertEq(1, len(t.sink.attempts))
+	ExpectEq("Select", t.sink.attempts[0].Operation)
+	ExpectEq(0, t.sink.attempts[0].StatusCode)
+	ExpectEq(time.Second, t.sink.attempts[0].Latency)
+}
+
+func (t *MetricsTest) Retry() {
+	req := Request{"Action": "DeleteDomain"}
+
+	// The first attempt is rejected, and the request is retried.
+	_, err := t.send(req, time.Second, &HttpResponse{StatusCode: 403}, nil)
+	AssertEq(nil, err)
+
+	t.interceptor.BeforeRetry(req)
+
+	_, err = t.send(req, time.Second, &HttpResponse{StatusCode: 200}, nil)
+	AssertEq(nil, err)
+
+	ExpectThat(t.sink.retries, ElementsAre("sdb/DeleteDomain"))
+	AssertEq(2, len(t.sink.attempts))
+}
@@ -0,0 +1,46 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"github.com/jacobsa/aws/ratelimit"
+	sys_time "time"
+)
+
+// NewRateLimitInterceptor returns an interceptor that makes a connection
+// consult the supplied limiter before sending each request, under the
+// request's action (e.g. "PutAttributes"), and tells it when SimpleDB responds
+// with 503 Service Unavailable. Pass it to sdb.NewSimpleDB. Time spent waiting
+// isn't counted in the latency seen by other interceptors.
+func NewRateLimitInterceptor(l ratelimit.Limiter) Interceptor {
+	return &InterceptorFuncs{
+		BeforeSendFunc: func(req Request) {
+			l.Wait(req["Action"], int64(len(assemblePostBody(req))))
+		},
+
+		AfterResponseFunc: func(
+			req Request,
+			latency sys_time.Duration,
+			resp *HttpResponse,
+			err error) (*HttpResponse, error) {
+			if resp != nil {
+				l.Observe(req["Action"], resp.StatusCode == 503)
+			}
+
+			return resp, err
+		},
+	}
+}
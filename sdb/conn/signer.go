@@ -25,31 +25,55 @@ import (
 )
 
 type Signer interface {
-	// Add appropriate parameters to the supplied request in order to sign it.
+	// Add appropriate parameters to the supplied request in order to sign it,
+	// replacing any signature it already carries. This includes the access key
+	// ID and session token of the key used.
 	SignRequest(req Request) error
 }
 
-// Create a signer that uses the supplied key for requests to the given host.
-func NewSigner(key aws.AccessKey, host string) (Signer, error) {
-	return newSigner(key, host, computeStringToSign), nil
+// Create a signer that uses the key returned by the supplied provider for
+// requests to the given host.
+func NewSigner(
+	creds aws.CredentialsProvider,
+	host string) (Signer, error) {
+	return newSigner(creds, host, computeStringToSign), nil
 }
 
 // The underlying constructor, which accepts a "string to sign" function for
 // testability.
 func newSigner(
-	key aws.AccessKey,
+	creds aws.CredentialsProvider,
 	host string,
 	sts func(Request, string) (string, error)) Signer {
-	return &signer{key, host, sts}
+	return &signer{creds, host, sts}
 }
 
 type signer struct {
-	key  aws.AccessKey
-	host string
-	sts  func(Request, string) (string, error)
+	creds aws.CredentialsProvider
+	host  string
+	sts   func(Request, string) (string, error)
 }
 
 func (s *signer) SignRequest(req Request) error {
+	// Find out which key to use.
+	key, err := s.creds.Credentials()
+	if err != nil {
+		return fmt.Errorf("Credentials: %v", err)
+	}
+
+	// Identify the key. Credentials may be rotated between attempts at
+	// sending the request, so anything set by a previous attempt is replaced.
+	req["AWSAccessKeyId"] = key.Id
+	if key.SessionToken != "" {
+		req["SecurityToken"] = key.SessionToken
+	} else {
+		delete(req, "SecurityToken")
+	}
+
+	// A signature from a previous attempt at sending the request is not part
+	// of what we sign.
+	delete(req, "Signature")
+
 	// Decide on the string to sign.
 	toSign, err := s.sts(req, s.host)
 	if err != nil {
@@ -57,7 +81,7 @@ func (s *signer) SignRequest(req Request) error {
 	}
 
 	// Sign the request.
-	h := hmac.New(sha1.New, []byte(s.key.Secret))
+	h := hmac.New(sha1.New, []byte(key.Secret))
 	if _, err := h.Write([]byte(toSign)); err != nil {
 		return fmt.Errorf("hmac.Write: %v", err)
 	}
@@ -33,6 +33,13 @@ func TestSigner(t *testing.T) { RunTests(t) }
 // Helpers
 ////////////////////////////////////////////////////////////////////////
 
+// A credentials provider that always fails.
+type failingCredentials struct{}
+
+func (c failingCredentials) Credentials() (aws.AccessKey, error) {
+	return aws.AccessKey{}, errors.New("taco")
+}
+
 type SignerTest struct {
 }
 
@@ -64,6 +71,99 @@ func (t *SignerTest) CallsFunction() {
 	ExpectEq("some_host", hostArg)
 }
 
+func (t *SignerTest) SignsKeyIdAndSessionToken() {
+	// Function
+	var signed Request
+
+	sts := func(r Request, host string) (string, error) {
+		signed = Request{}
+		for k, v := range r {
+			signed[k] = v
+		}
+
+		return "", nil
+	}
+
+	// Signer
+	key := aws.AccessKey{Id: "queso", Secret: "burrito", SessionToken: "taco"}
+	signer := newSigner(key, "some_host", sts)
+
+	// Call
+	req := Request{"foo": "bar"}
+	err := signer.SignRequest(req)
+	AssertEq(nil, err)
+
+	ExpectEq("queso", req["AWSAccessKeyId"])
+	ExpectEq("taco", req["SecurityToken"])
+
+	ExpectEq("queso", signed["AWSAccessKeyId"])
+	ExpectEq("taco", signed["SecurityToken"])
+}
+
+func (t *SignerTest) RotatedKeyReplacesPreviousOne() {
+	// Function
+	sts := func(r Request, host string) (string, error) {
+		return "", nil
+	}
+
+	// Signer
+	key := aws.AccessKey{Id: "queso", Secret: "burrito"}
+	signer := newSigner(key, "some_host", sts)
+
+	// Call with a request left over from an attempt using another key.
+	req := Request{
+		"AWSAccessKeyId": "enchilada",
+		"SecurityToken":  "taco",
+	}
+
+	err := signer.SignRequest(req)
+	AssertEq(nil, err)
+
+	ExpectEq("queso", req["AWSAccessKeyId"])
+	_, present := req["SecurityToken"]
+	ExpectFalse(present)
+}
+
+func (t *SignerTest) ExistingSignatureIsNotSigned() {
+	// Function
+	var signature string
+	var present bool
+
+	sts := func(r Request, host string) (string, error) {
+		signature, present = r["Signature"]
+		return "", nil
+	}
+
+	// Signer
+	signer := newSigner(aws.AccessKey{}, "some_host", sts)
+
+	// Call
+	req := Request{"foo": "bar", "Signature": "taco"}
+	err := signer.SignRequest(req)
+	AssertEq(nil, err)
+
+	ExpectFalse(present, "Signature: %s", signature)
+	ExpectNe("taco", req["Signature"])
+}
+
+func (t *SignerTest) CredentialsReturnError() {
+	// Function
+	sts := func(r Request, h string) (string, error) {
+		return "", nil
+	}
+
+	// Signer
+	signer := newSigner(failingCredentials{}, "", sts)
+
+	// Call
+	req := Request{}
+	err := signer.SignRequest(req)
+
+	ExpectThat(err, Error(HasSubstr("Credentials")))
+	ExpectThat(err, Error(HasSubstr("taco")))
+	ExpectEq("", req["Signature"])
+}
+
 func (t *SignerTest) FunctionReturnsError() {
 	// Function
 	sts := func(r Request, h string) (string, error) {
@@ -0,0 +1,53 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// The body of an error response from SimpleDB, as far as we need it.
+//
+// Reference:
+//     http://docs.aws.amazon.com/AmazonSimpleDB/latest/DeveloperGuide/APIError.html
+type errorDocument struct {
+	Codes []string `xml:"Errors>Error>Code"`
+}
+
+// If the response says that the request's timestamp was too far from the
+// server's clock, correct our clock using the response's Date header and
+// return true.
+func (c *conn) correctSkew(resp *HttpResponse) bool {
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+
+	var doc errorDocument
+	if err := xml.Unmarshal(resp.Body, &doc); err != nil {
+		return false
+	}
+
+	for _, code := range doc.Codes {
+		if code == "RequestExpired" || code == "RequestTimeTooSkewed" {
+			c.clock.Observe(serverTime)
+			return true
+		}
+	}
+
+	return false
+}
@@ -18,8 +18,16 @@
 //     go run integration_test/*.go \
 //         -key_id <key ID>
 //
+// The secret for the key ID is read from the terminal. If -key_id is omitted,
+// credentials are taken from the environment or ~/.aws/credentials instead.
+//
 // Before doing this, create an empty domain (or delete the contents of an
 // existing domain).
+//
+// Alternatively, run the tests against an in-process fake server with no
+// need for an AWS account:
+//
+//     go run integration_test/*.go -fake
 
 package main
 
@@ -28,6 +36,8 @@ import (
 	"fmt"
 	"github.com/jacobsa/aws"
 	"github.com/jacobsa/aws/sdb"
+	"github.com/jacobsa/aws/sdb/conn"
+	"github.com/jacobsa/aws/sdb/sdbtest"
 	"github.com/jacobsa/ogletest"
 	"os"
 	"regexp"
@@ -40,9 +50,29 @@ import (
 
 var g_keyId = flag.String("key_id", "", "Access key ID.")
 
+var g_fake = flag.Bool("fake", false, "Use an in-process fake server.")
+
 var g_region = sdb.RegionApacSydney
 var g_accessKey aws.AccessKey
 
+// Set only if -fake is set.
+var g_fakeServer *sdbtest.Server
+
+// Open a connection to the database under test using the supplied key and
+// interceptors.
+func openDb(
+	key aws.AccessKey,
+	interceptors ...conn.Interceptor) (sdb.SimpleDB, error) {
+	if g_fakeServer != nil {
+		return sdb.NewSimpleDBAtEndpoint(
+			g_fakeServer.Endpoint(),
+			key,
+			interceptors...)
+	}
+
+	return sdb.NewSimpleDB(g_region, key, interceptors...)
+}
+
 ////////////////////////////////////////////////////////////////////////
 // main
 ////////////////////////////////////////////////////////////////////////
@@ -50,18 +80,36 @@ var g_accessKey aws.AccessKey
 func main() {
 	flag.Parse()
 
-	if *g_keyId == "" {
-		fmt.Println("You must set the -key_id flag.")
-		fmt.Println("Find a key ID here:")
-		fmt.Println("    https://portal.aws.amazon.com/gp/aws/securityCredentials")
-		os.Exit(1)
+	if *g_fake {
+		runFake()
+		return
+	}
+
+	// Read in the access key, or find one in the environment.
+	if *g_keyId != "" {
+		g_accessKey.Id = *g_keyId
+		g_accessKey.Secret = readPassword("Access key secret: ")
+	} else {
+		var err error
+		g_accessKey, err = aws.DefaultCredentials().Credentials()
+		if err != nil {
+			fmt.Println("Couldn't find credentials:", err)
+			fmt.Println("Set the -key_id flag, or see aws.DefaultCredentials.")
+			os.Exit(1)
+		}
 	}
 
-	// Read in the access key.
-	g_accessKey.Id = *g_keyId
-	g_accessKey.Secret = readPassword("Access key secret: ")
+	runTests()
+}
+
+// Run the tests against a fake server, using a made-up access key.
+func runFake() {
+	g_accessKey = aws.AccessKey{Id: "fake_id", Secret: "fake_secret"}
+	g_fakeServer = sdbtest.NewServer(g_accessKey)
+	runTests()
+}
 
-	// Run the tests.
+func runTests() {
 	matchString := func(pat, str string) (bool, error) {
 		re, err := regexp.Compile(pat)
 		if err != nil {
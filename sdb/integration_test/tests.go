@@ -16,14 +16,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jacobsa/aws/sdb"
+	"github.com/jacobsa/aws/sdb/conn"
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
 	"math/rand"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 ////////////////////////////////////////////////////////////////////////
@@ -46,7 +49,7 @@ func (t *integrationTest) SetUp(i *TestInfo) {
 	t.deleteRequest = sdb.BatchDeleteMap{}
 
 	// Open a connection.
-	t.db, err = sdb.NewSimpleDB(g_region, g_accessKey)
+	t.db, err = openDb(g_accessKey)
 	AssertEq(nil, err)
 }
 
@@ -114,7 +117,7 @@ func (t *DomainsTest) SetUpTestSuite() {
 	var err error
 
 	// Open a connection.
-	g_domainsTestDb, err = sdb.NewSimpleDB(g_region, g_accessKey)
+	g_domainsTestDb, err = openDb(g_accessKey)
 	if err != nil {
 		panic(err)
 	}
@@ -163,7 +166,7 @@ func (t *DomainsTest) InvalidAccessKey() {
 	wrongKey := g_accessKey
 	wrongKey.Id += "taco"
 
-	db, err := sdb.NewSimpleDB(g_region, wrongKey)
+	db, err := openDb(wrongKey)
 	AssertEq(nil, err)
 
 	// Attempt to create a domain.
@@ -174,6 +177,53 @@ func (t *DomainsTest) InvalidAccessKey() {
 	ExpectThat(err, Error(HasSubstr("exist")))
 }
 
+func (t *DomainsTest) Interceptors() {
+	// Set up an interceptor that records the actions requested and the status
+	// codes of responses, and refuses to send domain deletions.
+	var actions []string
+	var statusCodes []int
+
+	interceptor := &conn.InterceptorFuncs{
+		BeforeSignFunc: func(req conn.Request) error {
+			actions = append(actions, req["Action"])
+			return nil
+		},
+
+		AfterSignFunc: func(req conn.Request) error {
+			if req["Action"] == "DeleteDomain" {
+				return errors.New("injected fault")
+			}
+
+			return nil
+		},
+
+		AfterResponseFunc: func(
+			req conn.Request,
+			latency time.Duration,
+			resp *conn.HttpResponse,
+			err error) (*conn.HttpResponse, error) {
+			if err == nil {
+				statusCodes = append(statusCodes, resp.StatusCode)
+			}
+
+			return resp, err
+		},
+	}
+
+	db, err := openDb(g_accessKey, interceptor)
+	AssertEq(nil, err)
+
+	// Open an existing domain, then attempt to delete it.
+	domain, err := db.OpenDomain(g_domainsTestDomain0.Name())
+	AssertEq(nil, err)
+
+	err = db.DeleteDomain(domain)
+	ExpectThat(err, Error(HasSubstr("injected fault")))
+
+	ExpectThat(actions, ElementsAre("CreateDomain", "DeleteDomain"))
+	ExpectThat(statusCodes, ElementsAre(200))
+}
+
 func (t *DomainsTest) SeparatelyNamedDomainsHaveIndependentItems() {
 	var err error
 
@@ -274,7 +324,7 @@ func (t *ItemsTest) SetUpTestSuite() {
 	var err error
 
 	// Open a connection.
-	g_itemsTestDb, err = sdb.NewSimpleDB(g_region, g_accessKey)
+	g_itemsTestDb, err = openDb(g_accessKey)
 	if err != nil {
 		panic(err)
 	}
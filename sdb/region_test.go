@@ -0,0 +1,104 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdb
+
+import (
+	"github.com/jacobsa/aws"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestRegion(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type RegionTest struct {
+}
+
+func init() { RegisterTestSuite(&RegionTest{}) }
+
+var allRegionConstants = []Region{
+	RegionUsEastNorthernVirginia,
+	RegionUsWestOregon,
+	RegionUsWestNorCal,
+	RegionEuIreland,
+	RegionApacSingapore,
+	RegionApacSydney,
+	RegionApacTokyo,
+	RegionSouthAmericaSaoPaulo,
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *RegionTest) ConstantsRoundTrip() {
+	for _, r := range allRegionConstants {
+		name, err := r.Name()
+		AssertEq(nil, err, "%s", r)
+
+		parsed, err := ParseRegion(name)
+		AssertEq(nil, err, "%s", name)
+		ExpectEq(r, parsed)
+	}
+}
+
+func (t *RegionTest) ParseName() {
+	r, err := ParseRegion("eu-west-1")
+	AssertEq(nil, err)
+	ExpectEq(RegionEuIreland, r)
+}
+
+func (t *RegionTest) ParseHostname() {
+	r, err := ParseRegion("SDB.EU-WEST-1.AMAZONAWS.COM")
+	AssertEq(nil, err)
+	ExpectEq(RegionEuIreland, r)
+}
+
+func (t *RegionTest) ParseOtherServiceHostname() {
+	_, err := ParseRegion("s3-eu-west-1.amazonaws.com")
+
+	ExpectThat(err, Error(HasSubstr("Unknown SimpleDB region")))
+	ExpectThat(err, Error(HasSubstr("s3-eu-west-1.amazonaws.com")))
+}
+
+func (t *RegionTest) ParseUnknown() {
+	_, err := ParseRegion("taco")
+	ExpectThat(err, Error(HasSubstr("Unknown SimpleDB region")))
+}
+
+func (t *RegionTest) NameOfUnknownEndpoint() {
+	_, err := Region("www.example.com").Name()
+	ExpectThat(err, Error(HasSubstr("Unknown endpoint")))
+}
+
+func (t *RegionTest) NameOfOtherServiceEndpoint() {
+	_, err := Region("s3-eu-west-1.amazonaws.com").Name()
+	ExpectThat(err, Error(HasSubstr("Not a SimpleDB endpoint")))
+}
+
+func (t *RegionTest) UnavailableRegion() {
+	_, err := RegionEndpoint("eu-central-1", aws.EndpointStandard)
+	ExpectThat(err, Error(HasSubstr("No standard endpoint")))
+}
+
+func (t *RegionTest) FIPSEndpoint() {
+	_, err := RegionEndpoint("us-east-1", aws.EndpointFIPS)
+	ExpectThat(err, Error(HasSubstr("No FIPS endpoint")))
+}
@@ -92,15 +92,34 @@ type SimpleDB interface {
 		nextToken []byte) (results []SelectedItem, tok []byte, err error)
 }
 
-// Return a SimpleDB connection tied to the given region, using the sipplied
-// access key to authenticate requests.
-func NewSimpleDB(region Region, key aws.AccessKey) (db SimpleDB, err error) {
-	// Open an appropriate HTTP connection.
+// Return a SimpleDB connection tied to the given region, using the access key
+// returned by the supplied provider to authenticate requests. An
+// aws.AccessKey may be passed directly, or use aws.DefaultCredentials to find
+// one in the environment. Any interceptors supplied see every request sent, in
+// the manner of conn.Chain.
+func NewSimpleDB(
+	region Region,
+	creds aws.CredentialsProvider,
+	interceptors ...conn.Interceptor) (db SimpleDB, err error) {
 	endpoint := &url.URL{
 		Scheme: "https",
 		Host:   string(region),
 	}
 
+	return NewSimpleDBAtEndpoint(endpoint, creds, interceptors...)
+}
+
+// NewSimpleDBAtEndpoint is like NewSimpleDB, but talks to the server at the
+// supplied endpoint rather than one of the regional endpoints. Both http and
+// https endpoints are supported. This is mostly useful for testing against a
+// fake server, such as the one in package sdbtest.
+func NewSimpleDBAtEndpoint(
+	endpoint *url.URL,
+	creds aws.CredentialsProvider,
+	interceptors ...conn.Interceptor) (db SimpleDB, err error) {
+	chain := conn.Chain(interceptors)
+
+	// Open an appropriate HTTP connection.
 	httpConn, err := conn.NewHttpConn(endpoint)
 	if err != nil {
 		err = fmt.Errorf("Opening HTTP connection: %v", err)
@@ -108,14 +127,14 @@ func NewSimpleDB(region Region, key aws.AccessKey) (db SimpleDB, err error) {
 	}
 
 	// Create a request signer.
-	signer, err := conn.NewSigner(key, endpoint.Host)
+	signer, err := conn.NewSigner(creds, endpoint.Host)
 	if err != nil {
 		err = fmt.Errorf("Creating signer: %v", err)
 		return
 	}
 
 	// Create a connection to the server.
-	c, err := conn.NewConn(key, httpConn, signer, time.RealClock())
+	c, err := conn.NewConn(httpConn, signer, chain, time.RealClock())
 	if err != nil {
 		err = fmt.Errorf("Creating connection: %v", err)
 		return
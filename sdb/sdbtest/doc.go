@@ -0,0 +1,33 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sdbtest contains an in-process fake SimpleDB server, useful for
+// testing code that uses package sdb without access to a real AWS account.
+//
+// The server speaks the subset of the SimpleDB query API used by package sdb:
+// creating and deleting domains, putting, getting, and deleting attributes
+// (singly or in batches, with optional preconditions), and running select
+// queries. Requests are authenticated using signature version 2, as with real
+// SimpleDB. All reads are consistent.
+//
+// For example:
+//
+//     key := aws.AccessKey{Id: "some_id", Secret: "some_secret"}
+//     server := sdbtest.NewServer(key)
+//     defer server.Close()
+//
+//     db, err := sdb.NewSimpleDBAtEndpoint(server.Endpoint(), key)
+//
+package sdbtest
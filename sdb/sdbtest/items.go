@@ -0,0 +1,588 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdbtest
+
+import (
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SimpleDB's limits on the sizes of requests.
+const (
+	maxAttributesPerRequest = 256
+	maxItemsPerBatch        = 25
+)
+
+////////////////////////////////////////////////////////////////////////
+// Parameter parsing
+////////////////////////////////////////////////////////////////////////
+
+// Group parameters of the form <prefix><n>.<rest> by n, returning the
+// distinct values of n in increasing order and a map from each to the
+// corresponding map from <rest> to value.
+func groupParams(
+	params map[string]string,
+	prefix string) (indices []int, groups map[int]map[string]string) {
+	groups = make(map[int]map[string]string)
+	for key, val := range params {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(key[len(prefix):], ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+
+		if _, ok := groups[n]; !ok {
+			groups[n] = make(map[string]string)
+			indices = append(indices, n)
+		}
+
+		groups[n][parts[1]] = val
+	}
+
+	sort.Ints(indices)
+	return
+}
+
+type putUpdate struct {
+	attribute
+	replace bool
+}
+
+// Parse updates of the form Attribute.<n>.{Name,Value,Replace}.
+func parsePutUpdates(
+	params map[string]string) (updates []putUpdate, e *serverError) {
+	indices, groups := groupParams(params, "Attribute.")
+	for _, n := range indices {
+		group := groups[n]
+
+		name, ok := group["Name"]
+		if !ok {
+			e = newError(
+				400,
+				"MissingParameter",
+				"Attribute.Name missing for Attribute.Value='%s'.",
+				group["Value"])
+
+			return
+		}
+
+		value, ok := group["Value"]
+		if !ok {
+			e = newError(
+				400,
+				"MissingParameter",
+				"Attribute.Value missing for Attribute.Name='%s'.",
+				name)
+
+			return
+		}
+
+		u := putUpdate{attribute{name, value}, group["Replace"] == "true"}
+		updates = append(updates, u)
+	}
+
+	if len(updates) == 0 {
+		e = newError(400, "MissingParameter", "No attributes")
+		return
+	}
+
+	if len(updates) > maxAttributesPerRequest {
+		e = newError(
+			400,
+			"NumberSubmittedAttributesExceeded",
+			"Too many attributes in a single call.")
+
+		return
+	}
+
+	return
+}
+
+type deleteUpdate struct {
+	name  string
+	value *string
+}
+
+// Parse deletes of the form Attribute.<n>.{Name,Value}.
+func parseDeleteUpdates(
+	params map[string]string) (updates []deleteUpdate, e *serverError) {
+	indices, groups := groupParams(params, "Attribute.")
+	for _, n := range indices {
+		group := groups[n]
+
+		name, ok := group["Name"]
+		if !ok {
+			e = newError(
+				400,
+				"MissingParameter",
+				"Attribute.Name missing for Attribute.Value='%s'.",
+				group["Value"])
+
+			return
+		}
+
+		u := deleteUpdate{name: name}
+		if value, ok := group["Value"]; ok {
+			u.value = &value
+		}
+
+		updates = append(updates, u)
+	}
+
+	if len(updates) > maxAttributesPerRequest {
+		e = newError(
+			400,
+			"NumberSubmittedAttributesExceeded",
+			"Too many attributes in a single call.")
+
+		return
+	}
+
+	return
+}
+
+type precondition struct {
+	name   string
+	value  *string // nil if the attribute must not exist
+	exists bool
+}
+
+// Parse an optional precondition of the form Expected.1.{Name,Value,Exists}.
+func parsePrecondition(
+	params map[string]string) (p *precondition, e *serverError) {
+	indices, groups := groupParams(params, "Expected.")
+	switch len(indices) {
+	case 0:
+		return
+
+	case 1:
+
+	default:
+		e = newError(
+			400,
+			"InvalidParameterValue",
+			"Only one expected attribute is supported.")
+
+		return
+	}
+
+	group := groups[indices[0]]
+	p = &precondition{name: group["Name"], exists: true}
+	if p.name == "" {
+		e = newError(400, "MissingParameter", "Expected.Name missing.")
+		return
+	}
+
+	if value, ok := group["Value"]; ok {
+		p.value = &value
+	}
+
+	if exists, ok := group["Exists"]; ok {
+		p.exists = exists == "true"
+	}
+
+	if p.exists && p.value == nil {
+		e = newError(
+			400,
+			"IncompleteExpectedExpression",
+			"If Expected.Exists = true or unspecified, then Expected.Value has "+
+				"to be specified.")
+
+		return
+	}
+
+	if !p.exists && p.value != nil {
+		e = newError(
+			400,
+			"IncompleteExpectedExpression",
+			"If Expected.Exists = false, then Expected.Value cannot be "+
+				"specified.")
+
+		return
+	}
+
+	return
+}
+
+func parseItemName(params map[string]string) (name string, e *serverError) {
+	name, ok := params["ItemName"]
+	if !ok {
+		e = newError(
+			400,
+			"MissingParameter",
+			"The request must contain the parameter ItemName")
+		return
+	}
+
+	if name == "" {
+		e = newError(
+			400,
+			"InvalidParameterValue",
+			"Value () for parameter Name is invalid. The empty string is an "+
+				"illegal attribute name")
+		return
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Updates
+////////////////////////////////////////////////////////////////////////
+
+func checkPrecondition(p *precondition, attrs []attribute) *serverError {
+	if p == nil {
+		return nil
+	}
+
+	var values []string
+	for _, a := range attrs {
+		if a.Name == p.name {
+			values = append(values, a.Value)
+		}
+	}
+
+	switch {
+	case !p.exists && len(values) > 0:
+		return newError(
+			409,
+			"ConditionalCheckFailed",
+			"Conditional check failed. Attribute (%s) value exists",
+			p.name)
+
+	case !p.exists:
+		return nil
+
+	case len(values) == 0:
+		return newError(
+			404,
+			"AttributeDoesNotExist",
+			"Attribute (%s) does not exist",
+			p.name)
+
+	case len(values) > 1:
+		return newError(
+			409,
+			"MultiValuedAttribute",
+			"Attribute (%s) is multi-valued. Conditional check can only be "+
+				"performed on a single-valued attribute",
+			p.name)
+
+	case values[0] != *p.value:
+		return newError(
+			409,
+			"ConditionalCheckFailed",
+			"Conditional check failed. Attribute (%s) value is (%s) but was "+
+				"expected (%s)",
+			p.name,
+			values[0],
+			*p.value)
+	}
+
+	return nil
+}
+
+func containsAttribute(attrs []attribute, a attribute) bool {
+	for _, existing := range attrs {
+		if existing == a {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply the supplied updates to a list of attributes, returning the new list.
+func applyPut(attrs []attribute, updates []putUpdate) (result []attribute) {
+	// Values for attributes being replaced are removed first, so that several
+	// values supplied for the same attribute in a single request all survive.
+	replaced := make(map[string]bool)
+	for _, u := range updates {
+		if u.replace {
+			replaced[u.Name] = true
+		}
+	}
+
+	for _, a := range attrs {
+		if !replaced[a.Name] {
+			result = append(result, a)
+		}
+	}
+
+	// Name/value pairs are unique within an item.
+	for _, u := range updates {
+		if !containsAttribute(result, u.attribute) {
+			result = append(result, u.attribute)
+		}
+	}
+
+	return
+}
+
+// Apply the supplied deletes to a list of attributes, returning the new list.
+// If there are no deletes, all attributes are removed.
+func applyDelete(
+	attrs []attribute,
+	deletes []deleteUpdate) (result []attribute) {
+	if len(deletes) == 0 {
+		return nil
+	}
+
+	for _, a := range attrs {
+		deleted := false
+		for _, d := range deletes {
+			if d.name == a.Name && (d.value == nil || *d.value == a.Value) {
+				deleted = true
+				break
+			}
+		}
+
+		if !deleted {
+			result = append(result, a)
+		}
+	}
+
+	return
+}
+
+// Store the supplied attributes for the named item, removing the item if
+// there are none.
+func (d *domain) setItem(name string, attrs []attribute) {
+	if len(attrs) == 0 {
+		delete(d.items, name)
+		return
+	}
+
+	d.items[name] = attrs
+}
+
+func (s *Server) putAttributes(
+	params map[string]string) (response, *serverError) {
+	d, e := s.findDomain(params)
+	if e != nil {
+		return nil, e
+	}
+
+	name, e := parseItemName(params)
+	if e != nil {
+		return nil, e
+	}
+
+	updates, e := parsePutUpdates(params)
+	if e != nil {
+		return nil, e
+	}
+
+	p, e := parsePrecondition(params)
+	if e != nil {
+		return nil, e
+	}
+
+	if e := checkPrecondition(p, d.items[name]); e != nil {
+		return nil, e
+	}
+
+	d.setItem(name, applyPut(d.items[name], updates))
+	return nil, nil
+}
+
+func (s *Server) deleteAttributes(
+	params map[string]string) (response, *serverError) {
+	d, e := s.findDomain(params)
+	if e != nil {
+		return nil, e
+	}
+
+	name, e := parseItemName(params)
+	if e != nil {
+		return nil, e
+	}
+
+	deletes, e := parseDeleteUpdates(params)
+	if e != nil {
+		return nil, e
+	}
+
+	p, e := parsePrecondition(params)
+	if e != nil {
+		return nil, e
+	}
+
+	if e := checkPrecondition(p, d.items[name]); e != nil {
+		return nil, e
+	}
+
+	d.setItem(name, applyDelete(d.items[name], deletes))
+	return nil, nil
+}
+
+// Parse the items of a batch request, returning their names and the
+// parameters for each, in request order.
+func parseBatchItems(params map[string]string) (
+	names []string,
+	itemParams []map[string]string,
+	e *serverError) {
+	indices, groups := groupParams(params, "Item.")
+	if len(indices) == 0 {
+		e = newError(400, "MissingParameter", "No items")
+		return
+	}
+
+	if len(indices) > maxItemsPerBatch {
+		e = newError(
+			400,
+			"NumberSubmittedItemsExceeded",
+			"Too many items in a single call. Up to 25 items per call allowed.")
+
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, n := range indices {
+		group := groups[n]
+
+		var name string
+		if name, e = parseItemName(group); e != nil {
+			return
+		}
+
+		if seen[name] {
+			e = newError(
+				400,
+				"DuplicateItemName",
+				"Item %s was specified more than once.",
+				name)
+
+			return
+		}
+
+		seen[name] = true
+		names = append(names, name)
+		itemParams = append(itemParams, group)
+	}
+
+	return
+}
+
+func (s *Server) batchPutAttributes(
+	params map[string]string) (response, *serverError) {
+	d, e := s.findDomain(params)
+	if e != nil {
+		return nil, e
+	}
+
+	names, itemParams, e := parseBatchItems(params)
+	if e != nil {
+		return nil, e
+	}
+
+	// Validate everything before applying anything.
+	allUpdates := make([][]putUpdate, len(names))
+	for i, _ := range names {
+		if allUpdates[i], e = parsePutUpdates(itemParams[i]); e != nil {
+			return nil, e
+		}
+	}
+
+	for i, name := range names {
+		d.setItem(name, applyPut(d.items[name], allUpdates[i]))
+	}
+
+	return nil, nil
+}
+
+func (s *Server) batchDeleteAttributes(
+	params map[string]string) (response, *serverError) {
+	d, e := s.findDomain(params)
+	if e != nil {
+		return nil, e
+	}
+
+	names, itemParams, e := parseBatchItems(params)
+	if e != nil {
+		return nil, e
+	}
+
+	// Validate everything before applying anything.
+	allDeletes := make([][]deleteUpdate, len(names))
+	for i, _ := range names {
+		if allDeletes[i], e = parseDeleteUpdates(itemParams[i]); e != nil {
+			return nil, e
+		}
+	}
+
+	for i, name := range names {
+		d.setItem(name, applyDelete(d.items[name], allDeletes[i]))
+	}
+
+	return nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Reads
+////////////////////////////////////////////////////////////////////////
+
+type getAttributesResult struct {
+	Attributes []attribute `xml:"Attribute"`
+}
+
+type getAttributesResponse struct {
+	XMLName             xml.Name `xml:"GetAttributesResponse"`
+	Xmlns               string   `xml:"xmlns,attr"`
+	GetAttributesResult getAttributesResult
+	withMetadata
+}
+
+func (s *Server) getAttributes(
+	params map[string]string) (response, *serverError) {
+	d, e := s.findDomain(params)
+	if e != nil {
+		return nil, e
+	}
+
+	name, e := parseItemName(params)
+	if e != nil {
+		return nil, e
+	}
+
+	// Find the requested attribute names, if any.
+	wanted := make(map[string]bool)
+	for key, val := range params {
+		if strings.HasPrefix(key, "AttributeName.") {
+			wanted[val] = true
+		}
+	}
+
+	resp := &getAttributesResponse{Xmlns: xmlNamespace}
+	for _, a := range d.items[name] {
+		if len(wanted) == 0 || wanted[a.Name] {
+			resp.GetAttributesResult.Attributes =
+				append(resp.GetAttributesResult.Attributes, a)
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,818 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdbtest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The default and maximum number of items returned by a single Select
+// request.
+const (
+	defaultSelectLimit = 100
+	maxSelectLimit     = 2500
+)
+
+////////////////////////////////////////////////////////////////////////
+// Query representation
+////////////////////////////////////////////////////////////////////////
+
+type outputKind int
+
+const (
+	outputAll outputKind = iota
+	outputItemName
+	outputCount
+	outputAttributes
+)
+
+// An attribute referenced by a query: either a named attribute or the item
+// name, as with itemName().
+type attrRef struct {
+	name     string
+	itemName bool
+}
+
+// A parsed select expression.
+//
+// Reference:
+//     http://goo.gl/GTsSZ
+type query struct {
+	output outputKind
+	attrs  []string // Set only for outputAttributes
+	domain string
+	where  expr // nil if there is no where clause
+
+	sorted   bool
+	sortAttr attrRef
+	sortDesc bool
+
+	limit int
+}
+
+// A boolean expression in a where clause. This is one of *comparison,
+// *notExpr, or *binaryExpr.
+type expr interface{}
+
+// A comparison of an attribute against one or more constants. op is one of:
+//     =  !=  >  >=  <  <=  like  not like  between  in  is null  is not null
+type comparison struct {
+	attr   attrRef
+	every  bool
+	op     string
+	values []string
+}
+
+type notExpr struct {
+	e expr
+}
+
+// op is one of "and", "or", and "intersection".
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+////////////////////////////////////////////////////////////////////////
+// Lexing
+////////////////////////////////////////////////////////////////////////
+
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenWord             // An unquoted identifier or keyword.
+	tokenName             // A backtick-quoted name.
+	tokenString           // A single- or double-quoted string.
+	tokenPunct            // Parentheses, commas, '*', and comparison operators.
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Read a quoted string starting at s[0], where the quote character is
+// escaped within the string by doubling it. Return the unquoted string and
+// the number of bytes consumed.
+func readQuoted(s string) (unquoted string, n int, err error) {
+	quote := s[0]
+	var buf []byte
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			buf = append(buf, s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == quote {
+			buf = append(buf, quote)
+			i++
+			continue
+		}
+
+		return string(buf), i + 1, nil
+	}
+
+	return "", 0, fmt.Errorf("Unterminated quoted string: %s", s)
+}
+
+func tokenize(s string) (tokens []token, err error) {
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case unicode.IsSpace(r):
+			s = s[size:]
+
+		case r == '`':
+			name, n, err := readQuoted(s)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{tokenName, name})
+			s = s[n:]
+
+		case r == '\'' || r == '"':
+			str, n, err := readQuoted(s)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{tokenString, str})
+			s = s[n:]
+
+		case strings.HasPrefix(s, "!=") ||
+			strings.HasPrefix(s, ">=") ||
+			strings.HasPrefix(s, "<="):
+			tokens = append(tokens, token{tokenPunct, s[:2]})
+			s = s[2:]
+
+		case strings.ContainsRune("()*,=<>", r):
+			tokens = append(tokens, token{tokenPunct, s[:1]})
+			s = s[1:]
+
+		default:
+			n := strings.IndexFunc(s, func(r rune) bool { return !isWordRune(r) })
+			if n == 0 {
+				return nil, fmt.Errorf("Unexpected character: %q", r)
+			}
+
+			if n < 0 {
+				n = len(s)
+			}
+
+			tokens = append(tokens, token{tokenWord, s[:n]})
+			s = s[n:]
+		}
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Parsing
+////////////////////////////////////////////////////////////////////////
+
+type parser struct {
+	tokens []token
+}
+
+func (p *parser) peek() token {
+	if len(p.tokens) == 0 {
+		return token{kind: tokenEOF}
+	}
+
+	return p.tokens[0]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+
+	return t
+}
+
+// Is the next token the given keyword (case-insensitively)?
+func (p *parser) atKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) atPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == punct
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.atKeyword(keyword) {
+		return fmt.Errorf("Expected %q, got %q", keyword, p.peek().text)
+	}
+
+	p.next()
+	return nil
+}
+
+func (p *parser) expectPunct(punct string) error {
+	if !p.atPunct(punct) {
+		return fmt.Errorf("Expected %q, got %q", punct, p.peek().text)
+	}
+
+	p.next()
+	return nil
+}
+
+func (p *parser) parseName() (string, error) {
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenName {
+		return "", fmt.Errorf("Expected a name, got %q", t.text)
+	}
+
+	return t.text, nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokenString {
+		return "", fmt.Errorf("Expected a quoted value, got %q", t.text)
+	}
+
+	return t.text, nil
+}
+
+// Parse an attribute reference, which may be wrapped in every().
+func (p *parser) parseAttrRef() (ref attrRef, every bool, err error) {
+	if p.atKeyword("every") {
+		p.next()
+		if err = p.expectPunct("("); err != nil {
+			return
+		}
+
+		if ref, _, err = p.parseAttrRef(); err != nil {
+			return
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return
+		}
+
+		every = true
+		return
+	}
+
+	if p.atKeyword("itemName") {
+		p.next()
+		if err = p.expectPunct("("); err != nil {
+			return
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return
+		}
+
+		ref.itemName = true
+		return
+	}
+
+	ref.name, err = p.parseName()
+	return
+}
+
+func (p *parser) parseComparison() (e expr, err error) {
+	c := &comparison{}
+	if c.attr, c.every, err = p.parseAttrRef(); err != nil {
+		return
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == tokenPunct:
+		switch t.text {
+		case "=", "!=", ">", ">=", "<", "<=":
+		default:
+			return nil, fmt.Errorf("Expected an operator, got %q", t.text)
+		}
+
+		c.op = t.text
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		c.values = []string{value}
+
+	case t.kind == tokenWord && strings.EqualFold(t.text, "like"):
+		c.op = "like"
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		c.values = []string{value}
+
+	case t.kind == tokenWord && strings.EqualFold(t.text, "not"):
+		if err = p.expectKeyword("like"); err != nil {
+			return
+		}
+
+		c.op = "not like"
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		c.values = []string{value}
+
+	case t.kind == tokenWord && strings.EqualFold(t.text, "between"):
+		c.op = "between"
+		lower, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectKeyword("and"); err != nil {
+			return nil, err
+		}
+
+		upper, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		c.values = []string{lower, upper}
+
+	case t.kind == tokenWord && strings.EqualFold(t.text, "in"):
+		c.op = "in"
+		if err = p.expectPunct("("); err != nil {
+			return
+		}
+
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+
+			c.values = append(c.values, value)
+			if !p.atPunct(",") {
+				break
+			}
+
+			p.next()
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return
+		}
+
+	case t.kind == tokenWord && strings.EqualFold(t.text, "is"):
+		c.op = "is null"
+		if p.atKeyword("not") {
+			p.next()
+			c.op = "is not null"
+		}
+
+		if err = p.expectKeyword("null"); err != nil {
+			return
+		}
+
+	default:
+		return nil, fmt.Errorf("Expected an operator, got %q", t.text)
+	}
+
+	return c, nil
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	if p.atKeyword("not") {
+		p.next()
+		e, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notExpr{e}, nil
+	}
+
+	if p.atPunct("(") {
+		p.next()
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+
+		return e, nil
+	}
+
+	return p.parseComparison()
+}
+
+// Parse a sequence of sub-expressions separated by the given operator, using
+// the supplied function to parse each sub-expression.
+func (p *parser) parseBinary(
+	op string,
+	parseSub func() (expr, error)) (e expr, err error) {
+	if e, err = parseSub(); err != nil {
+		return
+	}
+
+	for p.atKeyword(op) {
+		p.next()
+
+		var right expr
+		if right, err = parseSub(); err != nil {
+			return
+		}
+
+		e = &binaryExpr{op, e, right}
+	}
+
+	return
+}
+
+// Operators bind in the following order, from tightest to loosest:
+//     not
+//     and
+//     or
+//     intersection
+func (p *parser) parseExpr() (expr, error) {
+	parseAnd := func() (expr, error) {
+		return p.parseBinary("and", p.parsePrimary)
+	}
+
+	parseOr := func() (expr, error) {
+		return p.parseBinary("or", parseAnd)
+	}
+
+	return p.parseBinary("intersection", parseOr)
+}
+
+func (p *parser) parseOutputList(q *query) (err error) {
+	switch {
+	case p.atPunct("*"):
+		p.next()
+		q.output = outputAll
+
+	case p.atKeyword("itemName"):
+		p.next()
+		if err = p.expectPunct("("); err != nil {
+			return
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return
+		}
+
+		q.output = outputItemName
+
+	case p.atKeyword("count"):
+		p.next()
+		if err = p.expectPunct("("); err != nil {
+			return
+		}
+
+		if err = p.expectPunct("*"); err != nil {
+			return
+		}
+
+		if err = p.expectPunct(")"); err != nil {
+			return
+		}
+
+		q.output = outputCount
+
+	default:
+		q.output = outputAttributes
+		for {
+			var name string
+			if name, err = p.parseName(); err != nil {
+				return
+			}
+
+			q.attrs = append(q.attrs, name)
+			if !p.atPunct(",") {
+				break
+			}
+
+			p.next()
+		}
+	}
+
+	return
+}
+
+// Parse the supplied select expression, returning an error if it is not
+// syntactically valid.
+func parseQuery(s string) (q *query, err error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return
+	}
+
+	p := &parser{tokens}
+	q = &query{limit: defaultSelectLimit}
+
+	// select <output list>
+	if err = p.expectKeyword("select"); err != nil {
+		return
+	}
+
+	if err = p.parseOutputList(q); err != nil {
+		return
+	}
+
+	// from <domain>
+	if err = p.expectKeyword("from"); err != nil {
+		return
+	}
+
+	if q.domain, err = p.parseName(); err != nil {
+		return
+	}
+
+	// [where <expression>]
+	if p.atKeyword("where") {
+		p.next()
+		if q.where, err = p.parseExpr(); err != nil {
+			return
+		}
+	}
+
+	// [order by <attribute> [asc|desc]]
+	if p.atKeyword("order") {
+		p.next()
+		if err = p.expectKeyword("by"); err != nil {
+			return
+		}
+
+		q.sorted = true
+		var every bool
+		if q.sortAttr, every, err = p.parseAttrRef(); err != nil {
+			return
+		}
+
+		if every {
+			err = fmt.Errorf("every() may not be used in a sort expression.")
+			return
+		}
+
+		switch {
+		case p.atKeyword("asc"):
+			p.next()
+		case p.atKeyword("desc"):
+			p.next()
+			q.sortDesc = true
+		}
+	}
+
+	// [limit <n>]
+	if p.atKeyword("limit") {
+		p.next()
+		t := p.next()
+		if q.limit, err = strconv.Atoi(t.text); err != nil || t.kind != tokenWord {
+			err = fmt.Errorf("Invalid limit: %q", t.text)
+			return
+		}
+	}
+
+	if p.peek().kind != tokenEOF {
+		err = fmt.Errorf("Unexpected trailing input: %q", p.peek().text)
+		return
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Evaluation
+////////////////////////////////////////////////////////////////////////
+
+// Convert a like pattern, in which '%' matches any sequence of characters,
+// to an anchored regular expression.
+func likeRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "%")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.MustCompile("^(?s:" + strings.Join(parts, ".*") + ")$")
+}
+
+// Does the single value v satisfy the supplied comparison? Comparisons are
+// lexicographic.
+func compareValue(c *comparison, v string) bool {
+	switch c.op {
+	case "=":
+		return v == c.values[0]
+	case "!=":
+		return v != c.values[0]
+	case ">":
+		return v > c.values[0]
+	case ">=":
+		return v >= c.values[0]
+	case "<":
+		return v < c.values[0]
+	case "<=":
+		return v <= c.values[0]
+	case "like":
+		return likeRegexp(c.values[0]).MatchString(v)
+	case "not like":
+		return !likeRegexp(c.values[0]).MatchString(v)
+	case "between":
+		return v >= c.values[0] && v <= c.values[1]
+	case "in":
+		for _, candidate := range c.values {
+			if v == candidate {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	panic(fmt.Sprintf("Unknown operator: %s", c.op))
+}
+
+// Return the values of the referenced attribute for the supplied item.
+func refValues(ref attrRef, name string, attrs []attribute) (values []string) {
+	if ref.itemName {
+		return []string{name}
+	}
+
+	for _, a := range attrs {
+		if a.Name == ref.name {
+			values = append(values, a.Value)
+		}
+	}
+
+	return
+}
+
+// If the supplied expression consists only of comparisons against a single
+// ordinary attribute, return that attribute's name. Such expressions are
+// evaluated against each of the attribute's values in turn, so that e.g.
+//
+//     foo > '1' and foo < '3'
+//
+// matches only items with a single value of foo in that range. (Use
+// intersection to match items with separate values satisfying each.)
+func singleAttribute(e expr) (name string, ok bool) {
+	switch e := e.(type) {
+	case *comparison:
+		if e.attr.itemName ||
+			e.every ||
+			e.op == "is null" ||
+			e.op == "is not null" {
+			return "", false
+		}
+
+		return e.attr.name, true
+
+	case *notExpr:
+		return singleAttribute(e.e)
+
+	case *binaryExpr:
+		if e.op == "intersection" {
+			return "", false
+		}
+
+		left, ok := singleAttribute(e.left)
+		if !ok {
+			return "", false
+		}
+
+		right, ok := singleAttribute(e.right)
+		if !ok || right != left {
+			return "", false
+		}
+
+		return left, true
+	}
+
+	panic(fmt.Sprintf("Unknown expression: %v", e))
+}
+
+// Evaluate an expression for which singleAttribute returned true against a
+// single value of the attribute.
+func evalForValue(e expr, v string) bool {
+	switch e := e.(type) {
+	case *comparison:
+		return compareValue(e, v)
+
+	case *notExpr:
+		return !evalForValue(e.e, v)
+
+	case *binaryExpr:
+		if e.op == "and" {
+			return evalForValue(e.left, v) && evalForValue(e.right, v)
+		}
+
+		return evalForValue(e.left, v) || evalForValue(e.right, v)
+	}
+
+	panic(fmt.Sprintf("Unknown expression: %v", e))
+}
+
+// Does the named item with the given attributes match the supplied
+// expression?
+func matches(e expr, name string, attrs []attribute) bool {
+	// Expressions involving a single attribute are evaluated per value.
+	if attrName, ok := singleAttribute(e); ok {
+		for _, v := range refValues(attrRef{name: attrName}, name, attrs) {
+			if evalForValue(e, v) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch e := e.(type) {
+	case *comparison:
+		values := refValues(e.attr, name, attrs)
+		switch {
+		case e.op == "is null":
+			return len(values) == 0
+
+		case e.op == "is not null":
+			return len(values) > 0
+
+		case e.every:
+			for _, v := range values {
+				if !compareValue(e, v) {
+					return false
+				}
+			}
+
+			return len(values) > 0
+
+		default:
+			for _, v := range values {
+				if compareValue(e, v) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+	case *notExpr:
+		return !matches(e.e, name, attrs)
+
+	case *binaryExpr:
+		if e.op == "or" {
+			return matches(e.left, name, attrs) || matches(e.right, name, attrs)
+		}
+
+		return matches(e.left, name, attrs) && matches(e.right, name, attrs)
+	}
+
+	panic(fmt.Sprintf("Unknown expression: %v", e))
+}
+
+// Does the supplied expression contain a predicate on the given attribute
+// that is not "is null"? SimpleDB requires this of sort attributes.
+func constrains(e expr, ref attrRef) bool {
+	switch e := e.(type) {
+	case nil:
+		return false
+
+	case *comparison:
+		return e.attr == ref && e.op != "is null"
+
+	case *notExpr:
+		return constrains(e.e, ref)
+
+	case *binaryExpr:
+		return constrains(e.left, ref) || constrains(e.right, ref)
+	}
+
+	panic(fmt.Sprintf("Unknown expression: %v", e))
+}
@@ -0,0 +1,191 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdbtest
+
+import (
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestQuery(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type QueryTest struct {
+}
+
+func init() { RegisterTestSuite(&QueryTest{}) }
+
+// Parse the supplied where clause and evaluate it against an item with the
+// given attributes, given as alternating names and values.
+func whereMatches(where string, nameValues ...string) bool {
+	q, err := parseQuery("select * from foo where " + where)
+	AssertEq(nil, err)
+
+	var attrs []attribute
+	for i := 0; i+1 < len(nameValues); i += 2 {
+		attrs = append(attrs, attribute{nameValues[i], nameValues[i+1]})
+	}
+
+	return matches(q.where, "some_item", attrs)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Parsing
+////////////////////////////////////////////////////////////////////////
+
+func (t *QueryTest) SyntaxErrors() {
+	queries := []string{
+		"",
+		"select",
+		"select foo bar baz",
+		"select * from",
+		"select * from foo where",
+		"select * from foo where bar",
+		"select * from foo where bar = baz",
+		"select * from foo where bar = 'baz",
+		"select * from foo where (bar = 'baz'",
+		"select * from foo order bar",
+		"select * from foo limit taco",
+		"select * from foo taco",
+		"select count(bar) from foo",
+	}
+
+	for _, q := range queries {
+		_, err := parseQuery(q)
+		ExpectNe(nil, err, "Query: %s", q)
+	}
+}
+
+func (t *QueryTest) OutputLists() {
+	q, err := parseQuery("SELECT * FROM foo")
+	AssertEq(nil, err)
+	ExpectEq(outputAll, q.output)
+	ExpectEq("foo", q.domain)
+	ExpectEq(defaultSelectLimit, q.limit)
+
+	q, err = parseQuery("select itemName() from `foo.bar`")
+	AssertEq(nil, err)
+	ExpectEq(outputItemName, q.output)
+	ExpectEq("foo.bar", q.domain)
+
+	q, err = parseQuery("select count(*) from foo")
+	AssertEq(nil, err)
+	ExpectEq(outputCount, q.output)
+
+	q, err = parseQuery("select bar, `baz qux` from foo")
+	AssertEq(nil, err)
+	ExpectEq(outputAttributes, q.output)
+	ExpectThat(q.attrs, ElementsAre("bar", "baz qux"))
+}
+
+func (t *QueryTest) SortAndLimit() {
+	q, err := parseQuery(
+		"select * from foo where bar > '1' order by bar desc limit 17")
+
+	AssertEq(nil, err)
+	ExpectTrue(q.sorted)
+	ExpectEq("bar", q.sortAttr.name)
+	ExpectTrue(q.sortDesc)
+	ExpectEq(17, q.limit)
+}
+
+func (t *QueryTest) QuotedStrings() {
+	q, err := parseQuery(`select * from foo where a = 'it''s' or b = "say ""hi"""`)
+	AssertEq(nil, err)
+
+	e := q.where.(*binaryExpr)
+	ExpectEq("it's", e.left.(*comparison).values[0])
+	ExpectEq(`say "hi"`, e.right.(*comparison).values[0])
+}
+
+////////////////////////////////////////////////////////////////////////
+// Evaluation
+////////////////////////////////////////////////////////////////////////
+
+func (t *QueryTest) Comparisons() {
+	ExpectTrue(whereMatches("a = '1'", "a", "1"))
+	ExpectFalse(whereMatches("a = '1'", "a", "2"))
+	ExpectTrue(whereMatches("a != '1'", "a", "2"))
+	ExpectTrue(whereMatches("a > '09'", "a", "1"))
+	ExpectFalse(whereMatches("a > '1'", "a", "09"))
+	ExpectTrue(whereMatches("a >= '1'", "a", "1"))
+	ExpectTrue(whereMatches("a < '1'", "a", "09"))
+	ExpectTrue(whereMatches("a <= '1'", "a", "1"))
+	ExpectFalse(whereMatches("a = '1'", "b", "1"))
+}
+
+func (t *QueryTest) Like() {
+	ExpectTrue(whereMatches("a like 'ta%'", "a", "taco"))
+	ExpectTrue(whereMatches("a like '%co'", "a", "taco"))
+	ExpectTrue(whereMatches("a like 't%o'", "a", "taco"))
+	ExpectFalse(whereMatches("a like 'ta%'", "a", "burrito"))
+	ExpectFalse(whereMatches("a like 't.co'", "a", "taco"))
+	ExpectTrue(whereMatches("a not like 'ta%'", "a", "burrito"))
+}
+
+func (t *QueryTest) BetweenAndIn() {
+	ExpectTrue(whereMatches("a between '1' and '3'", "a", "2"))
+	ExpectTrue(whereMatches("a between '1' and '3'", "a", "3"))
+	ExpectFalse(whereMatches("a between '1' and '3'", "a", "4"))
+
+	ExpectTrue(whereMatches("a in ('1', '2')", "a", "2"))
+	ExpectFalse(whereMatches("a in ('1', '2')", "a", "3"))
+}
+
+func (t *QueryTest) IsNull() {
+	ExpectTrue(whereMatches("a is null", "b", "1"))
+	ExpectFalse(whereMatches("a is null", "a", "1"))
+	ExpectTrue(whereMatches("a is not null", "a", "1"))
+	ExpectFalse(whereMatches("a is not null", "b", "1"))
+}
+
+func (t *QueryTest) ItemName() {
+	ExpectTrue(whereMatches("itemName() = 'some_item'"))
+	ExpectFalse(whereMatches("itemName() = 'other_item'"))
+	ExpectTrue(whereMatches("itemName() like 'some%'"))
+}
+
+func (t *QueryTest) MultiValuedAttributes() {
+	// Any value may satisfy a comparison.
+	ExpectTrue(whereMatches("a = '1'", "a", "1", "a", "2"))
+	ExpectTrue(whereMatches("a = '2'", "a", "1", "a", "2"))
+
+	// Unless every() is used.
+	ExpectFalse(whereMatches("every(a) = '1'", "a", "1", "a", "2"))
+	ExpectTrue(whereMatches("every(a) > '0'", "a", "1", "a", "2"))
+
+	// Predicates on a single attribute must be satisfied by a single value.
+	ExpectFalse(whereMatches("a = '1' and a = '2'", "a", "1", "a", "2"))
+	ExpectTrue(whereMatches("a > '0' and a < '2'", "a", "1", "a", "2"))
+
+	// Intersection lifts that restriction.
+	ExpectTrue(whereMatches("a = '1' intersection a = '2'", "a", "1", "a", "2"))
+}
+
+func (t *QueryTest) BooleanOperators() {
+	attrs := []string{"a", "1", "b", "2"}
+
+	ExpectTrue(whereMatches("a = '1' and b = '2'", attrs...))
+	ExpectFalse(whereMatches("a = '1' and b = '3'", attrs...))
+	ExpectTrue(whereMatches("a = '3' or b = '2'", attrs...))
+	ExpectFalse(whereMatches("not a = '1'", attrs...))
+	ExpectTrue(whereMatches("not (a = '3' or b = '3')", attrs...))
+	ExpectTrue(whereMatches("a = '3' or (a = '1' and b = '2')", attrs...))
+}
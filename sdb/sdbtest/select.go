@@ -0,0 +1,231 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdbtest
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type selectedItem struct {
+	Name       string
+	Attributes []attribute `xml:"Attribute"`
+}
+
+type selectResult struct {
+	Items     []selectedItem `xml:"Item"`
+	NextToken string         `xml:",omitempty"`
+}
+
+type selectResponse struct {
+	XMLName      xml.Name `xml:"SelectResponse"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	SelectResult selectResult
+	withMetadata
+}
+
+// Next tokens encode the number of matching items already returned.
+func encodeNextToken(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeNextToken(tok string) (offset int, err error) {
+	decoded, err := base64.StdEncoding.DecodeString(tok)
+	if err != nil {
+		return
+	}
+
+	if offset, err = strconv.Atoi(string(decoded)); err != nil {
+		return
+	}
+
+	if offset < 0 {
+		err = fmt.Errorf("Negative offset: %d", offset)
+		return
+	}
+
+	return
+}
+
+// The value by which an item is sorted, and whether it has one at all.
+func sortValue(
+	ref attrRef,
+	name string,
+	attrs []attribute) (v string, ok bool) {
+	values := refValues(ref, name, attrs)
+	if len(values) == 0 {
+		return "", false
+	}
+
+	// Multi-valued attributes sort by their smallest value.
+	sort.Strings(values)
+	return values[0], true
+}
+
+// Return the names of the items in the domain that match the supplied query,
+// in the order in which they should be returned.
+func findMatches(q *query, d *domain) (names []string) {
+	for name, attrs := range d.items {
+		if q.where != nil && !matches(q.where, name, attrs) {
+			continue
+		}
+
+		// Items without a value for the sort attribute are excluded.
+		if q.sorted {
+			if _, ok := sortValue(q.sortAttr, name, attrs); !ok {
+				continue
+			}
+		}
+
+		names = append(names, name)
+	}
+
+	// Order by item name by default, to give a stable order across requests.
+	sort.Strings(names)
+
+	if q.sorted {
+		sort.SliceStable(names, func(i, j int) bool {
+			vi, _ := sortValue(q.sortAttr, names[i], d.items[names[i]])
+			vj, _ := sortValue(q.sortAttr, names[j], d.items[names[j]])
+			if q.sortDesc {
+				return vi > vj
+			}
+
+			return vi < vj
+		})
+	}
+
+	return
+}
+
+// Select the appropriate attributes of the named item for output.
+func selectAttributes(q *query, attrs []attribute) (result []attribute) {
+	switch q.output {
+	case outputAll:
+		return attrs
+
+	case outputAttributes:
+		for _, a := range attrs {
+			for _, wanted := range q.attrs {
+				if a.Name == wanted {
+					result = append(result, a)
+					break
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func (s *Server) selectItems(
+	params map[string]string) (response, *serverError) {
+	// Parse the query.
+	expression, ok := params["SelectExpression"]
+	if !ok {
+		return nil, newError(
+			400,
+			"MissingParameter",
+			"The request must contain the parameter SelectExpression")
+	}
+
+	q, err := parseQuery(expression)
+	if err != nil {
+		return nil, newError(
+			400,
+			"InvalidQueryExpression",
+			"The specified query expression syntax is not valid. (%v)",
+			err)
+	}
+
+	if q.limit < 1 || q.limit > maxSelectLimit {
+		return nil, newError(
+			400,
+			"InvalidParameterValue",
+			"Value (%d) for parameter Limit is invalid. The maximum limit is %d.",
+			q.limit,
+			maxSelectLimit)
+	}
+
+	if q.sorted && !constrains(q.where, q.sortAttr) {
+		return nil, newError(
+			400,
+			"InvalidSortExpression",
+			"The sort attribute must be present in at least one of the "+
+				"predicates, and the predicate cannot contain the is null operator.")
+	}
+
+	d, ok := s.domains[q.domain]
+	if !ok {
+		return nil, newError(
+			400,
+			"NoSuchDomain",
+			"The specified domain does not exist.")
+	}
+
+	// Skip past items returned by previous requests.
+	offset := 0
+	if tok, ok := params["NextToken"]; ok {
+		if offset, err = decodeNextToken(tok); err != nil {
+			return nil, newError(
+				400,
+				"InvalidNextToken",
+				"The specified next token is not valid.")
+		}
+	}
+
+	names := findMatches(q, d)
+	if offset > len(names) {
+		offset = len(names)
+	}
+
+	names = names[offset:]
+
+	// Apply the limit.
+	resp := &selectResponse{Xmlns: xmlNamespace}
+	if len(names) > q.limit {
+		names = names[:q.limit]
+		resp.SelectResult.NextToken = encodeNextToken(offset + q.limit)
+	}
+
+	// Count queries return a single pseudo-item.
+	if q.output == outputCount {
+		resp.SelectResult.Items = []selectedItem{
+			selectedItem{
+				Name: "Domain",
+				Attributes: []attribute{
+					attribute{Name: "Count", Value: strconv.Itoa(len(names))},
+				},
+			},
+		}
+
+		return resp, nil
+	}
+
+	for _, name := range names {
+		item := selectedItem{
+			Name:       name,
+			Attributes: selectAttributes(q, d.items[name]),
+		}
+
+		resp.SelectResult.Items = append(resp.SelectResult.Items, item)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,468 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdbtest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/time"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	sys_time "time"
+)
+
+const xmlNamespace = "http://sdb.amazonaws.com/doc/2009-04-15/"
+
+// The box usage reported for every request. Real SimpleDB varies this
+// according to the work done.
+const boxUsage = "0.0000219907"
+
+// The maximum difference between a request's timestamp and the server's clock
+// that SimpleDB will accept.
+const maxClockSkew = 15 * sys_time.Minute
+
+var domainNameRe = regexp.MustCompile(`^[-a-zA-Z0-9_.]{3,255}$`)
+
+// Server is a fake SimpleDB server listening on a local port. It stores all of
+// its data in memory, and is safe for concurrent access.
+type Server struct {
+	httpServer *httptest.Server
+	clock      time.Clock
+
+	mutex         sync.Mutex
+	keys          map[string]aws.AccessKey // Protected by mutex
+	domains       map[string]*domain       // Protected by mutex
+	nextRequestId uint64                   // Protected by mutex
+}
+
+type attribute struct {
+	Name  string
+	Value string
+}
+
+type domain struct {
+	// The attributes of each item, in the order in which they were added.
+	// Items with no attributes are not present.
+	items map[string][]attribute
+}
+
+// NewServer starts a fake server that accepts requests signed with any of the
+// supplied access keys. Requests signed with a key that has a session token
+// must carry that token. The caller must call Close when done with it.
+func NewServer(keys ...aws.AccessKey) *Server {
+	s := &Server{
+		clock:   time.RealClock(),
+		keys:    make(map[string]aws.AccessKey),
+		domains: make(map[string]*domain),
+	}
+
+	for _, key := range keys {
+		s.keys[key.Id] = key
+	}
+
+	s.httpServer = httptest.NewServer(s)
+	return s
+}
+
+// Endpoint returns the URL at which the server is listening, suitable for
+// passing to sdb.NewSimpleDBAtEndpoint.
+func (s *Server) Endpoint() *url.URL {
+	endpoint, err := url.Parse(s.httpServer.URL)
+	if err != nil {
+		panic(fmt.Sprintf("url.Parse: %v", err))
+	}
+
+	return endpoint
+}
+
+// Close shuts down the server, blocking until all outstanding requests have
+// completed.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Responses
+////////////////////////////////////////////////////////////////////////
+
+type responseMetadata struct {
+	RequestId string
+	BoxUsage  string
+}
+
+// A successful response body. Implementations embed withMetadata.
+type response interface {
+	setMetadata(md responseMetadata)
+}
+
+type withMetadata struct {
+	ResponseMetadata responseMetadata
+}
+
+func (m *withMetadata) setMetadata(md responseMetadata) {
+	m.ResponseMetadata = md
+}
+
+// An error to be returned to the client.
+type serverError struct {
+	statusCode int
+	code       string
+	message    string
+}
+
+func newError(
+	statusCode int,
+	code string,
+	format string,
+	v ...interface{}) *serverError {
+	return &serverError{statusCode, code, fmt.Sprintf(format, v...)}
+}
+
+// An error response, in the format returned by SimpleDB.
+//
+// Reference:
+//     http://goo.gl/b8VDt
+type errorResponse struct {
+	XMLName   xml.Name       `xml:"Response"`
+	Errors    []errorElement `xml:"Errors>Error"`
+	RequestID string
+}
+
+type errorElement struct {
+	Code     string
+	Message  string
+	BoxUsage string
+}
+
+func writeXml(w http.ResponseWriter, statusCode int, v interface{}) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("xml.Marshal: %v", err))
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, requestId string, e *serverError) {
+	resp := errorResponse{
+		Errors:    []errorElement{{e.code, e.message, boxUsage}},
+		RequestID: requestId,
+	}
+
+	writeXml(w, e.statusCode, resp)
+}
+
+// The response to an action that returns nothing but metadata.
+type emptyResponse struct {
+	XMLName xml.Name
+	withMetadata
+}
+
+////////////////////////////////////////////////////////////////////////
+// Request handling
+////////////////////////////////////////////////////////////////////////
+
+// A handler for a particular action. It returns a value to be marshalled as
+// the response body (or nil if there is nothing but metadata to return), or
+// an error.
+type actionHandler func(
+	s *Server,
+	params map[string]string) (response, *serverError)
+
+var actionHandlers = map[string]actionHandler{
+	"CreateDomain":          (*Server).createDomain,
+	"DeleteDomain":          (*Server).deleteDomain,
+	"PutAttributes":         (*Server).putAttributes,
+	"BatchPutAttributes":    (*Server).batchPutAttributes,
+	"GetAttributes":         (*Server).getAttributes,
+	"DeleteAttributes":      (*Server).deleteAttributes,
+	"BatchDeleteAttributes": (*Server).batchDeleteAttributes,
+	"Select":                (*Server).selectItems,
+}
+
+// ServeHTTP implements http.Handler. Most users will not need to call it
+// directly.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextRequestId++
+	requestId := fmt.Sprintf("%08x-0000-0000-0000-000000000000", s.nextRequestId)
+
+	// Report the time according to our clock, which clients use to correct
+	// for skew.
+	w.Header().Set("Date", s.clock.Now().UTC().Format(http.TimeFormat))
+
+	// Parse parameters, from either the query string or the POST body.
+	if err := r.ParseForm(); err != nil {
+		writeError(w, requestId, newError(
+			400,
+			"MalformedQueryString",
+			"%v",
+			err))
+		return
+	}
+
+	params := make(map[string]string)
+	for key, values := range r.Form {
+		params[key] = values[0]
+	}
+
+	// Make sure the request is properly signed.
+	if e := s.authenticate(r, params); e != nil {
+		writeError(w, requestId, e)
+		return
+	}
+
+	// Dispatch.
+	action := params["Action"]
+	handler, ok := actionHandlers[action]
+	if !ok {
+		e := newError(
+			400,
+			"InvalidAction",
+			"The action %s is not valid for this web service.",
+			action)
+		writeError(w, requestId, e)
+		return
+	}
+
+	resp, e := handler(s, params)
+	if e != nil {
+		writeError(w, requestId, e)
+		return
+	}
+
+	// Fill in metadata and write out the response.
+	if resp == nil {
+		resp = &emptyResponse{
+			XMLName: xml.Name{Space: xmlNamespace, Local: action + "Response"},
+		}
+	}
+
+	resp.setMetadata(responseMetadata{requestId, boxUsage})
+	writeXml(w, 200, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Authentication
+////////////////////////////////////////////////////////////////////////
+
+// Encode a string as required by Signature Version 2: every byte other than
+// the RFC 3986 unreserved characters is percent-encoded.
+func encodeForSignature(s string) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z',
+			c >= 'a' && c <= 'z',
+			c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buf = append(buf, c)
+		default:
+			buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+
+	return string(buf)
+}
+
+// Compute the string to sign for the supplied request, as the server sees it.
+//
+// Reference:
+//     http://goo.gl/0aD5S
+func stringToSign(r *http.Request, params map[string]string) string {
+	var keys []string
+	for key, _ := range params {
+		if key != "Signature" {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = encodeForSignature(key) + "=" + encodeForSignature(params[key])
+	}
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join(
+		[]string{
+			r.Method,
+			strings.ToLower(r.Host),
+			path,
+			strings.Join(parts, "&"),
+		},
+		"\n")
+}
+
+func (s *Server) authenticate(
+	r *http.Request,
+	params map[string]string) *serverError {
+	// Check the signature parameters.
+	if params["SignatureVersion"] != "2" {
+		return newError(
+			400,
+			"InvalidParameterValue",
+			"Value (%s) for parameter SignatureVersion is invalid.",
+			params["SignatureVersion"])
+	}
+
+	var newHash func() hash.Hash
+	switch params["SignatureMethod"] {
+	case "HmacSHA1":
+		newHash = sha1.New
+	case "HmacSHA256":
+		newHash = sha256.New
+	default:
+		return newError(
+			400,
+			"InvalidParameterValue",
+			"Value (%s) for parameter SignatureMethod is invalid.",
+			params["SignatureMethod"])
+	}
+
+	// Check the timestamp.
+	timestamp, ok := params["Timestamp"]
+	if !ok {
+		return newError(
+			400,
+			"MissingParameter",
+			"The request must contain the parameter Timestamp")
+	}
+
+	t, err := sys_time.Parse(sys_time.RFC3339, timestamp)
+	if err != nil {
+		return newError(
+			400,
+			"InvalidParameterValue",
+			"Value (%s) for parameter Timestamp is invalid.",
+			timestamp)
+	}
+
+	skew := s.clock.Now().Sub(t)
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		return newError(
+			403,
+			"RequestExpired",
+			"Request has expired. Timestamp date is %s",
+			timestamp)
+	}
+
+	// Find the key.
+	key, ok := s.keys[params["AWSAccessKeyId"]]
+	if !ok {
+		return newError(
+			403,
+			"InvalidClientTokenId",
+			"The AWS Access Key Id you provided does not exist in our records.")
+	}
+
+	// Temporary credentials must be accompanied by their session token.
+	if params["SecurityToken"] != key.SessionToken {
+		return newError(
+			403,
+			"InvalidClientTokenId",
+			"The security token included in the request is invalid.")
+	}
+
+	// Check the signature.
+	h := hmac.New(newHash, []byte(key.Secret))
+	h.Write([]byte(stringToSign(r, params)))
+	expected := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	if params["Signature"] != expected {
+		return newError(
+			403,
+			"SignatureDoesNotMatch",
+			"The request signature we calculated does not match the signature "+
+				"you provided. Check your AWS Secret Access Key and signing "+
+				"method.")
+	}
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Domains
+////////////////////////////////////////////////////////////////////////
+
+// Find the domain named by the DomainName parameter.
+func (s *Server) findDomain(params map[string]string) (*domain, *serverError) {
+	name, ok := params["DomainName"]
+	if !ok {
+		return nil, newError(
+			400,
+			"MissingParameter",
+			"The request must contain the parameter DomainName")
+	}
+
+	d, ok := s.domains[name]
+	if !ok {
+		return nil, newError(
+			400,
+			"NoSuchDomain",
+			"The specified domain does not exist.")
+	}
+
+	return d, nil
+}
+
+func (s *Server) createDomain(
+	params map[string]string) (response, *serverError) {
+	name := params["DomainName"]
+	if !domainNameRe.MatchString(name) {
+		return nil, newError(
+			400,
+			"InvalidParameterValue",
+			"Value (%s) for parameter DomainName is invalid.",
+			name)
+	}
+
+	// Creating a domain that already exists is not an error.
+	if _, ok := s.domains[name]; !ok {
+		s.domains[name] = &domain{items: make(map[string][]attribute)}
+	}
+
+	return nil, nil
+}
+
+func (s *Server) deleteDomain(
+	params map[string]string) (response, *serverError) {
+	// Deleting a domain that doesn't exist is not an error.
+	delete(s.domains, params["DomainName"])
+	return nil, nil
+}
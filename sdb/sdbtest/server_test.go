@@ -0,0 +1,350 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdbtest_test
+
+import (
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/sdb"
+	"github.com/jacobsa/aws/sdb/sdbtest"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+)
+
+func TestServer(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type ServerTest struct {
+	key    aws.AccessKey
+	server *sdbtest.Server
+	db     sdb.SimpleDB
+	domain sdb.Domain
+}
+
+func init() { RegisterTestSuite(&ServerTest{}) }
+
+func (t *ServerTest) SetUp(i *TestInfo) {
+	var err error
+
+	t.key = aws.AccessKey{Id: "some_id", Secret: "some_secret"}
+	t.server = sdbtest.NewServer(t.key)
+
+	t.db, err = sdb.NewSimpleDBAtEndpoint(t.server.Endpoint(), t.key)
+	AssertEq(nil, err)
+
+	t.domain, err = t.db.OpenDomain("some_domain")
+	AssertEq(nil, err)
+}
+
+func (t *ServerTest) TearDown() {
+	t.server.Close()
+}
+
+// Put the supplied number of items, named item.000 and so on, each with a
+// single attribute "n" whose value is the item's number.
+func (t *ServerTest) putNumberedItems(n int) {
+	for i := 0; i < n; i++ {
+		err := t.domain.PutAttributes(
+			sdb.ItemName(fmt.Sprintf("item.%03d", i)),
+			[]sdb.PutUpdate{
+				sdb.PutUpdate{Name: "n", Value: fmt.Sprintf("%03d", i)},
+			},
+			nil)
+
+		AssertEq(nil, err)
+	}
+}
+
+func itemNames(results []sdb.SelectedItem) (names []string) {
+	for _, r := range results {
+		names = append(names, string(r.Name))
+	}
+
+	return
+}
+
+func makeStrPtr(s string) *string { return &s }
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *ServerTest) WrongSecret() {
+	wrongKey := t.key
+	wrongKey.Secret += "taco"
+
+	db, err := sdb.NewSimpleDBAtEndpoint(t.server.Endpoint(), wrongKey)
+	AssertEq(nil, err)
+
+	_, err = db.OpenDomain("some_domain")
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("SignatureDoesNotMatch")))
+}
+
+func (t *ServerTest) UnknownKeyId() {
+	wrongKey := t.key
+	wrongKey.Id += "taco"
+
+	db, err := sdb.NewSimpleDBAtEndpoint(t.server.Endpoint(), wrongKey)
+	AssertEq(nil, err)
+
+	_, err = db.OpenDomain("some_domain")
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("Key Id")))
+}
+
+func (t *ServerTest) DeletedDomain() {
+	AssertEq(nil, t.db.DeleteDomain(t.domain))
+
+	_, err := t.domain.GetAttributes("foo", true, nil)
+	ExpectThat(err, Error(HasSubstr("NoSuchDomain")))
+}
+
+func (t *ServerTest) PutGetAndDelete() {
+	// Put
+	err := t.domain.PutAttributes(
+		"foo",
+		[]sdb.PutUpdate{
+			sdb.PutUpdate{Name: "a", Value: "taco"},
+			sdb.PutUpdate{Name: "b", Value: "burrito", Add: true},
+			sdb.PutUpdate{Name: "b", Value: "enchilada", Add: true},
+		},
+		nil)
+
+	AssertEq(nil, err)
+
+	// Replace a and delete one value of b.
+	err = t.domain.PutAttributes(
+		"foo",
+		[]sdb.PutUpdate{sdb.PutUpdate{Name: "a", Value: "queso"}},
+		nil)
+
+	AssertEq(nil, err)
+
+	err = t.domain.DeleteAttributes(
+		"foo",
+		[]sdb.DeleteUpdate{
+			sdb.DeleteUpdate{Name: "b", Value: makeStrPtr("burrito")},
+		},
+		nil)
+
+	AssertEq(nil, err)
+
+	// Get
+	attrs, err := t.domain.GetAttributes("foo", true, nil)
+	AssertEq(nil, err)
+	ExpectThat(
+		attrs,
+		ElementsAre(
+			DeepEquals(sdb.Attribute{Name: "b", Value: "enchilada"}),
+			DeepEquals(sdb.Attribute{Name: "a", Value: "queso"}),
+		))
+
+	// Get a particular attribute.
+	attrs, err = t.domain.GetAttributes("foo", true, []string{"a"})
+	AssertEq(nil, err)
+	ExpectThat(
+		attrs,
+		ElementsAre(
+			DeepEquals(sdb.Attribute{Name: "a", Value: "queso"}),
+		))
+
+	// Delete everything.
+	err = t.domain.DeleteAttributes("foo", nil, nil)
+	AssertEq(nil, err)
+
+	attrs, err = t.domain.GetAttributes("foo", true, nil)
+	AssertEq(nil, err)
+	ExpectThat(attrs, ElementsAre())
+}
+
+func (t *ServerTest) Preconditions() {
+	err := t.domain.PutAttributes(
+		"foo",
+		[]sdb.PutUpdate{sdb.PutUpdate{Name: "a", Value: "taco"}},
+		nil)
+
+	AssertEq(nil, err)
+
+	// Wrong value.
+	err = t.domain.PutAttributes(
+		"foo",
+		[]sdb.PutUpdate{sdb.PutUpdate{Name: "b", Value: "burrito"}},
+		&sdb.Precondition{Name: "a", Value: makeStrPtr("queso")})
+
+	ExpectThat(err, Error(HasSubstr("409")))
+	ExpectThat(err, Error(HasSubstr("ConditionalCheckFailed")))
+
+	// Missing attribute.
+	err = t.domain.DeleteAttributes(
+		"foo",
+		nil,
+		&sdb.Precondition{Name: "c", Value: makeStrPtr("queso")})
+
+	ExpectThat(err, Error(HasSubstr("404")))
+	ExpectThat(err, Error(HasSubstr("AttributeDoesNotExist")))
+
+	// Attribute unexpectedly exists.
+	err = t.domain.DeleteAttributes(
+		"foo",
+		nil,
+		&sdb.Precondition{Name: "a"})
+
+	ExpectThat(err, Error(HasSubstr("409")))
+	ExpectThat(err, Error(HasSubstr("exists")))
+
+	// Nothing should have changed.
+	attrs, err := t.domain.GetAttributes("foo", true, nil)
+	AssertEq(nil, err)
+	ExpectThat(
+		attrs,
+		ElementsAre(
+			DeepEquals(sdb.Attribute{Name: "a", Value: "taco"}),
+		))
+}
+
+func (t *ServerTest) BatchPutAndDelete() {
+	err := t.domain.BatchPutAttributes(
+		sdb.BatchPutMap{
+			"foo": []sdb.PutUpdate{sdb.PutUpdate{Name: "a", Value: "taco"}},
+			"bar": []sdb.PutUpdate{sdb.PutUpdate{Name: "a", Value: "burrito"}},
+		})
+
+	AssertEq(nil, err)
+
+	err = t.domain.BatchDeleteAttributes(sdb.BatchDeleteMap{"foo": nil})
+	AssertEq(nil, err)
+
+	query := "select itemName() from some_domain"
+	results, _, err := t.db.Select(query, true, nil)
+	AssertEq(nil, err)
+	ExpectThat(itemNames(results), ElementsAre("bar"))
+}
+
+func (t *ServerTest) InvalidQuery() {
+	_, _, err := t.db.Select("select foo bar baz", true, nil)
+
+	ExpectThat(err, Error(HasSubstr("400")))
+	ExpectThat(err, Error(HasSubstr("InvalidQueryExpression")))
+	ExpectThat(err, Error(HasSubstr("syntax")))
+}
+
+func (t *ServerTest) SortAttributeNotInPredicate() {
+	query := "select * from some_domain order by n"
+	_, _, err := t.db.Select(query, true, nil)
+
+	ExpectThat(err, Error(HasSubstr("InvalidSortExpression")))
+}
+
+func (t *ServerTest) SelectWithOrderAndLimit() {
+	t.putNumberedItems(5)
+
+	query := "select itemName() from some_domain " +
+		"where n between '001' and '003' order by n desc"
+
+	results, tok, err := t.db.Select(query, true, nil)
+	AssertEq(nil, err)
+	ExpectEq(nil, tok)
+	ExpectThat(
+		itemNames(results),
+		ElementsAre("item.003", "item.002", "item.001"))
+}
+
+func (t *ServerTest) SelectWithNextToken() {
+	t.putNumberedItems(5)
+
+	query := "select n from some_domain where n >= '000' order by n limit 2"
+	var names []string
+	var tok []byte
+	var calls int
+
+	for calls = 0; calls == 0 || tok != nil; calls++ {
+		results, nextTok, err := t.db.Select(query, true, tok)
+		AssertEq(nil, err)
+		AssertLe(len(results), 2)
+
+		names = append(names, itemNames(results)...)
+		tok = nextTok
+	}
+
+	ExpectEq(3, calls)
+	ExpectThat(
+		names,
+		ElementsAre("item.000", "item.001", "item.002", "item.003", "item.004"))
+}
+
+func (t *ServerTest) SelectCount() {
+	t.putNumberedItems(5)
+
+	query := "select count(*) from some_domain where n in ('001', '004', '007')"
+	results, _, err := t.db.Select(query, true, nil)
+	AssertEq(nil, err)
+
+	ExpectThat(
+		results,
+		ElementsAre(
+			DeepEquals(
+				sdb.SelectedItem{
+					Name: "Domain",
+					Attributes: []sdb.Attribute{
+						sdb.Attribute{Name: "Count", Value: "2"},
+					},
+				},
+			),
+		))
+}
+
+func (t *ServerTest) SessionToken() {
+	tempKey := aws.AccessKey{
+		Id:           "temp_id",
+		Secret:       "temp_secret",
+		SessionToken: "some_token",
+	}
+
+	server := sdbtest.NewServer(tempKey)
+	defer server.Close()
+
+	// The right token
+	db, err := sdb.NewSimpleDBAtEndpoint(server.Endpoint(), tempKey)
+	AssertEq(nil, err)
+
+	_, err = db.OpenDomain("some_domain")
+	AssertEq(nil, err)
+
+	// The wrong token
+	wrongKey := tempKey
+	wrongKey.SessionToken = "other_token"
+
+	db, err = sdb.NewSimpleDBAtEndpoint(server.Endpoint(), wrongKey)
+	AssertEq(nil, err)
+
+	_, err = db.OpenDomain("some_domain")
+	ExpectThat(err, Error(HasSubstr("403")))
+	ExpectThat(err, Error(HasSubstr("security token")))
+
+	// No token
+	wrongKey.SessionToken = ""
+
+	db, err = sdb.NewSimpleDBAtEndpoint(server.Endpoint(), wrongKey)
+	AssertEq(nil, err)
+
+	_, err = db.OpenDomain("some_domain")
+	ExpectThat(err, Error(HasSubstr("security token")))
+}
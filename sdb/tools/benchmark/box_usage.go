@@ -0,0 +1,94 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"github.com/jacobsa/aws/sdb/conn"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The parts of a response from SimpleDB that give the box usage charged for
+// the request, which are in different places for successes and errors.
+type boxUsageDocument struct {
+	Success string   `xml:"ResponseMetadata>BoxUsage"`
+	Errors  []string `xml:"Errors>Error>BoxUsage"`
+}
+
+// Totals the box usage reported in SimpleDB's responses, by action.
+type boxUsageCounter struct {
+	mutex sync.Mutex
+
+	// The box usage for each action since the last reset.
+	//
+	// Protected by mutex
+	totals map[string]float64
+}
+
+func newBoxUsageCounter() *boxUsageCounter {
+	return &boxUsageCounter{totals: make(map[string]float64)}
+}
+
+// Return an interceptor that feeds the counter. Pass it to openDb.
+func (c *boxUsageCounter) Interceptor() conn.Interceptor {
+	return &conn.InterceptorFuncs{AfterResponseFunc: c.afterResponse}
+}
+
+func (c *boxUsageCounter) afterResponse(
+	req conn.Request,
+	latency time.Duration,
+	resp *conn.HttpResponse,
+	err error) (*conn.HttpResponse, error) {
+	if resp == nil {
+		return resp, err
+	}
+
+	var doc boxUsageDocument
+	if xml.Unmarshal(resp.Body, &doc) != nil {
+		return resp, err
+	}
+
+	var usage float64
+	for _, s := range append(doc.Errors, doc.Success) {
+		if f, parseErr := strconv.ParseFloat(s, 64); parseErr == nil {
+			usage += f
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.totals[req["Action"]] += usage
+	return resp, err
+}
+
+// Return the box usage for the given action since the last reset.
+func (c *boxUsageCounter) Total(action string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.totals[action]
+}
+
+// Forget all box usage seen so far.
+func (c *boxUsageCounter) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.totals = make(map[string]float64)
+}
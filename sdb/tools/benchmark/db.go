@@ -0,0 +1,87 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws"
+	"github.com/jacobsa/aws/sdb"
+	"github.com/jacobsa/aws/sdb/conn"
+	"github.com/jacobsa/aws/sdb/sdbtest"
+	"github.com/jacobsa/util/password"
+	"log"
+)
+
+var g_region = flag.String(
+	"region",
+	"us-east-1",
+	"The region to use, e.g. eu-west-1 or sdb.eu-west-1.amazonaws.com.")
+
+var g_keyId = flag.String(
+	"key_id",
+	"",
+	"The AWS access key ID. If unset, credentials are taken from the "+
+		"environment.")
+
+var g_fake = flag.Bool(
+	"fake",
+	false,
+	"Use an in-process fake server, for trying out the tool.")
+
+// Open a connection to the database to use for benchmarking, with the
+// supplied interceptors.
+func openDb(interceptors ...conn.Interceptor) (sdb.SimpleDB, error) {
+	if *g_fake {
+		key := aws.AccessKey{Id: "fake_id", Secret: "fake_secret"}
+		server := sdbtest.NewServer(key)
+		return sdb.NewSimpleDBAtEndpoint(
+			server.Endpoint(),
+			key,
+			interceptors...)
+	}
+
+	region, err := sdb.ParseRegion(*g_region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read in the access key, or find one in the environment.
+	var creds aws.CredentialsProvider = aws.DefaultCredentials()
+	if *g_keyId != "" {
+		prompt := fmt.Sprintf(
+			"Enter secret for AWS access key %s: ",
+			*g_keyId,
+		)
+
+		creds = aws.AccessKey{
+			Id:     *g_keyId,
+			Secret: password.ReadPassword(prompt),
+		}
+	}
+
+	return sdb.NewSimpleDB(region, creds, interceptors...)
+}
+
+// Open the database, exiting on failure.
+func getDb(interceptors ...conn.Interceptor) sdb.SimpleDB {
+	db, err := openDb(interceptors...)
+	if err != nil {
+		log.Fatalf("openDb: %v", err)
+	}
+
+	return db
+}
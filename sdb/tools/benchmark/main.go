@@ -0,0 +1,470 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Measure the latency, throughput and box usage of SimpleDB operations at
+// various item sizes, domain sizes and levels of parallelism, reporting the
+// results in text, JSON or CSV form so that runs may be compared. For
+// example:
+//
+//     benchmark -region eu-west-1 -ops put,get,get_consistent \
+//         -item_sizes 100,1KiB -domain_sizes 100,10000 -parallelism 1,8 \
+//         -format csv -output results.csv
+//
+// A domain is created for each item size, named after -domain, and filled
+// with items of that size; the domains are deleted when the run finishes
+// unless -cleanup=false. Progress is logged to stderr. Use -fake to try the
+// tool against an in-process fake server.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jacobsa/aws/bench"
+	"github.com/jacobsa/aws/sdb"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var g_ops = flag.String(
+	"ops",
+	"put,batch_put,get,get_consistent,select",
+	"Comma-separated operations to measure: put, batch_put, get, "+
+		"get_consistent and select.")
+
+var g_itemSizes = flag.String(
+	"item_sizes",
+	"100,1KiB,8KiB",
+	"Comma-separated total sizes of the attribute values of each item.")
+
+var g_domainSizes = flag.String(
+	"domain_sizes",
+	"100,1000",
+	"Comma-separated numbers of items in the domain.")
+
+var g_parallelism = flag.String(
+	"parallelism",
+	"1,4",
+	"Comma-separated numbers of concurrent requests.")
+
+var g_duration = flag.Duration(
+	"duration",
+	5*time.Second,
+	"How long to measure each combination of operation and sizes.")
+
+var g_batchSize = flag.Int(
+	"batch_size",
+	25,
+	"The number of items written by each batch_put, at most 25.")
+
+var g_selectQuery = flag.String(
+	"select_query",
+	"select count(*) from `%s`",
+	"The query used by select, with %s standing for the domain name.")
+
+var g_domain = flag.String(
+	"domain",
+	"benchmark",
+	"A prefix for the names of the domains used.")
+
+var g_cleanup = flag.Bool(
+	"cleanup",
+	true,
+	"Delete the domains used once finished.")
+
+var g_format = flag.String(
+	"format",
+	"text",
+	"The format in which to report results: text, json or csv.")
+
+var g_output = flag.String(
+	"output",
+	"",
+	"A file to which results are written. The default is stdout.")
+
+////////////////////////////////////////////////////////////////////////
+// Items
+////////////////////////////////////////////////////////////////////////
+
+// The largest attribute value SimpleDB accepts.
+const maxValueSize = 1024
+
+// SimpleDB's limit on the size of a BatchPutAttributes request, with some room
+// left for the request's other parameters.
+const maxBatchBytes = 512 * 1024
+
+// Return the name of the i'th item in a domain.
+func itemName(i int) sdb.ItemName {
+	return sdb.ItemName(fmt.Sprintf("item_%08d", i))
+}
+
+// Return updates that give an item attributes whose values total the given
+// size, split into attributes of at most maxValueSize bytes.
+func makeUpdates(size int64) (updates []sdb.PutUpdate) {
+	for i := 0; size > 0; i++ {
+		n := size
+		if n > maxValueSize {
+			n = maxValueSize
+		}
+
+		updates = append(
+			updates,
+			sdb.PutUpdate{
+				Name:  fmt.Sprintf("a%d", i),
+				Value: strings.Repeat(string('a'+rune(rand.Intn(26))), int(n)),
+			})
+
+		size -= n
+	}
+
+	return
+}
+
+// Return the number of items to write in each BatchPutAttributes request, so
+// that no request is too large.
+func batchSize(itemSize int64) int {
+	n := *g_batchSize
+	if int64(n)*itemSize > maxBatchBytes {
+		n = int(maxBatchBytes / itemSize)
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	return n
+}
+
+// Return a batch of n distinct items chosen at random from the first
+// numItems items of a domain, all with the supplied updates.
+func randomBatch(
+	numItems int,
+	n int,
+	updates []sdb.PutUpdate) sdb.BatchPutMap {
+	if n > numItems {
+		n = numItems
+	}
+
+	batch := make(sdb.BatchPutMap)
+	start := rand.Intn(numItems)
+	for i := 0; i < n; i++ {
+		batch[itemName((start+i)%numItems)] = updates
+	}
+
+	return batch
+}
+
+// Add items to the domain until it contains numItems of them, given that it
+// currently contains the first existing.
+func fillDomain(
+	domain sdb.Domain,
+	existing int,
+	numItems int,
+	updates []sdb.PutUpdate,
+	itemSize int64) error {
+	n := batchSize(itemSize)
+	for i := existing; i < numItems; i += n {
+		batch := make(sdb.BatchPutMap)
+		for j := i; j < i+n && j < numItems; j++ {
+			batch[itemName(j)] = updates
+		}
+
+		if err := domain.BatchPutAttributes(batch); err != nil {
+			return fmt.Errorf("BatchPutAttributes: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Return the total size of the values of the supplied attributes.
+func valueBytes(attrs []sdb.Attribute) (n int64) {
+	for _, a := range attrs {
+		n += int64(len(a.Value))
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Operations
+////////////////////////////////////////////////////////////////////////
+
+// The domain against which operations are measured, and what it contains.
+type target struct {
+	db       sdb.SimpleDB
+	domain   sdb.Domain
+	numItems int
+	itemSize int64
+	updates  []sdb.PutUpdate
+}
+
+// An operation that may be measured. run returns the number of bytes of
+// attribute values written or read.
+type operation struct {
+	// The SimpleDB action used by the operation, e.g. "GetAttributes".
+	action string
+
+	// Whether reads are consistent, "true" or "false", or empty for writes.
+	consistent string
+
+	run func(t *target) (int64, error)
+}
+
+var operations = map[string]operation{
+	"put": operation{
+		"PutAttributes",
+		"",
+		func(t *target) (int64, error) {
+			item := itemName(rand.Intn(t.numItems))
+			err := t.domain.PutAttributes(item, t.updates, nil)
+			return t.itemSize, err
+		},
+	},
+
+	"batch_put": operation{
+		"BatchPutAttributes",
+		"",
+		func(t *target) (int64, error) {
+			batch := randomBatch(t.numItems, batchSize(t.itemSize), t.updates)
+			err := t.domain.BatchPutAttributes(batch)
+			return t.itemSize * int64(len(batch)), err
+		},
+	},
+
+	"get": operation{
+		"GetAttributes",
+		"false",
+		func(t *target) (int64, error) {
+			item := itemName(rand.Intn(t.numItems))
+			attrs, err := t.domain.GetAttributes(item, false, nil)
+			return valueBytes(attrs), err
+		},
+	},
+
+	"get_consistent": operation{
+		"GetAttributes",
+		"true",
+		func(t *target) (int64, error) {
+			item := itemName(rand.Intn(t.numItems))
+			attrs, err := t.domain.GetAttributes(item, true, nil)
+			return valueBytes(attrs), err
+		},
+	},
+
+	"select": operation{
+		"Select",
+		"false",
+		func(t *target) (n int64, err error) {
+			query := fmt.Sprintf(*g_selectQuery, t.domain.Name())
+			items, _, err := t.db.Select(query, false, nil)
+			for _, item := range items {
+				n += valueBytes(item.Attributes)
+			}
+
+			return
+		},
+	},
+}
+
+////////////////////////////////////////////////////////////////////////
+// Main
+////////////////////////////////////////////////////////////////////////
+
+func main() {
+	flag.Parse()
+
+	// Set up bare logging output.
+	log.SetFlags(0)
+
+	// Sanity-check flags.
+	var ops []operation
+	for _, name := range strings.Split(*g_ops, ",") {
+		op, ok := operations[strings.TrimSpace(name)]
+		if !ok {
+			log.Fatalf("Unknown operation in -ops: %q", name)
+		}
+
+		ops = append(ops, op)
+	}
+
+	itemSizes, err := bench.ParseSizes(*g_itemSizes)
+	if err != nil {
+		log.Fatalf("-item_sizes: %v", err)
+	}
+
+	for _, size := range itemSizes {
+		if size <= 0 || size > 256*maxValueSize {
+			log.Fatalf("Item sizes must be in [1, 256KiB]: %d", size)
+		}
+	}
+
+	domainSizes, err := bench.ParseInts(*g_domainSizes)
+	if err != nil {
+		log.Fatalf("-domain_sizes: %v", err)
+	}
+
+	for _, size := range domainSizes {
+		if size <= 0 {
+			log.Fatalln("Domain sizes must be positive.")
+		}
+	}
+
+	// Domains are filled incrementally, so work through them in order of
+	// size.
+	sort.Ints(domainSizes)
+
+	parallelisms, err := bench.ParseInts(*g_parallelism)
+	if err != nil {
+		log.Fatalf("-parallelism: %v", err)
+	}
+
+	if *g_batchSize < 1 || *g_batchSize > 25 {
+		log.Fatalln("-batch_size must be in [1, 25].")
+	}
+
+	switch *g_format {
+	case "text", "json", "csv":
+	default:
+		log.Fatalf("Unknown -format: %q", *g_format)
+	}
+
+	// Open the output before spending time on measurements.
+	output := os.Stdout
+	if *g_output != "" {
+		if output, err = os.Create(*g_output); err != nil {
+			log.Fatalf("os.Create: %v", err)
+		}
+	}
+
+	// Open the database, keeping track of box usage.
+	boxUsage := newBoxUsageCounter()
+	db := getDb(boxUsage.Interceptor())
+
+	// Measure each combination.
+	var results []bench.Result
+	for _, itemSize := range itemSizes {
+		name := fmt.Sprintf("%s_%d", *g_domain, itemSize)
+		domain, err := db.OpenDomain(name)
+		if err != nil {
+			log.Fatalf("OpenDomain: %v", err)
+		}
+
+		t := &target{
+			db:       db,
+			domain:   domain,
+			itemSize: itemSize,
+			updates:  makeUpdates(itemSize),
+		}
+
+		for _, domainSize := range domainSizes {
+			log.Printf(
+				"Filling %s with %d items of %s.",
+				name,
+				domainSize,
+				bench.FormatBytes(uint64(itemSize)))
+
+			err := fillDomain(domain, t.numItems, domainSize, t.updates, itemSize)
+			if err != nil {
+				log.Fatalf("fillDomain: %v", err)
+			}
+
+			t.numItems = domainSize
+
+			for _, op := range ops {
+				for _, parallelism := range parallelisms {
+					results = append(results, measure(t, op, parallelism, boxUsage))
+				}
+			}
+		}
+
+		if *g_cleanup {
+			if err := db.DeleteDomain(domain); err != nil {
+				log.Fatalf("DeleteDomain: %v", err)
+			}
+		}
+	}
+
+	// Report the results.
+	if err := bench.WriteResults(output, *g_format, results); err != nil {
+		log.Fatalf("WriteResults: %v", err)
+	}
+
+	if err := output.Close(); err != nil {
+		log.Fatalf("Close: %v", err)
+	}
+}
+
+// Measure a single operation against the target at the given parallelism.
+func measure(
+	t *target,
+	op operation,
+	parallelism int,
+	boxUsage *boxUsageCounter) bench.Result {
+	params := []bench.Param{
+		bench.Param{Name: "item_size", Value: strconv.FormatInt(t.itemSize, 10)},
+		bench.Param{Name: "domain_size", Value: strconv.Itoa(t.numItems)},
+		bench.Param{Name: "parallelism", Value: strconv.Itoa(parallelism)},
+	}
+
+	if op.consistent != "" {
+		params = append(
+			params,
+			bench.Param{Name: "consistent", Value: op.consistent})
+	}
+
+	desc := op.action
+	for _, p := range params {
+		desc += fmt.Sprintf(" %s=%s", p.Name, p.Value)
+	}
+
+	log.Printf("Measuring %s.", desc)
+
+	boxUsage.Reset()
+	result, err := bench.Run(
+		parallelism,
+		*g_duration,
+		func() (int64, error) { return op.run(t) })
+
+	if err != nil {
+		log.Printf(
+			"%d of %d %s requests failed; first error: %v",
+			result.Errors,
+			result.Ops,
+			op.action,
+			err)
+	}
+
+	result.Name = op.action
+	result.Params = params
+
+	total := boxUsage.Total(op.action)
+	result.Stats = []bench.Stat{bench.Stat{Name: "box_usage", Value: total}}
+	if result.Ops > 0 {
+		result.Stats = append(
+			result.Stats,
+			bench.Stat{
+				Name:  "box_usage_per_op",
+				Value: total / float64(result.Ops),
+			})
+	}
+
+	return result
+}
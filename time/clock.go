@@ -13,7 +13,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package time is an implementation detail. You should not use it directly.
+// Package time is mostly an implementation detail, abstracting over the
+// passage of time so that code depending on it can be tested
+// deterministically. Tests may use SimulatedClock in place of RealClock.
 package time
 
 import (
@@ -23,6 +25,32 @@ import (
 type Clock interface {
 	// Return the current local time.
 	Now() time.Time
+
+	// Block until the supplied duration has passed.
+	Sleep(d time.Duration)
+
+	// Return a channel that receives the current time once the supplied
+	// duration has passed.
+	After(d time.Duration) <-chan time.Time
+
+	// Return a timer that fires once the supplied duration has passed.
+	NewTimer(d time.Duration) Timer
+}
+
+// A single event in the future, like time.Timer.
+type Timer interface {
+	// Return the channel on which the current time is delivered when the timer
+	// fires.
+	C() <-chan time.Time
+
+	// Prevent the timer from firing, returning false if it had already fired
+	// or been stopped.
+	Stop() bool
+
+	// Change the timer to fire once the supplied duration has passed from now,
+	// returning true if it had been active. As with time.Timer, a value from an
+	// earlier firing that has not been received is left in the channel.
+	Reset(d time.Duration) bool
 }
 
 // Return a clock that uses the real time.
@@ -35,3 +63,31 @@ type realClock struct{}
 func (c *realClock) Now() time.Time {
 	return time.Now()
 }
+
+func (c *realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (c *realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c *realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
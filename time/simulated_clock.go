@@ -0,0 +1,208 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package time
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SimulatedClock is a Clock for use in tests, whose time changes only when
+// SetTime or AdvanceTime is called. Sleeps, channels returned by After, and
+// timers fire when the time is moved to or past their deadlines. It is safe
+// for concurrent access.
+//
+// A test that needs another goroutine to be waiting on the clock before
+// advancing it can use WaitForTimers:
+//
+//     go func() { doSomethingThatSleeps(clock) }()
+//     clock.WaitForTimers(1)
+//     clock.AdvanceTime(time.Minute)
+//
+type SimulatedClock struct {
+	mutex sync.Mutex
+
+	// Signalled whenever the set of pending timers changes.
+	changed *sync.Cond
+
+	now     time.Time         // Protected by mutex
+	pending []*simulatedTimer // Protected by mutex
+}
+
+// NewSimulatedClock returns a clock whose time starts at the supplied value.
+func NewSimulatedClock(now time.Time) *SimulatedClock {
+	c := &SimulatedClock{now: now}
+	c.changed = sync.NewCond(&c.mutex)
+	return c
+}
+
+func (c *SimulatedClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// SetTime sets the clock's time, firing any timers whose deadlines are no
+// later than the new time, in order of deadline. The time may be moved
+// backward, in which case nothing fires.
+func (c *SimulatedClock) SetTime(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = t
+
+	// Find the timers that are due.
+	var due []*simulatedTimer
+	var remaining []*simulatedTimer
+	for _, timer := range c.pending {
+		if timer.deadline.After(t) {
+			remaining = append(remaining, timer)
+		} else {
+			due = append(due, timer)
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	c.pending = remaining
+	c.changed.Broadcast()
+
+	sort.Stable(timersByDeadline(due))
+	for _, timer := range due {
+		timer.fire(t)
+	}
+}
+
+// AdvanceTime moves the clock's time forward by the supplied duration, as
+// with SetTime.
+func (c *SimulatedClock) AdvanceTime(d time.Duration) {
+	c.SetTime(c.Now().Add(d))
+}
+
+// WaitForTimers blocks until at least n timers are pending, including those
+// underlying calls to Sleep and After.
+func (c *SimulatedClock) WaitForTimers(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for len(c.pending) < n {
+		c.changed.Wait()
+	}
+}
+
+func (c *SimulatedClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *SimulatedClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *SimulatedClock) NewTimer(d time.Duration) Timer {
+	t := &simulatedTimer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.schedule(t, d)
+	return t
+}
+
+// Arrange for the timer to fire once the supplied duration has passed, firing
+// it immediately if the duration isn't positive.
+//
+// REQUIRES: c.mutex is held
+// REQUIRES: t is not pending
+func (c *SimulatedClock) schedule(t *simulatedTimer, d time.Duration) {
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		t.fire(c.now)
+		return
+	}
+
+	c.pending = append(c.pending, t)
+	c.changed.Broadcast()
+}
+
+// Remove the timer from the pending set, returning false if it wasn't there.
+//
+// REQUIRES: c.mutex is held
+func (c *SimulatedClock) unschedule(t *simulatedTimer) bool {
+	for i, other := range c.pending {
+		if other == t {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			c.changed.Broadcast()
+			return true
+		}
+	}
+
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////
+// Timers
+////////////////////////////////////////////////////////////////////////
+
+type simulatedTimer struct {
+	clock    *SimulatedClock
+	c        chan time.Time
+	deadline time.Time // Protected by clock.mutex
+}
+
+// Deliver the supplied time, dropping it if an earlier one hasn't been
+// received, as time.Timer does.
+func (t *simulatedTimer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *simulatedTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *simulatedTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	return t.clock.unschedule(t)
+}
+
+func (t *simulatedTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	active := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return active
+}
+
+type timersByDeadline []*simulatedTimer
+
+func (s timersByDeadline) Len() int      { return len(s) }
+func (s timersByDeadline) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s timersByDeadline) Less(i, j int) bool {
+	return s[i].deadline.Before(s[j].deadline)
+}
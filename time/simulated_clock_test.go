@@ -0,0 +1,197 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package time
+
+import (
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestSimulatedClock(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type SimulatedClockTest struct {
+	start time.Time
+	clock *SimulatedClock
+}
+
+func init() { RegisterTestSuite(&SimulatedClockTest{}) }
+
+func (t *SimulatedClockTest) SetUp(i *TestInfo) {
+	t.start = time.Date(2012, time.August, 15, 22, 56, 0, 0, time.UTC)
+	t.clock = NewSimulatedClock(t.start)
+}
+
+// Return the value waiting in the channel, or the zero time if there is none.
+func received(c <-chan time.Time) time.Time {
+	select {
+	case now := <-c:
+		return now
+	default:
+		return time.Time{}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *SimulatedClockTest) InitialTime() {
+	ExpectTrue(t.clock.Now().Equal(t.start))
+}
+
+func (t *SimulatedClockTest) SetAndAdvanceTime() {
+	later := t.start.Add(time.Hour)
+
+	t.clock.SetTime(later)
+	ExpectTrue(t.clock.Now().Equal(later))
+
+	t.clock.AdvanceTime(time.Second)
+	ExpectTrue(t.clock.Now().Equal(later.Add(time.Second)))
+
+	t.clock.SetTime(t.start)
+	ExpectTrue(t.clock.Now().Equal(t.start))
+}
+
+func (t *SimulatedClockTest) TimerFiresAtDeadline() {
+	timer := t.clock.NewTimer(time.Minute)
+
+	t.clock.AdvanceTime(59 * time.Second)
+	ExpectTrue(received(timer.C()).IsZero())
+
+	t.clock.AdvanceTime(time.Second)
+	ExpectTrue(received(timer.C()).Equal(t.start.Add(time.Minute)))
+}
+
+func (t *SimulatedClockTest) TimerReceivesTimeAtFiring() {
+	timer := t.clock.NewTimer(time.Minute)
+
+	t.clock.AdvanceTime(time.Hour)
+	ExpectTrue(received(timer.C()).Equal(t.start.Add(time.Hour)))
+}
+
+func (t *SimulatedClockTest) TimerFiresOnlyOnce() {
+	timer := t.clock.NewTimer(time.Minute)
+
+	t.clock.AdvanceTime(time.Minute)
+	received(timer.C())
+
+	t.clock.AdvanceTime(time.Hour)
+	ExpectTrue(received(timer.C()).IsZero())
+}
+
+func (t *SimulatedClockTest) NonPositiveDurationFiresImmediately() {
+	ExpectTrue(received(t.clock.After(0)).Equal(t.start))
+	ExpectTrue(received(t.clock.After(-time.Second)).Equal(t.start))
+}
+
+func (t *SimulatedClockTest) StopPendingTimer() {
+	timer := t.clock.NewTimer(time.Minute)
+
+	ExpectTrue(timer.Stop())
+	ExpectFalse(timer.Stop())
+
+	t.clock.AdvanceTime(time.Hour)
+	ExpectTrue(received(timer.C()).IsZero())
+}
+
+func (t *SimulatedClockTest) StopFiredTimer() {
+	timer := t.clock.NewTimer(time.Minute)
+	t.clock.AdvanceTime(time.Hour)
+
+	ExpectFalse(timer.Stop())
+}
+
+func (t *SimulatedClockTest) ResetPendingTimer() {
+	timer := t.clock.NewTimer(time.Minute)
+	t.clock.AdvanceTime(30 * time.Second)
+
+	ExpectTrue(timer.Reset(time.Minute))
+
+	t.clock.AdvanceTime(59 * time.Second)
+	ExpectTrue(received(timer.C()).IsZero())
+
+	t.clock.AdvanceTime(time.Second)
+	ExpectFalse(received(timer.C()).IsZero())
+}
+
+func (t *SimulatedClockTest) ResetFiredTimer() {
+	timer := t.clock.NewTimer(time.Minute)
+	t.clock.AdvanceTime(time.Minute)
+	received(timer.C())
+
+	ExpectFalse(timer.Reset(time.Minute))
+
+	t.clock.AdvanceTime(time.Minute)
+	ExpectFalse(received(timer.C()).IsZero())
+}
+
+func (t *SimulatedClockTest) MultipleTimers() {
+	a := t.clock.After(3 * time.Second)
+	b := t.clock.After(1 * time.Second)
+	c := t.clock.After(5 * time.Second)
+
+	t.clock.AdvanceTime(4 * time.Second)
+
+	ExpectFalse(received(a).IsZero())
+	ExpectFalse(received(b).IsZero())
+	ExpectTrue(received(c).IsZero())
+}
+
+func (t *SimulatedClockTest) SleepBlocksUntilTimeAdvanced() {
+	done := make(chan bool)
+	go func() {
+		t.clock.Sleep(time.Minute)
+		done <- true
+	}()
+
+	t.clock.WaitForTimers(1)
+	t.clock.AdvanceTime(30 * time.Second)
+
+	select {
+	case <-done:
+		AddFailure("Sleep returned early.")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	t.clock.AdvanceTime(30 * time.Second)
+	<-done
+}
+
+func (t *SimulatedClockTest) WaitForTimersCountsPendingOnly() {
+	t.clock.NewTimer(time.Minute)
+	stopped := t.clock.NewTimer(time.Minute)
+	stopped.Stop()
+
+	done := make(chan bool)
+	go func() {
+		t.clock.WaitForTimers(2)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+		AddFailure("WaitForTimers returned early.")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	t.clock.NewTimer(time.Minute)
+	<-done
+}
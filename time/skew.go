@@ -0,0 +1,84 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package time
+
+import (
+	"sync"
+	"time"
+)
+
+// A clock that corrects another clock for its difference from a server's
+// clock, as learned from times reported by the server. This allows requests
+// to be timestamped acceptably even when the local clock has drifted.
+type SkewAdjustingClock interface {
+	Clock
+
+	// Record that the server's clock reads the supplied time now, for example
+	// as reported by the Date header of a response just received. Subsequent
+	// calls to Now will be offset accordingly.
+	Observe(serverTime time.Time)
+
+	// Return the amount by which the server's clock is currently believed to
+	// be ahead of the underlying clock.
+	Offset() time.Duration
+}
+
+// Return a skew-adjusting clock wrapping the supplied one. Until Observe is
+// called, it reports the same time as the wrapped clock.
+func NewSkewAdjustingClock(c Clock) SkewAdjustingClock {
+	return &skewAdjustingClock{wrapped: c}
+}
+
+type skewAdjustingClock struct {
+	wrapped Clock
+
+	mutex  sync.Mutex
+	offset time.Duration // Protected by mutex
+}
+
+func (c *skewAdjustingClock) Now() time.Time {
+	return c.wrapped.Now().Add(c.Offset())
+}
+
+func (c *skewAdjustingClock) Observe(serverTime time.Time) {
+	offset := serverTime.Sub(c.wrapped.Now())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.offset = offset
+}
+
+func (c *skewAdjustingClock) Offset() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.offset
+}
+
+// Durations are unaffected by skew, so waiting is left to the wrapped clock.
+
+func (c *skewAdjustingClock) Sleep(d time.Duration) {
+	c.wrapped.Sleep(d)
+}
+
+func (c *skewAdjustingClock) After(d time.Duration) <-chan time.Time {
+	return c.wrapped.After(d)
+}
+
+func (c *skewAdjustingClock) NewTimer(d time.Duration) Timer {
+	return c.wrapped.NewTimer(d)
+}
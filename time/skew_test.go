@@ -0,0 +1,94 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package time
+
+import (
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"testing"
+	"time"
+)
+
+func TestSkew(t *testing.T) { RunTests(t) }
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+type SkewAdjustingClockTest struct {
+	wrapped *SimulatedClock
+	clock   SkewAdjustingClock
+}
+
+func init() { RegisterTestSuite(&SkewAdjustingClockTest{}) }
+
+func (t *SkewAdjustingClockTest) SetUp(i *TestInfo) {
+	t.wrapped = NewSimulatedClock(
+		time.Date(2012, time.August, 15, 22, 56, 0, 0, time.UTC))
+
+	t.clock = NewSkewAdjustingClock(t.wrapped)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *SkewAdjustingClockTest) NoObservations() {
+	ExpectEq(0, t.clock.Offset())
+	ExpectTrue(t.clock.Now().Equal(t.wrapped.Now()))
+}
+
+func (t *SkewAdjustingClockTest) ServerAhead() {
+	t.clock.Observe(t.wrapped.Now().Add(time.Hour))
+
+	ExpectEq(time.Hour, t.clock.Offset())
+	ExpectTrue(t.clock.Now().Equal(t.wrapped.Now().Add(time.Hour)))
+}
+
+func (t *SkewAdjustingClockTest) ServerBehind() {
+	t.clock.Observe(t.wrapped.Now().Add(-20 * time.Minute))
+
+	ExpectEq(-20*time.Minute, t.clock.Offset())
+	ExpectTrue(t.clock.Now().Equal(t.wrapped.Now().Add(-20 * time.Minute)))
+}
+
+func (t *SkewAdjustingClockTest) OffsetFollowsWrappedClock() {
+	t.clock.Observe(t.wrapped.Now().Add(time.Hour))
+	t.wrapped.AdvanceTime(time.Minute)
+
+	expected := t.wrapped.Now().Add(time.Hour)
+	ExpectTrue(t.clock.Now().Equal(expected), "%v", t.clock.Now())
+}
+
+func (t *SkewAdjustingClockTest) LatestObservationWins() {
+	t.clock.Observe(t.wrapped.Now().Add(time.Hour))
+	t.clock.Observe(t.wrapped.Now().Add(time.Second))
+
+	ExpectThat(t.clock.Offset(), Equals(time.Second))
+}
+
+func (t *SkewAdjustingClockTest) TimersUseWrappedClock() {
+	t.clock.Observe(t.wrapped.Now().Add(time.Hour))
+	timer := t.clock.NewTimer(time.Minute)
+
+	t.wrapped.AdvanceTime(time.Minute)
+
+	select {
+	case <-timer.C():
+	default:
+		AddFailure("Timer didn't fire.")
+	}
+}